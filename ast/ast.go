@@ -32,6 +32,8 @@ type Expression interface {
 // this block describes the types that are used in the AST
 type Program struct {
 	TopLevelDeclarations []TopLevelDeclaration
+	// Comments holds every comment of the source in order of appearance.
+	Comments []*Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -51,14 +53,46 @@ func (p *Program) String() string {
 	return res.String()
 }
 
+// ImportDeclaration imports the module at Path, whose exported functions
+// are called with the name of the module like name.F().
+type ImportDeclaration struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Path      *StringLiteral
+	Semicolon token.Pos
+}
+
+func (id *ImportDeclaration) topLevelDeclaration() {}
+func (id *ImportDeclaration) TokenLiteral() string { return id.Literal }
+func (id *ImportDeclaration) String() string {
+	return id.Literal + " " + id.Path.String() + ";\n"
+}
+
+// Name returns the name of the imported module, the last element of its
+// path.
+func (id *ImportDeclaration) Name() string {
+	return id.Path.Value[strings.LastIndex(id.Path.Value, "/")+1:]
+}
+
+// IsExported reports whether name starts with an upper case letter, only
+// those functions can be called from other modules.
+func IsExported(name string) bool {
+	return name != "" && name[0] >= 'A' && name[0] <= 'Z'
+}
+
+// FunctionDeclaration declares a function, or a method of a struct type if
+// Receiver is not nil.
 type FunctionDeclaration struct {
 	Token   token.Token
 	Literal string
+	Pos     token.Pos
 
+	Receiver   *Parameter
 	Identifier *Identifier
-	// TODO Add parameters to functions
-	// Parameters []*Parameter
-	ReturnType *Identifier
+	Parameters []*Parameter
+	ReturnType Expression
 	Body       *BlockStatement
 }
 
@@ -68,8 +102,18 @@ func (fd *FunctionDeclaration) String() string {
 	var res strings.Builder
 
 	res.WriteString(fd.Literal + " ")
+	if fd.Receiver != nil {
+		res.WriteString("(" + fd.Receiver.String() + ") ")
+	}
 	res.WriteString(fd.Identifier.String())
-	res.WriteString("() ")
+	res.WriteString("(")
+	for i, param := range fd.Parameters {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(param.String())
+	}
+	res.WriteString(") ")
 	res.WriteString(fd.ReturnType.String())
 	res.WriteString(" ")
 	res.WriteString(fd.Body.String())
@@ -77,11 +121,192 @@ func (fd *FunctionDeclaration) String() string {
 	return res.String()
 }
 
+// Signature returns the declaration without its body the way it is written
+// in source, the return type of functions without one is left out.
+func (fd *FunctionDeclaration) Signature() string {
+	var res strings.Builder
+
+	res.WriteString("fn ")
+	if fd.Receiver != nil {
+		res.WriteString("(" + fd.Receiver.String() + ") ")
+	}
+	res.WriteString(fd.Identifier.Value + "(")
+	for i, param := range fd.Parameters {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(param.String())
+	}
+	res.WriteString(")")
+	if fd.ReturnType != nil && fd.ReturnType.String() != "void" {
+		res.WriteString(" " + fd.ReturnType.String())
+	}
+
+	return res.String()
+}
+
+// Name returns the name of the function, methods are qualified with the
+// name of their receiver type like Point.len.
+func (fd *FunctionDeclaration) Name() string {
+	if fd.Receiver != nil {
+		return fd.Receiver.Type.String() + "." + fd.Identifier.Value
+	}
+	return fd.Identifier.Value
+}
+
+// ValueDeclaration declares a variable with let or var, which are
+// interchangeable, or a constant with const, either globally or in a block.
+// Type is nil if the type is inferred from the value, Value is nil if the
+// variable starts with the zero value of its type.
+type ValueDeclaration struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Identifier *Identifier
+	Type       Expression
+	Value      Expression
+	Semicolon  token.Pos
+}
+
+func (vd *ValueDeclaration) topLevelDeclaration() {}
+func (vd *ValueDeclaration) statement()           {}
+func (vd *ValueDeclaration) TokenLiteral() string { return vd.Literal }
+func (vd *ValueDeclaration) String() string {
+	var res strings.Builder
+
+	res.WriteString(vd.Literal + " ")
+	res.WriteString(vd.Identifier.String())
+	if vd.Type != nil {
+		res.WriteString(" " + vd.Type.String())
+	}
+	if vd.Value != nil {
+		res.WriteString(" = " + vd.Value.String())
+	}
+	res.WriteString(";\n")
+
+	return res.String()
+}
+
+// IsConst reports whether the declaration declares a constant.
+func (vd *ValueDeclaration) IsConst() bool { return vd.Token == token.CONST }
+
+type Parameter struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Identifier *Identifier
+	Type       Expression
+}
+
+func (p *Parameter) TokenLiteral() string { return p.Literal }
+func (p *Parameter) String() string       { return p.Identifier.String() + " " + p.Type.String() }
+
+// StructDeclaration declares a struct type like struct Point { x int, y int }.
+type StructDeclaration struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Identifier *Identifier
+	Fields     []*Field
+	Rbrace     token.Pos
+}
+
+func (sd *StructDeclaration) topLevelDeclaration() {}
+func (sd *StructDeclaration) TokenLiteral() string { return sd.Literal }
+func (sd *StructDeclaration) String() string {
+	var res strings.Builder
+
+	res.WriteString(sd.Literal + " ")
+	res.WriteString(sd.Identifier.String())
+	res.WriteString(" {")
+	for i, field := range sd.Fields {
+		if i > 0 {
+			res.WriteString(",")
+		}
+		res.WriteString(" " + field.String())
+	}
+	res.WriteString(" }\n")
+
+	return res.String()
+}
+
+// Field is a field of a struct declaration.
+type Field struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Identifier *Identifier
+	Type       Expression
+}
+
+func (f *Field) TokenLiteral() string { return f.Literal }
+func (f *Field) String() string       { return f.Identifier.String() + " " + f.Type.String() }
+
+// EnumDeclaration declares an enum type like enum Shape { Circle(int),
+// Empty } whose values are one of its variants.
+type EnumDeclaration struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Identifier *Identifier
+	Variants   []*Variant
+	Rbrace     token.Pos
+}
+
+func (ed *EnumDeclaration) topLevelDeclaration() {}
+func (ed *EnumDeclaration) TokenLiteral() string { return ed.Literal }
+func (ed *EnumDeclaration) String() string {
+	var res strings.Builder
+
+	res.WriteString(ed.Literal + " ")
+	res.WriteString(ed.Identifier.String())
+	res.WriteString(" {")
+	for i, variant := range ed.Variants {
+		if i > 0 {
+			res.WriteString(",")
+		}
+		res.WriteString(" " + variant.String())
+	}
+	res.WriteString(" }\n")
+
+	return res.String()
+}
+
+// Variant is a variant of an enum declaration, Payload holds the types of
+// the values it carries and is empty if it carries none.
+type Variant struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Identifier *Identifier
+	Payload    []Expression
+}
+
+func (v *Variant) TokenLiteral() string { return v.Literal }
+func (v *Variant) String() string {
+	if len(v.Payload) == 0 {
+		return v.Identifier.String()
+	}
+	types := make([]string, len(v.Payload))
+	for i, typ := range v.Payload {
+		types[i] = typ.String()
+	}
+	return v.Identifier.String() + "(" + strings.Join(types, ", ") + ")"
+}
+
 type BlockStatement struct {
 	Token   token.Token
 	Literal string
+	Pos     token.Pos
 
 	Statements []Statement
+	Rbrace     token.Pos
 }
 
 func (bs *BlockStatement) statement()           {}
@@ -100,31 +325,64 @@ func (bs *BlockStatement) String() string {
 	return res.String()
 }
 
+// AssignmentStatement assigns to declared variables or elements with =, or
+// declares variables of the types of the values with :=. Several targets
+// take the results of a call like in q, r = divmod(7, 2), two targets also
+// take the value and presence of a map element like in v, ok = m[k].
 type AssignmentStatement struct {
 	Token   token.Token
 	Literal string
+	Pos     token.Pos
 
-	Identifier *Identifier
-	Value      Expression
+	Targets   []Expression
+	Value     Expression
+	Semicolon token.Pos
 }
 
 func (as *AssignmentStatement) statement()           {}
 func (as *AssignmentStatement) TokenLiteral() string { return as.Literal }
+
+// IsDefine reports whether the statement declares the variable with :=.
+func (as *AssignmentStatement) IsDefine() bool { return as.Token == token.DEFINE }
+
 func (as *AssignmentStatement) String() string {
 	var res strings.Builder
 
-	res.WriteString(as.Identifier.String())
-	res.WriteString(" = ")
+	for i, target := range as.Targets {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(target.String())
+	}
+	res.WriteString(" " + as.Token.String() + " ")
 	res.WriteString(as.Value.String())
 	res.WriteString(";\n")
 
 	return res.String()
 }
 
+// ExpressionStatement is an expression whose value is discarded, like a call
+// of a void function.
+type ExpressionStatement struct {
+	Token      token.Token
+	Literal    string
+	Pos        token.Pos
+	Expression Expression
+	Semicolon  token.Pos
+}
+
+func (es *ExpressionStatement) statement()           {}
+func (es *ExpressionStatement) TokenLiteral() string { return es.Literal }
+func (es *ExpressionStatement) String() string       { return es.Expression.String() + ";\n" }
+
+// ReturnStatement returns the values of a function, one for every result
+// or a single call with the same results.
 type ReturnStatement struct {
-	Token       token.Token
-	Literal     string
-	ReturnValue Expression
+	Token        token.Token
+	Literal      string
+	Pos          token.Pos
+	ReturnValues []Expression
+	Semicolon    token.Pos
 }
 
 func (rs *ReturnStatement) statement()           {}
@@ -132,16 +390,54 @@ func (rs *ReturnStatement) TokenLiteral() string { return rs.Literal }
 func (rs *ReturnStatement) String() string {
 	var res strings.Builder
 
-	res.WriteString(rs.Literal + " ")
-	res.WriteString(rs.ReturnValue.String())
+	res.WriteString(rs.Literal)
+	for i, value := range rs.ReturnValues {
+		if i > 0 {
+			res.WriteString(",")
+		}
+		res.WriteString(" ")
+		res.WriteString(value.String())
+	}
 	res.WriteString(";\n")
 
 	return res.String()
 }
 
+// ForStatement runs Body once for every element of Iterable, declaring
+// Value as the element and Index, if not nil, as its index.
+type ForStatement struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+
+	Index    *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statement()           {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Literal }
+func (fs *ForStatement) String() string {
+	var res strings.Builder
+
+	res.WriteString(fs.Literal + " ")
+	if fs.Index != nil {
+		res.WriteString(fs.Index.String() + ", ")
+	}
+	res.WriteString(fs.Value.String())
+	res.WriteString(" in ")
+	res.WriteString(fs.Iterable.String())
+	res.WriteString(" ")
+	res.WriteString(fs.Body.String())
+
+	return res.String()
+}
+
 type Identifier struct {
 	Token   token.Token
 	Literal string
+	Pos     token.Pos
 	Value   string
 }
 
@@ -149,9 +445,108 @@ func (i *Identifier) expression()          {}
 func (i *Identifier) TokenLiteral() string { return i.Literal }
 func (i *Identifier) String() string       { return i.Value }
 
+// SliceType is a slice type like []int, types are expressions so that they
+// can be nested.
+type SliceType struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Elem    Expression
+}
+
+func (st *SliceType) expression()          {}
+func (st *SliceType) TokenLiteral() string { return st.Literal }
+func (st *SliceType) String() string       { return "[]" + st.Elem.String() }
+
+// FuncType is a function type like fn(int, string) int, Result is nil for
+// functions without result.
+type FuncType struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Params  []Expression
+	Result  Expression
+}
+
+func (ft *FuncType) expression()          {}
+func (ft *FuncType) TokenLiteral() string { return ft.Literal }
+func (ft *FuncType) String() string {
+	var res strings.Builder
+
+	res.WriteString("fn(")
+	for i, param := range ft.Params {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(param.String())
+	}
+	res.WriteString(")")
+	if ft.Result != nil {
+		res.WriteString(" " + ft.Result.String())
+	}
+
+	return res.String()
+}
+
+// TryExpression propagates the error that is the last value of X like in
+// parse(s)?: a non-nil error is returned from the enclosing function,
+// otherwise the other values of X are the value of the expression.
+type TryExpression struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	X       Expression
+}
+
+func (te *TryExpression) expression()          {}
+func (te *TryExpression) TokenLiteral() string { return te.Literal }
+func (te *TryExpression) String() string       { return te.X.String() + "?" }
+
+// ResultList is the parenthesized result list of a function with several
+// results like (int, string).
+type ResultList struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Types   []Expression
+}
+
+func (rl *ResultList) expression()          {}
+func (rl *ResultList) TokenLiteral() string { return rl.Literal }
+func (rl *ResultList) String() string {
+	var res strings.Builder
+
+	res.WriteString("(")
+	for i, typ := range rl.Types {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(typ.String())
+	}
+	res.WriteString(")")
+
+	return res.String()
+}
+
+// MapType is a map type like map[string]int.
+type MapType struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Key     Expression
+	Value   Expression
+}
+
+func (mt *MapType) expression()          {}
+func (mt *MapType) TokenLiteral() string { return mt.Literal }
+func (mt *MapType) String() string {
+	return "map[" + mt.Key.String() + "]" + mt.Value.String()
+}
+
 type IntLiteral struct {
 	Token   token.Token
 	Literal string
+	Pos     token.Pos
 	Value   int64
 }
 
@@ -159,10 +554,146 @@ func (il *IntLiteral) expression()          {}
 func (il *IntLiteral) TokenLiteral() string { return il.Literal }
 func (il *IntLiteral) String() string       { return il.Literal }
 
-type CallExpression struct {
+type FloatLiteral struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Value   float64
+}
+
+func (fl *FloatLiteral) expression()          {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Literal }
+
+// StringLiteral is a double quoted string, Literal holds the quoted source
+// and Value the unquoted string.
+type StringLiteral struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Value   string
+}
+
+func (sl *StringLiteral) expression()          {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Literal }
+func (sl *StringLiteral) String() string       { return sl.Literal }
+
+// SliceLiteral is a slice like [1, 2, 3], the type of its elements is
+// inferred.
+type SliceLiteral struct {
 	Token    token.Token
 	Literal  string
-	Function *Identifier
+	Pos      token.Pos
+	Elements []Expression
+}
+
+func (sl *SliceLiteral) expression()          {}
+func (sl *SliceLiteral) TokenLiteral() string { return sl.Literal }
+func (sl *SliceLiteral) String() string {
+	var res strings.Builder
+
+	res.WriteString("[")
+	for i, elem := range sl.Elements {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(elem.String())
+	}
+	res.WriteString("]")
+
+	return res.String()
+}
+
+// MapLiteral is a map like {"a": 1}, Type is nil if the types of the keys
+// and values are inferred or a map type like in map[string]int{}. Its
+// position is the position of the opening brace.
+type MapLiteral struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Type    Expression
+	Pairs   []*KeyValueExpression
+}
+
+func (ml *MapLiteral) expression()          {}
+func (ml *MapLiteral) TokenLiteral() string { return ml.Literal }
+func (ml *MapLiteral) String() string {
+	var res strings.Builder
+
+	if ml.Type != nil {
+		res.WriteString(ml.Type.String())
+	}
+	res.WriteString("{")
+	for i, pair := range ml.Pairs {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(pair.String())
+	}
+	res.WriteString("}")
+
+	return res.String()
+}
+
+// KeyValueExpression is an element of a literal like "a": 1, its position
+// is the position of the colon.
+type KeyValueExpression struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Key     Expression
+	Value   Expression
+}
+
+func (kv *KeyValueExpression) expression()          {}
+func (kv *KeyValueExpression) TokenLiteral() string { return kv.Literal }
+func (kv *KeyValueExpression) String() string {
+	return kv.Key.String() + ": " + kv.Value.String()
+}
+
+// StructLiteral is a value of a struct type like Point{x: 1, y: 2}, the keys
+// of Fields are identifiers. Fields that are left out have their zero value.
+// Its position is the position of the opening brace.
+type StructLiteral struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Type    *Identifier
+	Fields  []*KeyValueExpression
+}
+
+func (sl *StructLiteral) expression()          {}
+func (sl *StructLiteral) TokenLiteral() string { return sl.Literal }
+func (sl *StructLiteral) String() string {
+	var res strings.Builder
+
+	res.WriteString(sl.Type.String() + "{")
+	for i, field := range sl.Fields {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(field.String())
+	}
+	res.WriteString("}")
+
+	return res.String()
+}
+
+// CallExpression calls a function, Module is the name of the module of
+// the function or nil if it is declared in the same module. Receiver is
+// the value a method is called on and nil for functions, the position of a
+// method call is the position of the method name. Calls of a function value
+// that is not named, like f(1)(2), have a Callee instead of a Function and
+// are positioned at the opening parenthesis.
+type CallExpression struct {
+	Token     token.Token
+	Literal   string
+	Pos       token.Pos
+	Receiver  Expression
+	Module    *Identifier
+	Function  *Identifier
+	Callee    Expression
+	Arguments []Expression
 }
 
 func (ce *CallExpression) expression()          {}
@@ -170,8 +701,323 @@ func (ce *CallExpression) TokenLiteral() string { return ce.Literal }
 func (ce *CallExpression) String() string {
 	var res strings.Builder
 
-	res.WriteString(ce.Function.String())
-	res.WriteString("()")
+	if ce.Receiver != nil {
+		res.WriteString(ce.Receiver.String() + ".")
+	}
+	if ce.Module != nil {
+		res.WriteString(ce.Module.String() + ".")
+	}
+	if ce.Callee != nil {
+		res.WriteString(ce.Callee.String())
+	} else {
+		res.WriteString(ce.Function.String())
+	}
+	res.WriteString("(")
+	for i, arg := range ce.Arguments {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(arg.String())
+	}
+	res.WriteString(")")
+
+	return res.String()
+}
+
+// FunctionLiteral is an anonymous function like fn(x int) int { return x;
+// }, which captures the variables of the enclosing functions by reference.
+// Like for declarations, ReturnType is void without position if the
+// function has no result.
+type FunctionLiteral struct {
+	Token      token.Token
+	Literal    string
+	Pos        token.Pos
+	Parameters []*Parameter
+	ReturnType Expression
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expression()          {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Literal }
+func (fl *FunctionLiteral) String() string {
+	var res strings.Builder
+
+	res.WriteString(fl.Literal + "(")
+	for i, param := range fl.Parameters {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(param.String())
+	}
+	res.WriteString(")")
+	if fl.ReturnType != nil && fl.ReturnType.String() != "void" {
+		res.WriteString(" " + fl.ReturnType.String())
+	}
+	res.WriteString(" ")
+	res.WriteString(fl.Body.String())
 
 	return res.String()
 }
+
+// IndexExpression is an element access like xs[i], its position is the
+// position of the opening bracket.
+type IndexExpression struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Left    Expression
+	Index   Expression
+}
+
+func (ie *IndexExpression) expression()          {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Literal }
+func (ie *IndexExpression) String() string {
+	return ie.Left.String() + "[" + ie.Index.String() + "]"
+}
+
+// SliceExpression is a slice of a slice like xs[low:high], Low and High are
+// nil if they are left out. Its position is the position of the opening
+// bracket.
+type SliceExpression struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Left    Expression
+	Low     Expression
+	High    Expression
+}
+
+func (se *SliceExpression) expression()          {}
+func (se *SliceExpression) TokenLiteral() string { return se.Literal }
+func (se *SliceExpression) String() string {
+	var res strings.Builder
+
+	res.WriteString(se.Left.String() + "[")
+	if se.Low != nil {
+		res.WriteString(se.Low.String())
+	}
+	res.WriteString(":")
+	if se.High != nil {
+		res.WriteString(se.High.String())
+	}
+	res.WriteString("]")
+
+	return res.String()
+}
+
+// SelectorExpression selects the field Sel of the struct X like p.x or the
+// variant Sel of the enum X like Color.Red, its position is the position of
+// the dot.
+type SelectorExpression struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	X       Expression
+	Sel     *Identifier
+}
+
+func (se *SelectorExpression) expression()          {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Literal }
+func (se *SelectorExpression) String() string       { return se.X.String() + "." + se.Sel.String() }
+
+// InfixExpression is a binary operation like a + b, its position is the
+// position of the operator.
+type InfixExpression struct {
+	Token    token.Token
+	Literal  string
+	Pos      token.Pos
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (ie *InfixExpression) expression()          {}
+func (ie *InfixExpression) TokenLiteral() string { return ie.Literal }
+func (ie *InfixExpression) String() string {
+	return "(" + ie.Left.String() + " " + ie.Operator + " " + ie.Right.String() + ")"
+}
+
+// MatchExpression evaluates the body of the first arm whose pattern matches
+// Subject, like match s { Shape.Circle(r) => r, _ => 0 }.
+type MatchExpression struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Pos
+}
+
+func (me *MatchExpression) expression()          {}
+func (me *MatchExpression) TokenLiteral() string { return me.Literal }
+func (me *MatchExpression) String() string {
+	var res strings.Builder
+
+	res.WriteString(me.Literal + " " + me.Subject.String() + " {")
+	for i, arm := range me.Arms {
+		if i > 0 {
+			res.WriteString(",")
+		}
+		res.WriteString(" " + arm.String())
+	}
+	res.WriteString(" }")
+
+	return res.String()
+}
+
+// MatchArm is an arm of a match expression. Patterns are the wildcard _, a
+// name that is bound to the matched value, an int or string literal or a
+// variant like Color.Red or Shape.Circle(r) whose payload is matched by
+// patterns in turn. Guard is nil if the arm has none. Its position is the
+// position of the arrow.
+type MatchArm struct {
+	Token   token.Token
+	Literal string
+	Pos     token.Pos
+	Pattern Expression
+	Guard   Expression
+	Body    Expression
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Literal }
+func (ma *MatchArm) String() string {
+	var res strings.Builder
+
+	res.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		res.WriteString(" if " + ma.Guard.String())
+	}
+	res.WriteString(" => " + ma.Body.String())
+
+	return res.String()
+}
+
+// Comment is a single line comment, Text includes the leading slashes.
+type Comment struct {
+	Pos  token.Pos
+	Text string
+}
+
+func (c *Comment) TokenLiteral() string { return c.Text }
+func (c *Comment) String() string       { return c.Text }
+
+// Start returns the position of the first token of node. For most nodes this
+// is the position of their token, methods start with fn, assignments with
+// their first target, composite literals with their type, method calls with
+// their receiver, match arms with their pattern and infix, index, slice,
+// selector and key value expressions with their left operand.
+func Start(node Node) token.Pos {
+	switch n := node.(type) {
+	case *Program:
+		if len(n.TopLevelDeclarations) > 0 {
+			return Start(n.TopLevelDeclarations[0])
+		}
+	case *ImportDeclaration:
+		return n.Pos
+	case *FunctionDeclaration:
+		return n.Pos
+	case *ValueDeclaration:
+		return n.Pos
+	case *Parameter:
+		return n.Pos
+	case *StructDeclaration:
+		return n.Pos
+	case *Field:
+		return n.Pos
+	case *EnumDeclaration:
+		return n.Pos
+	case *Variant:
+		return n.Pos
+	case *BlockStatement:
+		return n.Pos
+	case *AssignmentStatement:
+		if len(n.Targets) > 0 {
+			return Start(n.Targets[0])
+		}
+		return n.Pos
+	case *ExpressionStatement:
+		return n.Pos
+	case *ReturnStatement:
+		return n.Pos
+	case *ForStatement:
+		return n.Pos
+	case *Identifier:
+		return n.Pos
+	case *SliceType:
+		return n.Pos
+	case *MapType:
+		return n.Pos
+	case *IntLiteral:
+		return n.Pos
+	case *FloatLiteral:
+		return n.Pos
+	case *StringLiteral:
+		return n.Pos
+	case *SliceLiteral:
+		return n.Pos
+	case *MapLiteral:
+		if n.Type != nil {
+			return Start(n.Type)
+		}
+		return n.Pos
+	case *KeyValueExpression:
+		if n.Key != nil {
+			return Start(n.Key)
+		}
+		return n.Pos
+	case *StructLiteral:
+		if n.Type != nil {
+			return n.Type.Pos
+		}
+		return n.Pos
+	case *CallExpression:
+		if n.Receiver != nil {
+			return Start(n.Receiver)
+		}
+		if n.Callee != nil {
+			return Start(n.Callee)
+		}
+		return n.Pos
+	case *FuncType:
+		return n.Pos
+	case *ResultList:
+		return n.Pos
+	case *TryExpression:
+		if n.X != nil {
+			return Start(n.X)
+		}
+		return n.Pos
+	case *FunctionLiteral:
+		return n.Pos
+	case *IndexExpression:
+		if n.Left != nil {
+			return Start(n.Left)
+		}
+		return n.Pos
+	case *SliceExpression:
+		if n.Left != nil {
+			return Start(n.Left)
+		}
+		return n.Pos
+	case *SelectorExpression:
+		if n.X != nil {
+			return Start(n.X)
+		}
+		return n.Pos
+	case *InfixExpression:
+		if n.Left != nil {
+			return Start(n.Left)
+		}
+		return n.Pos
+	case *MatchExpression:
+		return n.Pos
+	case *MatchArm:
+		if n.Pattern != nil {
+			return Start(n.Pattern)
+		}
+		return n.Pos
+	case *Comment:
+		return n.Pos
+	}
+	return token.Pos{}
+}
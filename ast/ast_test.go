@@ -19,7 +19,7 @@ func TestIdentifier_String(t *testing.T) {
 
 func TestReturnStatement_String(t *testing.T) {
 	ident := &Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"}
-	retStmt := &ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValue: ident}
+	retStmt := &ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{ident}}
 	assert.Equal(t, "return foo;\n", retStmt.String())
 }
 
@@ -27,16 +27,19 @@ func TestAssignmentStatement_String(t *testing.T) {
 	ident := &Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"}
 	intLit := &IntLiteral{Token: token.INT, Literal: "42", Value: 42}
 	assignStmt := &AssignmentStatement{
-		Token: token.ASSIGN, Literal: "=", Identifier: ident, Value: intLit,
+		Token: token.ASSIGN, Literal: "=", Targets: []Expression{ident}, Value: intLit,
 	}
 	assert.Equal(t, "foo = 42;\n", assignStmt.String())
+
+	defineStmt := &AssignmentStatement{Token: token.DEFINE, Literal: ":=", Targets: []Expression{ident}, Value: intLit}
+	assert.Equal(t, "foo := 42;\n", defineStmt.String())
 }
 
 func TestBlockStatement_String(t *testing.T) {
 	ident := &Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"}
 	intLit := &IntLiteral{Token: token.INT, Literal: "42", Value: 42}
-	assignStmt := &AssignmentStatement{Token: token.ASSIGN, Literal: "=", Identifier: ident, Value: intLit}
-	retStmt := &ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValue: ident}
+	assignStmt := &AssignmentStatement{Token: token.ASSIGN, Literal: "=", Targets: []Expression{ident}, Value: intLit}
+	retStmt := &ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{ident}}
 	blockStmt := &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{assignStmt, retStmt}}
 	assert.Equal(t, "{\nfoo = 42;\nreturn foo;\n}\n", blockStmt.String())
 }
@@ -45,7 +48,7 @@ func TestFunctionDeclaration_String(t *testing.T) {
 	main := &Identifier{Token: token.IDENT, Literal: "main", Value: "main"}
 	resValue := &Identifier{Token: token.IDENT, Literal: "int", Value: "int"}
 	intLit := &IntLiteral{Token: token.INT, Literal: "42", Value: 42}
-	retStmt := &ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValue: intLit}
+	retStmt := &ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{intLit}}
 	blockStmt := &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{retStmt}}
 	funcDecl := &FunctionDeclaration{
 		Token: token.FN, Literal: "fn", Identifier: main, ReturnType: resValue, Body: blockStmt,
@@ -0,0 +1,130 @@
+// Package astdot renders syntax trees in the Graphviz DOT language.
+package astdot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+)
+
+// Fprint writes the tree rooted at node as a DOT graph to w. Every node is
+// labeled with its type, its value for identifiers and literals, and its
+// position.
+func Fprint(w io.Writer, node ast.Node) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph ast {")
+	fmt.Fprintln(bw, "\tnode [shape=box fontname=\"monospace\"];")
+
+	var (
+		ids   = map[ast.Node]int{}
+		stack []ast.Node
+	)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+
+		id := len(ids)
+		ids[n] = id
+		fmt.Fprintf(bw, "\tn%d [label=%s];\n", id, quote(label(n)))
+		if len(stack) > 0 {
+			fmt.Fprintf(bw, "\tn%d -> n%d;\n", ids[stack[len(stack)-1]], id)
+		}
+
+		stack = append(stack, n)
+		return true
+	})
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func label(node ast.Node) string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+
+	var pos string
+	switch n := node.(type) {
+	case *ast.Program:
+		return kind
+	case *ast.ImportDeclaration:
+		pos = n.Pos.String()
+	case *ast.FunctionDeclaration:
+		pos = n.Pos.String()
+	case *ast.ValueDeclaration:
+		pos = n.Pos.String()
+	case *ast.Parameter:
+		pos = n.Pos.String()
+	case *ast.StructDeclaration:
+		pos = n.Pos.String()
+	case *ast.Field:
+		pos = n.Pos.String()
+	case *ast.EnumDeclaration:
+		pos = n.Pos.String()
+	case *ast.Variant:
+		pos = n.Pos.String()
+	case *ast.BlockStatement:
+		pos = n.Pos.String()
+	case *ast.AssignmentStatement:
+		pos = n.Pos.String()
+	case *ast.ExpressionStatement:
+		pos = n.Pos.String()
+	case *ast.ReturnStatement:
+		pos = n.Pos.String()
+	case *ast.ForStatement:
+		pos = n.Pos.String()
+	case *ast.SliceType:
+		pos = n.Pos.String()
+	case *ast.MapType:
+		pos = n.Pos.String()
+	case *ast.FuncType:
+		pos = n.Pos.String()
+	case *ast.TryExpression:
+		pos = n.Pos.String()
+	case *ast.ResultList:
+		pos = n.Pos.String()
+	case *ast.FunctionLiteral:
+		pos = n.Pos.String()
+	case *ast.SliceLiteral:
+		pos = n.Pos.String()
+	case *ast.MapLiteral:
+		pos = n.Pos.String()
+	case *ast.KeyValueExpression:
+		pos = n.Pos.String()
+	case *ast.StructLiteral:
+		pos = n.Pos.String()
+	case *ast.CallExpression:
+		pos = n.Pos.String()
+	case *ast.IndexExpression:
+		pos = n.Pos.String()
+	case *ast.SliceExpression:
+		pos = n.Pos.String()
+	case *ast.SelectorExpression:
+		pos = n.Pos.String()
+	case *ast.MatchExpression:
+		pos = n.Pos.String()
+	case *ast.MatchArm:
+		pos = n.Pos.String()
+	case *ast.InfixExpression:
+		return fmt.Sprintf("%s %s\n%s", kind, n.Operator, n.Pos)
+	case *ast.Identifier:
+		return fmt.Sprintf("%s %s\n%s", kind, n.Value, n.Pos)
+	case *ast.IntLiteral:
+		return fmt.Sprintf("%s %s\n%s", kind, n.Literal, n.Pos)
+	case *ast.FloatLiteral:
+		return fmt.Sprintf("%s %s\n%s", kind, n.Literal, n.Pos)
+	case *ast.StringLiteral:
+		return fmt.Sprintf("%s %s\n%s", kind, n.Literal, n.Pos)
+	}
+	return kind + "\n" + pos
+}
+
+// quote returns s as a DOT string.
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
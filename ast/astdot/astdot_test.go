@@ -0,0 +1,40 @@
+package astdot
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFprint(t *testing.T) {
+	program, err := parser.NewParser(scanner.NewScanner("fn main() int {\n\treturn helper();\n}")).Parse()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Fprint(&buf, program))
+
+	expected := `digraph ast {
+	node [shape=box fontname="monospace"];
+	n0 [label="Program"];
+	n1 [label="FunctionDeclaration\n1:1"];
+	n0 -> n1;
+	n2 [label="Identifier main\n1:4"];
+	n1 -> n2;
+	n3 [label="Identifier int\n1:11"];
+	n1 -> n3;
+	n4 [label="BlockStatement\n1:15"];
+	n1 -> n4;
+	n5 [label="ReturnStatement\n2:2"];
+	n4 -> n5;
+	n6 [label="CallExpression\n2:9"];
+	n5 -> n6;
+	n7 [label="Identifier helper\n2:9"];
+	n6 -> n7;
+}
+`
+	assert.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,401 @@
+// Package astjson converts syntax trees to and from JSON.
+//
+// Every node is encoded as an object with a "kind" discriminator holding the
+// name of the node type, followed by the token, literal and position of the
+// node and its fields:
+//
+//	{"kind":"Identifier","token":"IDENT","literal":"foo","pos":{"line":1,"column":1},"value":"foo"}
+//
+// Decoding restores the exact tree that was encoded, including positions
+// and comments.
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/token"
+)
+
+// Marshal returns the JSON encoding of node.
+func Marshal(node ast.Node) ([]byte, error) {
+	return encode(node)
+}
+
+// MarshalIndent is like Marshal but applies json.Indent to the output.
+func MarshalIndent(node ast.Node, prefix, indent string) ([]byte, error) {
+	b, err := encode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a node encoded by Marshal. The dynamic type of the
+// result is the concrete node type named by the "kind" of the object.
+func Unmarshal(data []byte) (ast.Node, error) {
+	return decode(data)
+}
+
+// UnmarshalProgram decodes a program encoded by Marshal.
+func UnmarshalProgram(data []byte) (*ast.Program, error) {
+	node, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	program, ok := node.(*ast.Program)
+	if !ok {
+		return nil, fmt.Errorf("astjson: expected Program, found %s", kindOf(node))
+	}
+	return program, nil
+}
+
+type pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// header holds the fields shared by all nodes, the position is omitted for
+// nodes that were not created by the parser.
+type header struct {
+	Kind    string `json:"kind"`
+	Token   string `json:"token,omitempty"`
+	Literal string `json:"literal,omitempty"`
+	Pos     *pos   `json:"pos,omitempty"`
+}
+
+type program struct {
+	header
+	TopLevelDeclarations []json.RawMessage `json:"topLevelDeclarations"`
+	Comments             []json.RawMessage `json:"comments"`
+}
+
+type importDeclaration struct {
+	header
+	Path      json.RawMessage `json:"path"`
+	Semicolon *pos            `json:"semicolon,omitempty"`
+}
+
+type functionDeclaration struct {
+	header
+	Receiver   json.RawMessage   `json:"receiver,omitempty"`
+	Identifier json.RawMessage   `json:"identifier"`
+	Parameters []json.RawMessage `json:"parameters,omitempty"`
+	ReturnType json.RawMessage   `json:"returnType"`
+	Body       json.RawMessage   `json:"body"`
+}
+
+type valueDeclaration struct {
+	header
+	Identifier json.RawMessage `json:"identifier"`
+	Type       json.RawMessage `json:"type"`
+	Value      json.RawMessage `json:"value"`
+	Semicolon  *pos            `json:"semicolon,omitempty"`
+}
+
+type parameter struct {
+	header
+	Identifier json.RawMessage `json:"identifier"`
+	Type       json.RawMessage `json:"type"`
+}
+
+type structDeclaration struct {
+	header
+	Identifier json.RawMessage   `json:"identifier"`
+	Fields     []json.RawMessage `json:"fields,omitempty"`
+	Rbrace     *pos              `json:"rbrace,omitempty"`
+}
+
+type field struct {
+	header
+	Identifier json.RawMessage `json:"identifier"`
+	Type       json.RawMessage `json:"type"`
+}
+
+type enumDeclaration struct {
+	header
+	Identifier json.RawMessage   `json:"identifier"`
+	Variants   []json.RawMessage `json:"variants,omitempty"`
+	Rbrace     *pos              `json:"rbrace,omitempty"`
+}
+
+type variant struct {
+	header
+	Identifier json.RawMessage   `json:"identifier"`
+	Payload    []json.RawMessage `json:"payload,omitempty"`
+}
+
+type blockStatement struct {
+	header
+	Statements []json.RawMessage `json:"statements"`
+	Rbrace     *pos              `json:"rbrace,omitempty"`
+}
+
+type assignmentStatement struct {
+	header
+	Targets   []json.RawMessage `json:"targets"`
+	Value     json.RawMessage   `json:"value"`
+	Semicolon *pos              `json:"semicolon,omitempty"`
+}
+
+type expressionStatement struct {
+	header
+	Expression json.RawMessage `json:"expression"`
+	Semicolon  *pos            `json:"semicolon,omitempty"`
+}
+
+type returnStatement struct {
+	header
+	ReturnValues []json.RawMessage `json:"returnValues"`
+	Semicolon    *pos              `json:"semicolon,omitempty"`
+}
+
+type forStatement struct {
+	header
+	Index    json.RawMessage `json:"index,omitempty"`
+	Value    json.RawMessage `json:"value"`
+	Iterable json.RawMessage `json:"iterable"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type identifier struct {
+	header
+	Value string `json:"value"`
+}
+
+type sliceType struct {
+	header
+	Elem json.RawMessage `json:"elem"`
+}
+
+type mapType struct {
+	header
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type funcType struct {
+	header
+	Params []json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage   `json:"result,omitempty"`
+}
+
+type tryExpression struct {
+	header
+	X json.RawMessage `json:"x"`
+}
+
+type resultList struct {
+	header
+	Types []json.RawMessage `json:"types"`
+}
+
+type functionLiteral struct {
+	header
+	Parameters []json.RawMessage `json:"parameters,omitempty"`
+	ReturnType json.RawMessage   `json:"returnType"`
+	Body       json.RawMessage   `json:"body"`
+}
+
+type intLiteral struct {
+	header
+	Value int64 `json:"value"`
+}
+
+type floatLiteral struct {
+	header
+	Value float64 `json:"value"`
+}
+
+type stringLiteral struct {
+	header
+	Value string `json:"value"`
+}
+
+type sliceLiteral struct {
+	header
+	Elements []json.RawMessage `json:"elements,omitempty"`
+}
+
+type mapLiteral struct {
+	header
+	Type  json.RawMessage   `json:"type,omitempty"`
+	Pairs []json.RawMessage `json:"pairs,omitempty"`
+}
+
+type keyValueExpression struct {
+	header
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type structLiteral struct {
+	header
+	Type   json.RawMessage   `json:"type"`
+	Fields []json.RawMessage `json:"fields,omitempty"`
+}
+
+type callExpression struct {
+	header
+	Receiver  json.RawMessage   `json:"receiver,omitempty"`
+	Module    json.RawMessage   `json:"module,omitempty"`
+	Function  json.RawMessage   `json:"function,omitempty"`
+	Callee    json.RawMessage   `json:"callee,omitempty"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+type indexExpression struct {
+	header
+	Left  json.RawMessage `json:"left"`
+	Index json.RawMessage `json:"index"`
+}
+
+type sliceExpression struct {
+	header
+	Left json.RawMessage `json:"left"`
+	Low  json.RawMessage `json:"low"`
+	High json.RawMessage `json:"high"`
+}
+
+type selectorExpression struct {
+	header
+	X   json.RawMessage `json:"x"`
+	Sel json.RawMessage `json:"sel"`
+}
+
+type infixExpression struct {
+	header
+	Left     json.RawMessage `json:"left"`
+	Operator string          `json:"operator"`
+	Right    json.RawMessage `json:"right"`
+}
+
+type matchExpression struct {
+	header
+	Subject json.RawMessage   `json:"subject"`
+	Arms    []json.RawMessage `json:"arms,omitempty"`
+	Rbrace  *pos              `json:"rbrace,omitempty"`
+}
+
+type matchArm struct {
+	header
+	Pattern json.RawMessage `json:"pattern"`
+	Guard   json.RawMessage `json:"guard,omitempty"`
+	Body    json.RawMessage `json:"body"`
+}
+
+type comment struct {
+	header
+	Text string `json:"text"`
+}
+
+// kindOf returns the discriminator of node, which is the name of its type.
+func kindOf(node ast.Node) string {
+	switch node.(type) {
+	case *ast.Program:
+		return "Program"
+	case *ast.ImportDeclaration:
+		return "ImportDeclaration"
+	case *ast.FunctionDeclaration:
+		return "FunctionDeclaration"
+	case *ast.ValueDeclaration:
+		return "ValueDeclaration"
+	case *ast.Parameter:
+		return "Parameter"
+	case *ast.StructDeclaration:
+		return "StructDeclaration"
+	case *ast.Field:
+		return "Field"
+	case *ast.EnumDeclaration:
+		return "EnumDeclaration"
+	case *ast.Variant:
+		return "Variant"
+	case *ast.BlockStatement:
+		return "BlockStatement"
+	case *ast.AssignmentStatement:
+		return "AssignmentStatement"
+	case *ast.ExpressionStatement:
+		return "ExpressionStatement"
+	case *ast.ReturnStatement:
+		return "ReturnStatement"
+	case *ast.ForStatement:
+		return "ForStatement"
+	case *ast.Identifier:
+		return "Identifier"
+	case *ast.SliceType:
+		return "SliceType"
+	case *ast.MapType:
+		return "MapType"
+	case *ast.FuncType:
+		return "FuncType"
+	case *ast.ResultList:
+		return "ResultList"
+	case *ast.TryExpression:
+		return "TryExpression"
+	case *ast.FunctionLiteral:
+		return "FunctionLiteral"
+	case *ast.IntLiteral:
+		return "IntLiteral"
+	case *ast.FloatLiteral:
+		return "FloatLiteral"
+	case *ast.StringLiteral:
+		return "StringLiteral"
+	case *ast.SliceLiteral:
+		return "SliceLiteral"
+	case *ast.MapLiteral:
+		return "MapLiteral"
+	case *ast.KeyValueExpression:
+		return "KeyValueExpression"
+	case *ast.StructLiteral:
+		return "StructLiteral"
+	case *ast.CallExpression:
+		return "CallExpression"
+	case *ast.IndexExpression:
+		return "IndexExpression"
+	case *ast.SliceExpression:
+		return "SliceExpression"
+	case *ast.SelectorExpression:
+		return "SelectorExpression"
+	case *ast.InfixExpression:
+		return "InfixExpression"
+	case *ast.MatchExpression:
+		return "MatchExpression"
+	case *ast.MatchArm:
+		return "MatchArm"
+	case *ast.Comment:
+		return "Comment"
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func encodePos(p token.Pos) *pos {
+	if !p.IsValid() {
+		return nil
+	}
+	return &pos{Line: p.Line, Column: p.Column}
+}
+
+func decodePos(p *pos) token.Pos {
+	if p == nil {
+		return token.Pos{}
+	}
+	return token.Pos{Line: p.Line, Column: p.Column}
+}
+
+func decodeToken(s string) (token.Token, error) {
+	if s == "" {
+		return token.ILLEGAL, nil
+	}
+	tok, ok := token.FromString(s)
+	if !ok {
+		return tok, fmt.Errorf("astjson: unknown token %q", s)
+	}
+	return tok, nil
+}
@@ -0,0 +1,94 @@
+package astjson
+
+import (
+	"testing"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/examples"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	ident := &ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 2, Column: 8}, Value: "foo"}
+	retStmt := &ast.ReturnStatement{Token: token.RETURN, Literal: "return", Pos: token.Pos{Line: 2, Column: 1}, ReturnValues: []ast.Expression{ident}}
+
+	res, err := Marshal(retStmt)
+	require.NoError(t, err)
+	expected := `{"kind":"ReturnStatement","token":"return","literal":"return","pos":{"line":2,"column":1},` +
+		`"returnValues":[{"kind":"Identifier","token":"IDENT","literal":"foo","pos":{"line":2,"column":8},"value":"foo"}]}`
+	assert.Equal(t, expected, string(res))
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("round_trips_programs", func(t *testing.T) {
+		for name, source := range map[string]string{
+			"function.em":        examples.Function,
+			"main_and_helper.em": examples.MainAndHelper,
+			"comments":           "// doc\nfn main() int { // trailing\n\treturn 1;\n}\n",
+			"parameters":         "fn second(a int, b int) int {\n\treturn b;\n}\n\nfn main() int {\n\treturn second(1, 2);\n}\n",
+			"operators":          "fn main() int {\n\treturn (1 + 2) * 3 / 4 - 5;\n}\n",
+			"strings":            "fn main() {\n\tprintln(\"a\\n\" + \"b\");\n}\n",
+			"globals":            "let a int = 1;\nvar s string;\nconst c = a + 2;\n",
+			"locals":             "fn main() {\n\tlet a int = 1;\n\tb := a;\n\tb = 2;\n}\n",
+			"slices":             "fn f(xs []int) [][]int {\n\tfor i, x in xs[1:] {\n\t\tprintln(i, x, xs[:i]);\n\t}\n\treturn [xs, []];\n}\n",
+			"maps":               "fn main() {\n\tm := {\"a\": 1};\n\tvar e map[int][]string = map[int][]string{};\n\tv, ok := m[\"a\"];\n}\n",
+			"structs":            "struct P {\n\tx int,\n}\n\nfn (p P) f() int {\n\tp.x = P{x: 1}.f();\n\treturn p.x;\n}\n",
+			"enums":              "enum Shape {\n\tCircle(int),\n\tEmpty,\n}\n\nfn f(s Shape) int {\n\treturn match s {\n\t\tShape.Circle(r) if r => r,\n\t\t_ => 0,\n\t};\n}\n",
+			"functions":          "fn adder(n int) fn(int) int {\n\treturn fn(x int) int {\n\t\treturn x + n;\n\t};\n}\n\nfn main() {\n\tadder(1)(2);\n}\n",
+			"multiple_results":   "fn divmod(a int, b int) (int, int) {\n\treturn a / b, a - a / b * b;\n}\n\nfn main() {\n\tq, r := divmod(7, 2);\n}\n",
+			"errors":             "fn load(s string) (int, error) {\n\tn := parse(s)?;\n\treturn n, error(\"x\");\n}\n",
+			"floats":             "fn main() float {\n\treturn 1.5e3 * float(0x1F);\n}\n",
+			"imports":            "import \"a/b\";\n\nfn main() {\n\tb.F(b.G());\n}\n",
+		} {
+			t.Run(name, func(t *testing.T) {
+				program, err := parser.NewParser(scanner.NewScanner(source)).Parse()
+				require.NoError(t, err)
+
+				b, err := MarshalIndent(program, "", "  ")
+				require.NoError(t, err)
+				res, err := UnmarshalProgram(b)
+				require.NoError(t, err)
+				assert.Equal(t, program, res)
+			})
+		}
+	})
+
+	t.Run("round_trips_nodes_without_positions_and_nil_children", func(t *testing.T) {
+		node := &ast.AssignmentStatement{Token: token.ASSIGN, Literal: "=", Targets: []ast.Expression{&ast.Identifier{Token: token.IDENT, Value: "x"}}}
+		b, err := Marshal(node)
+		require.NoError(t, err)
+		res, err := Unmarshal(b)
+		require.NoError(t, err)
+		assert.Equal(t, node, res)
+	})
+
+	t.Run("decodes_into_the_interfaces_of_the_fields", func(t *testing.T) {
+		res, err := Unmarshal([]byte(`{"kind":"BlockStatement","statements":[{"kind":"ReturnStatement","returnValues":[{"kind":"IntLiteral","value":1}]}]}`))
+		require.NoError(t, err)
+		block := res.(*ast.BlockStatement)
+		assert.IsType(t, &ast.ReturnStatement{}, block.Statements[0])
+		assert.IsType(t, &ast.IntLiteral{}, block.Statements[0].(*ast.ReturnStatement).ReturnValues[0])
+	})
+
+	t.Run("returns_errors", func(t *testing.T) {
+		tests := map[string]string{
+			"invalid_json":           `{"kind":`,
+			"unknown_kind":           `{"kind":"WhileStatement"}`,
+			"unknown_token":          `{"kind":"Identifier","token":"WHILE"}`,
+			"node_of_the_wrong_kind": `{"kind":"BlockStatement","statements":[{"kind":"IntLiteral","value":1}]}`,
+		}
+		for name, data := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := Unmarshal([]byte(data))
+				assert.Error(t, err)
+			})
+		}
+
+		_, err := UnmarshalProgram([]byte(`{"kind":"Identifier","value":"x"}`))
+		assert.EqualError(t, err, "astjson: expected Program, found Identifier")
+	})
+}
@@ -0,0 +1,435 @@
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/token"
+)
+
+// decoder decodes nodes and keeps the first error that occurred, so that
+// decoding a node with several children does not need an error check after
+// every child.
+type decoder struct {
+	err error
+}
+
+func decode(data []byte) (ast.Node, error) {
+	d := &decoder{}
+	node := d.node(data)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+func (d *decoder) node(data json.RawMessage) ast.Node {
+	if d.err != nil || len(data) == 0 || bytes.Equal(data, null) {
+		return nil
+	}
+
+	var h header
+	if !d.unmarshal(data, &h) {
+		return nil
+	}
+
+	switch h.Kind {
+	case "Program":
+		var v program
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.Program{}
+		n.TopLevelDeclarations = decodeList[ast.TopLevelDeclaration](d, v.TopLevelDeclarations, "top level declaration")
+		n.Comments = decodeList[*ast.Comment](d, v.Comments, "comment")
+		return n
+
+	case "ImportDeclaration":
+		var v importDeclaration
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.ImportDeclaration{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Semicolon: decodePos(v.Semicolon)}
+		n.Path = decodeAs[*ast.StringLiteral](d, v.Path, "string literal")
+		return n
+
+	case "FunctionDeclaration":
+		var v functionDeclaration
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.FunctionDeclaration{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Receiver = decodeAs[*ast.Parameter](d, v.Receiver, "parameter")
+		n.Identifier = decodeAs[*ast.Identifier](d, v.Identifier, "identifier")
+		n.Parameters = decodeList[*ast.Parameter](d, v.Parameters, "parameter")
+		n.ReturnType = decodeAs[ast.Expression](d, v.ReturnType, "expression")
+		n.Body = decodeAs[*ast.BlockStatement](d, v.Body, "block statement")
+		return n
+
+	case "ValueDeclaration":
+		var v valueDeclaration
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.ValueDeclaration{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Semicolon: decodePos(v.Semicolon)}
+		n.Identifier = decodeAs[*ast.Identifier](d, v.Identifier, "identifier")
+		n.Type = decodeAs[ast.Expression](d, v.Type, "expression")
+		n.Value = decodeAs[ast.Expression](d, v.Value, "expression")
+		return n
+
+	case "Parameter":
+		var v parameter
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.Parameter{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Identifier = decodeAs[*ast.Identifier](d, v.Identifier, "identifier")
+		n.Type = decodeAs[ast.Expression](d, v.Type, "expression")
+		return n
+
+	case "StructDeclaration":
+		var v structDeclaration
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.StructDeclaration{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Rbrace: decodePos(v.Rbrace)}
+		n.Identifier = decodeAs[*ast.Identifier](d, v.Identifier, "identifier")
+		n.Fields = decodeList[*ast.Field](d, v.Fields, "field")
+		return n
+
+	case "Field":
+		var v field
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.Field{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Identifier = decodeAs[*ast.Identifier](d, v.Identifier, "identifier")
+		n.Type = decodeAs[ast.Expression](d, v.Type, "expression")
+		return n
+
+	case "EnumDeclaration":
+		var v enumDeclaration
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.EnumDeclaration{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Rbrace: decodePos(v.Rbrace)}
+		n.Identifier = decodeAs[*ast.Identifier](d, v.Identifier, "identifier")
+		n.Variants = decodeList[*ast.Variant](d, v.Variants, "variant")
+		return n
+
+	case "Variant":
+		var v variant
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.Variant{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Identifier = decodeAs[*ast.Identifier](d, v.Identifier, "identifier")
+		n.Payload = decodeList[ast.Expression](d, v.Payload, "expression")
+		return n
+
+	case "BlockStatement":
+		var v blockStatement
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.BlockStatement{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Rbrace: decodePos(v.Rbrace)}
+		n.Statements = decodeList[ast.Statement](d, v.Statements, "statement")
+		return n
+
+	case "AssignmentStatement":
+		var v assignmentStatement
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.AssignmentStatement{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Semicolon: decodePos(v.Semicolon)}
+		n.Targets = decodeList[ast.Expression](d, v.Targets, "expression")
+		n.Value = decodeAs[ast.Expression](d, v.Value, "expression")
+		return n
+
+	case "ExpressionStatement":
+		var v expressionStatement
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.ExpressionStatement{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Semicolon: decodePos(v.Semicolon)}
+		n.Expression = decodeAs[ast.Expression](d, v.Expression, "expression")
+		return n
+
+	case "ReturnStatement":
+		var v returnStatement
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.ReturnStatement{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Semicolon: decodePos(v.Semicolon)}
+		n.ReturnValues = decodeList[ast.Expression](d, v.ReturnValues, "expression")
+		return n
+
+	case "ForStatement":
+		var v forStatement
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.ForStatement{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Index = decodeAs[*ast.Identifier](d, v.Index, "identifier")
+		n.Value = decodeAs[*ast.Identifier](d, v.Value, "identifier")
+		n.Iterable = decodeAs[ast.Expression](d, v.Iterable, "expression")
+		n.Body = decodeAs[*ast.BlockStatement](d, v.Body, "block statement")
+		return n
+
+	case "Identifier":
+		var v identifier
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		return &ast.Identifier{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Value: v.Value}
+
+	case "SliceType":
+		var v sliceType
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.SliceType{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Elem = decodeAs[ast.Expression](d, v.Elem, "expression")
+		return n
+
+	case "MapType":
+		var v mapType
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.MapType{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Key = decodeAs[ast.Expression](d, v.Key, "expression")
+		n.Value = decodeAs[ast.Expression](d, v.Value, "expression")
+		return n
+
+	case "FuncType":
+		var v funcType
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.FuncType{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Params = decodeList[ast.Expression](d, v.Params, "expression")
+		n.Result = decodeAs[ast.Expression](d, v.Result, "expression")
+		return n
+
+	case "TryExpression":
+		var v tryExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.TryExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.X = decodeAs[ast.Expression](d, v.X, "expression")
+		return n
+
+	case "ResultList":
+		var v resultList
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.ResultList{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Types = decodeList[ast.Expression](d, v.Types, "expression")
+		return n
+
+	case "FunctionLiteral":
+		var v functionLiteral
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.FunctionLiteral{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Parameters = decodeList[*ast.Parameter](d, v.Parameters, "parameter")
+		n.ReturnType = decodeAs[ast.Expression](d, v.ReturnType, "expression")
+		n.Body = decodeAs[*ast.BlockStatement](d, v.Body, "block statement")
+		return n
+
+	case "IntLiteral":
+		var v intLiteral
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		return &ast.IntLiteral{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Value: v.Value}
+
+	case "FloatLiteral":
+		var v floatLiteral
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		return &ast.FloatLiteral{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Value: v.Value}
+
+	case "StringLiteral":
+		var v stringLiteral
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		return &ast.StringLiteral{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Value: v.Value}
+
+	case "SliceLiteral":
+		var v sliceLiteral
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.SliceLiteral{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Elements = decodeList[ast.Expression](d, v.Elements, "expression")
+		return n
+
+	case "MapLiteral":
+		var v mapLiteral
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.MapLiteral{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Type = decodeAs[ast.Expression](d, v.Type, "expression")
+		n.Pairs = decodeList[*ast.KeyValueExpression](d, v.Pairs, "key value expression")
+		return n
+
+	case "KeyValueExpression":
+		var v keyValueExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.KeyValueExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Key = decodeAs[ast.Expression](d, v.Key, "expression")
+		n.Value = decodeAs[ast.Expression](d, v.Value, "expression")
+		return n
+
+	case "StructLiteral":
+		var v structLiteral
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.StructLiteral{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Type = decodeAs[*ast.Identifier](d, v.Type, "identifier")
+		n.Fields = decodeList[*ast.KeyValueExpression](d, v.Fields, "key value expression")
+		return n
+
+	case "CallExpression":
+		var v callExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.CallExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Receiver = decodeAs[ast.Expression](d, v.Receiver, "expression")
+		n.Module = decodeAs[*ast.Identifier](d, v.Module, "identifier")
+		n.Function = decodeAs[*ast.Identifier](d, v.Function, "identifier")
+		n.Callee = decodeAs[ast.Expression](d, v.Callee, "expression")
+		n.Arguments = decodeList[ast.Expression](d, v.Arguments, "expression")
+		return n
+
+	case "IndexExpression":
+		var v indexExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.IndexExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Left = decodeAs[ast.Expression](d, v.Left, "expression")
+		n.Index = decodeAs[ast.Expression](d, v.Index, "expression")
+		return n
+
+	case "SliceExpression":
+		var v sliceExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.SliceExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Left = decodeAs[ast.Expression](d, v.Left, "expression")
+		n.Low = decodeAs[ast.Expression](d, v.Low, "expression")
+		n.High = decodeAs[ast.Expression](d, v.High, "expression")
+		return n
+
+	case "SelectorExpression":
+		var v selectorExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.SelectorExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.X = decodeAs[ast.Expression](d, v.X, "expression")
+		n.Sel = decodeAs[*ast.Identifier](d, v.Sel, "identifier")
+		return n
+
+	case "InfixExpression":
+		var v infixExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.InfixExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Operator: v.Operator}
+		n.Left = decodeAs[ast.Expression](d, v.Left, "expression")
+		n.Right = decodeAs[ast.Expression](d, v.Right, "expression")
+		return n
+
+	case "MatchExpression":
+		var v matchExpression
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.MatchExpression{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos), Rbrace: decodePos(v.Rbrace)}
+		n.Subject = decodeAs[ast.Expression](d, v.Subject, "expression")
+		n.Arms = decodeList[*ast.MatchArm](d, v.Arms, "match arm")
+		return n
+
+	case "MatchArm":
+		var v matchArm
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		n := &ast.MatchArm{Token: d.token(h.Token), Literal: h.Literal, Pos: decodePos(h.Pos)}
+		n.Pattern = decodeAs[ast.Expression](d, v.Pattern, "expression")
+		n.Guard = decodeAs[ast.Expression](d, v.Guard, "expression")
+		n.Body = decodeAs[ast.Expression](d, v.Body, "expression")
+		return n
+
+	case "Comment":
+		var v comment
+		if !d.unmarshal(data, &v) {
+			return nil
+		}
+		return &ast.Comment{Pos: decodePos(h.Pos), Text: v.Text}
+	}
+
+	d.err = fmt.Errorf("astjson: unknown node kind %q", h.Kind)
+	return nil
+}
+
+func (d *decoder) unmarshal(data []byte, v interface{}) bool {
+	if err := json.Unmarshal(data, v); err != nil {
+		d.err = fmt.Errorf("astjson: %w", err)
+		return false
+	}
+	return true
+}
+
+func (d *decoder) token(s string) (tok token.Token) {
+	if d.err == nil {
+		tok, d.err = decodeToken(s)
+	}
+	return tok
+}
+
+// decodeAs decodes data and checks that the result is an N. A missing node
+// results in the zero value of N. what describes N for error messages.
+func decodeAs[N ast.Node](d *decoder, data json.RawMessage, what string) N {
+	var zero N
+	node := d.node(data)
+	if node == nil {
+		return zero
+	}
+	res, ok := node.(N)
+	if !ok {
+		if d.err == nil {
+			d.err = fmt.Errorf("astjson: expected %s, found %s", what, kindOf(node))
+		}
+		return zero
+	}
+	return res
+}
+
+func decodeList[N ast.Node](d *decoder, list []json.RawMessage, what string) []N {
+	if list == nil {
+		return nil
+	}
+	res := make([]N, len(list))
+	for i, data := range list {
+		res[i] = decodeAs[N](d, data, what)
+	}
+	return res
+}
@@ -0,0 +1,301 @@
+package astjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/token"
+)
+
+var null = json.RawMessage("null")
+
+func encode(node ast.Node) (json.RawMessage, error) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return null, nil
+	}
+
+	var (
+		v   interface{}
+		err error
+	)
+	switch n := node.(type) {
+	case *ast.Program:
+		p := program{header: header{Kind: kindOf(n)}}
+		p.TopLevelDeclarations, err = encodeList(n.TopLevelDeclarations)
+		if err == nil {
+			p.Comments, err = encodeList(n.Comments)
+		}
+		v = p
+
+	case *ast.ImportDeclaration:
+		id := importDeclaration{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Semicolon: encodePos(n.Semicolon)}
+		id.Path = encodeChild(&err, n.Path)
+		v = id
+
+	case *ast.FunctionDeclaration:
+		fd := functionDeclaration{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		if n.Receiver != nil {
+			fd.Receiver = encodeChild(&err, n.Receiver)
+		}
+		fd.Identifier = encodeChild(&err, n.Identifier)
+		if err == nil {
+			fd.Parameters, err = encodeList(n.Parameters)
+		}
+		fd.ReturnType = encodeChild(&err, n.ReturnType)
+		fd.Body = encodeChild(&err, n.Body)
+		v = fd
+
+	case *ast.ValueDeclaration:
+		vd := valueDeclaration{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Semicolon: encodePos(n.Semicolon)}
+		vd.Identifier = encodeChild(&err, n.Identifier)
+		vd.Type = encodeChild(&err, n.Type)
+		vd.Value = encodeChild(&err, n.Value)
+		v = vd
+
+	case *ast.Parameter:
+		p := parameter{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		p.Identifier = encodeChild(&err, n.Identifier)
+		p.Type = encodeChild(&err, n.Type)
+		v = p
+
+	case *ast.StructDeclaration:
+		sd := structDeclaration{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Rbrace: encodePos(n.Rbrace)}
+		sd.Identifier = encodeChild(&err, n.Identifier)
+		if err == nil {
+			sd.Fields, err = encodeList(n.Fields)
+		}
+		v = sd
+
+	case *ast.Field:
+		f := field{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		f.Identifier = encodeChild(&err, n.Identifier)
+		f.Type = encodeChild(&err, n.Type)
+		v = f
+
+	case *ast.EnumDeclaration:
+		ed := enumDeclaration{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Rbrace: encodePos(n.Rbrace)}
+		ed.Identifier = encodeChild(&err, n.Identifier)
+		if err == nil {
+			ed.Variants, err = encodeList(n.Variants)
+		}
+		v = ed
+
+	case *ast.Variant:
+		vr := variant{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		vr.Identifier = encodeChild(&err, n.Identifier)
+		if err == nil {
+			vr.Payload, err = encodeList(n.Payload)
+		}
+		v = vr
+
+	case *ast.BlockStatement:
+		bs := blockStatement{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Rbrace: encodePos(n.Rbrace)}
+		bs.Statements, err = encodeList(n.Statements)
+		v = bs
+
+	case *ast.AssignmentStatement:
+		as := assignmentStatement{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Semicolon: encodePos(n.Semicolon)}
+		as.Targets, err = encodeList(n.Targets)
+		as.Value = encodeChild(&err, n.Value)
+		v = as
+
+	case *ast.ExpressionStatement:
+		es := expressionStatement{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Semicolon: encodePos(n.Semicolon)}
+		es.Expression = encodeChild(&err, n.Expression)
+		v = es
+
+	case *ast.ReturnStatement:
+		rs := returnStatement{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Semicolon: encodePos(n.Semicolon)}
+		rs.ReturnValues, err = encodeList(n.ReturnValues)
+		v = rs
+
+	case *ast.ForStatement:
+		fs := forStatement{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		if n.Index != nil {
+			fs.Index = encodeChild(&err, n.Index)
+		}
+		fs.Value = encodeChild(&err, n.Value)
+		fs.Iterable = encodeChild(&err, n.Iterable)
+		fs.Body = encodeChild(&err, n.Body)
+		v = fs
+
+	case *ast.Identifier:
+		v = identifier{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Value: n.Value}
+
+	case *ast.SliceType:
+		st := sliceType{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		st.Elem = encodeChild(&err, n.Elem)
+		v = st
+
+	case *ast.MapType:
+		mt := mapType{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		mt.Key = encodeChild(&err, n.Key)
+		mt.Value = encodeChild(&err, n.Value)
+		v = mt
+
+	case *ast.FuncType:
+		ft := funcType{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		ft.Params, err = encodeList(n.Params)
+		if n.Result != nil {
+			ft.Result = encodeChild(&err, n.Result)
+		}
+		v = ft
+
+	case *ast.TryExpression:
+		te := tryExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		te.X = encodeChild(&err, n.X)
+		v = te
+
+	case *ast.ResultList:
+		rl := resultList{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		rl.Types, err = encodeList(n.Types)
+		v = rl
+
+	case *ast.FunctionLiteral:
+		fl := functionLiteral{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		fl.Parameters, err = encodeList(n.Parameters)
+		fl.ReturnType = encodeChild(&err, n.ReturnType)
+		fl.Body = encodeChild(&err, n.Body)
+		v = fl
+
+	case *ast.IntLiteral:
+		v = intLiteral{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Value: n.Value}
+
+	case *ast.FloatLiteral:
+		v = floatLiteral{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Value: n.Value}
+
+	case *ast.StringLiteral:
+		v = stringLiteral{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Value: n.Value}
+
+	case *ast.SliceLiteral:
+		sl := sliceLiteral{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		sl.Elements, err = encodeList(n.Elements)
+		v = sl
+
+	case *ast.MapLiteral:
+		ml := mapLiteral{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		if n.Type != nil {
+			ml.Type = encodeChild(&err, n.Type)
+		}
+		if err == nil {
+			ml.Pairs, err = encodeList(n.Pairs)
+		}
+		v = ml
+
+	case *ast.KeyValueExpression:
+		kv := keyValueExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		kv.Key = encodeChild(&err, n.Key)
+		kv.Value = encodeChild(&err, n.Value)
+		v = kv
+
+	case *ast.StructLiteral:
+		sl := structLiteral{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		sl.Type = encodeChild(&err, n.Type)
+		if err == nil {
+			sl.Fields, err = encodeList(n.Fields)
+		}
+		v = sl
+
+	case *ast.CallExpression:
+		ce := callExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		if n.Receiver != nil {
+			ce.Receiver = encodeChild(&err, n.Receiver)
+		}
+		if n.Module != nil {
+			ce.Module = encodeChild(&err, n.Module)
+		}
+		if n.Callee != nil {
+			ce.Callee = encodeChild(&err, n.Callee)
+		} else {
+			ce.Function = encodeChild(&err, n.Function)
+		}
+		if err == nil {
+			ce.Arguments, err = encodeList(n.Arguments)
+		}
+		v = ce
+
+	case *ast.IndexExpression:
+		ie := indexExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		ie.Left = encodeChild(&err, n.Left)
+		ie.Index = encodeChild(&err, n.Index)
+		v = ie
+
+	case *ast.SliceExpression:
+		se := sliceExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		se.Left = encodeChild(&err, n.Left)
+		se.Low = encodeChild(&err, n.Low)
+		se.High = encodeChild(&err, n.High)
+		v = se
+
+	case *ast.SelectorExpression:
+		se := selectorExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		se.X = encodeChild(&err, n.X)
+		se.Sel = encodeChild(&err, n.Sel)
+		v = se
+
+	case *ast.InfixExpression:
+		ie := infixExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Operator: n.Operator}
+		ie.Left = encodeChild(&err, n.Left)
+		ie.Right = encodeChild(&err, n.Right)
+		v = ie
+
+	case *ast.MatchExpression:
+		me := matchExpression{header: nodeHeader(n, n.Token, n.Literal, n.Pos), Rbrace: encodePos(n.Rbrace)}
+		me.Subject = encodeChild(&err, n.Subject)
+		if err == nil {
+			me.Arms, err = encodeList(n.Arms)
+		}
+		v = me
+
+	case *ast.MatchArm:
+		ma := matchArm{header: nodeHeader(n, n.Token, n.Literal, n.Pos)}
+		ma.Pattern = encodeChild(&err, n.Pattern)
+		ma.Guard = encodeChild(&err, n.Guard)
+		ma.Body = encodeChild(&err, n.Body)
+		v = ma
+
+	case *ast.Comment:
+		v = comment{header: header{Kind: kindOf(n), Pos: encodePos(n.Pos)}, Text: n.Text}
+
+	default:
+		return nil, fmt.Errorf("astjson: unexpected node type %T", node)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// encodeChild encodes node unless a previous call already failed, the first
+// error is kept in err. This keeps the encoding of nodes with several
+// children readable.
+func encodeChild(err *error, node ast.Node) json.RawMessage {
+	if *err != nil {
+		return nil
+	}
+	var res json.RawMessage
+	res, *err = encode(node)
+	return res
+}
+
+func encodeList[N ast.Node](list []N) ([]json.RawMessage, error) {
+	if list == nil {
+		return nil, nil
+	}
+	res := make([]json.RawMessage, len(list))
+	for i, node := range list {
+		b, err := encode(node)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = b
+	}
+	return res, nil
+}
+
+func nodeHeader(node ast.Node, tok token.Token, literal string, p token.Pos) header {
+	return header{Kind: kindOf(node), Token: tok.String(), Literal: literal, Pos: encodePos(p)}
+}
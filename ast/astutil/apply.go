@@ -0,0 +1,329 @@
+// Package astutil contains utilities for transforming syntax trees.
+package astutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/muggel/emlang/ast"
+)
+
+// ApplyFunc is called by Apply with a cursor positioned at the current
+// node. Its result decides how the traversal goes on, see Apply.
+type ApplyFunc func(*Cursor) bool
+
+// Apply visits root and all nodes below it in source order and returns
+// root, or the node that replaced it.
+//
+// pre is called when a node is reached and post after its children have
+// been visited. Either may be nil. If pre returns false, the children of
+// the node are skipped and post is not called for it. If post returns
+// false, Apply stops. Nodes that replace the current node in pre are
+// visited in its place, inserted nodes are not visited.
+func Apply(root ast.Node, pre, post ApplyFunc) ast.Node {
+	a := &applier{pre: pre, post: post}
+	a.apply(&Cursor{node: root, index: -1, set: func(n ast.Node) { root = n }})
+	return root
+}
+
+// Cursor gives access to the node being visited by Apply and allows to
+// replace it or, if it is an element of a list, to delete it or insert
+// nodes next to it.
+type Cursor struct {
+	parent ast.Node
+	name   string
+	node   ast.Node
+
+	// set stores a replacement in the parent field, for list elements
+	// list and index locate the element
+	set   func(ast.Node)
+	list  nodeList
+	index int
+	next  *int // index of the element visited after this one
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() ast.Node { return c.node }
+
+// Parent returns the node that holds the current node, or nil for the
+// root.
+func (c *Cursor) Parent() ast.Node { return c.parent }
+
+// Name returns the name of the field of the parent that holds the current
+// node, e.g. "Statements" for a statement of a block.
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the current node in the list that holds it,
+// or -1 if it is not an element of a list. Inserting before the current
+// node increases its index.
+func (c *Cursor) Index() int { return c.index }
+
+// Replace replaces the current node with n. It panics if n cannot be
+// stored in the field of the parent, e.g. a call in place of an
+// identifier.
+func (c *Cursor) Replace(n ast.Node) {
+	c.set(n)
+	c.node = n
+}
+
+// Delete removes the current node from the list that holds it. It panics
+// if the node is not an element of a list.
+func (c *Cursor) Delete() {
+	c.mustBeListed("Delete")
+	c.list.remove(c.index)
+	*c.next--
+}
+
+// InsertBefore inserts n in front of the current node, which must be an
+// element of a list. n is not visited.
+func (c *Cursor) InsertBefore(n ast.Node) {
+	c.mustBeListed("InsertBefore")
+	c.list.insert(c.index, n)
+	c.index++
+	*c.next++
+}
+
+// InsertAfter inserts n after the current node, which must be an element
+// of a list. n is not visited.
+func (c *Cursor) InsertAfter(n ast.Node) {
+	c.mustBeListed("InsertAfter")
+	c.list.insert(c.index+1, n)
+	*c.next++
+}
+
+func (c *Cursor) mustBeListed(op string) {
+	if c.list == nil {
+		panic(fmt.Sprintf("astutil: %s of %T, which is not an element of a list", op, c.node))
+	}
+}
+
+// nodeList is a list of nodes in a field of a node.
+type nodeList interface {
+	insert(i int, n ast.Node)
+	remove(i int)
+}
+
+// elements is a nodeList of nodes of type N.
+type elements[N ast.Node] struct {
+	field string
+	elems *[]N
+}
+
+func (l elements[N]) insert(i int, n ast.Node) {
+	var zero N
+	s := append(*l.elems, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = as[N](n, l.field)
+	*l.elems = s
+}
+
+func (l elements[N]) remove(i int) {
+	s := *l.elems
+	copy(s[i:], s[i+1:])
+	var zero N
+	s[len(s)-1] = zero
+	*l.elems = s[:len(s)-1]
+}
+
+// as converts n to the type N of the field it is stored in.
+func as[N ast.Node](n ast.Node, field string) N {
+	var zero N
+	if n == nil {
+		return zero
+	}
+	res, ok := n.(N)
+	if !ok {
+		panic(fmt.Sprintf("astutil: cannot use %T in field %s", n, field))
+	}
+	return res
+}
+
+type applier struct {
+	pre, post ApplyFunc
+	stopped   bool // post returned false
+}
+
+// apply visits the node of c and its children.
+func (a *applier) apply(c *Cursor) {
+	if a.stopped || c.node == nil || isNil(c.node) {
+		return
+	}
+	if a.pre != nil && !a.pre(c) {
+		return
+	}
+
+	// the children in the order of the fields, the cases follow the order
+	// of the node types in ast.go
+	switch n := c.node.(type) {
+	case nil:
+		// pre replaced the node with nil
+
+	case *ast.Program:
+		applyList(a, n, "TopLevelDeclarations", &n.TopLevelDeclarations)
+
+	case *ast.ImportDeclaration:
+		applyField(a, n, "Path", &n.Path)
+
+	case *ast.FunctionDeclaration:
+		applyField(a, n, "Receiver", &n.Receiver)
+		applyField(a, n, "Identifier", &n.Identifier)
+		applyList(a, n, "Parameters", &n.Parameters)
+		applyField(a, n, "ReturnType", &n.ReturnType)
+		applyField(a, n, "Body", &n.Body)
+
+	case *ast.ValueDeclaration:
+		applyField(a, n, "Identifier", &n.Identifier)
+		applyField(a, n, "Type", &n.Type)
+		applyField(a, n, "Value", &n.Value)
+
+	case *ast.Parameter:
+		applyField(a, n, "Identifier", &n.Identifier)
+		applyField(a, n, "Type", &n.Type)
+
+	case *ast.StructDeclaration:
+		applyField(a, n, "Identifier", &n.Identifier)
+		applyList(a, n, "Fields", &n.Fields)
+
+	case *ast.Field:
+		applyField(a, n, "Identifier", &n.Identifier)
+		applyField(a, n, "Type", &n.Type)
+
+	case *ast.EnumDeclaration:
+		applyField(a, n, "Identifier", &n.Identifier)
+		applyList(a, n, "Variants", &n.Variants)
+
+	case *ast.Variant:
+		applyField(a, n, "Identifier", &n.Identifier)
+		applyList(a, n, "Payload", &n.Payload)
+
+	case *ast.BlockStatement:
+		applyList(a, n, "Statements", &n.Statements)
+
+	case *ast.AssignmentStatement:
+		applyList(a, n, "Targets", &n.Targets)
+		applyField(a, n, "Value", &n.Value)
+
+	case *ast.ExpressionStatement:
+		applyField(a, n, "Expression", &n.Expression)
+
+	case *ast.ReturnStatement:
+		applyList(a, n, "ReturnValues", &n.ReturnValues)
+
+	case *ast.ForStatement:
+		applyField(a, n, "Index", &n.Index)
+		applyField(a, n, "Value", &n.Value)
+		applyField(a, n, "Iterable", &n.Iterable)
+		applyField(a, n, "Body", &n.Body)
+
+	case *ast.SliceType:
+		applyField(a, n, "Elem", &n.Elem)
+
+	case *ast.MapType:
+		applyField(a, n, "Key", &n.Key)
+		applyField(a, n, "Value", &n.Value)
+
+	case *ast.FuncType:
+		applyList(a, n, "Params", &n.Params)
+		applyField(a, n, "Result", &n.Result)
+
+	case *ast.TryExpression:
+		applyField(a, n, "X", &n.X)
+
+	case *ast.ResultList:
+		applyList(a, n, "Types", &n.Types)
+
+	case *ast.FunctionLiteral:
+		applyList(a, n, "Parameters", &n.Parameters)
+		applyField(a, n, "ReturnType", &n.ReturnType)
+		applyField(a, n, "Body", &n.Body)
+
+	case *ast.SliceLiteral:
+		applyList(a, n, "Elements", &n.Elements)
+
+	case *ast.MapLiteral:
+		applyField(a, n, "Type", &n.Type)
+		applyList(a, n, "Pairs", &n.Pairs)
+
+	case *ast.KeyValueExpression:
+		applyField(a, n, "Key", &n.Key)
+		applyField(a, n, "Value", &n.Value)
+
+	case *ast.StructLiteral:
+		applyField(a, n, "Type", &n.Type)
+		applyList(a, n, "Fields", &n.Fields)
+
+	case *ast.CallExpression:
+		applyField(a, n, "Receiver", &n.Receiver)
+		applyField(a, n, "Module", &n.Module)
+		applyField(a, n, "Function", &n.Function)
+		applyField(a, n, "Callee", &n.Callee)
+		applyList(a, n, "Arguments", &n.Arguments)
+
+	case *ast.IndexExpression:
+		applyField(a, n, "Left", &n.Left)
+		applyField(a, n, "Index", &n.Index)
+
+	case *ast.SliceExpression:
+		applyField(a, n, "Left", &n.Left)
+		applyField(a, n, "Low", &n.Low)
+		applyField(a, n, "High", &n.High)
+
+	case *ast.SelectorExpression:
+		applyField(a, n, "X", &n.X)
+		applyField(a, n, "Sel", &n.Sel)
+
+	case *ast.InfixExpression:
+		applyField(a, n, "Left", &n.Left)
+		applyField(a, n, "Right", &n.Right)
+
+	case *ast.MatchExpression:
+		applyField(a, n, "Subject", &n.Subject)
+		applyList(a, n, "Arms", &n.Arms)
+
+	case *ast.MatchArm:
+		applyField(a, n, "Pattern", &n.Pattern)
+		applyField(a, n, "Guard", &n.Guard)
+		applyField(a, n, "Body", &n.Body)
+
+	case *ast.Identifier, *ast.IntLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Comment:
+		// nothing to do
+
+	default:
+		panic(fmt.Sprintf("astutil.Apply: unexpected node type %T", n))
+	}
+
+	if !a.stopped && a.post != nil && !a.post(c) {
+		a.stopped = true
+	}
+}
+
+// applyField visits the node in the field name of parent, field points to
+// the field.
+func applyField[N ast.Node](a *applier, parent ast.Node, name string, field *N) {
+	a.apply(&Cursor{
+		parent: parent,
+		name:   name,
+		node:   *field,
+		set:    func(n ast.Node) { *field = as[N](n, name) },
+		index:  -1,
+	})
+}
+
+// applyList visits the elements of the list in the field name of parent.
+// The list may change while its elements are visited.
+func applyList[N ast.Node](a *applier, parent ast.Node, name string, field *[]N) {
+	list := elements[N]{field: name, elems: field}
+	for i := 0; i < len(*field) && !a.stopped; {
+		next := i + 1
+		c := &Cursor{parent: parent, name: name, node: (*field)[i], list: list, index: i, next: &next}
+		c.set = func(n ast.Node) { (*field)[c.index] = as[N](n, name) }
+		a.apply(c)
+		i = next
+	}
+}
+
+// isNil reports whether n is a nil pointer stored in the interface.
+func isNil(n ast.Node) bool {
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
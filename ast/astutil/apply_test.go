@@ -0,0 +1,268 @@
+package astutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/format"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `fn helper() int {
+	return 42;
+}
+
+fn main() int {
+	a = helper();
+	b = a;
+	return b;
+}
+`
+
+// imports and clones declare a node of every type, for TestClone.
+const imports = "import \"lib\";\n\n"
+
+const clones = `
+fn slices(xs []int) {
+	for i, x in xs[1:2] {
+		xs[i] = [x];
+	}
+}
+
+fn maps() {
+	m = map[string]int{"a": 1};
+}
+
+struct Point { x int }
+
+fn (p Point) norm() int {
+	q = Point{x: p.x};
+	return q.x;
+}
+
+enum Shape { Circle(int), Empty }
+
+fn area(s Shape) int {
+	return match s {
+		Shape.Circle(r) if r => r,
+		_ => 0,
+	};
+}
+
+fn adder(n int) fn(int) int {
+	return fn(x int) int {
+		return x + n;
+	};
+}
+
+fn three() int {
+	return adder(1)(2);
+}
+
+fn swap(a int, b int) (int, int) {
+	return b, a;
+}
+
+fn swapped() {
+	x, y = swap(1, 2);
+}
+
+fn check() error {
+	validate()?;
+	return nil;
+}
+
+fn half() float {
+	return 0.5;
+}
+
+const Limit int = 3;
+`
+
+func parse(t *testing.T, src string) *ast.Program {
+	program, err := parser.NewParser(scanner.NewScanner(src)).Parse()
+	require.NoError(t, err)
+	return program
+}
+
+func render(t *testing.T, node ast.Node) string {
+	var buf bytes.Buffer
+	require.NoError(t, format.Node(&buf, node))
+	return buf.String()
+}
+
+func ident(name string) *ast.Identifier {
+	return &ast.Identifier{Token: token.IDENT, Literal: name, Value: name}
+}
+
+func assign(name string, value ast.Expression) *ast.AssignmentStatement {
+	return &ast.AssignmentStatement{Token: token.ASSIGN, Literal: "=", Targets: []ast.Expression{ident(name)}, Value: value}
+}
+
+func TestApply(t *testing.T) {
+	t.Run("replaces_expressions", func(t *testing.T) {
+		program := parse(t, source)
+		Apply(program, func(c *Cursor) bool {
+			if call, ok := c.Node().(*ast.CallExpression); ok && call.Function.Value == "helper" {
+				c.Replace(&ast.IntLiteral{Token: token.INT, Literal: "42", Value: 42})
+			}
+			return true
+		}, nil)
+
+		assert.Contains(t, render(t, program), "\ta = 42;\n")
+	})
+
+	t.Run("inserts_statements_before_and_after", func(t *testing.T) {
+		program := parse(t, source)
+		var visited []string
+		Apply(program, func(c *Cursor) bool {
+			if stmt, ok := c.Node().(*ast.AssignmentStatement); ok {
+				name := stmt.Targets[0].String()
+				visited = append(visited, name)
+				if name == "b" {
+					c.InsertBefore(assign("before", ident("a")))
+					c.InsertAfter(assign("after", ident("b")))
+				}
+			}
+			return true
+		}, nil)
+
+		expected := "fn main() int {\n\ta = helper();\n\tbefore = a;\n\tb = a;\n\tafter = b;\n\treturn b;\n}\n"
+		assert.Equal(t, expected, render(t, program.TopLevelDeclarations[1]))
+		assert.Equal(t, []string{"a", "b"}, visited, "inserted nodes must not be walked")
+	})
+
+	t.Run("deletes_statements", func(t *testing.T) {
+		program := parse(t, source)
+		var visited []string
+		Apply(program, func(c *Cursor) bool {
+			if _, ok := c.Node().(ast.Statement); ok {
+				visited = append(visited, c.Node().TokenLiteral())
+			}
+			if _, ok := c.Node().(*ast.AssignmentStatement); ok {
+				c.Delete()
+				return false
+			}
+			return true
+		}, nil)
+
+		assert.Equal(t, "fn main() int {\n\treturn b;\n}\n", render(t, program.TopLevelDeclarations[1]))
+		assert.Equal(t, []string{"{", "return", "{", "=", "=", "return"}, visited)
+	})
+
+	t.Run("visits_the_nodes_walk_visits", func(t *testing.T) {
+		program := parse(t, imports+source+clones)
+		// nodes are compared by identity
+		var walked, applied []string
+		ast.Inspect(program, func(n ast.Node) bool {
+			if n != nil {
+				walked = append(walked, fmt.Sprintf("%T %p", n, n))
+			}
+			return true
+		})
+		Apply(program, func(c *Cursor) bool {
+			applied = append(applied, fmt.Sprintf("%T %p", c.Node(), c.Node()))
+			return true
+		}, nil)
+
+		assert.Equal(t, walked, applied)
+	})
+
+	t.Run("replaces_the_root", func(t *testing.T) {
+		res := Apply(ident("a"), func(c *Cursor) bool {
+			assert.Nil(t, c.Parent())
+			c.Replace(ident("b"))
+			return true
+		}, nil)
+
+		assert.Equal(t, ident("b"), res)
+	})
+
+	t.Run("reports_parent_name_and_index", func(t *testing.T) {
+		program := parse(t, source)
+		var names []string
+		Apply(program, nil, func(c *Cursor) bool {
+			if _, ok := c.Parent().(*ast.AssignmentStatement); ok {
+				names = append(names, c.Name())
+			}
+			if _, ok := c.Node().(*ast.ReturnStatement); ok {
+				assert.Equal(t, "Statements", c.Name())
+			}
+			return true
+		})
+
+		assert.Equal(t, []string{"Targets", "Value", "Targets", "Value"}, names)
+	})
+
+	t.Run("stops_when_post_returns_false", func(t *testing.T) {
+		program := parse(t, source)
+		var count int
+		res := Apply(program, nil, func(c *Cursor) bool {
+			count++
+			_, isReturn := c.Node().(*ast.ReturnStatement)
+			return !isReturn
+		})
+
+		assert.Equal(t, 4, count)
+		assert.Same(t, program, res)
+	})
+
+	t.Run("panics_when_replacement_does_not_fit", func(t *testing.T) {
+		program := parse(t, source)
+		assert.Panics(t, func() {
+			Apply(program, func(c *Cursor) bool {
+				if _, ok := c.Node().(*ast.Identifier); ok {
+					c.Replace(&ast.IntLiteral{Token: token.INT, Literal: "1", Value: 1})
+				}
+				return true
+			}, nil)
+		})
+	})
+
+	t.Run("panics_when_deleting_outside_a_list", func(t *testing.T) {
+		program := parse(t, source)
+		assert.Panics(t, func() {
+			Apply(program, func(c *Cursor) bool {
+				if c.Name() == "Body" {
+					c.Delete()
+				}
+				return true
+			}, nil)
+		})
+	})
+}
+
+func TestClone(t *testing.T) {
+	t.Run("copies_every_node", func(t *testing.T) {
+		program := parse(t, "// doc\n"+imports+source+clones)
+		clone := Clone(program)
+		assert.Equal(t, program, clone)
+
+		// no node may be shared between the original and the clone
+		seen := map[ast.Node]bool{}
+		ast.Inspect(program, func(n ast.Node) bool {
+			seen[n] = true
+			return true
+		})
+		ast.Inspect(clone, func(n ast.Node) bool {
+			if n != nil {
+				assert.False(t, seen[n], "%T is shared", n)
+			}
+			return true
+		})
+		assert.NotSame(t, program.Comments[0], clone.Comments[0])
+	})
+
+	t.Run("keeps_nil_nodes", func(t *testing.T) {
+		stmt := &ast.ReturnStatement{Token: token.RETURN, Literal: "return"}
+		assert.Equal(t, stmt, Clone(stmt))
+		assert.Nil(t, Clone[ast.Expression](nil))
+		assert.Nil(t, Clone((*ast.Identifier)(nil)))
+	})
+}
@@ -0,0 +1,245 @@
+package astutil
+
+import (
+	"fmt"
+
+	"github.com/muggel/emlang/ast"
+)
+
+// Clone returns a deep copy of node. The copy shares no nodes with the
+// original, so either one can be modified without affecting the other.
+func Clone[N ast.Node](node N) N {
+	res, _ := clone(node).(N)
+	return res
+}
+
+func clone(node ast.Node) ast.Node {
+	if node == nil || isNil(node) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		c := *n
+		c.TopLevelDeclarations = cloneList(n.TopLevelDeclarations)
+		c.Comments = cloneList(n.Comments)
+		return &c
+
+	case *ast.ImportDeclaration:
+		c := *n
+		c.Path = Clone(n.Path)
+		return &c
+
+	case *ast.FunctionDeclaration:
+		c := *n
+		c.Receiver = Clone(n.Receiver)
+		c.Identifier = Clone(n.Identifier)
+		c.Parameters = cloneList(n.Parameters)
+		c.ReturnType = Clone(n.ReturnType)
+		c.Body = Clone(n.Body)
+		return &c
+
+	case *ast.ValueDeclaration:
+		c := *n
+		c.Identifier = Clone(n.Identifier)
+		c.Type = Clone(n.Type)
+		c.Value = Clone(n.Value)
+		return &c
+
+	case *ast.Parameter:
+		c := *n
+		c.Identifier = Clone(n.Identifier)
+		c.Type = Clone(n.Type)
+		return &c
+
+	case *ast.StructDeclaration:
+		c := *n
+		c.Identifier = Clone(n.Identifier)
+		c.Fields = cloneList(n.Fields)
+		return &c
+
+	case *ast.Field:
+		c := *n
+		c.Identifier = Clone(n.Identifier)
+		c.Type = Clone(n.Type)
+		return &c
+
+	case *ast.EnumDeclaration:
+		c := *n
+		c.Identifier = Clone(n.Identifier)
+		c.Variants = cloneList(n.Variants)
+		return &c
+
+	case *ast.Variant:
+		c := *n
+		c.Identifier = Clone(n.Identifier)
+		c.Payload = cloneList(n.Payload)
+		return &c
+
+	case *ast.BlockStatement:
+		c := *n
+		c.Statements = cloneList(n.Statements)
+		return &c
+
+	case *ast.AssignmentStatement:
+		c := *n
+		c.Targets = cloneList(n.Targets)
+		c.Value = Clone(n.Value)
+		return &c
+
+	case *ast.ExpressionStatement:
+		c := *n
+		c.Expression = Clone(n.Expression)
+		return &c
+
+	case *ast.ReturnStatement:
+		c := *n
+		c.ReturnValues = cloneList(n.ReturnValues)
+		return &c
+
+	case *ast.ForStatement:
+		c := *n
+		c.Index = Clone(n.Index)
+		c.Value = Clone(n.Value)
+		c.Iterable = Clone(n.Iterable)
+		c.Body = Clone(n.Body)
+		return &c
+
+	case *ast.SliceType:
+		c := *n
+		c.Elem = Clone(n.Elem)
+		return &c
+
+	case *ast.MapType:
+		c := *n
+		c.Key = Clone(n.Key)
+		c.Value = Clone(n.Value)
+		return &c
+
+	case *ast.FuncType:
+		c := *n
+		c.Params = cloneList(n.Params)
+		c.Result = Clone(n.Result)
+		return &c
+
+	case *ast.TryExpression:
+		c := *n
+		c.X = Clone(n.X)
+		return &c
+
+	case *ast.ResultList:
+		c := *n
+		c.Types = cloneList(n.Types)
+		return &c
+
+	case *ast.FunctionLiteral:
+		c := *n
+		c.Parameters = cloneList(n.Parameters)
+		c.ReturnType = Clone(n.ReturnType)
+		c.Body = Clone(n.Body)
+		return &c
+
+	case *ast.SliceLiteral:
+		c := *n
+		c.Elements = cloneList(n.Elements)
+		return &c
+
+	case *ast.MapLiteral:
+		c := *n
+		c.Type = Clone(n.Type)
+		c.Pairs = cloneList(n.Pairs)
+		return &c
+
+	case *ast.KeyValueExpression:
+		c := *n
+		c.Key = Clone(n.Key)
+		c.Value = Clone(n.Value)
+		return &c
+
+	case *ast.StructLiteral:
+		c := *n
+		c.Type = Clone(n.Type)
+		c.Fields = cloneList(n.Fields)
+		return &c
+
+	case *ast.CallExpression:
+		c := *n
+		c.Receiver = Clone(n.Receiver)
+		c.Module = Clone(n.Module)
+		c.Function = Clone(n.Function)
+		c.Callee = Clone(n.Callee)
+		c.Arguments = cloneList(n.Arguments)
+		return &c
+
+	case *ast.IndexExpression:
+		c := *n
+		c.Left = Clone(n.Left)
+		c.Index = Clone(n.Index)
+		return &c
+
+	case *ast.SliceExpression:
+		c := *n
+		c.Left = Clone(n.Left)
+		c.Low = Clone(n.Low)
+		c.High = Clone(n.High)
+		return &c
+
+	case *ast.SelectorExpression:
+		c := *n
+		c.X = Clone(n.X)
+		c.Sel = Clone(n.Sel)
+		return &c
+
+	case *ast.InfixExpression:
+		c := *n
+		c.Left = Clone(n.Left)
+		c.Right = Clone(n.Right)
+		return &c
+
+	case *ast.MatchExpression:
+		c := *n
+		c.Subject = Clone(n.Subject)
+		c.Arms = cloneList(n.Arms)
+		return &c
+
+	case *ast.MatchArm:
+		c := *n
+		c.Pattern = Clone(n.Pattern)
+		c.Guard = Clone(n.Guard)
+		c.Body = Clone(n.Body)
+		return &c
+
+	case *ast.Identifier:
+		c := *n
+		return &c
+
+	case *ast.IntLiteral:
+		c := *n
+		return &c
+
+	case *ast.FloatLiteral:
+		c := *n
+		return &c
+
+	case *ast.StringLiteral:
+		c := *n
+		return &c
+
+	case *ast.Comment:
+		c := *n
+		return &c
+	}
+
+	panic(fmt.Sprintf("Clone: unexpected node type %T", node))
+}
+
+func cloneList[N ast.Node](list []N) []N {
+	if list == nil {
+		return nil
+	}
+	res := make([]N, len(list))
+	for i, n := range list {
+		res[i] = Clone(n)
+	}
+	return res
+}
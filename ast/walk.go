@@ -0,0 +1,222 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Visitor is called by Walk for every node. The visitor it returns is used
+// for the children of the node, which are skipped if it is nil. After the
+// children Walk calls Visit(nil) on that visitor.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, visiting the children of a
+// node in source order. Nil children, as left behind by a parser that
+// encountered errors, are skipped. Comments are not part of the tree and
+// are not visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, decl := range n.TopLevelDeclarations {
+			walk(v, decl)
+		}
+
+	case *ImportDeclaration:
+		walk(v, n.Path)
+
+	case *FunctionDeclaration:
+		walk(v, n.Receiver)
+		walk(v, n.Identifier)
+		for _, param := range n.Parameters {
+			walk(v, param)
+		}
+		walk(v, n.ReturnType)
+		walk(v, n.Body)
+
+	case *ValueDeclaration:
+		walk(v, n.Identifier)
+		walk(v, n.Type)
+		walk(v, n.Value)
+
+	case *Parameter:
+		walk(v, n.Identifier)
+		walk(v, n.Type)
+
+	case *StructDeclaration:
+		walk(v, n.Identifier)
+		for _, field := range n.Fields {
+			walk(v, field)
+		}
+
+	case *Field:
+		walk(v, n.Identifier)
+		walk(v, n.Type)
+
+	case *EnumDeclaration:
+		walk(v, n.Identifier)
+		for _, variant := range n.Variants {
+			walk(v, variant)
+		}
+
+	case *Variant:
+		walk(v, n.Identifier)
+		for _, typ := range n.Payload {
+			walk(v, typ)
+		}
+
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			walk(v, stmt)
+		}
+
+	case *AssignmentStatement:
+		for _, target := range n.Targets {
+			walk(v, target)
+		}
+		walk(v, n.Value)
+
+	case *ExpressionStatement:
+		walk(v, n.Expression)
+
+	case *ReturnStatement:
+		for _, value := range n.ReturnValues {
+			walk(v, value)
+		}
+
+	case *ForStatement:
+		walk(v, n.Index)
+		walk(v, n.Value)
+		walk(v, n.Iterable)
+		walk(v, n.Body)
+
+	case *SliceType:
+		walk(v, n.Elem)
+
+	case *MapType:
+		walk(v, n.Key)
+		walk(v, n.Value)
+
+	case *FuncType:
+		for _, param := range n.Params {
+			walk(v, param)
+		}
+		walk(v, n.Result)
+
+	case *TryExpression:
+		walk(v, n.X)
+
+	case *ResultList:
+		for _, typ := range n.Types {
+			walk(v, typ)
+		}
+
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			walk(v, param)
+		}
+		walk(v, n.ReturnType)
+		walk(v, n.Body)
+
+	case *SliceLiteral:
+		for _, elem := range n.Elements {
+			walk(v, elem)
+		}
+
+	case *MapLiteral:
+		walk(v, n.Type)
+		for _, pair := range n.Pairs {
+			walk(v, pair)
+		}
+
+	case *KeyValueExpression:
+		walk(v, n.Key)
+		walk(v, n.Value)
+
+	case *StructLiteral:
+		walk(v, n.Type)
+		for _, field := range n.Fields {
+			walk(v, field)
+		}
+
+	case *CallExpression:
+		walk(v, n.Receiver)
+		walk(v, n.Module)
+		walk(v, n.Function)
+		walk(v, n.Callee)
+		for _, arg := range n.Arguments {
+			walk(v, arg)
+		}
+
+	case *IndexExpression:
+		walk(v, n.Left)
+		walk(v, n.Index)
+
+	case *SliceExpression:
+		walk(v, n.Left)
+		walk(v, n.Low)
+		walk(v, n.High)
+
+	case *SelectorExpression:
+		walk(v, n.X)
+		walk(v, n.Sel)
+
+	case *InfixExpression:
+		walk(v, n.Left)
+		walk(v, n.Right)
+
+	case *MatchExpression:
+		walk(v, n.Subject)
+		for _, arm := range n.Arms {
+			walk(v, arm)
+		}
+
+	case *MatchArm:
+		walk(v, n.Pattern)
+		walk(v, n.Guard)
+		walk(v, n.Body)
+
+	case *Identifier, *IntLiteral, *FloatLiteral, *StringLiteral, *Comment:
+		// nothing to do
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walk calls Walk for node unless it is nil. The check has to look through
+// the interface, a nil *Identifier stored in a Node is not a nil Node.
+func walk(v Visitor, node Node) {
+	if node == nil || isNil(node) {
+		return
+	}
+	Walk(v, node)
+}
+
+func isNil(node Node) bool {
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect calls f for node and, as long as f returns true, for the nodes
+// below it, in the order of Walk. f(nil) is called after the children of a
+// node.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
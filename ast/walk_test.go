@@ -0,0 +1,559 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+)
+
+// testProgram returns the tree of
+//
+//	import "lib";
+//
+//	fn helper() int {
+//		return 42;
+//	}
+//
+//	fn main() {
+//		foo = helper();
+//		{
+//			return foo;
+//		}
+//	}
+//
+//	fn slices(xs []int) {
+//		for i, x in xs[1:2] {
+//			xs[i] = [x];
+//		}
+//	}
+//
+//	fn maps() {
+//		m = map[string]int{"a": 1};
+//	}
+//
+//	struct Point { x int }
+//
+//	fn (p Point) norm() int {
+//		q = Point{x: p.x};
+//		return q.x;
+//	}
+//
+//	enum Shape { Circle(int), Empty }
+//
+//	fn area(s Shape) int {
+//		return match s {
+//			Shape.Circle(r) if r => r,
+//			_ => 0,
+//		};
+//	}
+//
+//	fn adder(n int) fn(int) int {
+//		return fn(x int) int {
+//			return x + n;
+//		};
+//	}
+//
+//	fn three() int {
+//		return adder(1)(2);
+//	}
+//
+//	fn swap(a int, b int) (int, int) {
+//		return b, a;
+//	}
+//
+//	fn swapped() {
+//		x, y = swap(1, 2);
+//	}
+//
+//	fn check() error {
+//		validate()?;
+//		return nil;
+//	}
+//
+//	fn half() float {
+//		return 0.5;
+//	}
+//
+//	const Limit int = 3;
+func testProgram() *Program {
+	helper := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: &Identifier{Token: token.IDENT, Literal: "helper", Value: "helper"},
+		ReturnType: &Identifier{Token: token.IDENT, Literal: "int", Value: "int"},
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{&IntLiteral{Token: token.INT, Literal: "42", Value: 42}}},
+		}},
+	}
+	main := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: &Identifier{Token: token.IDENT, Literal: "main", Value: "main"},
+		ReturnType: &Identifier{Token: token.IDENT, Literal: "void", Value: "void"},
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&AssignmentStatement{
+				Token:   token.ASSIGN,
+				Literal: "=",
+				Targets: []Expression{&Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"}},
+				Value: &CallExpression{
+					Token:    token.IDENT,
+					Literal:  "helper",
+					Function: &Identifier{Token: token.IDENT, Literal: "helper", Value: "helper"},
+				},
+			},
+			&BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+				&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{&Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"}}},
+			}},
+		}},
+	}
+	slices := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("slices"),
+		Parameters: []*Parameter{
+			{Token: token.IDENT, Literal: "xs", Identifier: ident("xs"), Type: &SliceType{Token: token.LBRACK, Literal: "[", Elem: ident("int")}},
+		},
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ForStatement{
+				Token:    token.FOR,
+				Literal:  "for",
+				Index:    ident("i"),
+				Value:    ident("x"),
+				Iterable: &SliceExpression{Token: token.LBRACK, Literal: "[", Left: ident("xs"), Low: intLiteral(1), High: intLiteral(2)},
+				Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+					&AssignmentStatement{
+						Token:   token.ASSIGN,
+						Literal: "=",
+						Targets: []Expression{&IndexExpression{Token: token.LBRACK, Literal: "[", Left: ident("xs"), Index: ident("i")}},
+						Value:   &SliceLiteral{Token: token.LBRACK, Literal: "[", Elements: []Expression{ident("x")}},
+					},
+				}},
+			},
+		}},
+	}
+	maps := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("maps"),
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&AssignmentStatement{
+				Token:   token.ASSIGN,
+				Literal: "=",
+				Targets: []Expression{ident("m")},
+				Value: &MapLiteral{
+					Token:   token.LBRACE,
+					Literal: "{",
+					Type:    &MapType{Token: token.MAP, Literal: "map", Key: ident("string"), Value: ident("int")},
+					Pairs: []*KeyValueExpression{
+						{Token: token.COLON, Literal: ":", Key: &StringLiteral{Token: token.STRING, Literal: `"a"`, Value: "a"}, Value: intLiteral(1)},
+					},
+				},
+			},
+		}},
+	}
+	point := &StructDeclaration{
+		Token:      token.STRUCT,
+		Literal:    "struct",
+		Identifier: ident("Point"),
+		Fields:     []*Field{{Token: token.IDENT, Literal: "x", Identifier: ident("x"), Type: ident("int")}},
+	}
+	norm := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Receiver:   &Parameter{Token: token.IDENT, Literal: "p", Identifier: ident("p"), Type: ident("Point")},
+		Identifier: ident("norm"),
+		ReturnType: ident("int"),
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&AssignmentStatement{
+				Token:   token.ASSIGN,
+				Literal: "=",
+				Targets: []Expression{ident("q")},
+				Value: &StructLiteral{
+					Token:   token.LBRACE,
+					Literal: "{",
+					Type:    ident("Point"),
+					Fields: []*KeyValueExpression{
+						{Token: token.COLON, Literal: ":", Key: ident("x"), Value: &SelectorExpression{Token: token.DOT, Literal: ".", X: ident("p"), Sel: ident("x")}},
+					},
+				},
+			},
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{
+				&SelectorExpression{Token: token.DOT, Literal: ".", X: ident("q"), Sel: ident("x")},
+			}},
+		}},
+	}
+	shape := &EnumDeclaration{
+		Token:      token.ENUM,
+		Literal:    "enum",
+		Identifier: ident("Shape"),
+		Variants: []*Variant{
+			{Token: token.IDENT, Literal: "Circle", Identifier: ident("Circle"), Payload: []Expression{ident("int")}},
+			{Token: token.IDENT, Literal: "Empty", Identifier: ident("Empty")},
+		},
+	}
+	area := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("area"),
+		Parameters: []*Parameter{{Token: token.IDENT, Literal: "s", Identifier: ident("s"), Type: ident("Shape")}},
+		ReturnType: ident("int"),
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{
+				&MatchExpression{
+					Token:   token.MATCH,
+					Literal: "match",
+					Subject: ident("s"),
+					Arms: []*MatchArm{
+						{
+							Token:   token.ARROW,
+							Literal: "=>",
+							Pattern: &CallExpression{Token: token.IDENT, Literal: "Circle", Receiver: ident("Shape"), Function: ident("Circle"), Arguments: []Expression{ident("r")}},
+							Guard:   ident("r"),
+							Body:    ident("r"),
+						},
+						{Token: token.ARROW, Literal: "=>", Pattern: ident("_"), Body: intLiteral(0)},
+					},
+				},
+			}},
+		}},
+	}
+	adder := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("adder"),
+		Parameters: []*Parameter{{Token: token.IDENT, Literal: "n", Identifier: ident("n"), Type: ident("int")}},
+		ReturnType: &FuncType{Token: token.FN, Literal: "fn", Params: []Expression{ident("int")}, Result: ident("int")},
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{
+				&FunctionLiteral{
+					Token:      token.FN,
+					Literal:    "fn",
+					Parameters: []*Parameter{{Token: token.IDENT, Literal: "x", Identifier: ident("x"), Type: ident("int")}},
+					ReturnType: ident("int"),
+					Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+						&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{
+							&InfixExpression{Token: token.ADD, Literal: "+", Left: ident("x"), Operator: "+", Right: ident("n")},
+						}},
+					}},
+				},
+			}},
+		}},
+	}
+	three := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("three"),
+		ReturnType: ident("int"),
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{
+				&CallExpression{
+					Token:     token.LPAREN,
+					Literal:   "(",
+					Callee:    &CallExpression{Token: token.IDENT, Literal: "adder", Function: ident("adder"), Arguments: []Expression{intLiteral(1)}},
+					Arguments: []Expression{intLiteral(2)},
+				},
+			}},
+		}},
+	}
+	swap := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("swap"),
+		Parameters: []*Parameter{
+			{Token: token.IDENT, Literal: "a", Identifier: ident("a"), Type: ident("int")},
+			{Token: token.IDENT, Literal: "b", Identifier: ident("b"), Type: ident("int")},
+		},
+		ReturnType: &ResultList{Token: token.LPAREN, Literal: "(", Types: []Expression{ident("int"), ident("int")}},
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{ident("b"), ident("a")}},
+		}},
+	}
+	swapped := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("swapped"),
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&AssignmentStatement{
+				Token:   token.ASSIGN,
+				Literal: "=",
+				Targets: []Expression{ident("x"), ident("y")},
+				Value:   &CallExpression{Token: token.IDENT, Literal: "swap", Function: ident("swap"), Arguments: []Expression{intLiteral(1), intLiteral(2)}},
+			},
+		}},
+	}
+	check := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("check"),
+		ReturnType: ident("error"),
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ExpressionStatement{
+				Token:   token.IDENT,
+				Literal: "validate",
+				Expression: &TryExpression{
+					Token:   token.QUESTION,
+					Literal: "?",
+					X:       &CallExpression{Token: token.IDENT, Literal: "validate", Function: ident("validate")},
+				},
+			},
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{ident("nil")}},
+		}},
+	}
+	half := &FunctionDeclaration{
+		Token:      token.FN,
+		Literal:    "fn",
+		Identifier: ident("half"),
+		ReturnType: ident("float"),
+		Body: &BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []Statement{
+			&ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []Expression{&FloatLiteral{Token: token.FLOAT, Literal: "0.5", Value: 0.5}}},
+		}},
+	}
+	limit := &ValueDeclaration{Token: token.CONST, Literal: "const", Identifier: ident("Limit"), Type: ident("int"), Value: intLiteral(3)}
+	lib := &ImportDeclaration{Token: token.IMPORT, Literal: "import", Path: &StringLiteral{Token: token.STRING, Literal: `"lib"`, Value: "lib"}}
+	return &Program{TopLevelDeclarations: []TopLevelDeclaration{lib, helper, main, slices, maps, point, norm, shape, area, adder, three, swap, swapped, check, half, limit}}
+}
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.IDENT, Literal: name, Value: name}
+}
+
+func intLiteral(v int64) *IntLiteral {
+	lit := fmt.Sprint(v)
+	return &IntLiteral{Token: token.INT, Literal: lit, Value: v}
+}
+
+// describe returns a short description of node for comparisons
+func describe(node Node) string {
+	switch n := node.(type) {
+	case nil:
+		return "end"
+	case *Identifier:
+		return "Identifier " + n.Value
+	case *IntLiteral:
+		return "IntLiteral " + n.Literal
+	case *FloatLiteral:
+		return "FloatLiteral " + n.Literal
+	}
+	return fmt.Sprintf("%T", node)[len("*ast."):]
+}
+
+type recorder struct {
+	visited *[]string
+}
+
+func (r recorder) Visit(node Node) Visitor {
+	*r.visited = append(*r.visited, describe(node))
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	t.Run("visits_every_node_in_source_order", func(t *testing.T) {
+		var visited []string
+		Walk(recorder{&visited}, testProgram())
+
+		expected := []string{
+			"Program",
+			"ImportDeclaration", "StringLiteral", "end", "end",
+			"FunctionDeclaration",
+			"Identifier helper", "end",
+			"Identifier int", "end",
+			"BlockStatement",
+			"ReturnStatement", "IntLiteral 42", "end", "end",
+			"end",
+			"end",
+			"FunctionDeclaration",
+			"Identifier main", "end",
+			"Identifier void", "end",
+			"BlockStatement",
+			"AssignmentStatement",
+			"Identifier foo", "end",
+			"CallExpression", "Identifier helper", "end", "end",
+			"end",
+			"BlockStatement",
+			"ReturnStatement", "Identifier foo", "end", "end",
+			"end",
+			"end",
+			"end",
+			// fn slices
+			"FunctionDeclaration",
+			"Identifier slices", "end",
+			"Parameter", "Identifier xs", "end", "SliceType", "Identifier int", "end", "end", "end",
+			"BlockStatement",
+			"ForStatement", "Identifier i", "end", "Identifier x", "end",
+			"SliceExpression", "Identifier xs", "end", "IntLiteral 1", "end", "IntLiteral 2", "end", "end",
+			"BlockStatement",
+			"AssignmentStatement",
+			"IndexExpression", "Identifier xs", "end", "Identifier i", "end", "end",
+			"SliceLiteral", "Identifier x", "end", "end",
+			"end",
+			"end",
+			"end",
+			"end",
+			"end",
+			// fn maps
+			"FunctionDeclaration",
+			"Identifier maps", "end",
+			"BlockStatement",
+			"AssignmentStatement", "Identifier m", "end",
+			"MapLiteral", "MapType", "Identifier string", "end", "Identifier int", "end", "end",
+			"KeyValueExpression", "StringLiteral", "end", "IntLiteral 1", "end", "end",
+			"end",
+			"end",
+			"end",
+			"end",
+			// struct Point
+			"StructDeclaration", "Identifier Point", "end",
+			"Field", "Identifier x", "end", "Identifier int", "end", "end",
+			"end",
+			// fn (p Point) norm
+			"FunctionDeclaration",
+			"Parameter", "Identifier p", "end", "Identifier Point", "end", "end",
+			"Identifier norm", "end",
+			"Identifier int", "end",
+			"BlockStatement",
+			"AssignmentStatement", "Identifier q", "end",
+			"StructLiteral", "Identifier Point", "end",
+			"KeyValueExpression", "Identifier x", "end",
+			"SelectorExpression", "Identifier p", "end", "Identifier x", "end", "end",
+			"end",
+			"end",
+			"end",
+			"ReturnStatement", "SelectorExpression", "Identifier q", "end", "Identifier x", "end", "end", "end",
+			"end",
+			"end",
+			// enum Shape
+			"EnumDeclaration", "Identifier Shape", "end",
+			"Variant", "Identifier Circle", "end", "Identifier int", "end", "end",
+			"Variant", "Identifier Empty", "end", "end",
+			"end",
+			// fn area
+			"FunctionDeclaration",
+			"Identifier area", "end",
+			"Parameter", "Identifier s", "end", "Identifier Shape", "end", "end",
+			"Identifier int", "end",
+			"BlockStatement",
+			"ReturnStatement",
+			"MatchExpression", "Identifier s", "end",
+			"MatchArm",
+			"CallExpression", "Identifier Shape", "end", "Identifier Circle", "end", "Identifier r", "end", "end",
+			"Identifier r", "end",
+			"Identifier r", "end",
+			"end",
+			"MatchArm", "Identifier _", "end", "IntLiteral 0", "end", "end",
+			"end",
+			"end",
+			"end",
+			"end",
+			// fn adder
+			"FunctionDeclaration",
+			"Identifier adder", "end",
+			"Parameter", "Identifier n", "end", "Identifier int", "end", "end",
+			"FuncType", "Identifier int", "end", "Identifier int", "end", "end",
+			"BlockStatement",
+			"ReturnStatement",
+			"FunctionLiteral",
+			"Parameter", "Identifier x", "end", "Identifier int", "end", "end",
+			"Identifier int", "end",
+			"BlockStatement",
+			"ReturnStatement", "InfixExpression", "Identifier x", "end", "Identifier n", "end", "end", "end",
+			"end",
+			"end",
+			"end",
+			"end",
+			"end",
+			// fn three
+			"FunctionDeclaration",
+			"Identifier three", "end",
+			"Identifier int", "end",
+			"BlockStatement",
+			"ReturnStatement",
+			"CallExpression",
+			"CallExpression", "Identifier adder", "end", "IntLiteral 1", "end", "end",
+			"IntLiteral 2", "end",
+			"end",
+			"end",
+			"end",
+			"end",
+			// fn swap
+			"FunctionDeclaration",
+			"Identifier swap", "end",
+			"Parameter", "Identifier a", "end", "Identifier int", "end", "end",
+			"Parameter", "Identifier b", "end", "Identifier int", "end", "end",
+			"ResultList", "Identifier int", "end", "Identifier int", "end", "end",
+			"BlockStatement",
+			"ReturnStatement", "Identifier b", "end", "Identifier a", "end", "end",
+			"end",
+			"end",
+			// fn swapped
+			"FunctionDeclaration",
+			"Identifier swapped", "end",
+			"BlockStatement",
+			"AssignmentStatement", "Identifier x", "end", "Identifier y", "end",
+			"CallExpression", "Identifier swap", "end", "IntLiteral 1", "end", "IntLiteral 2", "end", "end",
+			"end",
+			"end",
+			"end",
+			// fn check
+			"FunctionDeclaration",
+			"Identifier check", "end",
+			"Identifier error", "end",
+			"BlockStatement",
+			"ExpressionStatement", "TryExpression", "CallExpression", "Identifier validate", "end", "end", "end", "end",
+			"ReturnStatement", "Identifier nil", "end", "end",
+			"end",
+			"end",
+			// fn half
+			"FunctionDeclaration",
+			"Identifier half", "end",
+			"Identifier float", "end",
+			"BlockStatement",
+			"ReturnStatement", "FloatLiteral 0.5", "end", "end",
+			"end",
+			"end",
+			// const Limit
+			"ValueDeclaration", "Identifier Limit", "end", "Identifier int", "end", "IntLiteral 3", "end", "end",
+			// Program
+			"end",
+		}
+		assert.Equal(t, expected, visited)
+	})
+
+	t.Run("skips_nil_children", func(t *testing.T) {
+		var visited []string
+		Walk(recorder{&visited}, &ReturnStatement{Token: token.RETURN, Literal: "return"})
+		assert.Equal(t, []string{"ReturnStatement", "end"}, visited)
+	})
+}
+
+func TestInspect(t *testing.T) {
+	t.Run("stops_descending_when_f_returns_false", func(t *testing.T) {
+		program := testProgram()
+		var visited []string
+		Inspect(program, func(node Node) bool {
+			if node != nil {
+				visited = append(visited, describe(node))
+			}
+			_, isDecl := node.(TopLevelDeclaration)
+			return !isDecl
+		})
+
+		expected := []string{"Program"}
+		for _, decl := range program.TopLevelDeclarations {
+			expected = append(expected, describe(decl))
+		}
+		assert.Equal(t, expected, visited)
+	})
+
+	t.Run("finds_all_calls", func(t *testing.T) {
+		var calls []string
+		Inspect(testProgram(), func(node Node) bool {
+			if call, ok := node.(*CallExpression); ok {
+				calls = append(calls, call.String())
+			}
+			return true
+		})
+		assert.Equal(t, []string{"helper()", "Shape.Circle(r)", "adder(1)(2)", "adder(1)", "swap(1, 2)", "validate()"}, calls)
+	})
+}
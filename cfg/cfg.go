@@ -0,0 +1,221 @@
+// Package cfg builds control-flow graphs of function declarations.
+//
+// A graph consists of basic blocks, each a sequence of statements that are
+// executed one after the other. Every graph has an entry block, holding the
+// first statements of the body, and an empty exit block that all returns
+// and the end of the body lead to. Statements following a return start a
+// new block that is not reachable from the entry. A statement with a ?
+// expression, which returns errors, ends its block with edges to the block
+// of the following statements and to the exit block. A loop consists of a
+// head block holding the loop statement, which leads to the body and to the
+// block following the loop.
+package cfg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+)
+
+type Kind int
+
+const (
+	Entry Kind = iota
+	Body
+	Exit
+	ForHead
+	ForBody
+	ForDone
+)
+
+var kinds = [...]string{
+	Entry:   "entry",
+	Body:    "body",
+	Exit:    "exit",
+	ForHead: "for.head",
+	ForBody: "for.body",
+	ForDone: "for.done",
+}
+
+func (k Kind) String() string {
+	return kinds[k]
+}
+
+// Block is a basic block of a function.
+type Block struct {
+	Index int
+	Kind  Kind
+	Stmts []ast.Statement
+	Succs []*Block
+	// Live reports whether the block is reachable from the entry block.
+	Live bool
+}
+
+// CFG is the control-flow graph of a single function. Blocks[0] is the
+// entry block and Blocks[len(Blocks)-1] the exit block.
+type CFG struct {
+	Func   *ast.FunctionDeclaration
+	Blocks []*Block
+}
+
+// New builds the control-flow graph of fd.
+func New(fd *ast.FunctionDeclaration) *CFG {
+	b := &builder{cfg: &CFG{Func: fd}}
+	b.current = b.newBlock(Entry)
+	exit := &Block{Kind: Exit}
+	b.exit = exit
+
+	if fd.Body != nil {
+		b.block(fd.Body)
+	}
+	b.jump(exit)
+
+	exit.Index = len(b.cfg.Blocks)
+	b.cfg.Blocks = append(b.cfg.Blocks, exit)
+	markLive(b.cfg.Blocks[0])
+
+	return b.cfg
+}
+
+// Program builds the control-flow graphs of all functions of program.
+func Program(program *ast.Program) []*CFG {
+	var res []*CFG
+	for _, decl := range program.TopLevelDeclarations {
+		if fd, ok := decl.(*ast.FunctionDeclaration); ok {
+			res = append(res, New(fd))
+		}
+	}
+	return res
+}
+
+type builder struct {
+	cfg     *CFG
+	current *Block // nil after a jump until the next statement
+	exit    *Block
+}
+
+func (b *builder) newBlock(kind Kind) *Block {
+	block := &Block{Index: len(b.cfg.Blocks), Kind: kind}
+	b.cfg.Blocks = append(b.cfg.Blocks, block)
+	return block
+}
+
+func (b *builder) block(block *ast.BlockStatement) {
+	for _, stmt := range block.Statements {
+		b.statement(stmt)
+	}
+}
+
+func (b *builder) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.BlockStatement:
+		b.block(s)
+		return
+	case *ast.ForStatement:
+		b.forStatement(s)
+		return
+	}
+
+	if b.current == nil {
+		// the statement follows a return
+		b.current = b.newBlock(Body)
+	}
+	b.current.Stmts = append(b.current.Stmts, stmt)
+
+	if _, ok := stmt.(*ast.ReturnStatement); ok {
+		b.jump(b.exit)
+	} else if hasTry(stmt) {
+		next := b.newBlock(Body)
+		b.current.Succs = append(b.current.Succs, next, b.exit)
+		b.current = next
+	}
+}
+
+// hasTry reports whether node contains a ? expression that returns from the
+// function, which excludes those in function literals.
+func hasTry(node ast.Node) bool {
+	res := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.TryExpression:
+			res = true
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return !res
+	})
+	return res
+}
+
+func (b *builder) forStatement(s *ast.ForStatement) {
+	head := b.newBlock(ForHead)
+	head.Stmts = append(head.Stmts, s)
+	b.jump(head)
+
+	body := b.newBlock(ForBody)
+	done := b.newBlock(ForDone)
+	head.Succs = append(head.Succs, body, done)
+	if hasTry(s.Iterable) {
+		head.Succs = append(head.Succs, b.exit)
+	}
+
+	b.current = body
+	b.block(s.Body)
+	b.jump(head)
+	b.current = done
+}
+
+// jump ends the current block with an edge to target.
+func (b *builder) jump(target *Block) {
+	if b.current == nil {
+		return
+	}
+	b.current.Succs = append(b.current.Succs, target)
+	b.current = nil
+}
+
+func markLive(block *Block) {
+	if block.Live {
+		return
+	}
+	block.Live = true
+	for _, succ := range block.Succs {
+		markLive(succ)
+	}
+}
+
+// Format returns a textual representation of the graph, listing each block
+// with its statements and successors.
+func (g *CFG) Format() string {
+	var res strings.Builder
+	for _, block := range g.Blocks {
+		fmt.Fprintf(&res, ".%d: # %s", block.Index, block.Kind)
+		if !block.Live {
+			res.WriteString(" (unreachable)")
+		}
+		res.WriteString("\n")
+
+		for _, stmt := range block.Stmts {
+			res.WriteString("\t" + statementString(stmt) + "\n")
+		}
+		if len(block.Succs) > 0 {
+			res.WriteString("\tsuccs:")
+			for _, succ := range block.Succs {
+				fmt.Fprintf(&res, " %d", succ.Index)
+			}
+			res.WriteString("\n")
+		}
+		res.WriteString("\n")
+	}
+	return res.String()
+}
+
+// statementString returns stmt without its trailing newline, loops are
+// shown without their body, which has blocks of its own.
+func statementString(stmt ast.Statement) string {
+	if loop, ok := stmt.(*ast.ForStatement); ok {
+		return strings.TrimSuffix(loop.String(), " "+loop.Body.String())
+	}
+	return strings.TrimSuffix(stmt.String(), "\n")
+}
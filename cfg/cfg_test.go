@@ -0,0 +1,99 @@
+package cfg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	program, err := parser.NewParser(scanner.NewScanner(src)).Parse()
+	require.NoError(t, err)
+	return program
+}
+
+func TestNew(t *testing.T) {
+	t.Run("builds_straight_line_function", func(t *testing.T) {
+		program := parse(t, "fn main() int {\n\ta = 1;\n\treturn a;\n}")
+		g := New(program.TopLevelDeclarations[0].(*ast.FunctionDeclaration))
+
+		expected := ".0: # entry\n\ta = 1;\n\treturn a;\n\tsuccs: 1\n\n.1: # exit\n\n"
+		assert.Equal(t, expected, g.Format())
+	})
+
+	t.Run("builds_empty_function", func(t *testing.T) {
+		program := parse(t, "fn main() {\n}")
+		g := New(program.TopLevelDeclarations[0].(*ast.FunctionDeclaration))
+
+		assert.Equal(t, 2, len(g.Blocks))
+		assert.Equal(t, []*Block{g.Blocks[1]}, g.Blocks[0].Succs)
+		assert.True(t, g.Blocks[1].Live)
+	})
+
+	t.Run("puts_statements_after_return_into_unreachable_block", func(t *testing.T) {
+		program := parse(t, "fn main() int {\n\treturn 1;\n\ta = 2;\n\treturn a;\n}")
+		g := New(program.TopLevelDeclarations[0].(*ast.FunctionDeclaration))
+
+		expected := ".0: # entry\n\treturn 1;\n\tsuccs: 2\n\n" +
+			".1: # body (unreachable)\n\ta = 2;\n\treturn a;\n\tsuccs: 2\n\n" +
+			".2: # exit\n\n"
+		assert.Equal(t, expected, g.Format())
+	})
+}
+
+func TestNew_errorPropagation(t *testing.T) {
+	program := parse(t, "fn main() error {\n\tvalidate()?;\n\tf := fn() error {\n\t\treturn check()?;\n\t};\n\tfor x in load()? {\n\t}\n\treturn nil;\n}")
+	g := New(program.TopLevelDeclarations[0].(*ast.FunctionDeclaration))
+
+	expected := ".0: # entry\n\tvalidate()?;\n\tsuccs: 1 5\n\n" +
+		".1: # body\n\tf := fn() error {\nreturn check()?;\n}\n;\n\tsuccs: 2\n\n" +
+		".2: # for.head\n\tfor x in load()?\n\tsuccs: 3 4 5\n\n" +
+		".3: # for.body\n\tsuccs: 2\n\n" +
+		".4: # for.done\n\treturn nil;\n\tsuccs: 5\n\n" +
+		".5: # exit\n\n"
+	assert.Equal(t, expected, g.Format())
+}
+
+func TestNew_loops(t *testing.T) {
+	program := parse(t, "fn main() {\n\tn := 0;\n\tfor x in [1, 2] {\n\t\tn = n + x;\n\t}\n\tprintln(n);\n}")
+	g := New(program.TopLevelDeclarations[0].(*ast.FunctionDeclaration))
+
+	expected := ".0: # entry\n\tn := 0;\n\tsuccs: 1\n\n" +
+		".1: # for.head\n\tfor x in [1, 2]\n\tsuccs: 2 3\n\n" +
+		".2: # for.body\n\tn = (n + x);\n\tsuccs: 1\n\n" +
+		".3: # for.done\n\tprintln(n);\n\tsuccs: 4\n\n" +
+		".4: # exit\n\n"
+	assert.Equal(t, expected, g.Format())
+}
+
+func TestProgram(t *testing.T) {
+	program := parse(t, "fn a() {\n}\n\nfn b() {\n}")
+	graphs := Program(program)
+
+	assert.Equal(t, 2, len(graphs))
+	assert.Equal(t, "b", graphs[1].Func.Identifier.Value)
+}
+
+func TestDot(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Dot(&buf, Program(parse(t, "fn main() int {\n\treturn 1;\n\ta = 2;\n}"))))
+
+	expected := `digraph cfg {
+	node [shape=box fontname="monospace"];
+	subgraph cluster_0 {
+		label="main";
+		"main.0" [label="entry\lreturn 1;\l"];
+		"main.1" [label="body\la = 2;\l" style=dashed];
+		"main.2" [label="exit\l"];
+		"main.0" -> "main.2";
+		"main.1" -> "main.2";
+	}
+}
+`
+	assert.Equal(t, expected, buf.String())
+}
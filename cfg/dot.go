@@ -0,0 +1,54 @@
+package cfg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Dot writes the graphs in the Graphviz DOT language, each function is
+// drawn as a cluster of its blocks. Unreachable blocks are dashed.
+func Dot(w io.Writer, graphs []*CFG) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph cfg {")
+	fmt.Fprintln(bw, "\tnode [shape=box fontname=\"monospace\"];")
+	for i, g := range graphs {
+		name := g.Func.Name()
+
+		fmt.Fprintf(bw, "\tsubgraph cluster_%d {\n", i)
+		fmt.Fprintf(bw, "\t\tlabel=%s;\n", quote(name))
+		for _, block := range g.Blocks {
+			label := block.Kind.String() + "\n"
+			for _, stmt := range block.Stmts {
+				label += statementString(stmt) + "\n"
+			}
+
+			style := ""
+			if !block.Live {
+				style = " style=dashed"
+			}
+			fmt.Fprintf(bw, "\t\t%s [label=%s%s];\n", blockID(name, block), quote(label), style)
+		}
+		for _, block := range g.Blocks {
+			for _, succ := range block.Succs {
+				fmt.Fprintf(bw, "\t\t%s -> %s;\n", blockID(name, block), blockID(name, succ))
+			}
+		}
+		fmt.Fprintln(bw, "\t}")
+	}
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+func blockID(function string, block *Block) string {
+	return quote(fmt.Sprintf("%s.%d", function, block.Index))
+}
+
+// quote returns s as a DOT string, lines are left aligned.
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\l`).Replace(s)
+	return `"` + s + `"`
+}
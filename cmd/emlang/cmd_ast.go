@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/muggel/emlang/ast/astdot"
+	"github.com/muggel/emlang/ast/astjson"
+)
+
+func runAst(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the syntax tree as JSON")
+	asDot := flags.Bool("dot", false, "print the syntax tree as a Graphviz DOT graph")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: emlang ast [--json | --dot] [file]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 || *asJSON && *asDot {
+		flags.Usage()
+		return 2
+	}
+
+	program, err := parseFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch {
+	case *asDot:
+		err = astdot.Fprint(os.Stdout, program)
+	case *asJSON:
+		var b []byte
+		if b, err = astjson.MarshalIndent(program, "", "  "); err == nil {
+			fmt.Println(string(b))
+		}
+	default:
+		fmt.Print(program.String())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/muggel/emlang/cfg"
+)
+
+func runCfg(args []string) int {
+	flags := flag.NewFlagSet("cfg", flag.ContinueOnError)
+	asDot := flags.Bool("dot", false, "print the graphs as Graphviz DOT")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: emlang cfg [--dot] [file]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	program, err := parseFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	graphs := cfg.Program(program)
+	if *asDot {
+		if err := cfg.Dot(os.Stdout, graphs); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, g := range graphs {
+		fmt.Printf("fn %s:\n%s", g.Func.Name(), g.Format())
+	}
+	return 0
+}
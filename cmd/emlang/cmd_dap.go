@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/muggel/emlang/dap"
+)
+
+func runDap(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: emlang dap")
+		return 2
+	}
+
+	if err := dap.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/muggel/emlang/debugger"
+)
+
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: emlang debug file")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	// the console reads its commands from the standard input, so the
+	// program has to come from a file
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	program, err := parseSource(path, src)
+	if err == nil {
+		err = checkProgram(path, program)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = debugger.Console(debugger.New(program), os.Stdin, os.Stdout, string(src))
+	if err != nil && !errors.Is(err, debugger.ErrTerminated) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/muggel/emlang/format"
+	"github.com/muggel/emlang/internal/diff"
+)
+
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	showDiff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: emlang fmt [-w] [-d] [path ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "emlang fmt: cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			err = formatSource("<standard input>", src, false, *showDiff)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	code := 0
+	for _, root := range flags.Args() {
+		// directories are searched for .em files, explicit files are always formatted
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".em" && path != root {
+				return err
+			}
+			if err := formatFile(path, *write, *showDiff); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 1
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+func formatFile(path string, write, showDiff bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return formatSource(path, src, write, showDiff)
+}
+
+// formatSource formats src and, depending on the flags, prints the result,
+// prints a diff against src or writes the result back to path.
+func formatSource(path string, src []byte, write, showDiff bool) error {
+	res, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s:%w", path, err)
+	}
+
+	if showDiff {
+		os.Stdout.Write(diff.Unified(path+".orig", src, path, res))
+	}
+	if write {
+		if bytes.Equal(src, res) {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, res, info.Mode().Perm())
+	}
+	if !showDiff {
+		os.Stdout.Write(res)
+	}
+	return nil
+}
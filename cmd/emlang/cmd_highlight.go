@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/muggel/emlang/highlight"
+)
+
+func runHighlight(args []string) int {
+	flags := flag.NewFlagSet("highlight", flag.ContinueOnError)
+	asHTML := flags.Bool("html", false, "print the source as HTML")
+	asANSI := flags.Bool("ansi", false, "print the source with ANSI colors")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: emlang highlight --html | --ansi [file]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 || *asHTML == *asANSI {
+		flags.Usage()
+		return 2
+	}
+
+	var (
+		src []byte
+		err error
+	)
+	if path := flags.Arg(0); path == "" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err == nil {
+		if *asHTML {
+			err = highlight.HTML(os.Stdout, string(src))
+		} else {
+			err = highlight.ANSI(os.Stdout, string(src))
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
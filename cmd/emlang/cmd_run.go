@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/muggel/emlang/evaluator"
+	"github.com/muggel/emlang/loader"
+	"github.com/muggel/emlang/object"
+)
+
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	var limits evaluator.Limits
+	flags.IntVar(&limits.MaxSteps, "max-steps", 0, "maximum number of statements to execute, 0 for no limit")
+	flags.IntVar(&limits.MaxDepth, "max-depth", evaluator.DefaultMaxDepth, "maximum call depth")
+	flags.Int64Var(&limits.MaxMemory, "max-memory", 0, "maximum number of bytes to allocate, 0 for no limit")
+	timeout := flags.Duration("timeout", 0, "abort the program after this duration, 0 for no timeout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: emlang run [flags] [file | dir]")
+		fmt.Fprintln(flags.Output(), "\nImports are resolved relative to the directory of the file, a directory")
+		fmt.Fprintln(flags.Output(), "runs its main"+loader.Ext+" file.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	module, err := loadModule(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	e := loader.NewEvaluator(module)
+	e.Limits = limits
+	result, err := e.Run(ctx)
+	if err != nil {
+		printRuntimeError(e.File, err)
+		return 1
+	}
+	if err, ok := result.(*object.Error); ok {
+		// main returned an error value
+		fmt.Fprintln(os.Stderr, "error:", err.Message)
+		return 1
+	}
+	if result != object.VOID && result != object.NIL {
+		fmt.Println(result.Inspect())
+	}
+	return 0
+}
+
+// loadModule loads the program at path, which is a file, a directory with a
+// main file or the standard input if path is empty.
+func loadModule(path string) (*loader.Module, error) {
+	if path == "" {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return (&loader.Loader{Root: "."}).LoadSource(sourceName(path), src)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return (&loader.Loader{Root: path}).Load(filepath.Join(path, "main"+loader.Ext))
+	}
+	return (&loader.Loader{Root: filepath.Dir(path)}).Load(path)
+}
+
+// printRuntimeError prints err prefixed with the name of the file it
+// occurred in, followed by the stack trace of a RuntimeError.
+func printRuntimeError(file string, err error) {
+	var rerr *evaluator.RuntimeError
+	if errors.As(err, &rerr) && len(rerr.Stack) > 0 {
+		file = rerr.Stack[0].File
+	}
+	fmt.Fprintf(os.Stderr, "%s:%v\n", file, err)
+	if rerr != nil {
+		fmt.Fprint(os.Stderr, rerr.StackTrace())
+	}
+}
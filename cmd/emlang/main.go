@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/types"
+)
+
+const usage = `Welcome to EmLang 🦥
+
+Usage:
+
+	emlang <command> [arguments]
+
+The commands are:
+
+	ast        print the syntax tree of a source file
+	cfg        print the control-flow graphs of the functions of a source file
+	dap        run the debug adapter on stdin and stdout
+	debug      run a source file in the interactive debugger
+	fmt        format emlang source files
+	highlight  print a source file with syntax highlighting
+	lsp        run the language server on stdin and stdout
+	run        run a source file or the main file of a directory
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		return
+	}
+
+	var code int
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "ast":
+		code = runAst(args)
+	case "cfg":
+		code = runCfg(args)
+	case "dap":
+		code = runDap(args)
+	case "debug":
+		code = runDebug(args)
+	case "fmt":
+		code = runFmt(args)
+	case "highlight":
+		code = runHighlight(args)
+	case "lsp":
+		code = runLsp(args)
+	case "run":
+		code = runRun(args)
+	default:
+		fmt.Fprintf(os.Stderr, "emlang: unknown command %q\n\n%s", cmd, usage)
+		code = 2
+	}
+	os.Exit(code)
+}
+
+// parseFile parses the file at path, or the standard input if path is empty.
+func parseFile(path string) (*ast.Program, error) {
+	var (
+		src []byte
+		err error
+	)
+	if path == "" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSource(path, src)
+}
+
+// parseSource parses src, errors are prefixed with the file name path.
+func parseSource(path string, src []byte) (*ast.Program, error) {
+	program, err := parser.NewParser(scanner.NewScanner(string(src))).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", sourceName(path), err)
+	}
+	return program, nil
+}
+
+// checkProgram type checks program, errors are prefixed with the file name
+// path.
+func checkProgram(path string, program *ast.Program) error {
+	if _, err := (&types.Config{}).Check(program); err != nil {
+		return fmt.Errorf("%s:%w", sourceName(path), err)
+	}
+	return nil
+}
+
+func sourceName(path string) string {
+	if path == "" {
+		return "<standard input>"
+	}
+	return path
+}
@@ -0,0 +1,350 @@
+// Package dap implements a Debug Adapter Protocol server for emlang.
+//
+// The server reads requests from a reader and writes responses and events to
+// a writer, usually stdin and stdout. A session launches a single program,
+// which runs under control of the debugger package on a single thread. The
+// initialized event is sent once the program is launched, the program starts
+// after the client sent configurationDone.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/muggel/emlang/debugger"
+	"github.com/muggel/emlang/evaluator"
+	"github.com/muggel/emlang/internal/framing"
+	"github.com/muggel/emlang/loader"
+	"github.com/muggel/emlang/object"
+)
+
+// threadID is the ID of the only thread of a program.
+const threadID = 1
+
+var errNotLaunched = errors.New("no program launched")
+
+type Server struct {
+	in *bufio.Reader
+
+	mu            sync.Mutex // guards the fields below, held while writing
+	out           io.Writer
+	seq           int
+	disconnecting bool
+
+	// owned by Run
+	path        string
+	debugger    *debugger.Debugger
+	stopOnEntry bool
+	configured  bool
+	started     bool
+	done        chan struct{} // closed when all events of the program were sent
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out}
+}
+
+// Run handles requests until the client sends disconnect or the input is
+// closed. A program that is still running is terminated.
+func (s *Server) Run() error {
+	defer s.stop()
+
+	for {
+		body, err := framing.Read(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(req *request) error {
+	var (
+		body interface{}
+		err  error
+		// resume, if set, is called after the response was sent, so that
+		// the response precedes the events of the resumed program.
+		resume func() error
+	)
+	switch req.Command {
+	case "initialize":
+		body = Capabilities{SupportsConfigurationDoneRequest: true}
+	case "launch":
+		var args LaunchArguments
+		if err = decodeArguments(req.Arguments, &args); err == nil {
+			err = s.launch(args)
+		}
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err = decodeArguments(req.Arguments, &args); err == nil {
+			body, err = s.setBreakpoints(args)
+		}
+	case "configurationDone":
+		s.configured = true
+	case "threads":
+		body = ThreadsResponse{Threads: []Thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		var args StackTraceArguments
+		if err = decodeArguments(req.Arguments, &args); err == nil {
+			body, err = s.stackTrace()
+		}
+	case "scopes":
+		var args ScopesArguments
+		if err = decodeArguments(req.Arguments, &args); err == nil {
+			body = ScopesResponse{Scopes: []Scope{{Name: "Locals", VariablesReference: args.FrameID}}}
+		}
+	case "variables":
+		var args VariablesArguments
+		if err = decodeArguments(req.Arguments, &args); err == nil {
+			body, err = s.variables(args)
+		}
+	case "continue":
+		body = ContinueResponse{AllThreadsContinued: true}
+		resume, err = s.resumer(func(d *debugger.Debugger) error { return d.Continue() })
+	case "next":
+		resume, err = s.resumer(func(d *debugger.Debugger) error { return d.StepOver() })
+	case "stepIn":
+		resume, err = s.resumer(func(d *debugger.Debugger) error { return d.StepIn() })
+	case "stepOut":
+		resume, err = s.resumer(func(d *debugger.Debugger) error { return d.StepOut() })
+	case "disconnect":
+		s.stop()
+	default:
+		err = fmt.Errorf("unsupported command %s", req.Command)
+	}
+
+	if err != nil {
+		return s.reply(req, nil, err)
+	}
+	if err := s.reply(req, body, nil); err != nil {
+		return err
+	}
+
+	switch req.Command {
+	case "launch":
+		if err := s.event("initialized", nil); err != nil {
+			return err
+		}
+		fallthrough
+	case "configurationDone":
+		if s.debugger != nil && s.configured && !s.started {
+			s.start()
+		}
+	}
+	if resume != nil {
+		return resume()
+	}
+	return nil
+}
+
+func decodeArguments(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func (s *Server) launch(args LaunchArguments) error {
+	if s.debugger != nil {
+		return errors.New("program already launched")
+	}
+
+	// parse and check the program, the imported modules are relative to
+	// its directory
+	module, err := (&loader.Loader{Root: filepath.Dir(args.Program)}).Load(args.Program)
+	if err != nil {
+		return err
+	}
+
+	s.path = args.Program
+	s.debugger = debugger.New(module.Program)
+	s.debugger.SetOutput(programOutput{s})
+	s.stopOnEntry = args.StopOnEntry
+	return nil
+}
+
+// setBreakpoints replaces the breakpoints of the program. Breakpoints on
+// lines without a statement are reported as not verified.
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) (SetBreakpointsResponse, error) {
+	if s.debugger == nil {
+		return SetBreakpointsResponse{}, errNotLaunched
+	}
+
+	lines := make([]int, len(args.Breakpoints))
+	for i, bp := range args.Breakpoints {
+		lines[i] = bp.Line
+	}
+	verified := map[int]bool{}
+	for _, line := range s.debugger.SetBreakpoints(lines) {
+		verified[line] = true
+	}
+
+	res := SetBreakpointsResponse{Breakpoints: []Breakpoint{}}
+	for _, line := range lines {
+		res.Breakpoints = append(res.Breakpoints, Breakpoint{Verified: verified[line], Line: line})
+	}
+	return res, nil
+}
+
+// stackTrace returns the frames of the paused program. Frame IDs start at
+// one, zero is not a valid reference in the protocol.
+func (s *Server) stackTrace() (StackTraceResponse, error) {
+	if s.debugger == nil {
+		return StackTraceResponse{}, errNotLaunched
+	}
+	frames, err := s.debugger.StackTrace()
+	if err != nil {
+		return StackTraceResponse{}, err
+	}
+
+	res := StackTraceResponse{StackFrames: []StackFrame{}, TotalFrames: len(frames)}
+	for i, frame := range frames {
+		res.StackFrames = append(res.StackFrames, StackFrame{
+			ID:     i + 1,
+			Name:   frame.Function,
+			Source: Source{Name: filepath.Base(s.path), Path: s.path},
+			Line:   frame.Pos.Line,
+			Column: frame.Pos.Column,
+		})
+	}
+	return res, nil
+}
+
+// variables returns the locals of a frame, the variables reference of the
+// Locals scope of a frame is the frame ID.
+func (s *Server) variables(args VariablesArguments) (VariablesResponse, error) {
+	if s.debugger == nil {
+		return VariablesResponse{}, errNotLaunched
+	}
+	vars, err := s.debugger.Locals(args.VariablesReference - 1)
+	if err != nil {
+		return VariablesResponse{}, err
+	}
+
+	res := VariablesResponse{Variables: []Variable{}}
+	for _, v := range vars {
+		res.Variables = append(res.Variables, Variable{Name: v.Name, Value: v.Value})
+	}
+	return res, nil
+}
+
+// resumer returns a function that resumes the program with fn, or an error
+// if the program is not paused.
+func (s *Server) resumer(fn func(d *debugger.Debugger) error) (func() error, error) {
+	if s.debugger == nil {
+		return nil, errNotLaunched
+	}
+	if !s.debugger.Stopped() {
+		return nil, debugger.ErrNotStopped
+	}
+	return func() error { return fn(s.debugger) }, nil
+}
+
+func (s *Server) start() {
+	s.started = true
+	s.done = make(chan struct{})
+	s.debugger.Start(s.stopOnEntry)
+	go s.forward(s.debugger)
+}
+
+// stop terminates the program if it was started and waits until it exited.
+func (s *Server) stop() {
+	if !s.started {
+		return
+	}
+	s.mu.Lock()
+	s.disconnecting = true
+	s.mu.Unlock()
+
+	// a running program is terminated by forward when it stops
+	_ = s.debugger.Terminate()
+	<-s.done
+}
+
+// forward sends the events of the program to the client.
+func (s *Server) forward(d *debugger.Debugger) {
+	defer close(s.done)
+
+	for ev := range d.Events() {
+		if ev.Kind == debugger.Stopped {
+			s.mu.Lock()
+			disconnecting := s.disconnecting
+			s.mu.Unlock()
+			if disconnecting {
+				_ = d.Terminate()
+				continue
+			}
+			_ = s.event("stopped", StoppedEvent{Reason: string(ev.Reason), ThreadID: threadID, AllThreadsStopped: true})
+			continue
+		}
+
+		exitCode := 0
+		switch {
+		case ev.Err == nil && ev.Result != object.VOID:
+			_ = s.event("output", OutputEvent{Category: "stdout", Output: ev.Result.Inspect() + "\n"})
+		case ev.Err != nil && !errors.Is(ev.Err, debugger.ErrTerminated):
+			output := ev.Err.Error() + "\n"
+			var rerr *evaluator.RuntimeError
+			if errors.As(ev.Err, &rerr) {
+				output += rerr.StackTrace()
+			}
+			_ = s.event("output", OutputEvent{Category: "stderr", Output: output})
+			exitCode = 1
+		case ev.Err != nil:
+			exitCode = 1
+		}
+		_ = s.event("exited", ExitedEvent{ExitCode: exitCode})
+		_ = s.event("terminated", nil)
+	}
+}
+
+// programOutput sends what the program prints to the client as output
+// events.
+type programOutput struct {
+	s *Server
+}
+
+func (o programOutput) Write(p []byte) (int, error) {
+	if err := o.s.event("output", OutputEvent{Category: "stdout", Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *Server) reply(req *request, body interface{}, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	resp := response{Seq: s.seq, Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	return framing.Write(s.out, resp)
+}
+
+func (s *Server) event(name string, body interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	return framing.Write(s.out, event{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
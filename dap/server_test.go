@@ -0,0 +1,239 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muggel/emlang/internal/framing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `fn helper() int {
+	x := 42;
+	return x;
+}
+
+fn main() int {
+	foo := helper();
+	bar := foo;
+	return bar;
+}
+`
+
+// step is a request sent by the client and the messages it expects in
+// response, in order. $PROGRAM is replaced by the path of the program as a
+// JSON string, $PATH by the bare path.
+type step struct {
+	request  string
+	expected []string
+}
+
+// run starts a server and plays the script against it.
+func run(t *testing.T, src string, script []step) error {
+	path := filepath.Join(t.TempDir(), "main.em")
+	require.NoError(t, os.WriteFile(path, []byte(src), 0o644))
+	quoted, _ := json.Marshal(path)
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+	messages := make(chan []byte, 100)
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			body, err := framing.Read(r)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- body
+		}
+	}()
+	defer inW.Close()
+
+	for _, step := range script {
+		replacer := strings.NewReplacer("$PROGRAM", string(quoted), "$PATH", path)
+		req := replacer.Replace(step.request)
+		require.NoError(t, framing.Write(inW, json.RawMessage(req)))
+
+		for _, expected := range step.expected {
+			select {
+			case msg, ok := <-messages:
+				require.True(t, ok, "server closed the connection, expected %s", expected)
+				expected = replacer.Replace(expected)
+				require.JSONEq(t, expected, string(msg), "in response to %s", req)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timeout waiting for %s", expected)
+			}
+		}
+	}
+
+	inW.Close()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the server to stop")
+		return nil
+	}
+}
+
+func TestServer_session(t *testing.T) {
+	err := run(t, source, []step{
+		{
+			`{"seq":1,"type":"request","command":"initialize","arguments":{"adapterID":"emlang"}}`,
+			[]string{`{"seq":1,"type":"response","request_seq":1,"success":true,"command":"initialize","body":{"supportsConfigurationDoneRequest":true}}`},
+		},
+		{
+			`{"seq":2,"type":"request","command":"launch","arguments":{"program":$PROGRAM,"stopOnEntry":true}}`,
+			[]string{
+				`{"seq":2,"type":"response","request_seq":2,"success":true,"command":"launch"}`,
+				`{"seq":3,"type":"event","event":"initialized"}`,
+			},
+		},
+		{
+			`{"seq":3,"type":"request","command":"setBreakpoints","arguments":{"source":{"path":$PROGRAM},"breakpoints":[{"line":3},{"line":5}]}}`,
+			[]string{`{"seq":4,"type":"response","request_seq":3,"success":true,"command":"setBreakpoints","body":{"breakpoints":[{"verified":true,"line":3},{"verified":false,"line":5}]}}`},
+		},
+		{
+			`{"seq":4,"type":"request","command":"configurationDone"}`,
+			[]string{
+				`{"seq":5,"type":"response","request_seq":4,"success":true,"command":"configurationDone"}`,
+				`{"seq":6,"type":"event","event":"stopped","body":{"reason":"entry","threadId":1,"allThreadsStopped":true}}`,
+			},
+		},
+		{
+			`{"seq":5,"type":"request","command":"threads"}`,
+			[]string{`{"seq":7,"type":"response","request_seq":5,"success":true,"command":"threads","body":{"threads":[{"id":1,"name":"main"}]}}`},
+		},
+		{
+			`{"seq":6,"type":"request","command":"continue","arguments":{"threadId":1}}`,
+			[]string{
+				`{"seq":8,"type":"response","request_seq":6,"success":true,"command":"continue","body":{"allThreadsContinued":true}}`,
+				`{"seq":9,"type":"event","event":"stopped","body":{"reason":"breakpoint","threadId":1,"allThreadsStopped":true}}`,
+			},
+		},
+		{
+			`{"seq":7,"type":"request","command":"stackTrace","arguments":{"threadId":1}}`,
+			[]string{`{"seq":10,"type":"response","request_seq":7,"success":true,"command":"stackTrace","body":{"stackFrames":[
+				{"id":1,"name":"helper","source":{"name":"main.em","path":$PROGRAM},"line":3,"column":2},
+				{"id":2,"name":"main","source":{"name":"main.em","path":$PROGRAM},"line":7,"column":2}
+			],"totalFrames":2}}`},
+		},
+		{
+			`{"seq":8,"type":"request","command":"scopes","arguments":{"frameId":1}}`,
+			[]string{`{"seq":11,"type":"response","request_seq":8,"success":true,"command":"scopes","body":{"scopes":[{"name":"Locals","variablesReference":1,"expensive":false}]}}`},
+		},
+		{
+			`{"seq":9,"type":"request","command":"variables","arguments":{"variablesReference":1}}`,
+			[]string{`{"seq":12,"type":"response","request_seq":9,"success":true,"command":"variables","body":{"variables":[{"name":"x","value":"42","variablesReference":0}]}}`},
+		},
+		{
+			`{"seq":10,"type":"request","command":"next","arguments":{"threadId":1}}`,
+			[]string{
+				`{"seq":13,"type":"response","request_seq":10,"success":true,"command":"next"}`,
+				`{"seq":14,"type":"event","event":"stopped","body":{"reason":"step","threadId":1,"allThreadsStopped":true}}`,
+			},
+		},
+		{
+			`{"seq":11,"type":"request","command":"variables","arguments":{"variablesReference":1}}`,
+			[]string{`{"seq":15,"type":"response","request_seq":11,"success":true,"command":"variables","body":{"variables":[{"name":"foo","value":"42","variablesReference":0}]}}`},
+		},
+		{
+			`{"seq":12,"type":"request","command":"continue","arguments":{"threadId":1}}`,
+			[]string{
+				`{"seq":16,"type":"response","request_seq":12,"success":true,"command":"continue","body":{"allThreadsContinued":true}}`,
+				`{"seq":17,"type":"event","event":"output","body":{"category":"stdout","output":"42\n"}}`,
+				`{"seq":18,"type":"event","event":"exited","body":{"exitCode":0}}`,
+				`{"seq":19,"type":"event","event":"terminated"}`,
+			},
+		},
+		{
+			`{"seq":13,"type":"request","command":"disconnect"}`,
+			[]string{`{"seq":20,"type":"response","request_seq":13,"success":true,"command":"disconnect"}`},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestServer_stepIn(t *testing.T) {
+	err := run(t, source, []step{
+		{`{"seq":1,"type":"request","command":"launch","arguments":{"program":$PROGRAM,"stopOnEntry":true}}`, []string{
+			`{"seq":1,"type":"response","request_seq":1,"success":true,"command":"launch"}`,
+			`{"seq":2,"type":"event","event":"initialized"}`,
+		}},
+		{`{"seq":2,"type":"request","command":"configurationDone"}`, []string{
+			`{"seq":3,"type":"response","request_seq":2,"success":true,"command":"configurationDone"}`,
+			`{"seq":4,"type":"event","event":"stopped","body":{"reason":"entry","threadId":1,"allThreadsStopped":true}}`,
+		}},
+		{`{"seq":3,"type":"request","command":"stepIn","arguments":{"threadId":1}}`, []string{
+			`{"seq":5,"type":"response","request_seq":3,"success":true,"command":"stepIn"}`,
+			`{"seq":6,"type":"event","event":"stopped","body":{"reason":"step","threadId":1,"allThreadsStopped":true}}`,
+		}},
+		{`{"seq":4,"type":"request","command":"stackTrace","arguments":{"threadId":1}}`, []string{
+			`{"seq":7,"type":"response","request_seq":4,"success":true,"command":"stackTrace","body":{"stackFrames":[
+				{"id":1,"name":"helper","source":{"name":"main.em","path":$PROGRAM},"line":2,"column":2},
+				{"id":2,"name":"main","source":{"name":"main.em","path":$PROGRAM},"line":7,"column":2}
+			],"totalFrames":2}}`,
+		}},
+		// disconnecting terminates the paused program
+		{`{"seq":5,"type":"request","command":"disconnect"}`, []string{
+			`{"seq":8,"type":"event","event":"exited","body":{"exitCode":1}}`,
+			`{"seq":9,"type":"event","event":"terminated"}`,
+			`{"seq":10,"type":"response","request_seq":5,"success":true,"command":"disconnect"}`,
+		}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestServer_output(t *testing.T) {
+	err := run(t, "fn fail(x int) int {\n\treturn 1 / x;\n}\n\nfn main() int {\n\tprintln(\"dividing\");\n\treturn fail(0);\n}\n", []step{
+		{`{"seq":1,"type":"request","command":"launch","arguments":{"program":$PROGRAM}}`, []string{
+			`{"seq":1,"type":"response","request_seq":1,"success":true,"command":"launch"}`,
+			`{"seq":2,"type":"event","event":"initialized"}`,
+		}},
+		{`{"seq":2,"type":"request","command":"configurationDone"}`, []string{
+			`{"seq":3,"type":"response","request_seq":2,"success":true,"command":"configurationDone"}`,
+			`{"seq":4,"type":"event","event":"output","body":{"category":"stdout","output":"dividing\n"}}`,
+			`{"seq":5,"type":"event","event":"output","body":{"category":"stderr","output":"2:11: integer divide by zero\n\tat fail (2:11)\n\tat main (7:9)\n"}}`,
+			`{"seq":6,"type":"event","event":"exited","body":{"exitCode":1}}`,
+			`{"seq":7,"type":"event","event":"terminated"}`,
+		}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestServer_errors(t *testing.T) {
+	err := run(t, "fn main() {\n\treturn 1\n}\n", []step{
+		{`{"seq":1,"type":"request","command":"setBreakpoints","arguments":{"source":{"path":$PROGRAM},"breakpoints":[{"line":2}]}}`, []string{
+			`{"seq":1,"type":"response","request_seq":1,"success":false,"command":"setBreakpoints","message":"no program launched"}`,
+		}},
+		{`{"seq":2,"type":"request","command":"launch","arguments":{"program":$PROGRAM}}`, []string{
+			`{"seq":2,"type":"response","request_seq":2,"success":false,"command":"launch","message":"$PATH:3:1: expected semicolon"}`,
+		}},
+		{`{"seq":3,"type":"request","command":"evaluate","arguments":{"expression":"x"}}`, []string{
+			`{"seq":3,"type":"response","request_seq":3,"success":false,"command":"evaluate","message":"unsupported command evaluate"}`,
+		}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestServer_typeErrors(t *testing.T) {
+	err := run(t, "fn main() int {\n\treturn len();\n}\n", []step{
+		{`{"seq":1,"type":"request","command":"launch","arguments":{"program":$PROGRAM}}`, []string{
+			`{"seq":1,"type":"response","request_seq":1,"success":false,"command":"launch","message":"$PATH:2:9: not enough arguments in call to len"}`,
+		}},
+	})
+	assert.NoError(t, err)
+}
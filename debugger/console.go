@@ -0,0 +1,190 @@
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/muggel/emlang/evaluator"
+)
+
+const consoleHelp = `Commands:
+  break, b <line>   set a breakpoint
+  clear <line>      remove a breakpoint
+  continue, c       run until the next breakpoint
+  step, s           step to the next statement, entering calls
+  next, n           step to the next statement in the current function
+  out, o            run until the current function returns
+  stack, bt         print the call stack
+  locals, l         print the local variables
+  print, p <name>   print a local variable
+  quit, q           stop the program
+  help, h           print this help
+`
+
+// Console runs the program of d interactively. Commands are read line by
+// line from in, their output and the output of the program is written to
+// out. The program stops on entry so that breakpoints can be set before it
+// runs. Console returns the error the program exited with, or nil if it
+// completed.
+func Console(d *Debugger, in io.Reader, out io.Writer, src string) error {
+	c := &console{d: d, out: out, lines: strings.Split(src, "\n")}
+	scanner := bufio.NewScanner(in)
+
+	d.SetOutput(out)
+	d.Start(true)
+	for ev := range d.Events() {
+		if ev.Kind == Exited {
+			if ev.Err != nil {
+				fmt.Fprintf(out, "program exited: %v\n", ev.Err)
+				var rerr *evaluator.RuntimeError
+				if errors.As(ev.Err, &rerr) {
+					fmt.Fprint(out, rerr.StackTrace())
+				}
+			} else {
+				fmt.Fprintf(out, "program exited: %s\n", ev.Result.Inspect())
+			}
+			return ev.Err
+		}
+
+		c.printStop(ev)
+		for resumed := false; !resumed; {
+			fmt.Fprint(out, "(emdb) ")
+			if !scanner.Scan() {
+				// end of input ends the session like quit
+				fmt.Fprintln(out)
+				_ = d.Terminate()
+				break
+			}
+			resumed = c.exec(strings.Fields(scanner.Text()))
+		}
+	}
+	return nil
+}
+
+type console struct {
+	d     *Debugger
+	out   io.Writer
+	lines []string
+}
+
+func (c *console) printStop(ev Event) {
+	fmt.Fprintf(c.out, "stopped at %s (%s)\n", ev.Pos, ev.Reason)
+	if n := ev.Pos.Line; n >= 1 && n <= len(c.lines) {
+		fmt.Fprintf(c.out, "%4d\t%s\n", n, c.lines[n-1])
+	}
+}
+
+// exec runs a single command and reports whether it resumed the program.
+func (c *console) exec(fields []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+
+	var err error
+	switch cmd, args := fields[0], fields[1:]; cmd {
+	case "break", "b":
+		err = c.setBreakpoint(args, true)
+	case "clear":
+		err = c.setBreakpoint(args, false)
+	case "continue", "c":
+		return c.resume(c.d.Continue())
+	case "step", "s":
+		return c.resume(c.d.StepIn())
+	case "next", "n":
+		return c.resume(c.d.StepOver())
+	case "out", "o":
+		return c.resume(c.d.StepOut())
+	case "quit", "q":
+		return c.resume(c.d.Terminate())
+	case "stack", "bt":
+		err = c.stack()
+	case "locals", "l":
+		err = c.locals("")
+	case "print", "p":
+		if len(args) != 1 {
+			err = fmt.Errorf("usage: print <name>")
+			break
+		}
+		err = c.locals(args[0])
+	case "help", "h":
+		fmt.Fprint(c.out, consoleHelp)
+	default:
+		err = fmt.Errorf("unknown command %q, try help", cmd)
+	}
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+	}
+	return false
+}
+
+func (c *console) resume(err error) bool {
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return false
+	}
+	return true
+}
+
+func (c *console) setBreakpoint(args []string, set bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: break <line>")
+	}
+	line, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid line %q", args[0])
+	}
+
+	var lines []int
+	for _, l := range c.d.Breakpoints() {
+		if l != line {
+			lines = append(lines, l)
+		}
+	}
+	if !set {
+		c.d.SetBreakpoints(lines)
+		fmt.Fprintf(c.out, "breakpoint at line %d cleared\n", line)
+		return nil
+	}
+
+	if len(c.d.SetBreakpoints(append(lines, line))) == len(lines) {
+		return fmt.Errorf("no statement at line %d", line)
+	}
+	fmt.Fprintf(c.out, "breakpoint set at line %d\n", line)
+	return nil
+}
+
+func (c *console) stack() error {
+	frames, err := c.d.StackTrace()
+	if err != nil {
+		return err
+	}
+	for i, frame := range frames {
+		fmt.Fprintf(c.out, "#%d %s() at %s\n", i, frame.Function, frame.Pos)
+	}
+	return nil
+}
+
+// locals prints the variables of the innermost frame, or only the variable
+// called name if name is not empty.
+func (c *console) locals(name string) error {
+	vars, err := c.d.Locals(0)
+	if err != nil {
+		return err
+	}
+	for _, v := range vars {
+		if name == "" || v.Name == name {
+			fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value)
+			if name != "" {
+				return nil
+			}
+		}
+	}
+	if name != "" {
+		return fmt.Errorf("undefined variable %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package debugger runs programs under control of a client that can set
+// line breakpoints, step through statements and inspect the call stack and
+// local variables while the program is paused.
+//
+// The program runs on its own goroutine. Whenever it pauses, a Stopped event
+// is sent on the Events channel, the state of the program can then be
+// inspected until one of Continue, StepIn, StepOver, StepOut or Terminate
+// resumes it. The last event is Exited, after which the channel is closed.
+package debugger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/evaluator"
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/token"
+)
+
+var (
+	// ErrNotStopped is returned when the program state is accessed or the
+	// program is resumed while it is running.
+	ErrNotStopped = errors.New("debugger: program is not stopped")
+	// ErrTerminated is the error of the Exited event after Terminate.
+	ErrTerminated = errors.New("debugger: program terminated")
+)
+
+type EventKind int
+
+const (
+	Stopped EventKind = iota
+	Exited
+)
+
+type StopReason string
+
+const (
+	ReasonEntry      StopReason = "entry"
+	ReasonBreakpoint StopReason = "breakpoint"
+	ReasonStep       StopReason = "step"
+)
+
+type Event struct {
+	Kind EventKind
+
+	// set for Stopped events
+	Reason StopReason
+	Pos    token.Pos
+
+	// set for Exited events
+	Result object.Object
+	Err    error
+}
+
+// StackFrame describes a function call of the paused program.
+type StackFrame struct {
+	Function string
+	Pos      token.Pos
+}
+
+type Variable struct {
+	Name  string
+	Value string
+}
+
+type action int
+
+const (
+	actionContinue action = iota
+	actionStepIn
+	actionStepOver
+	actionStepOut
+	actionTerminate
+)
+
+type Debugger struct {
+	eval   *evaluator.Evaluator
+	lines  map[int]bool // lines that hold a statement
+	events chan Event
+	resume chan action
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	stopped     bool
+
+	// owned by the program goroutine
+	mode      action
+	modeDepth int
+}
+
+func New(program *ast.Program) *Debugger {
+	d := &Debugger{
+		eval:        evaluator.New(program),
+		lines:       map[int]bool{},
+		events:      make(chan Event),
+		resume:      make(chan action),
+		breakpoints: map[int]bool{},
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if stmt, ok := node.(ast.Statement); ok {
+			if _, isBlock := stmt.(*ast.BlockStatement); !isBlock {
+				d.lines[ast.Start(stmt).Line] = true
+			}
+		}
+		return true
+	})
+	d.eval.Hook = d.hook
+	return d
+}
+
+// Events returns the channel on which the debugger reports stops and the
+// end of the program.
+func (d *Debugger) Events() <-chan Event {
+	return d.events
+}
+
+// SetOutput sets the writer that print and println of the program write to,
+// it has to be called before Start.
+func (d *Debugger) SetOutput(w io.Writer) {
+	d.eval.Out = w
+}
+
+// Start runs the main function of the program. With stopOnEntry the program
+// pauses before its first statement.
+func (d *Debugger) Start(stopOnEntry bool) {
+	d.mode = actionContinue
+	if stopOnEntry {
+		d.mode = actionStepIn
+	}
+
+	go func() {
+		result, err := d.eval.Run(context.Background())
+		d.events <- Event{Kind: Exited, Result: result, Err: err}
+		close(d.events)
+	}()
+}
+
+// SetBreakpoints replaces all breakpoints with breakpoints on the given
+// lines. Only lines that hold a statement can have a breakpoint, the lines
+// that were accepted are returned.
+func (d *Debugger) SetBreakpoints(lines []int) []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = map[int]bool{}
+	var verified []int
+	for _, line := range lines {
+		if d.lines[line] {
+			d.breakpoints[line] = true
+			verified = append(verified, line)
+		}
+	}
+	return verified
+}
+
+// Breakpoints returns the lines with a breakpoint in ascending order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var lines []int
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Continue resumes the program until the next breakpoint.
+func (d *Debugger) Continue() error { return d.send(actionContinue) }
+
+// StepIn resumes the program until the next statement, entering calls.
+func (d *Debugger) StepIn() error { return d.send(actionStepIn) }
+
+// StepOver resumes the program until the next statement in the current
+// function, or its caller if the function returns.
+func (d *Debugger) StepOver() error { return d.send(actionStepOver) }
+
+// StepOut resumes the program until the current function returned.
+func (d *Debugger) StepOut() error { return d.send(actionStepOut) }
+
+// Terminate stops the program, it exits with ErrTerminated.
+func (d *Debugger) Terminate() error { return d.send(actionTerminate) }
+
+func (d *Debugger) send(a action) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.mu.Unlock()
+		return ErrNotStopped
+	}
+	d.stopped = false
+	d.mu.Unlock()
+
+	d.resume <- a
+	return nil
+}
+
+// StackTrace returns the calls of the paused program, innermost first.
+func (d *Debugger) StackTrace() ([]StackFrame, error) {
+	if !d.Stopped() {
+		return nil, ErrNotStopped
+	}
+
+	frames := d.eval.Frames()
+	res := make([]StackFrame, len(frames))
+	for i, frame := range frames {
+		res[len(frames)-1-i] = StackFrame{Function: frame.Function.Name(), Pos: frame.Pos}
+	}
+	return res, nil
+}
+
+// Locals returns the variables of a frame of the paused program in
+// alphabetical order. Frames are numbered like in StackTrace, zero is the
+// innermost call.
+func (d *Debugger) Locals(frame int) ([]Variable, error) {
+	if !d.Stopped() {
+		return nil, ErrNotStopped
+	}
+
+	frames := d.eval.Frames()
+	if frame < 0 || frame >= len(frames) {
+		return nil, errors.New("debugger: no such frame")
+	}
+	env := frames[len(frames)-1-frame].Env
+
+	var res []Variable
+	for _, name := range env.Names() {
+		val, _ := env.Get(name)
+		res = append(res, Variable{Name: name, Value: val.Inspect()})
+	}
+	return res, nil
+}
+
+// Stopped reports whether the program is paused.
+func (d *Debugger) Stopped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stopped
+}
+
+// hook runs on the program goroutine before every statement.
+func (d *Debugger) hook(stmt ast.Statement) error {
+	depth := len(d.eval.Frames())
+	pos := ast.Start(stmt)
+
+	var reason StopReason
+	switch {
+	case d.mode == actionStepIn,
+		d.mode == actionStepOver && depth <= d.modeDepth,
+		d.mode == actionStepOut && depth < d.modeDepth:
+		reason = ReasonStep
+		if d.modeDepth == 0 {
+			reason = ReasonEntry
+		}
+	}
+	d.mu.Lock()
+	if d.breakpoints[pos.Line] {
+		reason = ReasonBreakpoint
+	}
+	if reason == "" {
+		d.mu.Unlock()
+		return nil
+	}
+	d.stopped = true
+	d.mu.Unlock()
+
+	d.events <- Event{Kind: Stopped, Reason: reason, Pos: pos}
+
+	a := <-d.resume
+	if a == actionTerminate {
+		return ErrTerminated
+	}
+	d.mode, d.modeDepth = a, depth
+	return nil
+}
@@ -0,0 +1,170 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `fn helper() int {
+	x := 42;
+	return x;
+}
+
+fn main() int {
+	foo := helper();
+	bar := foo;
+	return bar;
+}
+`
+
+func parse(t *testing.T, src string) *ast.Program {
+	program, err := parser.NewParser(scanner.NewScanner(src)).Parse()
+	require.NoError(t, err)
+	return program
+}
+
+func next(t *testing.T, d *Debugger) Event {
+	select {
+	case ev := <-d.Events():
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+		return Event{}
+	}
+}
+
+func requireStop(t *testing.T, d *Debugger, reason StopReason, line int) {
+	ev := next(t, d)
+	require.Equal(t, Stopped, ev.Kind)
+	assert.Equal(t, reason, ev.Reason)
+	assert.Equal(t, line, ev.Pos.Line)
+}
+
+func TestDebugger_breakpoints(t *testing.T) {
+	d := New(parse(t, source))
+	assert.Equal(t, []int{3, 8}, d.SetBreakpoints([]int{1, 3, 5, 8}))
+	assert.Equal(t, []int{3, 8}, d.Breakpoints())
+
+	d.Start(false)
+	requireStop(t, d, ReasonBreakpoint, 3)
+
+	frames, err := d.StackTrace()
+	require.NoError(t, err)
+	assert.Equal(t, []StackFrame{
+		{Function: "helper", Pos: token.Pos{Line: 3, Column: 2}},
+		{Function: "main", Pos: token.Pos{Line: 7, Column: 2}},
+	}, frames)
+
+	vars, err := d.Locals(0)
+	require.NoError(t, err)
+	assert.Equal(t, []Variable{{Name: "x", Value: "42"}}, vars)
+	vars, err = d.Locals(1)
+	require.NoError(t, err)
+	assert.Empty(t, vars)
+	_, err = d.Locals(2)
+	assert.Error(t, err)
+
+	require.NoError(t, d.Continue())
+	requireStop(t, d, ReasonBreakpoint, 8)
+	vars, err = d.Locals(0)
+	require.NoError(t, err)
+	assert.Equal(t, []Variable{{Name: "foo", Value: "42"}}, vars)
+
+	require.NoError(t, d.Continue())
+	ev := next(t, d)
+	assert.Equal(t, Exited, ev.Kind)
+	assert.NoError(t, ev.Err)
+	assert.Equal(t, &object.Integer{Value: 42}, ev.Result)
+
+	_, ok := <-d.Events()
+	assert.False(t, ok)
+}
+
+func TestDebugger_stepping(t *testing.T) {
+	t.Run("step_in_enters_calls", func(t *testing.T) {
+		d := New(parse(t, source))
+		d.Start(true)
+		requireStop(t, d, ReasonEntry, 7)
+		require.NoError(t, d.StepIn())
+		requireStop(t, d, ReasonStep, 2)
+		require.NoError(t, d.StepIn())
+		requireStop(t, d, ReasonStep, 3)
+		require.NoError(t, d.StepIn())
+		requireStop(t, d, ReasonStep, 8)
+		require.NoError(t, d.Terminate())
+		assert.ErrorIs(t, next(t, d).Err, ErrTerminated)
+	})
+
+	t.Run("step_over_skips_calls", func(t *testing.T) {
+		d := New(parse(t, source))
+		d.Start(true)
+		requireStop(t, d, ReasonEntry, 7)
+		require.NoError(t, d.StepOver())
+		requireStop(t, d, ReasonStep, 8)
+		require.NoError(t, d.StepOver())
+		requireStop(t, d, ReasonStep, 9)
+		require.NoError(t, d.StepOver())
+		assert.Equal(t, Exited, next(t, d).Kind)
+	})
+
+	t.Run("step_over_stops_at_breakpoints", func(t *testing.T) {
+		d := New(parse(t, source))
+		d.SetBreakpoints([]int{2})
+		d.Start(true)
+		requireStop(t, d, ReasonEntry, 7)
+		require.NoError(t, d.StepOver())
+		requireStop(t, d, ReasonBreakpoint, 2)
+		require.NoError(t, d.StepOut())
+		requireStop(t, d, ReasonStep, 8)
+		require.NoError(t, d.Continue())
+		assert.Equal(t, Exited, next(t, d).Kind)
+	})
+}
+
+func TestDebugger_notStopped(t *testing.T) {
+	d := New(parse(t, source))
+	assert.ErrorIs(t, d.Continue(), ErrNotStopped)
+	_, err := d.StackTrace()
+	assert.ErrorIs(t, err, ErrNotStopped)
+}
+
+func TestConsole(t *testing.T) {
+	in := strings.NewReader("b 3\nb 5\nc\nbt\np x\np y\nclear 3\nn\nl\nfoo\nc\n")
+	var out bytes.Buffer
+	require.NoError(t, Console(New(parse(t, source)), in, &out, source))
+
+	expected := `stopped at 7:2 (entry)
+   7		foo := helper();
+(emdb) breakpoint set at line 3
+(emdb) no statement at line 5
+(emdb) stopped at 3:2 (breakpoint)
+   3		return x;
+(emdb) #0 helper() at 3:2
+#1 main() at 7:2
+(emdb) x = 42
+(emdb) undefined variable y
+(emdb) breakpoint at line 3 cleared
+(emdb) stopped at 8:2 (step)
+   8		bar := foo;
+(emdb) foo = 42
+(emdb) unknown command "foo", try help
+(emdb) program exited: 42
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestConsole_endOfInput(t *testing.T) {
+	var out bytes.Buffer
+	err := Console(New(parse(t, source)), strings.NewReader(""), &out, source)
+	assert.ErrorIs(t, err, ErrTerminated)
+}
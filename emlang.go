@@ -0,0 +1,244 @@
+// Package emlang embeds emlang scripts in Go programs.
+//
+// A script is compiled once and its functions can then be called any number
+// of times, possibly concurrently:
+//
+//	program, err := emlang.Compile("fn id(x int) int {\n\treturn x;\n}")
+//	if err != nil {
+//		return err
+//	}
+//	result, err := program.Call(ctx, "id", 42) // int64(42)
+//
+// Arguments and results are converted between Go values and emlang objects
+// by ToObject and FromObject. Go functions registered with a Host can be
+// called by the scripts it compiles.
+package emlang
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/evaluator"
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/types"
+)
+
+// Program is a compiled script. It is safe for concurrent use, every call
+// runs in its own evaluator.
+type Program struct {
+	program   *ast.Program
+	host      map[string]*hostFunc
+	functions []*Function
+	byName    map[string]*Function
+	structs   map[string]*ast.StructDeclaration
+	limits    Limits
+	out       io.Writer
+}
+
+// Limits restricts the resources of a single call, zero means no limit. A
+// call that exceeds a limit fails with an error wrapping ErrStepLimit,
+// ErrDepthLimit or ErrMemoryLimit, a call whose context is done with an
+// error wrapping the error of the context.
+type Limits = evaluator.Limits
+
+// Errors of calls that exceed their Limits.
+var (
+	ErrStepLimit   = evaluator.ErrStepLimit
+	ErrDepthLimit  = evaluator.ErrDepthLimit
+	ErrMemoryLimit = evaluator.ErrMemoryLimit
+)
+
+// RuntimeError is the error of a call that failed while the script ran, like
+// a division by zero. It holds the call stack of the script.
+type RuntimeError = evaluator.RuntimeError
+
+// StackFrame is a call in the stack of a RuntimeError.
+type StackFrame = evaluator.StackFrame
+
+// Function describes a function declared by a script.
+type Function struct {
+	Name   string
+	Params []Param
+	Result Type
+}
+
+type Param struct {
+	Name string
+	Type Type
+}
+
+// String returns the signature of the function as written in source.
+func (f *Function) String() string {
+	params := make([]string, len(f.Params))
+	for i, param := range f.Params {
+		params[i] = param.Name + " " + string(param.Type)
+	}
+	sig := "fn " + f.Name + "(" + strings.Join(params, ", ") + ")"
+	if f.Result != Void {
+		sig += " " + string(f.Result)
+	}
+	return sig
+}
+
+// Compile parses and type checks source. Errors are reported as a
+// parser.ErrorList. Use a Host to compile scripts that call Go functions.
+func Compile(source string) (*Program, error) {
+	return NewHost().Compile(source)
+}
+
+func compile(source string, host map[string]*hostFunc, sigs map[string]*types.Signature) (*Program, error) {
+	program, err := parser.NewParser(scanner.NewScanner(source)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := (&types.Config{Funcs: sigs}).Check(program); err != nil {
+		return nil, err
+	}
+
+	p := &Program{program: program, host: host, byName: map[string]*Function{}, structs: map[string]*ast.StructDeclaration{}}
+	for _, decl := range program.TopLevelDeclarations {
+		if sd, ok := decl.(*ast.StructDeclaration); ok {
+			p.structs[sd.Identifier.Value] = sd
+		}
+		fd, ok := decl.(*ast.FunctionDeclaration)
+		if !ok || fd.Receiver != nil {
+			continue
+		}
+		fn := &Function{Name: fd.Identifier.Value, Result: Type(fd.ReturnType.String())}
+		for _, param := range fd.Parameters {
+			fn.Params = append(fn.Params, Param{Name: param.Identifier.Value, Type: Type(param.Type.String())})
+		}
+		p.functions = append(p.functions, fn)
+		p.byName[fn.Name] = fn
+	}
+	return p, nil
+}
+
+// WithLimits returns a copy of the program whose calls are restricted by
+// limits.
+func (p *Program) WithLimits(limits Limits) *Program {
+	c := *p
+	c.limits = limits
+	return &c
+}
+
+// WithOutput returns a copy of the program whose calls of print and println
+// write to w instead of the standard output.
+func (p *Program) WithOutput(w io.Writer) *Program {
+	c := *p
+	c.out = w
+	return &c
+}
+
+// Functions returns the functions of the program in declaration order.
+func (p *Program) Functions() []*Function {
+	return p.functions
+}
+
+// Function returns the function called name.
+func (p *Program) Function(name string) (*Function, bool) {
+	fn, ok := p.byName[name]
+	return fn, ok
+}
+
+// Call calls the function called name. The arguments are converted with
+// ToObject and have to match the parameter types, structs also the fields
+// declared by the program. The result is converted with FromObject. The
+// call is aborted once ctx is done or it exceeds the limits of the program.
+func (p *Program) Call(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	fn, ok := p.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("emlang: undefined function %s", name)
+	}
+	if len(args) != len(fn.Params) {
+		return nil, fmt.Errorf("emlang: wrong number of arguments for %s: want %d, got %d", name, len(fn.Params), len(args))
+	}
+
+	objects := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("emlang: argument %s of %s: %w", fn.Params[i].Name, name, err)
+		}
+		want := fn.Params[i].Type
+		t, static := staticType(arg)
+		if !static {
+			t = TypeOf(obj)
+		}
+		switch {
+		case static && t != want, !static && !p.matches(obj, want):
+			return nil, fmt.Errorf("emlang: cannot use %s as %s in argument %s of %s", t, want, fn.Params[i].Name, name)
+		case !p.matches(obj, want):
+			return nil, fmt.Errorf("emlang: argument %s of %s: the fields of %s do not match its declaration", fn.Params[i].Name, name, t)
+		}
+		objects[i] = obj
+	}
+
+	e := evaluator.New(p.program)
+	e.Limits = p.limits
+	e.Out = p.out
+	for name, hf := range p.host {
+		hf := hf
+		e.Define(name, func(args []object.Object) (object.Object, error) { return hf.call(ctx, args) })
+	}
+	result, err := e.Call(ctx, name, objects...)
+	if err != nil {
+		return nil, err
+	}
+	return FromObject(result), nil
+}
+
+// staticType returns the type that the Go type of the argument arg of Call
+// converts to. Unlike the object it converts to, it knows the element types
+// of empty slices and maps. There is none for objects and nil.
+func staticType(arg interface{}) (Type, bool) {
+	if _, ok := arg.(object.Object); ok || arg == nil {
+		return "", false
+	}
+	return typeOfValue(reflect.TypeOf(arg))
+}
+
+// matches reports whether obj is a value of type t. Structs have to have the
+// fields of the struct declared by the program, in the same order.
+func (p *Program) matches(obj object.Object, t Type) bool {
+	switch o := obj.(type) {
+	case *object.Slice:
+		if !strings.HasPrefix(string(t), "[]") {
+			return false
+		}
+		for _, elem := range o.Elements {
+			if !p.matches(elem, t.Elem()) {
+				return false
+			}
+		}
+		return true
+	case *object.Map:
+		if t.Key() == "" {
+			return false
+		}
+		for _, entry := range o.Entries() {
+			if !p.matches(entry.Key, t.Key()) || !p.matches(entry.Value, t.Elem()) {
+				return false
+			}
+		}
+		return true
+	case *object.Struct:
+		sd, ok := p.structs[o.TypeName]
+		if !ok || Type(o.TypeName) != t || len(o.Fields) != len(sd.Fields) {
+			return false
+		}
+		for i, f := range sd.Fields {
+			if o.Fields[i].Name != f.Identifier.Value || !p.matches(o.Fields[i].Value, Type(f.Type.String())) {
+				return false
+			}
+		}
+		return true
+	}
+	return TypeOf(obj) == t
+}
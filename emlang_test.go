@@ -0,0 +1,335 @@
+package emlang
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/muggel/emlang/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `fn second(a int, b int) int {
+	return b;
+}
+
+fn answer() int {
+	return second(0, 42);
+}
+
+fn main() {
+	x := answer();
+}
+`
+
+func TestCompile(t *testing.T) {
+	t.Run("collects_functions", func(t *testing.T) {
+		program, err := Compile(source)
+		require.NoError(t, err)
+
+		var sigs []string
+		for _, fn := range program.Functions() {
+			sigs = append(sigs, fn.String())
+		}
+		assert.Equal(t, []string{"fn second(a int, b int) int", "fn answer() int", "fn main()"}, sigs)
+
+		fn, ok := program.Function("second")
+		require.True(t, ok)
+		assert.Equal(t, &Function{Name: "second", Params: []Param{{Name: "a", Type: Int}, {Name: "b", Type: Int}}, Result: Int}, fn)
+		_, ok = program.Function("third")
+		assert.False(t, ok)
+	})
+
+	t.Run("returns_syntax_errors", func(t *testing.T) {
+		_, err := Compile("fn main() {\n\treturn 1\n}")
+		assert.EqualError(t, err, "3:1: expected semicolon")
+	})
+
+	t.Run("rejects_redeclared_functions", func(t *testing.T) {
+		_, err := Compile("fn main() {\n}\n\nfn main() {\n}")
+		assert.EqualError(t, err, "4:4: function main redeclared")
+	})
+}
+
+func TestProgram_Call(t *testing.T) {
+	program, err := Compile(source)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("converts_arguments_and_result", func(t *testing.T) {
+		result, err := program.Call(ctx, "second", 1, uint8(2))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result)
+
+		result, err = program.Call(ctx, "answer")
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), result)
+	})
+
+	t.Run("returns_error_values", func(t *testing.T) {
+		program, err := Compile("fn parse(s string) (int, error) {\n\treturn 0, error(\"cannot parse \" + s);\n}\n\nfn check(err error) error {\n\treturn err;\n}")
+		require.NoError(t, err)
+		result, err := program.Call(ctx, "parse", "x")
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+		assert.EqualError(t, result.([]interface{})[1].(error), "cannot parse x")
+
+		result, err = program.Call(ctx, "check", nil)
+		require.NoError(t, err)
+		assert.Nil(t, result)
+		result, err = program.Call(ctx, "check", errors.New("boom"))
+		require.NoError(t, err)
+		assert.EqualError(t, result.(error), "boom")
+	})
+
+	t.Run("returns_nil_for_void_functions", func(t *testing.T) {
+		result, err := program.Call(ctx, "main")
+		require.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("checks_arguments", func(t *testing.T) {
+		_, err := program.Call(ctx, "second", 1)
+		assert.EqualError(t, err, "emlang: wrong number of arguments for second: want 2, got 1")
+
+		_, err = program.Call(ctx, "second", 1, 2.5)
+		assert.EqualError(t, err, "emlang: cannot use float as int in argument b of second")
+
+		_, err = program.Call(ctx, "second", 1, 1i)
+		assert.EqualError(t, err, "emlang: argument b of second: cannot convert complex128 to an emlang value")
+
+		_, err = program.Call(ctx, "second", 1, "two")
+		assert.EqualError(t, err, "emlang: cannot use string as int in argument b of second")
+
+		_, err = program.Call(ctx, "second", 1, object.VOID)
+		assert.EqualError(t, err, "emlang: cannot use void as int in argument b of second")
+
+		_, err = program.Call(ctx, "third")
+		assert.EqualError(t, err, "emlang: undefined function third")
+	})
+
+	t.Run("converts_slices_maps_and_structs", func(t *testing.T) {
+		program, err := Compile(collections)
+		require.NoError(t, err)
+
+		result, err := program.Call(ctx, "sum", []int{1, 2, 3})
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), result)
+
+		result, err = program.Call(ctx, "sum", []int{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), result)
+
+		result, err = program.Call(ctx, "count", []string{"a", "b", "a"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{"a": 2, "b": 1}, result)
+
+		result, err = program.Call(ctx, "total", map[string]int{"a": 1, "b": 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), result)
+
+		result, err = program.Call(ctx, "scale", Point{X: 1, Y: 2}, 3)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"x": int64(3), "y": int64(6)}, result)
+
+		result, err = program.Call(ctx, "points", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"x": int64(0), "y": int64(0)},
+			map[string]interface{}{"x": int64(1), "y": int64(1)},
+		}, result)
+
+		_, err = program.Call(ctx, "sum", []string{"a"})
+		assert.EqualError(t, err, "emlang: cannot use []string as []int in argument xs of sum")
+
+		_, err = program.Call(ctx, "total", map[string]float64{})
+		assert.EqualError(t, err, "emlang: cannot use map[string]float as map[string]int in argument m of total")
+
+		type Point struct{ X, Y int }
+		_, err = program.Call(ctx, "scale", Point{X: 1, Y: 2}, 3)
+		assert.EqualError(t, err, "emlang: argument p of scale: the fields of Point do not match its declaration")
+
+		result, err = program.Call(ctx, "sum", &object.Slice{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), result)
+	})
+
+	t.Run("stops_when_context_is_done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := program.Call(ctx, "answer")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+const collections = `struct Point {
+	x int,
+	y int,
+}
+
+fn sum(xs []int) int {
+	n := 0;
+	for x in xs {
+		n = n + x;
+	}
+	return n;
+}
+
+fn count(words []string) map[string]int {
+	res := map[string]int{};
+	for w in words {
+		res[w] = res[w] + 1;
+	}
+	return res;
+}
+
+fn total(m map[string]int) int {
+	n := 0;
+	for _, v in m {
+		n = n + v;
+	}
+	return n;
+}
+
+fn scale(p Point, k int) Point {
+	return Point{x: p.x * k, y: p.y * k};
+}
+
+fn points(n int) []Point {
+	var res []Point;
+	for i, _ in [0, 0, 0][:n] {
+		res = append(res, Point{x: i, y: i});
+	}
+	return res;
+}
+`
+
+// Point is the Go counterpart of the struct Point in collections.
+type Point struct {
+	X      int `emlang:"x"`
+	Y      int `emlang:"y"`
+	hidden int
+}
+
+func TestProgram_WithOutput(t *testing.T) {
+	program, err := Compile("fn greet(n int) {\n\tprintln(\"hello\", n);\n}")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	_, err = program.WithOutput(&out).Call(context.Background(), "greet", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "hello 3\n", out.String())
+}
+
+func TestProgram_WithLimits(t *testing.T) {
+	program, err := Compile("fn loop(n int) int {\n\treturn loop(n);\n}")
+	require.NoError(t, err)
+
+	_, err = program.WithLimits(Limits{MaxSteps: 100}).Call(context.Background(), "loop", 1)
+	assert.ErrorIs(t, err, ErrStepLimit)
+	_, err = program.WithLimits(Limits{MaxDepth: 10}).Call(context.Background(), "loop", 1)
+	assert.ErrorIs(t, err, ErrDepthLimit)
+	_, err = program.WithLimits(Limits{MaxMemory: 1 << 10}).Call(context.Background(), "loop", 1)
+	assert.ErrorIs(t, err, ErrMemoryLimit)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	_, err = program.Call(ctx, "loop", 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestToObject(t *testing.T) {
+	obj, err := ToObject(int32(-3))
+	require.NoError(t, err)
+	assert.Equal(t, &object.Integer{Value: -3}, obj)
+
+	_, err = ToObject(uint64(1 << 63))
+	assert.EqualError(t, err, "9223372036854775808 overflows int")
+
+	obj, err = ToObject(float32(1.5))
+	require.NoError(t, err)
+	assert.Equal(t, &object.Float{Value: 1.5}, obj)
+
+	obj, err = ToObject(object.VOID)
+	require.NoError(t, err)
+	assert.Equal(t, object.VOID, obj)
+
+	obj, err = ToObject(errors.New("boom"))
+	require.NoError(t, err)
+	assert.Equal(t, &object.Error{Message: "boom"}, obj)
+
+	obj, err = ToObject(nil)
+	require.NoError(t, err)
+	assert.Equal(t, object.NIL, obj)
+
+	obj, err = ToObject([]uint8{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, &object.Slice{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}, obj)
+
+	obj, err = ToObject(map[string]int{"b": 2, "a": 1})
+	require.NoError(t, err)
+	m := object.NewMap(&object.Integer{})
+	m.Set(&object.String{Value: "a"}, &object.Integer{Value: 1})
+	m.Set(&object.String{Value: "b"}, &object.Integer{Value: 2})
+	assert.Equal(t, m, obj)
+
+	obj, err = ToObject(Point{X: 1, Y: 2})
+	require.NoError(t, err)
+	assert.Equal(t, &object.Struct{TypeName: "Point", Fields: []object.StructField{
+		{Name: "x", Value: &object.Integer{Value: 1}},
+		{Name: "y", Value: &object.Integer{Value: 2}},
+	}}, obj)
+
+	_, err = ToObject(map[float64]int{})
+	assert.EqualError(t, err, "cannot convert map[float64]int to an emlang value")
+
+	_, err = ToObject(struct{ X int }{})
+	assert.EqualError(t, err, "cannot convert struct { X int } to an emlang value")
+}
+
+func TestTypeOf(t *testing.T) {
+	xs, err := ToObject([][]string{{"a"}})
+	require.NoError(t, err)
+	m, err := ToObject(map[int]float64{1: 2})
+	require.NoError(t, err)
+	p, err := ToObject(Point{})
+	require.NoError(t, err)
+
+	assert.Equal(t, Int, TypeOf(&object.Integer{}))
+	assert.Equal(t, ErrorType, TypeOf(object.NIL))
+	assert.Equal(t, Type("[][]string"), TypeOf(xs))
+	assert.Equal(t, Type("map[int]float"), TypeOf(m))
+	assert.Equal(t, Type("Point"), TypeOf(p))
+	assert.Equal(t, Type("[]"), TypeOf(&object.Slice{}))
+	assert.Equal(t, Type("map[]string"), TypeOf(object.NewMap(&object.String{})))
+	assert.Equal(t, Type("(int, error)"), TypeOf(&object.Tuple{Elements: []object.Object{&object.Integer{}, object.NIL}}))
+}
+
+func TestFromObject(t *testing.T) {
+	assert.Equal(t, int64(5), FromObject(&object.Integer{Value: 5}))
+	assert.Equal(t, 2.5, FromObject(&object.Float{Value: 2.5}))
+	assert.Nil(t, FromObject(object.VOID))
+	assert.Nil(t, FromObject(object.NIL))
+	assert.EqualError(t, FromObject(&object.Error{Message: "boom"}).(error), "boom")
+	assert.Equal(t, []interface{}{int64(3), "one"}, FromObject(&object.Tuple{Elements: []object.Object{&object.Integer{Value: 3}, &object.String{Value: "one"}}}))
+
+	xs, err := ToObject([][]float64{{1.5}, {}})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{1.5}, {}}, FromObject(xs))
+
+	xs, err = ToObject([][]int{{}, {1}})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{}, []int64{1}}, FromObject(xs), "the element type is unknown")
+
+	m, err := ToObject(map[int][]string{2: {"b"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64][]string{2: {"b"}}, FromObject(m))
+	assert.Equal(t, map[interface{}]string{}, FromObject(object.NewMap(&object.String{})))
+
+	p, err := ToObject(Point{X: 1})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"x": int64(1), "y": int64(0)}, FromObject(p))
+}
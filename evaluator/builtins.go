@@ -0,0 +1,236 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/token"
+)
+
+// builtin is a predeclared function, pos is the position of the call. The
+// builtins validate their arguments, since programs may run unchecked.
+type builtin func(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error)
+
+// builtins are resolved before the functions of the program and the host.
+var builtins = map[string]builtin{
+	"print":   builtinPrint,
+	"println": builtinPrintln,
+	"len":     builtinLen,
+	"append":  builtinAppend,
+	"delete":  builtinDelete,
+	"abs":     builtinAbs,
+	"min":     builtinMin,
+	"max":     builtinMax,
+	"assert":  builtinAssert,
+	"panic":   builtinPanic,
+	"error":   builtinError,
+	"int":     builtinInt,
+	"float":   builtinFloat,
+}
+
+// builtinPrint writes its arguments without separators.
+func builtinPrint(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	var res strings.Builder
+	for _, arg := range args {
+		res.WriteString(arg.Inspect())
+	}
+	return object.VOID, e.print(pos, res.String())
+}
+
+// builtinPrintln writes its arguments separated by spaces and a newline.
+func builtinPrintln(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = arg.Inspect()
+	}
+	return object.VOID, e.print(pos, strings.Join(values, " ")+"\n")
+}
+
+func builtinLen(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "len", args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(len(arg.Value))}, nil
+	case *object.Slice:
+		return &object.Integer{Value: int64(len(arg.Elements))}, nil
+	case *object.Map:
+		return &object.Integer{Value: int64(arg.Len())}, nil
+	}
+	return nil, e.errorf(pos, nil, "invalid argument %s for len", args[0].Type())
+}
+
+// builtinAppend appends its other arguments to the slice passed first.
+func builtinAppend(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "append", args, 1, -1); err != nil {
+		return nil, err
+	}
+	s, err := argument[*object.Slice](e, pos, "append", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := e.allocate(pos, sizeElement*int64(len(args)-1)); err != nil {
+		return nil, err
+	}
+	return &object.Slice{Elements: append(s.Elements, args[1:]...)}, nil
+}
+
+// builtinDelete removes the key passed second from the map passed first.
+func builtinDelete(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "delete", args, 2, 2); err != nil {
+		return nil, err
+	}
+	m, err := argument[*object.Map](e, pos, "delete", args[0])
+	if err != nil {
+		return nil, err
+	}
+	m.Delete(args[1])
+	return object.VOID, nil
+}
+
+func builtinAbs(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "abs", args, 1, 1); err != nil {
+		return nil, err
+	}
+	i, err := argument[*object.Integer](e, pos, "abs", args[0])
+	if err != nil {
+		return nil, err
+	}
+	x := i.Value
+	if x == math.MinInt64 {
+		return nil, e.errorf(pos, nil, "integer overflow: abs(%d)", x)
+	}
+	if x < 0 {
+		x = -x
+	}
+	return &object.Integer{Value: x}, nil
+}
+
+func builtinMin(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	return extreme(e, pos, "min", args, func(x, y int64) bool { return x < y })
+}
+
+func builtinMax(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	return extreme(e, pos, "max", args, func(x, y int64) bool { return x > y })
+}
+
+// extreme returns the argument of min or max that comes before all others
+// in the order less.
+func extreme(e *Evaluator, pos token.Pos, name string, args []object.Object, less func(x, y int64) bool) (object.Object, error) {
+	if err := arity(e, pos, name, args, 1, -1); err != nil {
+		return nil, err
+	}
+	var res *object.Integer
+	for _, arg := range args {
+		i, err := argument[*object.Integer](e, pos, name, arg)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil || less(i.Value, res.Value) {
+			res = i
+		}
+	}
+	return res, nil
+}
+
+// builtinAssert fails if its first argument is zero, the optional second
+// argument is added to the error.
+func builtinAssert(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "assert", args, 1, 2); err != nil {
+		return nil, err
+	}
+	cond, err := argument[*object.Integer](e, pos, "assert", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if cond.Value != 0 {
+		return object.VOID, nil
+	}
+	if len(args) > 1 {
+		return nil, e.errorf(pos, nil, "assertion failed: %s", args[1].Inspect())
+	}
+	return nil, e.errorf(pos, nil, "assertion failed")
+}
+
+func builtinPanic(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "panic", args, 1, 1); err != nil {
+		return nil, err
+	}
+	return nil, e.errorf(pos, nil, "panic: %s", args[0].Inspect())
+}
+
+// builtinError returns an error value with the message passed.
+func builtinError(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "error", args, 1, 1); err != nil {
+		return nil, err
+	}
+	msg, err := argument[*object.String](e, pos, "error", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &object.Error{Message: msg.Value}, nil
+}
+
+// builtinInt converts an int or float to int, truncating towards zero.
+func builtinInt(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "int", args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg, nil
+	case *object.Float:
+		x := math.Trunc(arg.Value)
+		if math.IsNaN(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return nil, e.errorf(pos, nil, "integer overflow: int(%s)", arg.Inspect())
+		}
+		return e.result(pos, &object.Integer{Value: int64(x)})
+	}
+	return nil, e.errorf(pos, nil, "cannot convert %s to int", args[0].Type())
+}
+
+// builtinFloat converts an int or float to float.
+func builtinFloat(e *Evaluator, pos token.Pos, args []object.Object) (object.Object, error) {
+	if err := arity(e, pos, "float", args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return e.result(pos, &object.Float{Value: float64(arg.Value)})
+	case *object.Float:
+		return arg, nil
+	}
+	return nil, e.errorf(pos, nil, "cannot convert %s to float", args[0].Type())
+}
+
+// arity fails unless the call of the builtin name passes at least least and
+// at most most arguments, most is -1 if there is no limit.
+func arity(e *Evaluator, pos token.Pos, name string, args []object.Object, least, most int) error {
+	if len(args) < least {
+		return e.errorf(pos, nil, "not enough arguments in call to %s", name)
+	}
+	if most >= 0 && len(args) > most {
+		return e.errorf(pos, nil, "too many arguments in call to %s", name)
+	}
+	return nil
+}
+
+// argument returns arg as a T or fails if it has another type.
+func argument[T object.Object](e *Evaluator, pos token.Pos, name string, arg object.Object) (T, error) {
+	res, ok := arg.(T)
+	if !ok {
+		return res, e.errorf(pos, nil, "invalid argument %s for %s", arg.Type(), name)
+	}
+	return res, nil
+}
+
+// print writes s to the output of the program.
+func (e *Evaluator) print(pos token.Pos, s string) error {
+	if _, err := fmt.Fprint(e.output(), s); err != nil {
+		return e.errorf(pos, err, "%v", err)
+	}
+	return nil
+}
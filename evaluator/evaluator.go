@@ -0,0 +1,1428 @@
+// Package evaluator runs programs by walking their syntax tree.
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/internal/arith"
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/token"
+)
+
+// Errors of programs that exceed their Limits.
+var (
+	ErrStepLimit   = errors.New("step limit exceeded")
+	ErrDepthLimit  = errors.New("maximum call depth exceeded")
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+)
+
+// DefaultMaxDepth is the call depth limit used if Limits.MaxDepth is zero.
+// Deeper recursion could exhaust the stack of the Go runtime.
+const DefaultMaxDepth = 10000
+
+// maxTraceFrames is the maximum number of calls printed by StackTrace.
+const maxTraceFrames = 20
+
+// approximate sizes in bytes, used to account for allocations
+const (
+	sizeInteger  = 8
+	sizeFloat    = 8
+	sizeString   = 16 // plus the length of the string
+	sizeSlice    = 24 // plus sizeElement per element
+	sizeElement  = 8
+	sizeMap      = 48 // plus sizeEntry per entry
+	sizeEntry    = 16
+	sizeStruct   = 16 // plus sizeElement per field
+	sizeEnum     = 16 // plus sizeElement per payload value
+	sizeFunction = 24
+	sizeTuple    = 16 // plus sizeElement per result
+	sizeError    = 16 // plus the length of the message
+	sizeFrame    = 64
+	sizeVariable = 16
+)
+
+// RuntimeError is an error that occurred while running a program, like a
+// division by zero or an exceeded limit.
+type RuntimeError struct {
+	Pos token.Pos
+	Msg string
+	// Err is the cause of the error if it did not originate in the program,
+	// like an error of a host function, a limit error or the error of the
+	// context.
+	Err error
+	// Stack is the call stack at the time of the error, the innermost call
+	// comes first and is positioned at the error.
+	Stack []StackFrame
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// StackTrace returns the call stack with one indented line per call, the
+// middle of stacks deeper than maxTraceFrames is left out:
+//
+//	at div (prog.em:3:11)
+//	at main (prog.em:7:9)
+func (e *RuntimeError) StackTrace() string {
+	head, tail := e.Stack, []StackFrame(nil)
+	if len(e.Stack) > maxTraceFrames {
+		head, tail = e.Stack[:maxTraceFrames/2], e.Stack[len(e.Stack)-maxTraceFrames/2:]
+	}
+
+	var res strings.Builder
+	for _, frame := range head {
+		res.WriteString("\tat " + frame.String() + "\n")
+	}
+	if tail != nil {
+		fmt.Fprintf(&res, "\t... %d more calls ...\n", len(e.Stack)-maxTraceFrames)
+		for _, frame := range tail {
+			res.WriteString("\tat " + frame.String() + "\n")
+		}
+	}
+	return res.String()
+}
+
+// StackFrame is a call of a function at the time of a RuntimeError.
+type StackFrame struct {
+	Function string
+	// File is the name of the source file, empty if unknown.
+	File string
+	// Pos is the position the function was executing.
+	Pos token.Pos
+}
+
+func (f StackFrame) String() string {
+	if f.File == "" {
+		return f.Function + " (" + f.Pos.String() + ")"
+	}
+	return f.Function + " (" + f.File + ":" + f.Pos.String() + ")"
+}
+
+// HostFunc is a function implemented by the host. Returning an error aborts
+// the program, a nil result is void.
+type HostFunc func(args []object.Object) (object.Object, error)
+
+// Frame is the state of a single function call.
+type Frame struct {
+	Function *ast.FunctionDeclaration
+	Env      *object.Environment
+	// Pos is the position of the statement that is being executed.
+	Pos token.Pos
+
+	call   token.Pos // position of the call the frame is executing, if any
+	module *Module   // module of Function
+}
+
+// Limits restricts the resources a single Run or Call may use, zero means
+// no limit.
+type Limits struct {
+	// MaxSteps is the maximum number of statements executed.
+	MaxSteps int
+	// MaxDepth is the maximum depth of nested calls, DefaultMaxDepth if zero.
+	MaxDepth int
+	// MaxMemory is the maximum number of bytes allocated for values and
+	// calls. Memory is never given back, so this limits the total amount
+	// of allocations.
+	MaxMemory int64
+}
+
+type Evaluator struct {
+	main   *Module
+	host   map[string]HostFunc
+	frames []*Frame
+
+	// Hook, if set, is called before each statement is executed. Returning
+	// an error aborts the program with that error.
+	Hook func(stmt ast.Statement) error
+	// Limits restricts the resources of the program.
+	Limits Limits
+	// File is the name of the source file of the program used in stack
+	// traces.
+	File string
+	// Out is where print and println write to, os.Stdout if nil.
+	Out io.Writer
+
+	// state of the current run
+	done      <-chan struct{}
+	ctx       context.Context
+	steps     int
+	allocated int64
+}
+
+func New(program *ast.Program) *Evaluator {
+	return &Evaluator{main: NewModule("", program), host: map[string]HostFunc{}}
+}
+
+// Import makes the exported functions of m callable as name.F() in the
+// program.
+func (e *Evaluator) Import(name string, m *Module) {
+	e.main.Import(name, m)
+}
+
+// Define makes fn callable as name. Builtins and functions declared by the
+// program take precedence over host functions of the same name.
+func (e *Evaluator) Define(name string, fn HostFunc) {
+	e.host[name] = fn
+}
+
+// Run calls the main function of the program and returns its result.
+func (e *Evaluator) Run(ctx context.Context) (object.Object, error) {
+	return e.Call(ctx, "main")
+}
+
+// Call calls the function called name with args and returns its result. The
+// program is aborted with an error wrapping the error of ctx once ctx is
+// done.
+func (e *Evaluator) Call(ctx context.Context, name string, args ...object.Object) (object.Object, error) {
+	fd, ok := e.main.functions[name]
+	if !ok {
+		return nil, e.errorf(token.Pos{}, nil, "undefined function %s", name)
+	}
+	if len(args) != len(fd.Parameters) {
+		return nil, e.errorf(fd.Pos, nil, "%s", wrongArgumentCount(fd, len(args)))
+	}
+
+	e.ctx, e.done = ctx, ctx.Done()
+	e.steps, e.allocated = 0, 0
+	if err := e.init(e.main); err != nil {
+		return nil, err
+	}
+	return e.call(e.main, fd, nil, args, fd.Pos)
+}
+
+// Frames returns the call stack, the innermost call comes last. It is meant
+// to be used by the Hook, the frames change while the program runs.
+func (e *Evaluator) Frames() []*Frame {
+	return e.frames
+}
+
+// call calls fd of the module m, pos is the position of the call. The
+// receiver of a method comes first in args. Outer is the environment a
+// function literal captured and nil for declared functions.
+func (e *Evaluator) call(m *Module, fd *ast.FunctionDeclaration, outer *object.Environment, args []object.Object, pos token.Pos) (object.Object, error) {
+	maxDepth := e.Limits.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if len(e.frames) >= maxDepth {
+		return nil, e.errorf(pos, ErrDepthLimit, "%v", ErrDepthLimit)
+	}
+	if err := e.allocate(pos, sizeFrame+sizeVariable*int64(len(args))); err != nil {
+		return nil, err
+	}
+
+	if n := len(e.frames); n > 0 {
+		e.frames[n-1].call = pos
+	}
+	env := object.NewEnvironment()
+	if outer != nil {
+		env = object.NewEnclosedEnvironment(outer)
+	}
+	if fd.Receiver != nil {
+		env.Set(fd.Receiver.Identifier.Value, args[0])
+		args = args[1:]
+	}
+	for i, param := range fd.Parameters {
+		env.Set(param.Identifier.Value, args[i])
+	}
+	e.frames = append(e.frames, &Frame{Function: fd, Env: env, Pos: fd.Pos, module: m})
+	defer func() { e.frames = e.frames[:len(e.frames)-1] }()
+
+	result, _, err := e.evalBlockStatement(fd.Body)
+	if p, ok := err.(*propagation); ok {
+		return e.propagate(m, fd, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return object.VOID, nil
+	}
+	return result, nil
+}
+
+// propagation is the error evalTry returns to return an error value from
+// the current function, call turns it into the results of the function.
+type propagation struct {
+	pos token.Pos
+	err *object.Error
+}
+
+func (p *propagation) Error() string { return "propagated error: " + p.err.Message }
+
+// propagate returns the results of fd for the propagated error p: the zero
+// values of its other results and the error.
+func (e *Evaluator) propagate(m *Module, fd *ast.FunctionDeclaration, p *propagation) (object.Object, error) {
+	list, ok := fd.ReturnType.(*ast.ResultList)
+	if !ok || len(list.Types) == 1 {
+		return p.err, nil
+	}
+	values := make([]object.Object, len(list.Types))
+	for i, typ := range list.Types[:len(values)-1] {
+		values[i] = m.zeroValue(typ)
+	}
+	values[len(values)-1] = p.err
+	return e.result(p.pos, &object.Tuple{Elements: values})
+}
+
+// evalDeclaration returns the initial value of the variable declared by
+// vd, the zero value of its type if it has no value.
+func (e *Evaluator) evalDeclaration(vd *ast.ValueDeclaration) (object.Object, error) {
+	if err := e.allocate(vd.Pos, sizeVariable); err != nil {
+		return nil, err
+	}
+	if vd.Value != nil {
+		val, err := e.evalAs(vd.Value, vd.Type)
+		if err != nil {
+			return nil, err
+		}
+		return e.copy(ast.Start(vd.Value), val)
+	}
+	return e.frames[len(e.frames)-1].module.zeroValue(vd.Type), nil
+}
+
+// copy returns a copy of val if it is a struct, which is a value that must
+// not be shared when it is stored, and val otherwise.
+func (e *Evaluator) copy(pos token.Pos, val object.Object) (object.Object, error) {
+	s, ok := val.(*object.Struct)
+	if !ok {
+		return val, nil
+	}
+	return e.result(pos, s.Copy())
+}
+
+// evalBlockStatement runs the statements of block, returned reports whether a
+// return statement was executed, result is then its value.
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement) (result object.Object, returned bool, err error) {
+	for _, stmt := range block.Statements {
+		if result, returned, err = e.evalStatement(stmt); err != nil || returned {
+			return result, returned, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (e *Evaluator) evalStatement(stmt ast.Statement) (object.Object, bool, error) {
+	frame := e.frames[len(e.frames)-1]
+	if block, ok := stmt.(*ast.BlockStatement); ok {
+		env := frame.Env
+		frame.Env = object.NewEnclosedEnvironment(env)
+		defer func() { frame.Env = env }()
+		return e.evalBlockStatement(block)
+	}
+
+	frame.Pos = ast.Start(stmt)
+	if err := e.step(frame.Pos); err != nil {
+		return nil, false, err
+	}
+	if e.Hook != nil {
+		if err := e.Hook(stmt); err != nil {
+			return nil, false, err
+		}
+	}
+
+	switch s := stmt.(type) {
+	case *ast.AssignmentStatement:
+		return nil, false, e.evalAssignment(s)
+
+	case *ast.ValueDeclaration:
+		val, err := e.evalDeclaration(s)
+		if err != nil {
+			return nil, false, err
+		}
+		frame.Env.Set(s.Identifier.Value, val)
+		return nil, false, nil
+
+	case *ast.ExpressionStatement:
+		var err error
+		switch ex := s.Expression.(type) {
+		case *ast.CallExpression:
+			// calls of void functions are fine here
+			_, err = e.evalCallExpression(ex)
+		case *ast.MatchExpression:
+			_, err = e.evalMatch(ex)
+		case *ast.TryExpression:
+			_, err = e.evalTry(ex)
+		default:
+			_, err = e.evalExpression(s.Expression)
+		}
+		return nil, false, err
+
+	case *ast.ReturnStatement:
+		if len(s.ReturnValues) == 0 {
+			return object.VOID, true, nil
+		}
+		values, err := e.evalValues(s.ReturnValues, resultTypes(frame.Function))
+		if err != nil {
+			return nil, true, err
+		}
+		if len(values) == 1 {
+			return values[0], true, nil
+		}
+		val, err := e.result(s.Pos, &object.Tuple{Elements: values})
+		return val, true, err
+
+	case *ast.ForStatement:
+		return e.evalForStatement(s)
+	}
+
+	return nil, false, e.errorf(frame.Pos, nil, "unexpected statement %T", stmt)
+}
+
+// evalValues evaluates the values of a return statement or assignment, a
+// single call may have several results. types are the types of the values,
+// if they are known.
+func (e *Evaluator) evalValues(exprs []ast.Expression, types []ast.Expression) ([]object.Object, error) {
+	if te, ok := exprs[0].(*ast.TryExpression); ok && len(exprs) == 1 {
+		values, err := e.evalTry(te)
+		if err == nil && len(values) == 0 {
+			return nil, e.errorf(te.Pos, nil, "%s used as value", te)
+		}
+		return values, err
+	}
+	if call, ok := exprs[0].(*ast.CallExpression); ok && len(exprs) == 1 {
+		val, err := e.evalCallExpression(call)
+		if err != nil {
+			return nil, err
+		}
+		if tuple, ok := val.(*object.Tuple); ok {
+			return tuple.Elements, nil
+		}
+		if val == object.VOID {
+			return nil, e.errorf(call.Pos, nil, "%s() used as value", callee(call))
+		}
+		return []object.Object{val}, nil
+	}
+	values := make([]object.Object, len(exprs))
+	for i, expr := range exprs {
+		var typ ast.Expression
+		if i < len(types) {
+			typ = types[i]
+		}
+		val, err := e.evalAs(expr, typ)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+// resultTypes returns the result types of fd.
+func resultTypes(fd *ast.FunctionDeclaration) []ast.Expression {
+	if list, ok := fd.ReturnType.(*ast.ResultList); ok {
+		return list.Types
+	}
+	if fd.ReturnType != nil {
+		return []ast.Expression{fd.ReturnType}
+	}
+	return nil
+}
+
+// evalTry evaluates te.X, whose last value is an error. A non-nil error is
+// returned from the current function, otherwise the other values are the
+// values of te.
+func (e *Evaluator) evalTry(te *ast.TryExpression) ([]object.Object, error) {
+	var (
+		val object.Object
+		err error
+	)
+	if call, ok := te.X.(*ast.CallExpression); ok {
+		val, err = e.evalCallExpression(call)
+	} else {
+		val, err = e.evalExpression(te.X)
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := []object.Object{val}
+	if tuple, ok := val.(*object.Tuple); ok {
+		values = tuple.Elements
+	}
+	switch last := values[len(values)-1].(type) {
+	case *object.Error:
+		return nil, &propagation{pos: te.Pos, err: last}
+	case *object.Nil:
+		return values[:len(values)-1], nil
+	}
+	return nil, e.errorf(te.Pos, nil, "cannot propagate %s", values[len(values)-1].Type())
+}
+
+// multiValued reports whether expr may have several values, which is the
+// case for calls and the propagation of their errors.
+func multiValued(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.CallExpression, *ast.TryExpression:
+		return true
+	}
+	return false
+}
+
+// callee returns the function called by ex for error messages.
+func callee(ex *ast.CallExpression) fmt.Stringer {
+	if ex.Callee != nil {
+		return ex.Callee
+	}
+	return ex.Function
+}
+
+// evalAssignment assigns the value of s to its targets, several targets
+// take the results of a call or the value and presence of a map element.
+func (e *Evaluator) evalAssignment(s *ast.AssignmentStatement) error {
+	var values []object.Object
+	if multiValued(s.Value) {
+		var err error
+		if values, err = e.evalValues([]ast.Expression{s.Value}, nil); err != nil {
+			return err
+		}
+		if len(values) != len(s.Targets) {
+			return e.errorf(ast.Start(s), nil, "assignment mismatch: %d variables but %s returns %d values", len(s.Targets), s.Value, len(values))
+		}
+	} else if len(s.Targets) == 1 {
+		val, err := e.evalExpression(s.Value)
+		if err != nil {
+			return err
+		}
+		values = []object.Object{val}
+	} else {
+		index, ok := s.Value.(*ast.IndexExpression)
+		if !ok || len(s.Targets) != 2 {
+			return e.errorf(ast.Start(s), nil, "assignment mismatch: %d variables but 1 value", len(s.Targets))
+		}
+		val, found, err := e.evalElement(index)
+		if err != nil {
+			return err
+		}
+		present := int64(0)
+		if found {
+			present = 1
+		}
+		if err := e.allocate(index.Pos, sizeInteger); err != nil {
+			return err
+		}
+		values = []object.Object{val, &object.Integer{Value: present}}
+	}
+
+	for i, target := range s.Targets {
+		if err := e.assign(target, values[i], s.IsDefine()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign assigns val to target, a variable, an element of a slice or map or
+// a field of a struct. The variable is declared if define is set.
+func (e *Evaluator) assign(target ast.Expression, val object.Object, define bool) error {
+	frame := e.frames[len(e.frames)-1]
+	val, err := e.copy(ast.Start(target), val)
+	if err != nil {
+		return err
+	}
+	switch t := target.(type) {
+	case *ast.Identifier:
+		name := t.Value
+		if define {
+			if err := e.allocate(t.Pos, sizeVariable); err != nil {
+				return err
+			}
+			frame.Env.Set(name, val)
+			return nil
+		}
+		if old, ok := e.variable(name); ok {
+			val = convert(val, old)
+		}
+		if frame.Env.Assign(name, val) {
+			return nil
+		}
+		if _, ok := frame.module.globals.Get(name); !ok {
+			return e.errorf(t.Pos, nil, "identifier not found: %s", name)
+		}
+		if frame.module.consts[name] {
+			return e.errorf(t.Pos, nil, "cannot assign to constant %s", name)
+		}
+		frame.module.globals.Set(name, val)
+		return nil
+
+	case *ast.IndexExpression:
+		left, err := e.evalExpression(t.Left)
+		if err != nil {
+			return err
+		}
+		if m, ok := left.(*object.Map); ok {
+			key, err := e.evalExpression(t.Index)
+			if err != nil {
+				return err
+			}
+			if _, found := m.Get(key); !found {
+				if err := e.allocate(t.Pos, sizeEntry); err != nil {
+					return err
+				}
+			}
+			m.Set(key, convert(val, m.Zero))
+			return nil
+		}
+		slice, ok := left.(*object.Slice)
+		if !ok {
+			return e.errorf(ast.Start(t.Left), nil, "cannot index %s", left.Type())
+		}
+		i, err := e.evalIndex(t.Index)
+		if err != nil {
+			return err
+		}
+		if i < 0 || i >= int64(len(slice.Elements)) {
+			return e.errorf(t.Pos, nil, "index out of range [%d] with length %d", i, len(slice.Elements))
+		}
+		slice.Elements[i] = convert(val, slice.Elements[i])
+		return nil
+
+	case *ast.SelectorExpression:
+		// the struct is changed where it is stored, so it is not copied
+		x, err := e.evalExpression(t.X)
+		if err != nil {
+			return err
+		}
+		s, ok := x.(*object.Struct)
+		if ok {
+			val = convert(val, s.Get(t.Sel.Value))
+		}
+		if !ok || !s.Set(t.Sel.Value, val) {
+			return e.errorf(t.Sel.Pos, nil, "%s undefined", t)
+		}
+		return nil
+	}
+	return e.errorf(ast.Start(target), nil, "cannot assign to %s", target)
+}
+
+// evalForStatement runs the body of s for every element of its iterable,
+// which is evaluated once. Every iteration declares new variables. Maps are
+// iterated in insertion order, keys added by the body are not visited.
+func (e *Evaluator) evalForStatement(s *ast.ForStatement) (object.Object, bool, error) {
+	val, err := e.evalExpression(s.Iterable)
+	if err != nil {
+		return nil, false, err
+	}
+	var entries []object.MapEntry
+	switch v := val.(type) {
+	case *object.Slice:
+		entries = make([]object.MapEntry, len(v.Elements))
+		for i, elem := range v.Elements {
+			entries[i] = object.MapEntry{Key: &object.Integer{Value: int64(i)}, Value: elem}
+		}
+	case *object.Map:
+		entries = v.Entries()
+	default:
+		return nil, false, e.errorf(ast.Start(s.Iterable), nil, "cannot iterate over %s", val.Type())
+	}
+
+	frame := e.frames[len(e.frames)-1]
+	env := frame.Env
+	defer func() { frame.Env = env }()
+	for _, entry := range entries {
+		if err := e.allocate(s.Pos, 2*sizeVariable); err != nil {
+			return nil, false, err
+		}
+		frame.Env = object.NewEnclosedEnvironment(env)
+		if s.Index != nil {
+			frame.Env.Set(s.Index.Value, entry.Key)
+		}
+		val, err := e.copy(s.Value.Pos, entry.Value)
+		if err != nil {
+			return nil, false, err
+		}
+		frame.Env.Set(s.Value.Value, val)
+		if result, returned, err := e.evalStatement(s.Body); err != nil || returned {
+			return result, returned, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (e *Evaluator) evalExpression(expr ast.Expression) (object.Object, error) {
+	switch ex := expr.(type) {
+	case *ast.IntLiteral:
+		if err := e.allocate(ex.Pos, sizeInteger); err != nil {
+			return nil, err
+		}
+		return &object.Integer{Value: ex.Value}, nil
+
+	case *ast.FloatLiteral:
+		if err := e.allocate(ex.Pos, sizeFloat); err != nil {
+			return nil, err
+		}
+		return &object.Float{Value: ex.Value}, nil
+
+	case *ast.StringLiteral:
+		if err := e.allocate(ex.Pos, sizeString+int64(len(ex.Value))); err != nil {
+			return nil, err
+		}
+		return &object.String{Value: ex.Value}, nil
+
+	case *ast.Identifier:
+		if val, ok := e.variable(ex.Value); ok {
+			return val, nil
+		}
+		m := e.frames[len(e.frames)-1].module
+		if fd, ok := m.functions[ex.Value]; ok {
+			return e.result(ex.Pos, &object.Function{Declaration: fd, Module: m})
+		}
+		if ex.Value == "nil" {
+			return object.NIL, nil
+		}
+		return nil, e.errorf(ex.Pos, nil, "identifier not found: %s", ex.Value)
+
+	case *ast.TryExpression:
+		values, err := e.evalTry(ex)
+		switch {
+		case err != nil:
+			return nil, err
+		case len(values) == 0:
+			return nil, e.errorf(ex.Pos, nil, "%s used as value", ex)
+		case len(values) > 1:
+			return nil, e.errorf(ex.Pos, nil, "multiple-value %s in single-value context", ex)
+		}
+		return values[0], nil
+
+	case *ast.FunctionLiteral:
+		frame := e.frames[len(e.frames)-1]
+		fd := frame.module.literal(ex, frame.Function)
+		return e.result(ex.Pos, &object.Function{Declaration: fd, Env: frame.Env, Module: frame.module})
+
+	case *ast.CallExpression:
+		val, err := e.evalCallExpression(ex)
+		if err != nil {
+			return nil, err
+		}
+		if val == object.VOID {
+			return nil, e.errorf(ex.Pos, nil, "%s() used as value", callee(ex))
+		}
+		if _, ok := val.(*object.Tuple); ok {
+			return nil, e.errorf(ex.Pos, nil, "multiple-value %s() in single-value context", callee(ex))
+		}
+		return val, nil
+
+	case *ast.SliceLiteral:
+		return e.evalSliceLiteral(ex, nil)
+
+	case *ast.MapLiteral:
+		return e.evalMapLiteral(ex)
+
+	case *ast.StructLiteral:
+		return e.evalStructLiteral(ex)
+
+	case *ast.MatchExpression:
+		val, err := e.evalMatch(ex)
+		if err != nil {
+			return nil, err
+		}
+		if val == object.VOID {
+			return nil, e.errorf(ex.Pos, nil, "match used as value")
+		}
+		return val, nil
+
+	case *ast.SelectorExpression:
+		if ed := e.enumOf(ex.X); ed != nil {
+			return e.variant(ex.Sel.Pos, ed, ex.Sel.Value, nil)
+		}
+		x, err := e.evalExpression(ex.X)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := x.(*object.Struct)
+		if !ok || s.Get(ex.Sel.Value) == nil {
+			return nil, e.errorf(ex.Sel.Pos, nil, "%s undefined", ex)
+		}
+		return s.Get(ex.Sel.Value), nil
+
+	case *ast.IndexExpression:
+		val, _, err := e.evalElement(ex)
+		return val, err
+
+	case *ast.SliceExpression:
+		return e.evalSliceExpression(ex)
+
+	case *ast.InfixExpression:
+		return e.evalInfixExpression(ex)
+	}
+
+	return nil, e.errorf(ast.Start(expr), nil, "unexpected expression %T", expr)
+}
+
+func (e *Evaluator) evalCallExpression(call *ast.CallExpression) (object.Object, error) {
+	if call.Receiver != nil {
+		return e.evalMethodCall(call)
+	}
+	if call.Callee != nil {
+		fn, err := e.evalExpression(call.Callee)
+		if err != nil {
+			return nil, err
+		}
+		return e.callValue(call, fn)
+	}
+	name := call.Function.Value
+	if fn, ok := e.variable(name); ok && call.Module == nil {
+		// variables shadow functions and builtins
+		return e.callValue(call, fn)
+	}
+	if b, ok := builtins[name]; ok && call.Module == nil {
+		args, err := e.evalArguments(call.Arguments, nil)
+		if err != nil {
+			return nil, err
+		}
+		val, err := b(e, call.Pos, args)
+		if err != nil {
+			return nil, err
+		}
+		return e.result(call.Pos, val)
+	}
+
+	m := e.frames[len(e.frames)-1].module
+	if call.Module != nil {
+		imported, ok := m.imports[call.Module.Value]
+		if !ok || !ast.IsExported(name) {
+			return nil, e.errorf(call.Pos, nil, "undefined function %s.%s", call.Module.Value, name)
+		}
+		m = imported
+	}
+	if fd, ok := m.functions[name]; ok {
+		if len(call.Arguments) != len(fd.Parameters) {
+			return nil, e.errorf(call.Pos, nil, "%s", wrongArgumentCount(fd, len(call.Arguments)))
+		}
+		args, err := e.evalArguments(call.Arguments, fd.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		return e.call(m, fd, nil, args, call.Pos)
+	}
+	if call.Module != nil {
+		return nil, e.errorf(call.Pos, nil, "undefined function %s.%s", call.Module.Value, name)
+	}
+
+	host, ok := e.host[name]
+	if !ok {
+		return nil, e.errorf(call.Pos, nil, "undefined function %s", name)
+	}
+	args, err := e.evalArguments(call.Arguments, nil)
+	if err != nil {
+		return nil, err
+	}
+	val, err := host(args)
+	if err != nil {
+		return nil, e.errorf(call.Pos, err, "%s: %v", name, err)
+	}
+	if val == nil {
+		return object.VOID, nil
+	}
+	return e.result(call.Pos, val)
+}
+
+// evalMethodCall calls the method of the struct type of the receiver of
+// call, which is passed as a copy, or constructs a variant with payload.
+func (e *Evaluator) evalMethodCall(call *ast.CallExpression) (object.Object, error) {
+	if ed := e.enumOf(call.Receiver); ed != nil {
+		payload, err := e.evalArguments(call.Arguments, nil)
+		if err != nil {
+			return nil, err
+		}
+		return e.variant(call.Pos, ed, call.Function.Value, payload)
+	}
+	recv, err := e.evalExpression(call.Receiver)
+	if err != nil {
+		return nil, err
+	}
+	m := e.frames[len(e.frames)-1].module
+	s, ok := recv.(*object.Struct)
+	if ok && s.Get(call.Function.Value) != nil {
+		// fields of function types are called like methods
+		return e.callValue(call, s.Get(call.Function.Value))
+	}
+	var fd *ast.FunctionDeclaration
+	if ok {
+		fd, ok = m.methods[s.TypeName+"."+call.Function.Value]
+	}
+	if !ok {
+		return nil, e.errorf(call.Pos, nil, "undefined method %s of %s", call.Function.Value, recv.Type())
+	}
+	if len(call.Arguments) != len(fd.Parameters) {
+		return nil, e.errorf(call.Pos, nil, "%s", wrongArgumentCount(fd, len(call.Arguments)))
+	}
+	if recv, err = e.copy(ast.Start(call.Receiver), recv); err != nil {
+		return nil, err
+	}
+	args, err := e.evalArguments(call.Arguments, fd.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return e.call(m, fd, nil, append([]object.Object{recv}, args...), call.Pos)
+}
+
+// variable returns the value of the local or global variable called name.
+func (e *Evaluator) variable(name string) (object.Object, bool) {
+	frame := e.frames[len(e.frames)-1]
+	val, ok := frame.Env.Get(name)
+	if !ok {
+		val, ok = frame.module.globals.Get(name)
+	}
+	return val, ok
+}
+
+// callValue calls the function value fn with the arguments of call.
+func (e *Evaluator) callValue(call *ast.CallExpression, fn object.Object) (object.Object, error) {
+	f, ok := fn.(*object.Function)
+	if !ok {
+		return nil, e.errorf(call.Pos, nil, "cannot call %s", fn.Type())
+	}
+	if f.Declaration == nil {
+		return nil, e.errorf(call.Pos, nil, "call of nil function")
+	}
+	if len(call.Arguments) != len(f.Declaration.Parameters) {
+		return nil, e.errorf(call.Pos, nil, "%s", wrongArgumentCount(f.Declaration, len(call.Arguments)))
+	}
+	args, err := e.evalArguments(call.Arguments, f.Declaration.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return e.call(f.Module.(*Module), f.Declaration, f.Env, args, call.Pos)
+}
+
+// enumOf returns the enum declaration x refers to, if x is the name of an
+// enum that is not shadowed by a variable.
+func (e *Evaluator) enumOf(x ast.Expression) *ast.EnumDeclaration {
+	ident, ok := x.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	frame := e.frames[len(e.frames)-1]
+	if _, ok := frame.Env.Get(ident.Value); ok {
+		return nil
+	}
+	return frame.module.enums[ident.Value]
+}
+
+// variant returns the variant called name of the enum ed with payload.
+func (e *Evaluator) variant(pos token.Pos, ed *ast.EnumDeclaration, name string, payload []object.Object) (object.Object, error) {
+	var v *ast.Variant
+	for _, variant := range ed.Variants {
+		if variant.Identifier.Value == name {
+			v = variant
+		}
+	}
+	if v == nil {
+		return nil, e.errorf(pos, nil, "undefined variant %s.%s", ed.Identifier.Value, name)
+	}
+	if len(payload) != len(v.Payload) {
+		return nil, e.errorf(pos, nil, "wrong payload for %s.%s: want %d values, got %d", ed.Identifier.Value, name, len(v.Payload), len(payload))
+	}
+	for i, typ := range v.Payload {
+		if isFloat(typ) {
+			payload[i] = convert(payload[i], &object.Float{})
+		}
+	}
+	zero := e.frames[len(e.frames)-1].module.zeroValue(ed.Identifier).(*object.Enum)
+	return e.result(pos, &object.Enum{TypeName: zero.TypeName, Variant: name, Payload: payload, Zero: zero})
+}
+
+// evalMatch evaluates the body of the first arm of me whose pattern matches
+// the subject and whose guard is true. The names a pattern binds are
+// declared in an environment of their own for its guard and body. The body
+// may be a call of a void function, then the result is VOID.
+func (e *Evaluator) evalMatch(me *ast.MatchExpression) (object.Object, error) {
+	subject, err := e.evalExpression(me.Subject)
+	if err != nil {
+		return nil, err
+	}
+	frame := e.frames[len(e.frames)-1]
+	env := frame.Env
+	defer func() { frame.Env = env }()
+
+	for _, arm := range me.Arms {
+		frame.Env = object.NewEnclosedEnvironment(env)
+		if ok, err := e.match(arm.Pattern, subject); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if arm.Guard != nil {
+			guard, err := e.evalExpression(arm.Guard)
+			if err != nil {
+				return nil, err
+			}
+			if i, ok := guard.(*object.Integer); !ok || i.Value == 0 {
+				continue
+			}
+		}
+		switch body := arm.Body.(type) {
+		case *ast.CallExpression:
+			return e.evalCallExpression(body)
+		case *ast.MatchExpression:
+			return e.evalMatch(body)
+		}
+		return e.evalExpression(arm.Body)
+	}
+	return nil, e.errorf(me.Pos, nil, "no match arm for %s", subject.Inspect())
+}
+
+// match reports whether val matches the pattern pat and binds the names in
+// pat to the matched values.
+func (e *Evaluator) match(pat ast.Expression, val object.Object) (bool, error) {
+	switch p := pat.(type) {
+	case *ast.Identifier:
+		if p.Value == "_" {
+			return true, nil
+		}
+		if _, shadowed := e.variable(p.Value); p.Value == "nil" && !shadowed {
+			return val == object.NIL, nil
+		}
+		if err := e.allocate(p.Pos, sizeVariable); err != nil {
+			return false, err
+		}
+		val, err := e.copy(p.Pos, val)
+		if err != nil {
+			return false, err
+		}
+		e.frames[len(e.frames)-1].Env.Set(p.Value, val)
+		return true, nil
+	case *ast.IntLiteral:
+		i, ok := val.(*object.Integer)
+		return ok && i.Value == p.Value, nil
+	case *ast.FloatLiteral:
+		f, ok := val.(*object.Float)
+		return ok && f.Value == p.Value, nil
+	case *ast.StringLiteral:
+		s, ok := val.(*object.String)
+		return ok && s.Value == p.Value, nil
+	case *ast.SelectorExpression:
+		v, ok := val.(*object.Enum)
+		return ok && v.Variant == p.Sel.Value, nil
+	case *ast.CallExpression:
+		v, ok := val.(*object.Enum)
+		if !ok || v.Variant != p.Function.Value || len(v.Payload) != len(p.Arguments) {
+			return false, nil
+		}
+		for i, arg := range p.Arguments {
+			if ok, err := e.match(arg, v.Payload[i]); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, e.errorf(ast.Start(pat), nil, "invalid pattern %s", pat)
+}
+
+// evalArguments evaluates the arguments of a call of a function with params,
+// which are nil for builtins and host functions. Structs are copied.
+func (e *Evaluator) evalArguments(exprs []ast.Expression, params []*ast.Parameter) ([]object.Object, error) {
+	args := make([]object.Object, len(exprs))
+	for i, arg := range exprs {
+		var typ ast.Expression
+		if i < len(params) {
+			typ = params[i].Type
+		}
+		val, err := e.evalAs(arg, typ)
+		if err != nil {
+			return nil, err
+		}
+		if args[i], err = e.copy(ast.Start(arg), val); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// evalAs is like evalExpression for a value of type typ, which may be nil if
+// the type is not known. The type checker lets constant ints be used where
+// floats are expected, they are converted.
+func (e *Evaluator) evalAs(expr, typ ast.Expression) (object.Object, error) {
+	if lit, ok := expr.(*ast.SliceLiteral); ok {
+		var elem ast.Expression
+		if t, ok := typ.(*ast.SliceType); ok {
+			elem = t.Elem
+		}
+		return e.evalSliceLiteral(lit, elem)
+	}
+	val, err := e.evalExpression(expr)
+	if err != nil || !isFloat(typ) {
+		return val, err
+	}
+	return convert(val, &object.Float{}), nil
+}
+
+// isFloat reports whether typ is the float type.
+func isFloat(typ ast.Expression) bool {
+	ident, ok := typ.(*ast.Identifier)
+	return ok && ident.Value == "float"
+}
+
+// convert returns val as a float if it is an int and like is a float, and
+// val otherwise.
+func convert(val, like object.Object) object.Object {
+	i, ok := val.(*object.Integer)
+	if _, isFloat := like.(*object.Float); ok && isFloat {
+		return &object.Float{Value: float64(i.Value)}
+	}
+	return val
+}
+
+// evalSliceLiteral evaluates the elements of lit, whose element type elem
+// may be nil. Without it the first element gives the type of the others.
+func (e *Evaluator) evalSliceLiteral(lit *ast.SliceLiteral, elem ast.Expression) (object.Object, error) {
+	if err := e.allocate(lit.Pos, sizeSlice+sizeElement*int64(len(lit.Elements))); err != nil {
+		return nil, err
+	}
+	elems := make([]object.Object, len(lit.Elements))
+	for i, x := range lit.Elements {
+		val, err := e.evalAs(x, elem)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && elem == nil {
+			val = convert(val, elems[0])
+		}
+		if elems[i], err = e.copy(ast.Start(x), val); err != nil {
+			return nil, err
+		}
+	}
+	return &object.Slice{Elements: elems}, nil
+}
+
+// result accounts for the result of a builtin or host function called at
+// pos and returns it.
+func (e *Evaluator) result(pos token.Pos, val object.Object) (object.Object, error) {
+	if err := e.allocate(pos, sizeOf(val)); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// evalMapLiteral evaluates the pairs of lit in order. The zero value of the
+// values is taken from the type of lit or else from its first value.
+func (e *Evaluator) evalMapLiteral(lit *ast.MapLiteral) (object.Object, error) {
+	if err := e.allocate(lit.Pos, sizeMap+sizeEntry*int64(len(lit.Pairs))); err != nil {
+		return nil, err
+	}
+	var m *object.Map
+	var elem ast.Expression
+	if t, ok := lit.Type.(*ast.MapType); ok {
+		m = object.NewMap(e.frames[len(e.frames)-1].module.zeroValue(t.Value))
+		elem = t.Value
+	}
+	for _, pair := range lit.Pairs {
+		key, err := e.evalExpression(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := e.evalAs(pair.Value, elem)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			val = convert(val, m.Zero)
+		}
+		if val, err = e.copy(ast.Start(pair.Value), val); err != nil {
+			return nil, err
+		}
+		if m == nil {
+			m = object.NewMap(object.ZeroOf(val))
+		}
+		m.Set(key, val)
+	}
+	if m == nil {
+		return nil, e.errorf(lit.Pos, nil, "cannot infer type of empty map literal")
+	}
+	return m, nil
+}
+
+// evalStructLiteral evaluates the fields of lit in order, the fields that
+// are left out have their zero value.
+func (e *Evaluator) evalStructLiteral(lit *ast.StructLiteral) (object.Object, error) {
+	zero := e.frames[len(e.frames)-1].module.zeroValue(lit.Type)
+	s, ok := zero.(*object.Struct)
+	if !ok {
+		return nil, e.errorf(lit.Type.Pos, nil, "undefined struct type %s", lit.Type.Value)
+	}
+	if err := e.allocate(lit.Pos, sizeOf(s)); err != nil {
+		return nil, err
+	}
+	sd := e.frames[len(e.frames)-1].module.structs[lit.Type.Value]
+	for _, field := range lit.Fields {
+		val, err := e.evalAs(field.Value, fieldType(sd, field.Key.(*ast.Identifier).Value))
+		if err != nil {
+			return nil, err
+		}
+		if val, err = e.copy(ast.Start(field.Value), val); err != nil {
+			return nil, err
+		}
+		if !s.Set(field.Key.(*ast.Identifier).Value, val) {
+			return nil, e.errorf(ast.Start(field.Key), nil, "unknown field %s in struct literal of type %s", field.Key, s.TypeName)
+		}
+	}
+	return s, nil
+}
+
+// fieldType returns the type of the field called name of sd, or nil if there
+// is no such field.
+func fieldType(sd *ast.StructDeclaration, name string) ast.Expression {
+	for _, f := range sd.Fields {
+		if f.Identifier.Value == name {
+			return f.Type
+		}
+	}
+	return nil
+}
+
+// evalElement returns the element of a slice or map and whether it is
+// present. Missing map elements are the zero value, indexes out of the
+// range of a slice are an error.
+func (e *Evaluator) evalElement(index *ast.IndexExpression) (object.Object, bool, error) {
+	left, err := e.evalExpression(index.Left)
+	if err != nil {
+		return nil, false, err
+	}
+	if m, ok := left.(*object.Map); ok {
+		key, err := e.evalExpression(index.Index)
+		if err != nil {
+			return nil, false, err
+		}
+		val, found := m.Get(key)
+		if !found {
+			if val, err = e.result(index.Pos, val); err != nil {
+				return nil, false, err
+			}
+		}
+		return val, found, nil
+	}
+	slice, ok := left.(*object.Slice)
+	if !ok {
+		return nil, false, e.errorf(ast.Start(index.Left), nil, "cannot index %s", left.Type())
+	}
+	i, err := e.evalIndex(index.Index)
+	if err != nil {
+		return nil, false, err
+	}
+	if i < 0 || i >= int64(len(slice.Elements)) {
+		return nil, false, e.errorf(index.Pos, nil, "index out of range [%d] with length %d", i, len(slice.Elements))
+	}
+	return slice.Elements[i], true, nil
+}
+
+// evalSliceExpression returns a slice sharing the elements of the slice it
+// is taken from, the bounds default to the start and end of the slice.
+func (e *Evaluator) evalSliceExpression(se *ast.SliceExpression) (object.Object, error) {
+	slice, err := e.evalSlice(se.Left)
+	if err != nil {
+		return nil, err
+	}
+	low, high := int64(0), int64(len(slice.Elements))
+	if se.Low != nil {
+		if low, err = e.evalIndex(se.Low); err != nil {
+			return nil, err
+		}
+	}
+	if se.High != nil {
+		if high, err = e.evalIndex(se.High); err != nil {
+			return nil, err
+		}
+	}
+	if low < 0 || high < low || high > int64(len(slice.Elements)) {
+		return nil, e.errorf(se.Pos, nil, "slice bounds out of range [%d:%d] with length %d", low, high, len(slice.Elements))
+	}
+	return e.result(se.Pos, &object.Slice{Elements: slice.Elements[low:high]})
+}
+
+// evalSlice evaluates the operand of a slice expression.
+func (e *Evaluator) evalSlice(expr ast.Expression) (*object.Slice, error) {
+	val, err := e.evalExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	slice, ok := val.(*object.Slice)
+	if !ok {
+		return nil, e.errorf(ast.Start(expr), nil, "cannot index %s", val.Type())
+	}
+	return slice, nil
+}
+
+// evalIndex evaluates an index or slice bound.
+func (e *Evaluator) evalIndex(expr ast.Expression) (int64, error) {
+	val, err := e.evalExpression(expr)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := val.(*object.Integer)
+	if !ok {
+		return 0, e.errorf(ast.Start(expr), nil, "invalid index of type %s", val.Type())
+	}
+	return i.Value, nil
+}
+
+func (e *Evaluator) evalInfixExpression(infix *ast.InfixExpression) (object.Object, error) {
+	left, err := e.evalExpression(infix.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.evalExpression(infix.Right)
+	if err != nil {
+		return nil, err
+	}
+	if l, ok := left.(*object.String); ok {
+		if r, ok := right.(*object.String); ok && infix.Token == token.ADD {
+			return e.result(infix.Pos, &object.String{Value: l.Value + r.Value})
+		}
+	}
+
+	if res, ok := floatOperands(left, right); ok {
+		val, err := arith.Float(infix.Token, res[0], res[1])
+		if err != nil {
+			return nil, e.errorf(infix.Pos, nil, "unknown operator %s", infix.Operator)
+		}
+		return e.result(infix.Pos, &object.Float{Value: val})
+	}
+
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return nil, e.errorf(infix.Pos, nil, "invalid operation: %s %s %s", left.Type(), infix.Operator, right.Type())
+	}
+
+	res, err := arith.Int(infix.Token, l.Value, r.Value)
+	switch err {
+	case nil:
+	case arith.ErrDivideByZero:
+		return nil, e.errorf(infix.Pos, nil, "%v", err)
+	case arith.ErrOverflow:
+		return nil, e.errorf(infix.Pos, nil, "integer overflow: %d %s %d", l.Value, infix.Operator, r.Value)
+	default:
+		return nil, e.errorf(infix.Pos, nil, "unknown operator %s", infix.Operator)
+	}
+
+	if err := e.allocate(infix.Pos, sizeInteger); err != nil {
+		return nil, err
+	}
+	return &object.Integer{Value: res}, nil
+}
+
+// floatOperands returns the values of left and right if at least one of them
+// is a float. The type checker only lets constant ints meet floats, they are
+// converted.
+func floatOperands(left, right object.Object) ([2]float64, bool) {
+	var res [2]float64
+	isFloat := false
+	for i, operand := range []object.Object{left, right} {
+		switch o := operand.(type) {
+		case *object.Float:
+			res[i] = o.Value
+			isFloat = true
+		case *object.Integer:
+			res[i] = float64(o.Value)
+		default:
+			return res, false
+		}
+	}
+	return res, isFloat
+}
+
+func wrongArgumentCount(fd *ast.FunctionDeclaration, got int) string {
+	return fmt.Sprintf("wrong number of arguments for %s: want %d, got %d", fd.Name(), len(fd.Parameters), got)
+}
+
+// errorf returns a RuntimeError at pos with the current call stack, cause
+// is the cause of the error, if any.
+func (e *Evaluator) errorf(pos token.Pos, cause error, format string, args ...interface{}) *RuntimeError {
+	err := &RuntimeError{Pos: pos, Msg: fmt.Sprintf(format, args...), Err: cause}
+	for i := len(e.frames) - 1; i >= 0; i-- {
+		frame := e.frames[i]
+		framePos := frame.call
+		if i == len(e.frames)-1 {
+			framePos = pos
+		}
+		err.Stack = append(err.Stack, StackFrame{Function: frame.Function.Name(), File: e.file(frame.module), Pos: framePos})
+	}
+	return err
+}
+
+// file returns the name of the source file of m.
+func (e *Evaluator) file(m *Module) string {
+	if m == e.main {
+		return e.File
+	}
+	return m.file
+}
+
+func (e *Evaluator) output() io.Writer {
+	if e.Out == nil {
+		return os.Stdout
+	}
+	return e.Out
+}
+
+// step counts the execution of the statement at pos and checks that the
+// program may go on.
+func (e *Evaluator) step(pos token.Pos) error {
+	select {
+	case <-e.done:
+		return e.errorf(pos, e.ctx.Err(), "%v", e.ctx.Err())
+	default:
+	}
+
+	e.steps++
+	if e.Limits.MaxSteps > 0 && e.steps > e.Limits.MaxSteps {
+		return e.errorf(pos, ErrStepLimit, "%v", ErrStepLimit)
+	}
+	return nil
+}
+
+// allocate accounts for size bytes allocated at pos.
+func (e *Evaluator) allocate(pos token.Pos, size int64) error {
+	e.allocated += size
+	if e.Limits.MaxMemory > 0 && e.allocated > e.Limits.MaxMemory {
+		return e.errorf(pos, ErrMemoryLimit, "%v", ErrMemoryLimit)
+	}
+	return nil
+}
+
+// sizeOf returns the size of obj for the memory limit.
+func sizeOf(obj object.Object) int64 {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return sizeInteger
+	case *object.Float:
+		return sizeFloat
+	case *object.String:
+		return sizeString + int64(len(o.Value))
+	case *object.Slice:
+		// the elements are accounted for when they are added
+		return sizeSlice
+	case *object.Map:
+		// the entries are accounted for when they are added
+		return sizeMap
+	case *object.Struct:
+		size := sizeStruct + sizeElement*int64(len(o.Fields))
+		for _, f := range o.Fields {
+			size += sizeOf(f.Value)
+		}
+		return size
+	case *object.Enum:
+		// the payload is accounted for when it is evaluated
+		return sizeEnum + sizeElement*int64(len(o.Payload))
+	case *object.Function:
+		return sizeFunction
+	case *object.Tuple:
+		// the results are accounted for when they are evaluated
+		return sizeTuple + sizeElement*int64(len(o.Elements))
+	case *object.Error:
+		return sizeError + int64(len(o.Message))
+	}
+	return 0
+}
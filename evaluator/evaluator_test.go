@@ -0,0 +1,697 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	program, err := parser.NewParser(scanner.NewScanner(src)).Parse()
+	require.NoError(t, err)
+	return program
+}
+
+func TestEvaluator_Run(t *testing.T) {
+	t.Run("returns_result_of_main", func(t *testing.T) {
+		result, err := New(parse(t, "fn helper() int {\n\tx := 42;\n\treturn x;\n}\n\nfn main() int {\n\treturn helper();\n}")).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 42}, result)
+	})
+
+	t.Run("returns_void_without_return", func(t *testing.T) {
+		result, err := New(parse(t, "fn main() {\n\tx := 1;\n}")).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, object.VOID, result)
+	})
+
+	t.Run("passes_arguments", func(t *testing.T) {
+		result, err := New(parse(t, "fn second(a int, b int) int {\n\treturn b;\n}\n\nfn main() int {\n\tx := 2;\n\treturn second(1, x);\n}")).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 2}, result)
+	})
+
+	t.Run("fails_on_wrong_number_of_arguments", func(t *testing.T) {
+		_, err := New(parse(t, "fn id(a int) int {\n\treturn a;\n}\n\nfn main() int {\n\treturn id();\n}")).Run(context.Background())
+		assert.EqualError(t, err, "6:9: wrong number of arguments for id: want 1, got 0")
+	})
+
+	t.Run("keeps_variables_local", func(t *testing.T) {
+		_, err := New(parse(t, "fn helper() int {\n\treturn x;\n}\n\nfn main() int {\n\tx := 1;\n\treturn helper();\n}")).Run(context.Background())
+		assert.EqualError(t, err, "2:9: identifier not found: x")
+	})
+
+	t.Run("fails_on_void_value", func(t *testing.T) {
+		_, err := New(parse(t, "fn helper() {\n}\n\nfn main() {\n\tx := helper();\n}")).Run(context.Background())
+		assert.EqualError(t, err, "5:7: helper() used as value")
+	})
+
+	t.Run("returns_early_from_void_functions", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "fn first(xs []int) {\n\tfor x in xs {\n\t\tprintln(x);\n\t\treturn;\n\t}\n\tprintln(\"empty\");\n}\n\nfn main() {\n\tfirst([1, 2]);\n\tfirst([]);\n\treturn;\n}"))
+		e.Out = &out
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, object.VOID, result)
+		assert.Equal(t, "1\nempty\n", out.String())
+	})
+
+	t.Run("fails_without_main", func(t *testing.T) {
+		_, err := New(parse(t, "fn helper() {\n}")).Run(context.Background())
+		assert.EqualError(t, err, "-: undefined function main")
+	})
+}
+
+func TestEvaluator_Arithmetic(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"7 / 2", 3},
+		{"0 - 7 / 2", -3},
+		{"9223372036854775807 - 1 + 1", 9223372036854775807},
+	}
+	for _, tt := range tests {
+		result, err := New(parse(t, "fn main() int {\n\treturn "+tt.source+";\n}")).Run(context.Background())
+		require.NoError(t, err, tt.source)
+		assert.Equal(t, &object.Integer{Value: tt.want}, result, tt.source)
+	}
+}
+
+func TestEvaluator_RuntimeError(t *testing.T) {
+	const src = "fn div(a int, b int) int {\n\treturn a / b;\n}\n\nfn ratio(x int) int {\n\ty := x * 2;\n\treturn div(y, x - x);\n}\n\nfn main() int {\n\treturn ratio(3);\n}"
+
+	t.Run("reports_division_by_zero_with_stack", func(t *testing.T) {
+		e := New(parse(t, src))
+		e.File = "prog.em"
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "2:11: integer divide by zero")
+
+		var rerr *RuntimeError
+		require.ErrorAs(t, err, &rerr)
+		assert.Equal(t, []StackFrame{
+			{Function: "div", File: "prog.em", Pos: token.Pos{Line: 2, Column: 11}},
+			{Function: "ratio", File: "prog.em", Pos: token.Pos{Line: 7, Column: 9}},
+			{Function: "main", File: "prog.em", Pos: token.Pos{Line: 11, Column: 9}},
+		}, rerr.Stack)
+		assert.Equal(t, "\tat div (prog.em:2:11)\n\tat ratio (prog.em:7:9)\n\tat main (prog.em:11:9)\n", rerr.StackTrace())
+	})
+
+	t.Run("reports_overflow", func(t *testing.T) {
+		tests := []string{
+			"9223372036854775807 + 1",
+			"0 - 9223372036854775807 - 2",
+			"4611686018427387904 * 2",
+			"(0 - 9223372036854775807 - 1) / (0 - 1)",
+		}
+		for _, source := range tests {
+			_, err := New(parse(t, "fn main() int {\n\treturn "+source+";\n}")).Run(context.Background())
+			var rerr *RuntimeError
+			if assert.ErrorAs(t, err, &rerr, source) {
+				assert.Contains(t, rerr.Msg, "integer overflow", source)
+				assert.Len(t, rerr.Stack, 1, source)
+			}
+		}
+	})
+
+	t.Run("reports_undefined_functions_with_stack", func(t *testing.T) {
+		e := New(parse(t, "fn helper() {\n\tmissing();\n}\n\nfn main() {\n\thelper();\n}"))
+		_, err := e.Run(context.Background())
+		var rerr *RuntimeError
+		require.ErrorAs(t, err, &rerr)
+		assert.Equal(t, "2:2: undefined function missing", rerr.Error())
+		assert.Equal(t, "\tat helper (2:2)\n\tat main (6:2)\n", rerr.StackTrace())
+	})
+
+	t.Run("elides_deep_stacks", func(t *testing.T) {
+		e := New(parse(t, "fn main() int {\n\treturn main();\n}"))
+		e.Limits.MaxDepth = 100
+		_, err := e.Run(context.Background())
+		var rerr *RuntimeError
+		require.ErrorAs(t, err, &rerr)
+		assert.Len(t, rerr.Stack, 100)
+		trace := rerr.StackTrace()
+		assert.Contains(t, trace, "\t... 80 more calls ...\n")
+		assert.Equal(t, maxTraceFrames+1, strings.Count(trace, "\n"))
+	})
+}
+
+func TestEvaluator_Builtins(t *testing.T) {
+	run := func(t *testing.T, body string) (string, error) {
+		var out bytes.Buffer
+		e := New(parse(t, "fn main() {\n"+body+"\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		return out.String(), err
+	}
+
+	t.Run("prints_values", func(t *testing.T) {
+		out, err := run(t, "\tprint(\"a\", 1, \"b\");\n\tprintln();\n\tprintln(\"x =\", 1 + 2, \"y\" + \"z\");")
+		require.NoError(t, err)
+		assert.Equal(t, "a1b\nx = 3 yz\n", out)
+	})
+
+	t.Run("computes_values", func(t *testing.T) {
+		out, err := run(t, "\tprintln(len(\"hello\"), abs(0 - 3), abs(4), min(3, 1, 2), max(3, 1, 2), min(5));")
+		require.NoError(t, err)
+		assert.Equal(t, "5 3 4 1 3 5\n", out)
+	})
+
+	t.Run("takes_precedence_over_functions", func(t *testing.T) {
+		e := New(parse(t, "fn len(s string) int {\n\treturn 0;\n}\n\nfn main() int {\n\treturn len(\"abc\");\n}"))
+		e.Define("abs", func(args []object.Object) (object.Object, error) { return &object.Integer{}, nil })
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 3}, result)
+	})
+
+	t.Run("fails_assertions", func(t *testing.T) {
+		_, err := run(t, "\tassert(1);\n\tassert(0);")
+		assert.EqualError(t, err, "3:2: assertion failed")
+
+		_, err = run(t, "\tassert(2 - 2, \"not zero\");")
+		assert.EqualError(t, err, "2:2: assertion failed: not zero")
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		out, err := run(t, "\tprintln(1);\n\tpanic(\"oops\");\n\tprintln(2);")
+		var rerr *RuntimeError
+		require.ErrorAs(t, err, &rerr)
+		assert.Equal(t, "3:2: panic: oops", rerr.Error())
+		assert.Equal(t, "\tat main (3:2)\n", rerr.StackTrace())
+		assert.Equal(t, "1\n", out)
+	})
+
+	t.Run("fails_on_invalid_arguments", func(t *testing.T) {
+		// programs that were not type checked may pass anything
+		tests := map[string]string{
+			"\tx := len();":             "2:7: not enough arguments in call to len",
+			"\tx := len(\"a\", \"b\");": "2:7: too many arguments in call to len",
+			"\tx := append(1, 2);":      "2:7: invalid argument INTEGER for append",
+			"\tdelete([1], 0);":         "2:2: invalid argument SLICE for delete",
+			"\tx := abs(\"a\");":        "2:7: invalid argument STRING for abs",
+			"\tx := min();":             "2:7: not enough arguments in call to min",
+			"\tx := max(1, \"a\");":     "2:7: invalid argument STRING for max",
+			"\tassert(\"a\");":          "2:2: invalid argument STRING for assert",
+			"\tpanic();":                "2:2: not enough arguments in call to panic",
+			"\tx := error(1);":          "2:7: invalid argument INTEGER for error",
+			"\tx := int(\"a\");":        "2:7: cannot convert STRING to int",
+			"\tx := float();":           "2:7: not enough arguments in call to float",
+		}
+		for body, want := range tests {
+			_, err := run(t, body)
+			var rerr *RuntimeError
+			if assert.ErrorAs(t, err, &rerr, body) {
+				assert.Equal(t, want, rerr.Error(), body)
+			}
+		}
+	})
+
+	t.Run("fails_on_overflow", func(t *testing.T) {
+		_, err := run(t, "\tx := abs(0 - 9223372036854775807 - 1);")
+		assert.EqualError(t, err, "2:7: integer overflow: abs(-9223372036854775808)")
+	})
+}
+
+func TestEvaluator_Globals(t *testing.T) {
+	t.Run("initializes_dependencies_first", func(t *testing.T) {
+		e := New(parse(t, "let a = b + 1;\nlet b = double(c);\nconst c = 2;\nvar s string;\n\nfn double(x int) int {\n\treturn 2 * x;\n}\n\nfn main() string {\n\treturn s + \"a\";\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.String{Value: "a"}, result)
+
+		result, err = e.Call(context.Background(), "double", &object.Integer{Value: 0})
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 0}, result)
+	})
+
+	t.Run("keeps_values_between_calls", func(t *testing.T) {
+		e := New(parse(t, "var n int;\n\nfn next() int {\n\tn = n + 1;\n\treturn n;\n}"))
+		for want := int64(1); want <= 3; want++ {
+			result, err := e.Call(context.Background(), "next")
+			require.NoError(t, err)
+			assert.Equal(t, &object.Integer{Value: want}, result)
+		}
+	})
+
+	t.Run("prefers_locals", func(t *testing.T) {
+		e := New(parse(t, "let x = 1;\n\nfn id(x int) int {\n\treturn x;\n}"))
+		result, err := e.Call(context.Background(), "id", &object.Integer{Value: 2})
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 2}, result)
+	})
+
+	t.Run("fails_in_init", func(t *testing.T) {
+		_, err := New(parse(t, "let x = 1 / zero();\n\nfn zero() int {\n\treturn 0;\n}\n\nfn main() {\n}")).Run(context.Background())
+		var runtimeErr *RuntimeError
+		require.ErrorAs(t, err, &runtimeErr)
+		assert.EqualError(t, err, "1:11: integer divide by zero")
+		assert.Equal(t, "\tat <init> (1:11)\n", runtimeErr.StackTrace())
+	})
+
+	t.Run("fails_on_initialization_cycles", func(t *testing.T) {
+		_, err := New(parse(t, "let x = x;\n\nfn main() {\n}")).Run(context.Background())
+		assert.EqualError(t, err, "1:5: initialization cycle: x refers to itself")
+	})
+
+	t.Run("fails_on_assignment_to_constants", func(t *testing.T) {
+		_, err := New(parse(t, "const x = 1;\n\nfn main() {\n\tx = 2;\n}")).Run(context.Background())
+		assert.EqualError(t, err, "4:2: cannot assign to constant x")
+	})
+}
+
+func TestEvaluator_Declarations(t *testing.T) {
+	t.Run("declares_local_variables", func(t *testing.T) {
+		result, err := New(parse(t, "fn main() string {\n\tlet n int = 2;\n\tvar s string;\n\tconst c = \"c\";\n\tx := n * 3;\n\tx = x + 1;\n\ts = s + c;\n\treturn s;\n}")).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.String{Value: "c"}, result)
+	})
+
+	t.Run("shadows_globals", func(t *testing.T) {
+		e := New(parse(t, "let x = 1;\n\nfn main() int {\n\tx := 2;\n\tx = 3;\n\treturn global();\n}\n\nfn global() int {\n\treturn x;\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 1}, result)
+	})
+
+	t.Run("fails_on_assignment_to_undeclared_variables", func(t *testing.T) {
+		_, err := New(parse(t, "fn main() {\n\tx = 1;\n}")).Run(context.Background())
+		assert.EqualError(t, err, "2:2: identifier not found: x")
+	})
+}
+
+func TestEvaluator_Slices(t *testing.T) {
+	t.Run("evaluates_slice_operations", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "fn main() int {\n\tlet xs []int;\n\txs = append(xs, 1, 2, 3);\n\tys := xs[1:];\n\tprintln(xs, ys, xs[:1], len(ys), [[\"a\"], []]);\n\treturn xs[2] * 10 + ys[0];\n}"))
+		e.Out = &out
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 32}, result)
+		assert.Equal(t, "[1, 2, 3] [2, 3] [1] 2 [[a], []]\n", out.String())
+	})
+
+	t.Run("iterates_over_slices", func(t *testing.T) {
+		e := New(parse(t, "fn main() int {\n\tn := 0;\n\tfor i, x in [10, 20, 30] {\n\t\tx := x + i;\n\t\tn = n + x;\n\t}\n\tfor x in [1] {\n\t\treturn n + x;\n\t}\n\treturn 0;\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 64}, result)
+	})
+
+	t.Run("fails_on_indexes_out_of_range", func(t *testing.T) {
+		tests := []struct {
+			expr string
+			err  string
+		}{
+			{"xs[3]", "3:12: index out of range [3] with length 3"},
+			{"xs[0 - 1]", "3:12: index out of range [-1] with length 3"},
+			{"xs[2:1]", "3:12: slice bounds out of range [2:1] with length 3"},
+			{"xs[:4]", "3:12: slice bounds out of range [0:4] with length 3"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.expr, func(t *testing.T) {
+				_, err := New(parse(t, "fn main() {\n\txs := [1, 2, 3];\n\tprintln("+tt.expr+");\n}")).Run(context.Background())
+				assert.EqualError(t, err, tt.err)
+			})
+		}
+	})
+}
+
+func TestEvaluator_Maps(t *testing.T) {
+	t.Run("evaluates_map_operations", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "fn main() int {\n\tm := {\"b\": 2, \"a\": 1};\n\tm[\"c\"] = 3;\n\tm[\"b\"] = 20;\n\tdelete(m, \"a\");\n\tv, ok := m[\"a\"];\n\tprintln(m, len(m), v, ok, m[\"x\"]);\n\treturn m[\"b\"] + m[\"c\"];\n}"))
+		e.Out = &out
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 23}, result)
+		assert.Equal(t, "{b: 20, c: 3} 2 0 0 0\n", out.String())
+	})
+
+	t.Run("iterates_in_insertion_order", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "fn main() {\n\tm := map[string]int{};\n\tfor k in [\"z\", \"a\", \"m\", \"a\"] {\n\t\tm[k] = m[k] + 1;\n\t}\n\tfor k, n in m {\n\t\tm[k + k] = n;\n\t\tprint(k, n, \" \");\n\t}\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "z1 a2 m1 ", out.String())
+	})
+
+	t.Run("returns_new_zero_values_for_missing_keys", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "fn main() {\n\tvar m map[int]map[string][]int;\n\tinner := m[1];\n\tinner[\"a\"] = append(inner[\"a\"], 1);\n\tprintln(m, inner, m[2]);\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "{} {a: [1]} {}\n", out.String())
+	})
+
+	t.Run("assigns_slice_elements", func(t *testing.T) {
+		_, err := New(parse(t, "fn main() {\n\txs := [1, 2, 3];\n\txs[3] = 4;\n}")).Run(context.Background())
+		assert.EqualError(t, err, "3:4: index out of range [3] with length 3")
+	})
+}
+
+func TestEvaluator_Structs(t *testing.T) {
+	t.Run("copies_struct_values", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "struct Point { x int, y int }\n\nstruct Line { a Point, b Point }\n\nfn main() {\n\tp := Point{x: 1};\n\tq := p;\n\tq.x = 2;\n\tl := Line{a: p};\n\tl.b.y = 3;\n\tps := [p];\n\tps[0].y = 4;\n\tp.y = 5;\n\tprintln(p, q, l, ps);\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "Point{x: 1, y: 5} Point{x: 2, y: 0} Line{a: Point{x: 1, y: 0}, b: Point{x: 0, y: 3}} [Point{x: 1, y: 4}]\n", out.String())
+	})
+
+	t.Run("calls_methods_on_copies", func(t *testing.T) {
+		e := New(parse(t, "struct Counter { n int }\n\nfn (c Counter) inc() Counter {\n\tc.n = c.n + 1;\n\treturn c;\n}\n\nfn main() int {\n\tc := Counter{};\n\td := c.inc().inc();\n\treturn c.n * 10 + d.n;\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 2}, result)
+	})
+
+	t.Run("reports_methods_in_stack_trace", func(t *testing.T) {
+		e := New(parse(t, "struct P { x int }\n\nfn (p P) fail() {\n\tpanic(\"boom\");\n}\n\nfn main() {\n\tP{}.fail();\n}"))
+		_, err := e.Run(context.Background())
+		var rerr *RuntimeError
+		require.ErrorAs(t, err, &rerr)
+		assert.Equal(t, "P.fail", rerr.Stack[0].Function)
+	})
+}
+
+func TestEvaluator_Enums(t *testing.T) {
+	t.Run("matches_variants_and_payloads", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "enum Shape { Circle(int), Rect(int, int), Empty }\n\nfn area(s Shape) int {\n\treturn match s {\n\t\tShape.Circle(r) => 3 * r * r,\n\t\tShape.Rect(w, h) if w - h => w * h,\n\t\tShape.Rect(w, _) => w * w,\n\t\tShape.Empty => 0,\n\t};\n}\n\nfn main() {\n\tvar z Shape;\n\tprintln(z, area(Shape.Circle(2)), area(Shape.Rect(2, 3)), area(Shape.Rect(3, 3)), area(Shape.Empty));\n\tmatch Shape.Rect(1, 2) {\n\t\tShape.Empty => println(\"empty\"),\n\t\ts => println(s),\n\t};\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "Shape.Circle(0) 12 6 9 0\nShape.Rect(1, 2)\n", out.String())
+	})
+
+	t.Run("matches_literals", func(t *testing.T) {
+		e := New(parse(t, "fn main() string {\n\tn := 2;\n\treturn match n {\n\t\t1 => \"one\",\n\t\t2 => \"two\",\n\t\t_ => \"many\",\n\t};\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.String{Value: "two"}, result)
+	})
+
+	t.Run("uses_first_variant_as_zero_value", func(t *testing.T) {
+		e := New(parse(t, "struct P { x int }\n\nenum E { A(P, string), B }\n\nfn main() E {\n\tm := map[int]E{};\n\treturn m[1];\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "E.A(P{x: 0}, )", result.Inspect())
+	})
+
+	t.Run("fails_if_no_arm_matches", func(t *testing.T) {
+		e := New(parse(t, "fn main() int {\n\treturn match 3 {\n\t\tn if n - 3 => n,\n\t};\n}"))
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "2:9: no match arm for 3")
+	})
+}
+
+func TestEvaluator_Functions(t *testing.T) {
+	t.Run("calls_function_values", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "struct Button { onClick fn(int) int }\n\nfn makeAdder(n int) fn(int) int {\n\treturn fn(x int) int {\n\t\treturn x + n;\n\t};\n}\n\nfn double(x int) int {\n\treturn x * 2;\n}\n\nfn main() {\n\tfs := [double, makeAdder(10)];\n\tb := Button{onClick: double};\n\tprintln(makeAdder(1)(2), fs[0](3), fs[1](3), b.onClick(4), double);\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "3 6 13 8 fn double\n", out.String())
+	})
+
+	t.Run("captures_variables_by_reference", func(t *testing.T) {
+		e := New(parse(t, "fn main() int {\n\tcount := 0;\n\tinc := fn() {\n\t\tcount = count + 1;\n\t};\n\tinc();\n\tinc();\n\tvar fib fn(int) int;\n\tfib = fn(n int) int {\n\t\treturn match n {\n\t\t\t0 => 0,\n\t\t\t1 => 1,\n\t\t\t_ => fib(n - 1) + fib(n - 2),\n\t\t};\n\t};\n\treturn count * 100 + fib(10);\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 255}, result)
+	})
+
+	t.Run("captures_a_variable_per_iteration", func(t *testing.T) {
+		e := New(parse(t, "fn main() int {\n\tvar fs []fn() int;\n\tfor x in [1, 2, 3] {\n\t\tfs = append(fs, fn() int {\n\t\t\treturn x;\n\t\t});\n\t}\n\treturn fs[0]() * 100 + fs[1]() * 10 + fs[2]();\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 123}, result)
+	})
+
+	t.Run("names_function_literals_in_stack_traces", func(t *testing.T) {
+		e := New(parse(t, "fn main() {\n\tfail := fn() {\n\t\tpanic(\"boom\");\n\t};\n\tfail();\n}"))
+		_, err := e.Run(context.Background())
+		var rerr *RuntimeError
+		require.ErrorAs(t, err, &rerr)
+		assert.Equal(t, "\tat main.func (3:3)\n\tat main (5:2)\n", rerr.StackTrace())
+	})
+
+	t.Run("fails_on_calls_of_nil_functions", func(t *testing.T) {
+		e := New(parse(t, "fn main() {\n\tvar f fn();\n\tf();\n}"))
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "3:2: call of nil function")
+	})
+}
+
+func TestEvaluator_MultipleResults(t *testing.T) {
+	t.Run("destructures_results", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "struct P { x int }\n\nfn divmod(a int, b int) (int, int) {\n\treturn a / b, a - a / b * b;\n}\n\nfn again(a int) (int, int) {\n\treturn divmod(a, 4);\n}\n\nfn swap(p P, q P) (P, P) {\n\treturn q, p;\n}\n\nfn main() {\n\tq, r := divmod(7, 2);\n\tprintln(q, r);\n\tq, r = again(9);\n\tprintln(q, r);\n\tp := P{x: 1};\n\ta, b := swap(p, P{x: 2});\n\ta.x = 3;\n\tprintln(a.x, b.x, p.x);\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "3 1\n2 1\n3 1 1\n", out.String())
+	})
+
+	t.Run("returns_results_of_main_as_tuple", func(t *testing.T) {
+		e := New(parse(t, "fn main() (int, string) {\n\treturn 1, \"one\";\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Tuple{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "one"}}}, result)
+	})
+
+	t.Run("fails_on_mismatched_assignments", func(t *testing.T) {
+		e := New(parse(t, "fn pair() (int, int) {\n\treturn 1, 2;\n}\n\nfn main() {\n\ta, b, c := pair();\n}"))
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "6:2: assignment mismatch: 3 variables but pair() returns 2 values")
+	})
+}
+
+func TestEvaluator_Errors(t *testing.T) {
+	t.Run("propagates_errors", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "struct Config { port int }\n\nfn parse(s string) (int, error) {\n\tports := {\"80\": 80};\n\tport, ok := ports[s];\n\treturn port, match ok {\n\t\t0 => error(\"invalid port \" + s),\n\t\t_ => nil,\n\t};\n}\n\nfn load(s string) (Config, error) {\n\tport := parse(s)?;\n\treturn Config{port: port}, nil;\n}\n\nfn describe(err error) string {\n\treturn match err {\n\t\tnil => \"ok\",\n\t\te => \"failed\",\n\t};\n}\n\nfn main() {\n\tc, err := load(\"80\");\n\tprintln(c.port, err, describe(err));\n\tc, err = load(\"x\");\n\tprintln(c.port, err, describe(err));\n}"))
+		e.Out = &out
+		_, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "80 nil ok\n0 invalid port x failed\n", out.String())
+	})
+
+	t.Run("returns_propagated_errors_from_main", func(t *testing.T) {
+		e := New(parse(t, "fn fail() error {\n\treturn error(\"boom\");\n}\n\nfn main() error {\n\tf := fn() error {\n\t\tfail()?;\n\t\treturn nil;\n\t};\n\tf()?;\n\tpanic(\"not reached\");\n\treturn nil;\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Error{Message: "boom"}, result)
+	})
+
+	t.Run("uses_nil_as_zero_value", func(t *testing.T) {
+		e := New(parse(t, "struct Result { err error }\n\nfn main() error {\n\tr := Result{};\n\treturn r.err;\n}"))
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, object.NIL, result)
+	})
+}
+
+func TestEvaluator_Floats(t *testing.T) {
+	t.Run("evaluates_float_arithmetic_and_conversions", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "fn mean(xs []int) float {\n\tvar sum float;\n\tfor _, x in xs {\n\t\tsum = sum + float(x);\n\t}\n\treturn sum / float(len(xs));\n}\n\nfn main() int {\n\tprintln(mean([1, 2, 4]), 1.5e3, 2 / 4.0, 0xff + 0o17 + 0b11 + 1_000);\n\tzero := 0.0;\n\tprintln(int(0 - 2.9), float(7) / 2, 1.0 / zero);\n\treturn int(mean([5, 6]));\n}"))
+		e.Out = &out
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 5}, result)
+		assert.Equal(t, "2.3333333333333335 1500 0.5 1273\n-2 3.5 +Inf\n", out.String())
+	})
+
+	t.Run("reports_conversions_out_of_range", func(t *testing.T) {
+		e := New(parse(t, "fn main() int {\n\tx := 1e300;\n\treturn int(x * x);\n}"))
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "3:9: integer overflow: int(+Inf)")
+	})
+
+	t.Run("converts_int_constants_where_floats_are_expected", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(parse(t, "const g float = 1;\n\nstruct P { x float, ys []float }\n\nenum Shape { Circle(float) }\n\nfn half(x float) float {\n\treturn x / 2;\n}\n\nfn one() float {\n\treturn 1;\n}\n\nfn main() float {\n\tlet x float = 1;\n\ty := 0.5;\n\ty = 3;\n\tlet xs []float = [1, 3];\n\tzs := [0.5, 1];\n\tm := map[string]float{\"a\": 1};\n\tp := P{x: 1, ys: [1]};\n\tp.x = 3;\n\ts := Shape.Circle(1);\n\tprintln(g / 2, x / 2, y / 2, xs[0] / 2, zs[1] / 2, m[\"a\"] / 2, p.x / 2, p.ys[0] / 2, half(1), one() / 2);\n\treturn match s {\n\t\tShape.Circle(r) => r / 2,\n\t};\n}"))
+		e.Out = &out
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Float{Value: 0.5}, result)
+		assert.Equal(t, "0.5 0.5 1.5 0.5 0.5 0.5 1.5 0.5 0.5 0.5\n", out.String())
+	})
+}
+
+func TestEvaluator_Import(t *testing.T) {
+	t.Run("calls_exported_functions", func(t *testing.T) {
+		lib := NewModule("lib.em", parse(t, "var calls int;\n\nfn Count() int {\n\tcalls = calls + 1;\n\treturn calls;\n}"))
+		e := New(parse(t, "import \"lib\";\n\nlet calls = 10;\n\nfn main() int {\n\tlib.Count();\n\treturn calls + lib.Count();\n}"))
+		e.Import("lib", lib)
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 12}, result)
+	})
+
+	t.Run("initializes_shared_modules_once", func(t *testing.T) {
+		base := NewModule("base.em", parse(t, "var n int = 1;\n\nfn Next() int {\n\tn = n + 1;\n\treturn n;\n}"))
+		a := NewModule("a.em", parse(t, "import \"base\";\n\nlet first = base.Next();\n\nfn First() int {\n\treturn first;\n}"))
+		a.Import("base", base)
+		e := New(parse(t, "import \"a\";\nimport \"base\";\n\nfn main() int {\n\treturn a.First() * 10 + base.Next();\n}"))
+		e.Import("a", a)
+		e.Import("base", base)
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.Integer{Value: 23}, result)
+	})
+
+	t.Run("reports_file_of_module_in_stack_trace", func(t *testing.T) {
+		e := New(parse(t, "import \"lib\";\n\nfn main() {\n\tlib.Fail();\n}"))
+		e.File = "main.em"
+		e.Import("lib", NewModule("lib.em", parse(t, "fn Fail() {\n\tpanic(\"boom\");\n}")))
+		_, err := e.Run(context.Background())
+		var runtimeErr *RuntimeError
+		require.ErrorAs(t, err, &runtimeErr)
+		assert.Equal(t, "\tat Fail (lib.em:2:2)\n\tat main (main.em:4:2)\n", runtimeErr.StackTrace())
+	})
+
+	t.Run("fails_on_unexported_functions", func(t *testing.T) {
+		e := New(parse(t, "import \"lib\";\n\nfn main() {\n\tlib.helper();\n}"))
+		e.Import("lib", NewModule("lib.em", parse(t, "fn helper() {\n}")))
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "4:2: undefined function lib.helper")
+	})
+}
+
+func TestEvaluator_Call(t *testing.T) {
+	e := New(parse(t, "fn id(a int) int {\n\treturn a;\n}"))
+
+	result, err := e.Call(context.Background(), "id", &object.Integer{Value: 7})
+	require.NoError(t, err)
+	assert.Equal(t, &object.Integer{Value: 7}, result)
+
+	_, err = e.Call(context.Background(), "id")
+	assert.EqualError(t, err, "1:1: wrong number of arguments for id: want 1, got 0")
+}
+
+func TestEvaluator_Define(t *testing.T) {
+	e := New(parse(t, "fn main() int {\n\tlog(7);\n\treturn twice(3);\n}"))
+
+	var logged []object.Object
+	e.Define("log", func(args []object.Object) (object.Object, error) {
+		logged = append(logged, args...)
+		return nil, nil
+	})
+	e.Define("twice", func(args []object.Object) (object.Object, error) {
+		return &object.Integer{Value: 2 * args[0].(*object.Integer).Value}, nil
+	})
+	result, err := e.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &object.Integer{Value: 6}, result)
+	assert.Equal(t, []object.Object{&object.Integer{Value: 7}}, logged)
+
+	errBoom := errors.New("boom")
+	e.Define("log", func(args []object.Object) (object.Object, error) { return nil, errBoom })
+	_, err = e.Run(context.Background())
+	assert.EqualError(t, err, "2:2: log: boom")
+	assert.ErrorIs(t, err, errBoom)
+}
+
+func TestEvaluator_Limits(t *testing.T) {
+	const recursive = "fn main() int {\n\tx := 1;\n\treturn main();\n}"
+
+	t.Run("stops_at_default_max_depth", func(t *testing.T) {
+		e := New(parse(t, recursive))
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "3:9: maximum call depth exceeded")
+		assert.ErrorIs(t, err, ErrDepthLimit)
+	})
+
+	t.Run("stops_at_max_depth", func(t *testing.T) {
+		e := New(parse(t, recursive))
+		e.Limits.MaxDepth = 3
+		var depth int
+		e.Hook = func(ast.Statement) error {
+			if len(e.Frames()) > depth {
+				depth = len(e.Frames())
+			}
+			return nil
+		}
+		_, err := e.Run(context.Background())
+		assert.ErrorIs(t, err, ErrDepthLimit)
+		assert.Equal(t, 3, depth)
+	})
+
+	t.Run("stops_at_max_steps", func(t *testing.T) {
+		e := New(parse(t, recursive))
+		e.Limits.MaxSteps = 5
+		_, err := e.Run(context.Background())
+		assert.EqualError(t, err, "3:2: step limit exceeded")
+		assert.ErrorIs(t, err, ErrStepLimit)
+	})
+
+	t.Run("stops_at_max_memory", func(t *testing.T) {
+		e := New(parse(t, recursive))
+		e.Limits.MaxMemory = 1000
+		_, err := e.Run(context.Background())
+		assert.ErrorIs(t, err, ErrMemoryLimit)
+	})
+
+	t.Run("stops_when_context_is_done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		e := New(parse(t, recursive))
+		e.Hook = func(ast.Statement) error {
+			if len(e.Frames()) == 10 {
+				cancel()
+			}
+			return nil
+		}
+		_, err := e.Run(ctx)
+		assert.EqualError(t, err, "3:2: context canceled")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("resets_between_runs", func(t *testing.T) {
+		e := New(parse(t, "fn main() int {\n\treturn 1;\n}"))
+		e.Limits = Limits{MaxSteps: 1, MaxMemory: sizeFrame + sizeInteger}
+		for i := 0; i < 3; i++ {
+			_, err := e.Run(context.Background())
+			require.NoError(t, err)
+		}
+	})
+}
+
+func TestEvaluator_Hook(t *testing.T) {
+	e := New(parse(t, "fn helper() int {\n\treturn 1;\n}\n\nfn main() int {\n\tx := helper();\n\treturn x;\n}"))
+
+	var trace []string
+	e.Hook = func(stmt ast.Statement) error {
+		frame := e.Frames()[len(e.Frames())-1]
+		trace = append(trace, frame.Function.Identifier.Value+" "+frame.Pos.String())
+		return nil
+	}
+	_, err := e.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main 6:2", "helper 2:2", "main 7:2"}, trace)
+
+	e.Hook = func(stmt ast.Statement) error {
+		return &RuntimeError{Pos: token.Pos{Line: 1, Column: 1}, Msg: "stop"}
+	}
+	_, err = e.Run(context.Background())
+	assert.EqualError(t, err, "1:1: stop")
+}
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/token"
+	"github.com/muggel/emlang/types"
+)
+
+// Module holds the functions and globals of a program. Programs call the
+// exported functions of the modules they import with the name of the
+// module, like name.F().
+type Module struct {
+	file      string
+	functions map[string]*ast.FunctionDeclaration
+	methods   map[string]*ast.FunctionDeclaration // by type and name, like Point.len
+	structs   map[string]*ast.StructDeclaration
+	enums     map[string]*ast.EnumDeclaration
+	literals  map[*ast.FunctionLiteral]*ast.FunctionDeclaration
+	imports   map[string]*Module
+	imported  []*Module // in order of Import calls, which is the order of initialization
+
+	// globals are initialized before the first call
+	globals     *object.Environment
+	consts      map[string]bool
+	initOrder   []*ast.ValueDeclaration
+	initErr     error
+	initialized bool
+}
+
+// NewModule returns the module of program, file is the name of its source
+// file used in stack traces.
+func NewModule(file string, program *ast.Program) *Module {
+	m := &Module{
+		file:      file,
+		functions: map[string]*ast.FunctionDeclaration{},
+		methods:   map[string]*ast.FunctionDeclaration{},
+		structs:   map[string]*ast.StructDeclaration{},
+		enums:     map[string]*ast.EnumDeclaration{},
+		literals:  map[*ast.FunctionLiteral]*ast.FunctionDeclaration{},
+		imports:   map[string]*Module{},
+		globals:   object.NewEnvironment(),
+		consts:    map[string]bool{},
+	}
+	for _, decl := range program.TopLevelDeclarations {
+		switch d := decl.(type) {
+		case *ast.FunctionDeclaration:
+			if d.Receiver != nil {
+				m.methods[d.Name()] = d
+			} else {
+				m.functions[d.Identifier.Value] = d
+			}
+		case *ast.StructDeclaration:
+			m.structs[d.Identifier.Value] = d
+		case *ast.EnumDeclaration:
+			m.enums[d.Identifier.Value] = d
+		case *ast.ValueDeclaration:
+			if d.IsConst() {
+				m.consts[d.Identifier.Value] = true
+			}
+		}
+	}
+	m.initOrder, m.initErr = types.InitOrder(program)
+	return m
+}
+
+// Import makes the exported functions of imported callable as name.F() in
+// m. Imported modules are initialized before m, in the order they were
+// imported.
+func (m *Module) Import(name string, imported *Module) {
+	m.imports[name] = imported
+	m.imported = append(m.imported, imported)
+}
+
+// zeroValue returns the zero value of the type typ, which may be a struct
+// or enum type of m. The zero value of an enum is its first variant with a
+// zero payload.
+func (m *Module) zeroValue(typ ast.Expression) object.Object {
+	switch t := typ.(type) {
+	case *ast.SliceType:
+		return &object.Slice{}
+	case *ast.MapType:
+		return object.NewMap(m.zeroValue(t.Value))
+	case *ast.FuncType:
+		return &object.Function{}
+	case *ast.Identifier:
+		if t.Value == "string" {
+			return &object.String{}
+		}
+		if t.Value == "float" {
+			return &object.Float{}
+		}
+		if t.Value == "error" {
+			return object.NIL
+		}
+		if sd, ok := m.structs[t.Value]; ok {
+			s := &object.Struct{TypeName: t.Value, Fields: make([]object.StructField, len(sd.Fields))}
+			for i, f := range sd.Fields {
+				s.Fields[i] = object.StructField{Name: f.Identifier.Value, Value: m.zeroValue(f.Type)}
+			}
+			return s
+		}
+		if ed, ok := m.enums[t.Value]; ok && len(ed.Variants) > 0 {
+			v := ed.Variants[0]
+			zero := &object.Enum{TypeName: t.Value, Variant: v.Identifier.Value, Payload: make([]object.Object, len(v.Payload))}
+			for i, p := range v.Payload {
+				zero.Payload[i] = m.zeroValue(p)
+			}
+			zero.Zero = zero
+			return zero
+		}
+	}
+	return &object.Integer{}
+}
+
+// literal returns the declaration of the function literal lit in the
+// function outer. It is named after outer, like main.func in stack traces.
+func (m *Module) literal(lit *ast.FunctionLiteral, outer *ast.FunctionDeclaration) *ast.FunctionDeclaration {
+	if fd, ok := m.literals[lit]; ok {
+		return fd
+	}
+	fd := &ast.FunctionDeclaration{
+		Token:      lit.Token,
+		Literal:    lit.Literal,
+		Pos:        lit.Pos,
+		Identifier: &ast.Identifier{Token: token.IDENT, Literal: "func", Pos: lit.Pos, Value: outer.Name() + ".func"},
+		Parameters: lit.Parameters,
+		ReturnType: lit.ReturnType,
+		Body:       lit.Body,
+	}
+	m.literals[lit] = fd
+	return fd
+}
+
+// initFunction is the function that initializes the globals in stack traces.
+var initFunction = &ast.FunctionDeclaration{Identifier: &ast.Identifier{Value: "<init>"}, Body: &ast.BlockStatement{}}
+
+// init initializes the modules imported by m and then the globals of m in
+// initialization order.
+func (e *Evaluator) init(m *Module) error {
+	if m.initialized {
+		return nil
+	}
+	for _, imported := range m.imported {
+		if err := e.init(imported); err != nil {
+			return err
+		}
+	}
+	if err, ok := m.initErr.(*types.Error); ok {
+		return &RuntimeError{Pos: err.Pos, Msg: err.Msg, Stack: []StackFrame{{Function: initFunction.Identifier.Value, File: e.file(m), Pos: err.Pos}}}
+	}
+
+	e.frames = append(e.frames, &Frame{Function: initFunction, Env: object.NewEnvironment(), module: m})
+	defer func() { e.frames = e.frames[:len(e.frames)-1] }()
+	for _, vd := range m.initOrder {
+		frame := e.frames[len(e.frames)-1]
+		frame.Pos = vd.Pos
+		if err := e.step(vd.Pos); err != nil {
+			return err
+		}
+		val, err := e.evalDeclaration(vd)
+		if err != nil {
+			return err
+		}
+		m.globals.Set(vd.Identifier.Value, val)
+	}
+	m.initialized = true
+	return nil
+}
@@ -3,5 +3,5 @@ fn helper() int {
 }
 
 fn main() {
-	foo = helper();
+	foo := helper();
 }
\ No newline at end of file
@@ -0,0 +1,43 @@
+// Package format prints emlang syntax trees in the canonical source style:
+// tab indentation, one statement per line, single spaces between tokens,
+// at most one blank line between statements and exactly one between top
+// level declarations. Comments of the program are kept in place.
+package format
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+)
+
+// Source parses src and returns it in canonical form. Formatting already
+// formatted source returns it unchanged.
+func Source(src []byte) ([]byte, error) {
+	program, err := parser.NewParser(scanner.NewScanner(string(src))).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := Node(&buf, program); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Node writes node in canonical form to w. Comments are only printed when
+// node is an *ast.Program.
+func Node(w io.Writer, node ast.Node) error {
+	p := &printer{}
+	if program, ok := node.(*ast.Program); ok {
+		p.comments = program.Comments
+	}
+	p.node(node)
+	p.flush()
+
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
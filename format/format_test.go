@@ -0,0 +1,203 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/examples"
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			name:     "formats_empty_source",
+			source:   "",
+			expected: "",
+		},
+		{
+			name:     "indents_statements_with_tabs",
+			source:   "fn main() int {\n  foo = 1;\n    return foo;\n}",
+			expected: "fn main() int {\n\tfoo = 1;\n\treturn foo;\n}\n",
+		},
+		{
+			name:     "puts_each_statement_on_its_own_line",
+			source:   "fn main()int{foo=helper();return   foo;}",
+			expected: "fn main() int {\n\tfoo = helper();\n\treturn foo;\n}\n",
+		},
+		{
+			name:     "keeps_only_necessary_parentheses",
+			source:   "fn main() int {\n\treturn ((1+2)*3)-(4-(5*6))/(7);\n}",
+			expected: "fn main() int {\n\treturn (1 + 2) * 3 - (4 - 5 * 6) / 7;\n}\n",
+		},
+		{
+			name:     "keeps_string_literals_as_written",
+			source:   "fn main() {\n\tprintln(\"a\\tb\"+\"\\u00e4\");\n}",
+			expected: "fn main() {\n\tprintln(\"a\\tb\" + \"\\u00e4\");\n}\n",
+		},
+		{
+			name:     "omits_void_return_type",
+			source:   "fn main() void {\n}",
+			expected: "fn main() {\n}\n",
+		},
+		{
+			name:     "separates_parameters_and_arguments_by_comma_and_space",
+			source:   "fn add(a int,b   int) int {\n\treturn add(a ,b);\n}",
+			expected: "fn add(a int, b int) int {\n\treturn add(a, b);\n}\n",
+		},
+		{
+			name:     "formats_expression_statements",
+			source:   "fn main() {\n\tlog( 1 ) ;\n}",
+			expected: "fn main() {\n\tlog(1);\n}\n",
+		},
+		{
+			name:     "separates_declarations_by_one_blank_line",
+			source:   "fn a() {\n}\n\n\n\nfn b() {\n}\nfn c() {\n}",
+			expected: "fn a() {\n}\n\nfn b() {\n}\n\nfn c() {\n}\n",
+		},
+		{
+			name:     "formats_global_declarations",
+			source:   "let  a=1 ;var s   string;\n\n\nconst c int=a*(2+3);\nfn main() {\n}",
+			expected: "let a = 1;\nvar s string;\n\nconst c int = a * (2 + 3);\n\nfn main() {\n}\n",
+		},
+		{
+			name:     "formats_local_declarations",
+			source:   "fn main() {\n\tlet x int=1;\n\ty:=x;\n\tconst  c=\"c\";\n}",
+			expected: "fn main() {\n\tlet x int = 1;\n\ty := x;\n\tconst c = \"c\";\n}\n",
+		},
+		{
+			name:     "formats_slices_and_loops",
+			source:   "fn main() {\n\tlet xs []int=[1,2 ,3];\n\tfor i,x in xs[ 1: ] {\n\t\tprintln(i,(xs)[i]);\n\t}\n\tprintln((xs[0]+1)*2, ([1]) [0]);\n}",
+			expected: "fn main() {\n\tlet xs []int = [1, 2, 3];\n\tfor i, x in xs[1:] {\n\t\tprintln(i, xs[i]);\n\t}\n\tprintln((xs[0] + 1) * 2, [1][0]);\n}\n",
+		},
+		{
+			name:     "formats_maps",
+			source:   "fn main() {\n\tm:={\"a\" :1,\"b\":2};\n\te := map[ string ][]int{ };\n\tv,ok=m[\"a\"];\n\tm[ \"c\" ]=v;\n}",
+			expected: "fn main() {\n\tm := {\"a\": 1, \"b\": 2};\n\te := map[string][]int{};\n\tv, ok = m[\"a\"];\n\tm[\"c\"] = v;\n}\n",
+		},
+		{
+			name:     "formats_structs",
+			source:   "struct P { x int, // x\n y []P }\nstruct E {}\nfn (p P) f() int {\n\tp.y[0].x=P{ x:1 }.f( );\n\treturn (p.x+1).y;\n}",
+			expected: "struct P {\n\tx int, // x\n\ty []P,\n}\n\nstruct E {}\n\nfn (p P) f() int {\n\tp.y[0].x = P{x: 1}.f();\n\treturn (p.x + 1).y;\n}\n",
+		},
+		{
+			name:     "formats_enums_and_matches",
+			source:   "enum Shape { Circle(int), Rect(int,int), // rect\n Empty }\nfn f(s Shape) int {\n\treturn match s { Shape.Circle(r) if r=>r*2, // circle\n_=>0 };\n}",
+			expected: "enum Shape {\n\tCircle(int),\n\tRect(int, int), // rect\n\tEmpty,\n}\n\nfn f(s Shape) int {\n\treturn match s {\n\t\tShape.Circle(r) if r => r * 2, // circle\n\t\t_ => 0,\n\t};\n}\n",
+		},
+		{
+			name:     "formats_function_literals",
+			source:   "fn adder(n int) fn(int) int {\n\treturn fn(x int) int { return x+n; };\n}\nfn main() {\n\tfn() { println(adder(1)(2)); }();\n}",
+			expected: "fn adder(n int) fn(int) int {\n\treturn fn(x int) int {\n\t\treturn x + n;\n\t};\n}\n\nfn main() {\n\tfn() {\n\t\tprintln(adder(1)(2));\n\t}();\n}\n",
+		},
+		{
+			name:     "formats_bare_returns",
+			source:   "fn main() {\n\treturn ;\n}",
+			expected: "fn main() {\n\treturn;\n}\n",
+		},
+		{
+			name:     "formats_multiple_results",
+			source:   "fn divmod(a int, b int) (int,int) {\n\treturn a/b,a-a/b*b;\n}\nfn main() {\n\tq,r := divmod(7, 2);\n\tvar f fn() (int,int);\n}",
+			expected: "fn divmod(a int, b int) (int, int) {\n\treturn a / b, a - a / b * b;\n}\n\nfn main() {\n\tq, r := divmod(7, 2);\n\tvar f fn() (int, int);\n}\n",
+		},
+		{
+			name:     "formats_error_propagation",
+			source:   "fn load(s string) (int,error) {\n\tn := parse(s) ?;\n\treturn n+check(n)?, nil;\n}",
+			expected: "fn load(s string) (int, error) {\n\tn := parse(s)?;\n\treturn n + check(n)?, nil;\n}\n",
+		},
+		{
+			name:     "keeps_numeric_literals",
+			source:   "const Mask = 0xFF_FF;\nfn main() float {\n\treturn 1.5e3*float(0b1010+0o17)/2.0;\n}",
+			expected: "const Mask = 0xFF_FF;\n\nfn main() float {\n\treturn 1.5e3 * float(0b1010 + 0o17) / 2.0;\n}\n",
+		},
+		{
+			name:     "groups_imports",
+			source:   "import \"a\";import  \"b/c\";\nfn main() {\n\tc.F( a.G() );\n}",
+			expected: "import \"a\";\nimport \"b/c\";\n\nfn main() {\n\tc.F(a.G());\n}\n",
+		},
+		{
+			name:     "keeps_at_most_one_blank_line_between_statements",
+			source:   "fn main() {\n\n\ta = 1;\n\tb = 2;\n\n\n\tc = 3;\n\n}",
+			expected: "fn main() {\n\ta = 1;\n\tb = 2;\n\n\tc = 3;\n}\n",
+		},
+		{
+			name:     "keeps_comments_on_their_own_line",
+			source:   "// helper returns a constant\nfn helper() int {\n// the answer\nreturn 42;\n    // nothing after this\n}\n// end of file",
+			expected: "// helper returns a constant\nfn helper() int {\n\t// the answer\n\treturn 42;\n\t// nothing after this\n}\n// end of file\n",
+		},
+		{
+			name:     "keeps_trailing_comments_on_the_same_line",
+			source:   "fn main() { // entry point\n\ta = 1;   // first\n\treturn a; // second\n} // done",
+			expected: "fn main() { // entry point\n\ta = 1; // first\n\treturn a; // second\n} // done\n",
+		},
+		{
+			name:     "joins_statements_spanning_several_lines",
+			source:   "let a =\n\t1;\nlet b = 2;\nfn main() {\n\tx =\n\t\t1;\n\ty = 2;\n\n\tz := f(\n\t\tx,\n\t);\n}",
+			expected: "let a = 1;\nlet b = 2;\n\nfn main() {\n\tx = 1;\n\ty = 2;\n\n\tz := f(x);\n}\n",
+		},
+		{
+			name:     "keeps_trailing_comments_of_statements_spanning_several_lines",
+			source:   "fn main() {\n\txs := [\n\t\t1, // one\n\t\t2,\n\t]; // end\n\ty := 2;\n}",
+			expected: "fn main() {\n\t// one\n\txs := [1, 2]; // end\n\ty := 2;\n}\n",
+		},
+		{
+			name:     "keeps_trailing_comments_of_arms_spanning_several_lines",
+			source:   "fn f(n int) int {\n\treturn match n {\n\t\t1 =>\n\t\t\t2, // one\n\t\t_ => 0,\n\t};\n}",
+			expected: "fn f(n int) int {\n\treturn match n {\n\t\t1 => 2, // one\n\t\t_ => 0,\n\t};\n}\n",
+		},
+		{
+			name:     "keeps_blank_line_between_comment_and_declaration",
+			source:   "// header\n\nfn main() {\n}",
+			expected: "// header\n\nfn main() {\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Source([]byte(tt.source))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(res))
+
+			again, err := Source(res)
+			assert.NoError(t, err)
+			assert.Equal(t, string(res), string(again), "formatting is not idempotent")
+		})
+	}
+
+	t.Run("returns_parse_errors", func(t *testing.T) {
+		_, err := Source([]byte("fn main() {\nreturn 1\n}"))
+		assert.Error(t, err)
+	})
+}
+
+func TestSource_examples(t *testing.T) {
+	for name, source := range map[string]string{
+		"function.em":        examples.Function,
+		"main_and_helper.em": examples.MainAndHelper,
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := Source([]byte(source))
+			assert.NoError(t, err)
+
+			again, err := Source(res)
+			assert.NoError(t, err)
+			assert.Equal(t, string(res), string(again))
+		})
+	}
+}
+
+func TestNode(t *testing.T) {
+	ident := &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"}
+	retStmt := &ast.ReturnStatement{Token: token.RETURN, Literal: "return", ReturnValues: []ast.Expression{ident}}
+	blockStmt := &ast.BlockStatement{Token: token.LBRACE, Literal: "{", Statements: []ast.Statement{retStmt}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Node(&buf, blockStmt))
+	assert.Equal(t, "{\n\treturn foo;\n}\n", buf.String())
+}
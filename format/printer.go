@@ -0,0 +1,498 @@
+package format
+
+import (
+	"bytes"
+	"math"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/token"
+)
+
+// separators between two consecutive lines of output
+const (
+	noBlank    = iota // never put a blank line in between, e.g. after an opening brace
+	keepBlank         // keep a single blank line if the source had one or more
+	forceBlank        // always put exactly one blank line in between
+)
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+
+	comments []*ast.Comment
+	next     int // index of the next comment to print
+
+	// last is the source line the last printed item ends on, it is used to
+	// keep blank lines and to detect comments trailing a statement
+	last int
+}
+
+func (p *printer) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Program:
+		p.program(n)
+	case *ast.ImportDeclaration:
+		p.write("import " + n.Path.Literal + ";")
+	case *ast.FunctionDeclaration:
+		p.functionDeclaration(n)
+	case *ast.StructDeclaration:
+		p.structDeclaration(n)
+	case *ast.EnumDeclaration:
+		p.enumDeclaration(n)
+	case *ast.ValueDeclaration:
+		p.valueDeclaration(n)
+	case *ast.MatchArm:
+		p.arm(n)
+	case ast.Statement:
+		p.statement(n)
+	case ast.Expression:
+		p.expression(n)
+	case *ast.Comment:
+		p.write(n.Text)
+	}
+}
+
+func (p *printer) program(program *ast.Program) {
+	var prev ast.TopLevelDeclaration
+	for _, decl := range program.TopLevelDeclarations {
+		p.item(decl, separator(prev, decl))
+		prev = decl
+	}
+}
+
+// separator returns the separator between the top level declarations prev
+// and decl. Functions are set apart by a blank line, consecutive imports and
+// value declarations keep their grouping.
+func separator(prev, decl ast.TopLevelDeclaration) int {
+	if prev == nil {
+		return noBlank
+	}
+	switch prev.(type) {
+	case *ast.ImportDeclaration:
+		if _, ok := decl.(*ast.ImportDeclaration); ok {
+			return keepBlank
+		}
+	case *ast.ValueDeclaration:
+		if _, ok := decl.(*ast.ValueDeclaration); ok {
+			return keepBlank
+		}
+	}
+	return forceBlank
+}
+
+func (p *printer) valueDeclaration(vd *ast.ValueDeclaration) {
+	p.write(vd.Literal + " " + vd.Identifier.Value)
+	if vd.Type != nil {
+		p.write(" " + vd.Type.String())
+	}
+	if vd.Value != nil {
+		p.write(" = ")
+		p.expression(vd.Value)
+	}
+	p.write(";")
+}
+
+func (p *printer) functionDeclaration(fd *ast.FunctionDeclaration) {
+	p.write(fd.Signature() + " ")
+	p.block(fd.Body)
+}
+
+// structDeclaration prints every field on a line of its own, followed by a
+// comma.
+func (p *printer) structDeclaration(sd *ast.StructDeclaration) {
+	p.write("struct " + sd.Identifier.Value + " {")
+	if len(sd.Fields) == 0 && !p.hasComments(sd.Rbrace) {
+		p.write("}")
+		return
+	}
+	p.indent++
+
+	sep := noBlank
+	for _, f := range sd.Fields {
+		p.begin(f.Pos, sep)
+		p.write(f.Identifier.Value + " " + f.Type.String() + ",")
+		sep = keepBlank
+	}
+	p.printComments(sd.Rbrace, sep)
+
+	p.indent--
+	p.newline(sd.Rbrace.Line, noBlank)
+	p.write("}")
+	p.setLast(sd.Rbrace)
+}
+
+// enumDeclaration prints every variant on a line of its own, followed by a
+// comma.
+func (p *printer) enumDeclaration(ed *ast.EnumDeclaration) {
+	p.write("enum " + ed.Identifier.Value + " {")
+	if len(ed.Variants) == 0 && !p.hasComments(ed.Rbrace) {
+		p.write("}")
+		return
+	}
+	p.indent++
+
+	sep := noBlank
+	for _, v := range ed.Variants {
+		p.begin(v.Pos, sep)
+		p.write(v.String() + ",")
+		sep = keepBlank
+	}
+	p.printComments(ed.Rbrace, sep)
+
+	p.indent--
+	p.newline(ed.Rbrace.Line, noBlank)
+	p.write("}")
+	p.setLast(ed.Rbrace)
+}
+
+// match prints every arm on a line of its own, followed by a comma.
+func (p *printer) match(me *ast.MatchExpression) {
+	p.write("match ")
+	p.expression(me.Subject)
+	p.write(" {")
+	p.indent++
+
+	sep := noBlank
+	for _, arm := range me.Arms {
+		p.item(arm, sep)
+		sep = keepBlank
+	}
+	p.printComments(me.Rbrace, sep)
+
+	p.indent--
+	p.newline(me.Rbrace.Line, noBlank)
+	p.write("}")
+	p.setLast(me.Rbrace)
+}
+
+func (p *printer) arm(arm *ast.MatchArm) {
+	p.expression(arm.Pattern)
+	if arm.Guard != nil {
+		p.write(" if ")
+		p.expression(arm.Guard)
+	}
+	p.write(" => ")
+	p.expression(arm.Body)
+	p.write(",")
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	p.write("{")
+	p.indent++
+
+	sep := noBlank
+	for _, stmt := range block.Statements {
+		p.item(stmt, sep)
+		sep = keepBlank
+	}
+	p.printComments(block.Rbrace, sep)
+
+	p.indent--
+	p.newline(block.Rbrace.Line, noBlank)
+	p.write("}")
+	p.setLast(block.Rbrace)
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.AssignmentStatement:
+		for i, target := range s.Targets {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(target)
+		}
+		p.write(" " + s.Token.String() + " ")
+		p.expression(s.Value)
+		p.write(";")
+	case *ast.ValueDeclaration:
+		p.valueDeclaration(s)
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return")
+		for i, value := range s.ReturnValues {
+			if i > 0 {
+				p.write(",")
+			}
+			p.write(" ")
+			p.expression(value)
+		}
+		p.write(";")
+	case *ast.ForStatement:
+		p.write("for ")
+		if s.Index != nil {
+			p.write(s.Index.Value + ", ")
+		}
+		p.write(s.Value.Value + " in ")
+		p.expression(s.Iterable)
+		p.write(" ")
+		p.block(s.Body)
+	case *ast.BlockStatement:
+		p.block(s)
+	}
+}
+
+func (p *printer) expression(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntLiteral:
+		p.write(e.Literal)
+	case *ast.FloatLiteral:
+		p.write(e.Literal)
+	case *ast.StringLiteral:
+		p.write(e.Literal)
+	case *ast.SliceType, *ast.MapType, *ast.FuncType:
+		p.write(e.String())
+	case *ast.SliceLiteral:
+		p.write("[")
+		for i, elem := range e.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(elem)
+		}
+		p.write("]")
+	case *ast.MapLiteral:
+		if e.Type != nil {
+			p.write(e.Type.String())
+		}
+		p.write("{")
+		for i, pair := range e.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(pair.Key)
+			p.write(": ")
+			p.expression(pair.Value)
+		}
+		p.write("}")
+	case *ast.StructLiteral:
+		p.write(e.Type.Value + "{")
+		for i, field := range e.Fields {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(field.Key)
+			p.write(": ")
+			p.expression(field.Value)
+		}
+		p.write("}")
+	case *ast.SelectorExpression:
+		p.operand(e.X, postfixPrec, false)
+		p.write("." + e.Sel.Value)
+	case *ast.CallExpression:
+		if e.Receiver != nil {
+			p.operand(e.Receiver, postfixPrec, false)
+			p.write(".")
+		}
+		if e.Module != nil {
+			p.write(e.Module.Value + ".")
+		}
+		if e.Callee != nil {
+			p.operand(e.Callee, postfixPrec, false)
+			p.write("(")
+		} else {
+			p.write(e.Function.Value + "(")
+		}
+		for i, arg := range e.Arguments {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(arg)
+		}
+		p.write(")")
+	case *ast.FunctionLiteral:
+		p.write(e.Literal + "(")
+		for i, param := range e.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(param.String())
+		}
+		p.write(") ")
+		if e.ReturnType.String() != "void" {
+			p.write(e.ReturnType.String() + " ")
+		}
+		p.block(e.Body)
+	case *ast.MatchExpression:
+		p.match(e)
+	case *ast.IndexExpression:
+		p.operand(e.Left, postfixPrec, false)
+		p.write("[")
+		p.expression(e.Index)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.operand(e.Left, postfixPrec, false)
+		p.write("[")
+		if e.Low != nil {
+			p.expression(e.Low)
+		}
+		p.write(":")
+		if e.High != nil {
+			p.expression(e.High)
+		}
+		p.write("]")
+	case *ast.TryExpression:
+		p.operand(e.X, postfixPrec, false)
+		p.write("?")
+	case *ast.InfixExpression:
+		prec := e.Token.Precedence()
+		p.operand(e.Left, prec, false)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, prec, true)
+	}
+}
+
+// postfixPrec is the precedence of index, slice, selector and try
+// expressions, which bind tighter than every binary operator.
+const postfixPrec = math.MaxInt
+
+// operand prints an operand of a binary operator with precedence prec. It is
+// parenthesized if it binds less tightly, or equally tight on the right,
+// since the operators are left associative.
+func (p *printer) operand(expr ast.Expression, prec int, right bool) {
+	if infix, ok := expr.(*ast.InfixExpression); ok {
+		if inner := infix.Token.Precedence(); inner < prec || right && inner == prec {
+			p.write("(")
+			p.expression(expr)
+			p.write(")")
+			return
+		}
+	}
+	p.expression(expr)
+}
+
+// item prints a declaration, statement or match arm on a new line. Items
+// without blocks are joined onto a single line, so the comments inside them
+// are moved in front of them. Afterwards last is the line the item ends on.
+func (p *printer) item(node ast.Node, sep int) {
+	end := end(node)
+	if !multiline(node) {
+		sep = p.printComments(token.Pos{Line: end.Line}, sep)
+	}
+	p.begin(ast.Start(node), sep)
+	p.node(node)
+	p.setLast(end)
+}
+
+// end returns the position of the closing semicolon or brace of node, or
+// the start of its last node below if neither is recorded.
+func end(node ast.Node) token.Pos {
+	res := ast.Start(node)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		for _, pos := range []token.Pos{ast.Start(n), closing(n)} {
+			if res.Before(pos) {
+				res = pos
+			}
+		}
+		return true
+	})
+	return res
+}
+
+// closing returns the position of the token that closes node, or the zero
+// position if the syntax tree does not record it.
+func closing(node ast.Node) token.Pos {
+	switch n := node.(type) {
+	case *ast.ImportDeclaration:
+		return n.Semicolon
+	case *ast.ValueDeclaration:
+		return n.Semicolon
+	case *ast.StructDeclaration:
+		return n.Rbrace
+	case *ast.EnumDeclaration:
+		return n.Rbrace
+	case *ast.BlockStatement:
+		return n.Rbrace
+	case *ast.AssignmentStatement:
+		return n.Semicolon
+	case *ast.ExpressionStatement:
+		return n.Semicolon
+	case *ast.ReturnStatement:
+		return n.Semicolon
+	case *ast.MatchExpression:
+		return n.Rbrace
+	}
+	return token.Pos{}
+}
+
+// multiline reports whether node is printed on more than one line, which
+// are the nodes with a block, a match or a declaration of a struct or enum.
+func multiline(node ast.Node) bool {
+	res := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BlockStatement, *ast.MatchExpression, *ast.StructDeclaration, *ast.EnumDeclaration:
+			res = true
+		}
+		return !res
+	})
+	return res
+}
+
+// begin starts a new line for an item at pos, printing the comments that
+// precede it first.
+func (p *printer) begin(pos token.Pos, sep int) {
+	sep = p.printComments(pos, sep)
+	p.newline(pos.Line, sep)
+	p.setLast(pos)
+}
+
+// printComments prints all comments before pos. Comments on the same line as
+// the last printed item stay at the end of that line, every other comment
+// gets a line of its own. The separator for the next line is returned.
+func (p *printer) printComments(pos token.Pos, sep int) int {
+	for ; p.next < len(p.comments) && p.comments[p.next].Pos.Before(pos); p.next++ {
+		c := p.comments[p.next]
+		if p.out.Len() > 0 && c.Pos.Line == p.last {
+			p.write(" " + c.Text)
+			continue
+		}
+
+		p.newline(c.Pos.Line, sep)
+		p.write(c.Text)
+		p.setLast(c.Pos)
+		sep = keepBlank
+	}
+	return sep
+}
+
+// hasComments reports whether there are comments left to print before pos.
+func (p *printer) hasComments(pos token.Pos) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Pos.Before(pos)
+}
+
+// flush prints the remaining comments and terminates the last line.
+func (p *printer) flush() {
+	p.printComments(token.Pos{Line: math.MaxInt}, keepBlank)
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+}
+
+func (p *printer) newline(line int, sep int) {
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+		if sep == forceBlank || sep == keepBlank && p.last > 0 && line-p.last > 1 {
+			p.out.WriteByte('\n')
+		}
+	}
+	p.out.WriteString(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) setLast(pos token.Pos) {
+	if pos.IsValid() {
+		p.last = pos.Line
+	}
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
@@ -0,0 +1,211 @@
+// Package highlight classifies the tokens of emlang sources for syntax
+// highlighting. Keywords, numbers, comments and operators are recognized by
+// the scanner alone, identifiers are classified using the syntax tree, which
+// tells function names apart from types.
+package highlight
+
+import (
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/token"
+)
+
+type Class int
+
+const (
+	Keyword Class = iota
+	Function
+	Parameter
+	Type
+	Number
+	String
+	Comment
+	Operator
+)
+
+var classes = [...]string{
+	Keyword:   "keyword",
+	Function:  "function",
+	Parameter: "parameter",
+	Type:      "type",
+	Number:    "number",
+	String:    "string",
+	Comment:   "comment",
+	Operator:  "operator",
+}
+
+// String returns the name of the class, which is also the name of the
+// corresponding LSP semantic token type.
+func (c Class) String() string {
+	return classes[c]
+}
+
+// Classes returns all classes in order of their value.
+func Classes() []Class {
+	res := make([]Class, len(classes))
+	for i := range classes {
+		res[i] = Class(i)
+	}
+	return res
+}
+
+// Range is a classified range of the source. Ranges never span lines.
+type Range struct {
+	Pos    token.Pos
+	Offset int // byte offset of Pos
+	Length int
+	Class  Class
+}
+
+// Source returns the classified ranges of src ordered by position. Sources
+// with syntax errors are classified as far as they could be parsed, tokens
+// without a class, like plain variables and punctuation, are left out.
+func Source(src string) []Range {
+	lines := lineOffsets(src)
+	identifiers := classifyIdentifiers(src)
+
+	var res []Range
+	s := scanner.NewScanner(src)
+	for {
+		tok, lit := s.Next()
+		if tok == token.EOF {
+			break
+		}
+
+		class, ok := classify(tok, s.Pos(), identifiers)
+		if !ok {
+			continue
+		}
+		pos := s.Pos()
+		res = append(res, Range{Pos: pos, Offset: lines[pos.Line-1] + pos.Column - 1, Length: len(lit), Class: class})
+	}
+	return res
+}
+
+func classify(tok token.Token, pos token.Pos, identifiers map[token.Pos]Class) (Class, bool) {
+	switch {
+	case tok.IsKeyword():
+		return Keyword, true
+	case tok.IsOperator():
+		return Operator, true
+	case tok == token.INT, tok == token.FLOAT:
+		return Number, true
+	case tok == token.STRING:
+		return String, true
+	case tok == token.COMMENT:
+		return Comment, true
+	case tok == token.IDENT:
+		class, ok := identifiers[pos]
+		return class, ok
+	}
+	return 0, false
+}
+
+// classifyIdentifiers returns the class of every identifier whose role is
+// known from the syntax tree.
+func classifyIdentifiers(src string) map[token.Pos]Class {
+	program, _ := parser.NewParser(scanner.NewScanner(src)).Parse()
+
+	res := map[token.Pos]Class{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FunctionDeclaration:
+			res[n.Identifier.Pos] = Function
+			classifyType(n.ReturnType, res)
+			all := n.Parameters
+			if n.Receiver != nil {
+				all = append([]*ast.Parameter{n.Receiver}, all...)
+			}
+			classifyParameters(all, n.Body, res)
+		case *ast.FunctionLiteral:
+			classifyType(n.ReturnType, res)
+			classifyParameters(n.Parameters, n.Body, res)
+		case *ast.StructDeclaration:
+			res[n.Identifier.Pos] = Type
+			for _, f := range n.Fields {
+				classifyType(f.Type, res)
+			}
+		case *ast.EnumDeclaration:
+			res[n.Identifier.Pos] = Type
+			for _, v := range n.Variants {
+				for _, p := range v.Payload {
+					classifyType(p, res)
+				}
+			}
+		case *ast.ValueDeclaration:
+			classifyType(n.Type, res)
+		case *ast.MapLiteral:
+			classifyType(n.Type, res)
+		case *ast.StructLiteral:
+			classifyType(n.Type, res)
+		case *ast.CallExpression:
+			if n.Function != nil {
+				res[n.Function.Pos] = Function
+			}
+		}
+		return true
+	})
+	return res
+}
+
+// classifyParameters classifies the parameters of a function, including the
+// receiver of a method, and every use of them in its body.
+func classifyParameters(all []*ast.Parameter, body *ast.BlockStatement, res map[token.Pos]Class) {
+	params := map[string]bool{}
+	for _, param := range all {
+		params[param.Identifier.Value] = true
+		res[param.Identifier.Pos] = Parameter
+		classifyType(param.Type, res)
+	}
+	if len(params) == 0 {
+		return
+	}
+
+	// selected fields and the field names of struct literals are not
+	// parameters, even if they are named like one
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Identifier:
+			if params[n.Value] {
+				res[n.Pos] = Parameter
+			}
+		case *ast.SelectorExpression:
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.StructLiteral:
+			for _, f := range n.Fields {
+				ast.Inspect(f.Value, visit)
+			}
+			return false
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+}
+
+// classifyType classifies the type names of typ, which may be nil. The
+// implicit void result type has no position and is left out.
+func classifyType(typ ast.Expression, res map[token.Pos]Class) {
+	if typ == nil {
+		return
+	}
+	ast.Inspect(typ, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok && ident.Pos.IsValid() {
+			res[ident.Pos] = Type
+		}
+		return true
+	})
+}
+
+// lineOffsets returns the byte offset of the start of every line.
+func lineOffsets(src string) []int {
+	res := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			res = append(res, i+1)
+		}
+	}
+	return res
+}
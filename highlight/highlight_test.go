@@ -0,0 +1,93 @@
+package highlight
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	src := "// doc\nfn main() int {\n\tfoo = helper();\n\treturn foo;\n}\n"
+	expected := []Range{
+		{Pos: token.Pos{Line: 1, Column: 1}, Offset: 0, Length: 6, Class: Comment},
+		{Pos: token.Pos{Line: 2, Column: 1}, Offset: 7, Length: 2, Class: Keyword},
+		{Pos: token.Pos{Line: 2, Column: 4}, Offset: 10, Length: 4, Class: Function},
+		{Pos: token.Pos{Line: 2, Column: 11}, Offset: 17, Length: 3, Class: Type},
+		{Pos: token.Pos{Line: 3, Column: 6}, Offset: 28, Length: 1, Class: Operator},
+		{Pos: token.Pos{Line: 3, Column: 8}, Offset: 30, Length: 6, Class: Function},
+		{Pos: token.Pos{Line: 4, Column: 2}, Offset: 41, Length: 6, Class: Keyword},
+	}
+	assert.Equal(t, expected, Source(src))
+}
+
+func TestSource_classifiesSourcesWithErrors(t *testing.T) {
+	res := Source("fn main() {\n\treturn 1\n")
+	classes := make([]Class, len(res))
+	for i, r := range res {
+		classes[i] = r.Class
+	}
+	assert.Equal(t, []Class{Keyword, Function, Keyword, Number}, classes)
+}
+
+func TestSource_classifiesParameters(t *testing.T) {
+	res := Source("fn id(x int) int {\n\treturn x;\n}\n")
+	classes := make([]Class, len(res))
+	for i, r := range res {
+		classes[i] = r.Class
+	}
+	assert.Equal(t, []Class{Keyword, Function, Parameter, Type, Type, Keyword, Parameter}, classes)
+}
+
+func TestSource_classifiesFieldsNamedLikeParameters(t *testing.T) {
+	res := Source("fn f(x int, p P) P {\n\treturn P{x: p.x + x};\n}\n")
+	var params []token.Pos
+	for _, r := range res {
+		if r.Class == Parameter {
+			params = append(params, r.Pos)
+		}
+	}
+	assert.Equal(t, []token.Pos{{Line: 1, Column: 6}, {Line: 1, Column: 13}, {Line: 2, Column: 14}, {Line: 2, Column: 20}}, params)
+}
+
+func TestSource_classifiesStrings(t *testing.T) {
+	res := Source("fn main() {\n\tprintln(\"hi\");\n}\n")
+	require.Len(t, res, 4)
+	assert.Equal(t, Range{Pos: token.Pos{Line: 2, Column: 10}, Offset: 21, Length: 4, Class: String}, res[3])
+}
+
+func TestSource_classifiesGlobals(t *testing.T) {
+	res := Source("const c int = 1;\nvar s string;\n")
+	classes := make([]Class, len(res))
+	for i, r := range res {
+		classes[i] = r.Class
+	}
+	assert.Equal(t, []Class{Keyword, Type, Operator, Number, Keyword, Type}, classes)
+}
+
+func TestSource_classifiesSliceTypes(t *testing.T) {
+	res := Source("fn f(xs [][]int) []int {\n\tfor x in xs {\n\t}\n\treturn xs[0];\n}\n")
+	classes := make([]Class, len(res))
+	for i, r := range res {
+		classes[i] = r.Class
+	}
+	assert.Equal(t, []Class{Keyword, Function, Parameter, Type, Type, Keyword, Keyword, Parameter, Keyword, Parameter, Number}, classes)
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, HTML(&buf, "fn main() {\n\ta = 1 / 2; // <half>\n}"))
+
+	expected := `<pre class="emlang"><span class="keyword">fn</span> <span class="function">main</span>() {` + "\n" +
+		"\ta <span class=\"operator\">=</span> <span class=\"number\">1</span> <span class=\"operator\">/</span> <span class=\"number\">2</span>; " +
+		`<span class="comment">// &lt;half&gt;</span>` + "\n}</pre>\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestANSI(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, ANSI(&buf, "return 1;"))
+	assert.Equal(t, "\x1b[35mreturn\x1b[0m \x1b[33m1\x1b[0m;", buf.String())
+}
@@ -0,0 +1,65 @@
+package highlight
+
+import (
+	"bufio"
+	"html"
+	"io"
+)
+
+var ansiColors = [...]string{
+	Keyword:   "\x1b[35m",
+	Function:  "\x1b[34m",
+	Parameter: "\x1b[3m",
+	Type:      "\x1b[36m",
+	Number:    "\x1b[33m",
+	String:    "\x1b[32m",
+	Comment:   "\x1b[90m",
+	Operator:  "\x1b[1m",
+}
+
+const ansiReset = "\x1b[0m"
+
+// HTML writes src as a pre element, every classified range is wrapped in a
+// span whose class is the name of the range class.
+func HTML(w io.Writer, src string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<pre class="emlang">`)
+	render(src, func(text string, class Class, classified bool) {
+		if !classified {
+			bw.WriteString(html.EscapeString(text))
+			return
+		}
+		bw.WriteString(`<span class="` + class.String() + `">` + html.EscapeString(text) + `</span>`)
+	})
+	bw.WriteString("</pre>\n")
+	return bw.Flush()
+}
+
+// ANSI writes src colored with ANSI escape sequences for terminals.
+func ANSI(w io.Writer, src string) error {
+	bw := bufio.NewWriter(w)
+	render(src, func(text string, class Class, classified bool) {
+		if !classified {
+			bw.WriteString(text)
+			return
+		}
+		bw.WriteString(ansiColors[class] + text + ansiReset)
+	})
+	return bw.Flush()
+}
+
+// render splits src into classified and unclassified pieces and calls emit
+// for each of them in order.
+func render(src string, emit func(text string, class Class, classified bool)) {
+	offset := 0
+	for _, r := range Source(src) {
+		if r.Offset > offset {
+			emit(src[offset:r.Offset], 0, false)
+		}
+		emit(src[r.Offset:r.Offset+r.Length], r.Class, true)
+		offset = r.Offset + r.Length
+	}
+	if offset < len(src) {
+		emit(src[offset:], 0, false)
+	}
+}
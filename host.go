@@ -0,0 +1,230 @@
+package emlang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/token"
+	"github.com/muggel/emlang/types"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Host holds the Go functions that the scripts it compiles can call.
+type Host struct {
+	funcs map[string]*hostFunc
+}
+
+type hostFunc struct {
+	name    string
+	fn      reflect.Value
+	sig     *types.Signature
+	withCtx bool // the first parameter is a context.Context
+}
+
+func NewHost() *Host {
+	return &Host{funcs: map[string]*hostFunc{}}
+}
+
+// RegisterFunc makes the Go function fn callable as name from scripts that
+// are compiled afterwards. The parameters of fn must be integers, floats or
+// strings, they may be preceded by a context.Context, which receives the
+// context of the call. fn may return an integer, a float or a string, an
+// error or both. A non-nil error aborts the script, the error returned by
+// Call then wraps it.
+func (h *Host) RegisterFunc(name string, fn interface{}) error {
+	if !isIdentifier(name) {
+		return fmt.Errorf("emlang: invalid function name %q", name)
+	}
+	if types.IsBuiltin(name) {
+		return fmt.Errorf("emlang: cannot register builtin %s", name)
+	}
+	if _, ok := h.funcs[name]; ok {
+		return fmt.Errorf("emlang: function %s registered twice", name)
+	}
+
+	hf, err := newHostFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	h.funcs[name] = hf
+	return nil
+}
+
+// Compile parses and type checks source. Calls of registered functions are
+// checked against their Go signatures. Errors are reported as a
+// parser.ErrorList.
+func (h *Host) Compile(source string) (*Program, error) {
+	funcs := map[string]*hostFunc{}
+	sigs := map[string]*types.Signature{}
+	for name, hf := range h.funcs {
+		funcs[name] = hf
+		sigs[name] = hf.sig
+	}
+	return compile(source, funcs, sigs)
+}
+
+func newHostFunc(name string, fn interface{}) (*hostFunc, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("emlang: %s: expected a function, got %T", name, fn)
+	}
+	t := v.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("emlang: %s: variadic functions are not supported", name)
+	}
+
+	hf := &hostFunc{name: name, fn: v, sig: &types.Signature{Result: types.Void}}
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && t.In(i) == contextType {
+			hf.withCtx = true
+			continue
+		}
+		param, ok := typeOfGo(t.In(i))
+		if !ok {
+			return nil, fmt.Errorf("emlang: %s: unsupported parameter type %s", name, t.In(i))
+		}
+		hf.sig.Params = append(hf.sig.Params, param)
+	}
+
+	results := t.NumOut()
+	if results > 0 && t.Out(results-1) == errorType {
+		results--
+	}
+	switch results {
+	case 0:
+	case 1:
+		result, ok := typeOfGo(t.Out(0))
+		if !ok {
+			return nil, fmt.Errorf("emlang: %s: unsupported result type %s", name, t.Out(0))
+		}
+		hf.sig.Result = result
+	default:
+		return nil, fmt.Errorf("emlang: %s: too many results", name)
+	}
+	return hf, nil
+}
+
+// call converts args to the parameter types of the Go function, calls it
+// and converts its result back.
+func (hf *hostFunc) call(ctx context.Context, args []object.Object) (object.Object, error) {
+	t := hf.fn.Type()
+	var in []reflect.Value
+	if hf.withCtx {
+		in = append(in, reflect.ValueOf(&ctx).Elem())
+	}
+	for _, arg := range args {
+		v, err := toGo(arg, t.In(len(in)))
+		if err != nil {
+			return nil, err
+		}
+		in = append(in, v)
+	}
+
+	out := hf.fn.Call(in)
+	if n := len(out); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := out[n-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:n-1]
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return ToObject(out[0].Interface())
+}
+
+// typeOfGo returns the emlang type that values of the Go type t convert to.
+func typeOfGo(t reflect.Type) (Type, bool) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Int, true
+	case reflect.Float32, reflect.Float64:
+		return Float, true
+	case reflect.String:
+		return String, true
+	}
+	return "", false
+}
+
+// typeOfValue returns the emlang type that values of the Go type t convert
+// to with ToObject, which also converts slices, maps and named structs.
+func typeOfValue(t reflect.Type) (Type, bool) {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem, ok := typeOfValue(t.Elem())
+		return types.SliceOf(elem), ok
+	case reflect.Map:
+		key, ok := typeOfGo(t.Key())
+		if !ok || key == Float {
+			return "", false
+		}
+		value, ok := typeOfValue(t.Elem())
+		return types.MapOf(key, value), ok
+	case reflect.Struct:
+		return Type(t.Name()), t.Name() != ""
+	}
+	if t.Implements(errorType) {
+		return ErrorType, true
+	}
+	return typeOfGo(t)
+}
+
+// toGo converts obj to a value of the Go type t.
+func toGo(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if s, ok := obj.(*object.String); ok && t.Kind() == reflect.String {
+		v.SetString(s.Value)
+		return v, nil
+	}
+	if f, ok := obj.(*object.Float); ok && (t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64) {
+		if v.OverflowFloat(f.Value) {
+			return v, fmt.Errorf("%g overflows %s", f.Value, t)
+		}
+		v.SetFloat(f.Value)
+		return v, nil
+	}
+	i, ok := obj.(*object.Integer)
+	if !ok {
+		return v, fmt.Errorf("cannot convert %s to %s", TypeOf(obj), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(i.Value) {
+			return v, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return v, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+	case reflect.Float32, reflect.Float64:
+		// the type checker lets constant ints be passed as floats
+		v.SetFloat(float64(i.Value))
+	default:
+		return v, errors.New("unsupported type " + t.String())
+	}
+	return v, nil
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || token.Lookup(name) != token.IDENT {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package emlang
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHost_RegisterFunc(t *testing.T) {
+	t.Run("rejects_unsupported_functions", func(t *testing.T) {
+		h := NewHost()
+		assert.EqualError(t, h.RegisterFunc("fn", func() {}), `emlang: invalid function name "fn"`)
+		assert.EqualError(t, h.RegisterFunc("a1", func() {}), `emlang: invalid function name "a1"`)
+		assert.EqualError(t, h.RegisterFunc("now", 42), "emlang: now: expected a function, got int")
+		assert.EqualError(t, h.RegisterFunc("print", func() {}), "emlang: cannot register builtin print")
+		assert.EqualError(t, h.RegisterFunc("now", func([]int) {}), "emlang: now: unsupported parameter type []int")
+		assert.EqualError(t, h.RegisterFunc("now", func() complex128 { return 0 }), "emlang: now: unsupported result type complex128")
+		assert.EqualError(t, h.RegisterFunc("now", func() (int, int) { return 0, 0 }), "emlang: now: too many results")
+		assert.EqualError(t, h.RegisterFunc("now", func(...int) {}), "emlang: now: variadic functions are not supported")
+
+		require.NoError(t, h.RegisterFunc("now", func() int64 { return 0 }))
+		assert.EqualError(t, h.RegisterFunc("now", func() int64 { return 0 }), "emlang: function now registered twice")
+	})
+}
+
+func TestHost_Compile(t *testing.T) {
+	h := NewHost()
+	require.NoError(t, h.RegisterFunc("now", func() int64 { return 1700000000 }))
+	require.NoError(t, h.RegisterFunc("double", func(x int32) int { return int(x) * 2 }))
+
+	t.Run("checks_calls_of_host_functions", func(t *testing.T) {
+		_, err := h.Compile("fn main() int {\n\treturn double();\n}")
+		assert.EqualError(t, err, "2:9: not enough arguments in call to double")
+
+		_, err = h.Compile("fn now() int {\n\treturn 1;\n}")
+		assert.EqualError(t, err, "1:4: function now redeclared")
+	})
+
+	t.Run("calls_host_functions", func(t *testing.T) {
+		program, err := h.Compile("fn main() int {\n\treturn double(now());\n}")
+		require.NoError(t, err)
+		result, err := program.Call(context.Background(), "main")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3400000000), result)
+	})
+
+	t.Run("converts_strings", func(t *testing.T) {
+		h := NewHost()
+		require.NoError(t, h.RegisterFunc("greet", func(name string) string { return "hello " + name }))
+		program, err := h.Compile("fn main(name string) string {\n\treturn greet(name) + \"!\";\n}")
+		require.NoError(t, err)
+		result, err := program.Call(context.Background(), "main", "gopher")
+		require.NoError(t, err)
+		assert.Equal(t, "hello gopher!", result)
+	})
+
+	t.Run("converts_floats", func(t *testing.T) {
+		h := NewHost()
+		require.NoError(t, h.RegisterFunc("sqrt", math.Sqrt))
+		program, err := h.Compile("fn main(x float) float {\n\treturn sqrt(x) * 2;\n}")
+		require.NoError(t, err)
+		result, err := program.Call(context.Background(), "main", 2.25)
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, result)
+
+		program, err = h.Compile("fn main() float {\n\treturn sqrt(9);\n}")
+		require.NoError(t, err)
+		result, err = program.Call(context.Background(), "main")
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, result)
+	})
+
+	t.Run("functions_registered_later_are_not_visible", func(t *testing.T) {
+		program, err := h.Compile("fn main() int {\n\treturn now();\n}")
+		require.NoError(t, err)
+		require.NoError(t, h.RegisterFunc("later", func() {}))
+
+		_, err = h.Compile("fn main() {\n\tlater();\n}")
+		assert.NoError(t, err)
+		_, err = program.Call(context.Background(), "main")
+		assert.NoError(t, err)
+	})
+}
+
+func TestHost_errors(t *testing.T) {
+	errBoom := errors.New("boom")
+	type key struct{}
+
+	h := NewHost()
+	require.NoError(t, h.RegisterFunc("fail", func(x int) (int, error) {
+		if x > 0 {
+			return 0, errBoom
+		}
+		return x, nil
+	}))
+	require.NoError(t, h.RegisterFunc("small", func(x int8) {}))
+	require.NoError(t, h.RegisterFunc("value", func(ctx context.Context) int {
+		return ctx.Value(key{}).(int)
+	}))
+
+	program, err := h.Compile("fn fail_if(x int) int {\n\treturn fail(x);\n}\n\nfn small_if(x int) {\n\tsmall(x);\n}\n\nfn main() int {\n\treturn value();\n}")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := program.Call(ctx, "fail_if", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result)
+
+	_, err = program.Call(ctx, "fail_if", 1)
+	assert.EqualError(t, err, "2:9: fail: boom")
+	assert.ErrorIs(t, err, errBoom)
+
+	_, err = program.Call(ctx, "small_if", 1000)
+	assert.EqualError(t, err, "6:2: small: 1000 overflows int8")
+
+	result, err = program.Call(context.WithValue(ctx, key{}, 7), "main")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), result)
+}
@@ -0,0 +1,62 @@
+// Package arith implements the arithmetic of emlang. Integer arithmetic
+// reports overflows and divisions by zero instead of wrapping around or
+// panicking, float arithmetic follows IEEE 754.
+package arith
+
+import (
+	"errors"
+	"math"
+
+	"github.com/muggel/emlang/token"
+)
+
+var (
+	ErrDivideByZero = errors.New("integer divide by zero")
+	ErrOverflow     = errors.New("integer overflow")
+)
+
+// Int applies the binary operator op to a and b.
+func Int(op token.Token, a, b int64) (int64, error) {
+	var (
+		res      int64
+		overflow bool
+	)
+	switch op {
+	case token.ADD:
+		res = a + b
+		overflow = (b > 0 && res < a) || (b < 0 && res > a)
+	case token.SUB:
+		res = a - b
+		overflow = (b > 0 && res > a) || (b < 0 && res < a)
+	case token.MUL:
+		res = a * b
+		overflow = a != 0 && (res/a != b || a == -1 && b == math.MinInt64)
+	case token.DIV:
+		if b == 0 {
+			return 0, ErrDivideByZero
+		}
+		res = a / b
+		overflow = a == math.MinInt64 && b == -1
+	default:
+		return 0, errors.New("unknown operator " + op.String())
+	}
+	if overflow {
+		return 0, ErrOverflow
+	}
+	return res, nil
+}
+
+// Float applies the binary operator op to a and b.
+func Float(op token.Token, a, b float64) (float64, error) {
+	switch op {
+	case token.ADD:
+		return a + b, nil
+	case token.SUB:
+		return a - b, nil
+	case token.MUL:
+		return a * b, nil
+	case token.DIV:
+		return a / b, nil
+	}
+	return 0, errors.New("unknown operator " + op.String())
+}
@@ -0,0 +1,57 @@
+package arith
+
+import (
+	"math"
+	"testing"
+
+	"github.com/muggel/emlang/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		op   token.Token
+		a, b int64
+		want int64
+		err  error
+	}{
+		{token.ADD, 1, 2, 3, nil},
+		{token.ADD, math.MaxInt64, 1, 0, ErrOverflow},
+		{token.ADD, math.MinInt64, -1, 0, ErrOverflow},
+		{token.SUB, 1, 2, -1, nil},
+		{token.SUB, math.MinInt64, 1, 0, ErrOverflow},
+		{token.SUB, 0, math.MinInt64, 0, ErrOverflow},
+		{token.MUL, -3, 4, -12, nil},
+		{token.MUL, math.MaxInt64/2 + 1, 2, 0, ErrOverflow},
+		{token.MUL, -1, math.MinInt64, 0, ErrOverflow},
+		{token.MUL, math.MinInt64, -1, 0, ErrOverflow},
+		{token.DIV, -7, 2, -3, nil},
+		{token.DIV, 1, 0, 0, ErrDivideByZero},
+		{token.DIV, math.MinInt64, -1, 0, ErrOverflow},
+	}
+	for _, tt := range tests {
+		res, err := Int(tt.op, tt.a, tt.b)
+		assert.Equal(t, tt.err, err, "%d %s %d", tt.a, tt.op, tt.b)
+		assert.Equal(t, tt.want, res, "%d %s %d", tt.a, tt.op, tt.b)
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		op   token.Token
+		a, b float64
+		want float64
+	}{
+		{token.ADD, 1.5, 2, 3.5},
+		{token.SUB, 1, 2.5, -1.5},
+		{token.MUL, -3, 0.5, -1.5},
+		{token.DIV, 7, 2, 3.5},
+		{token.DIV, 1, 0, math.Inf(1)},
+		{token.MUL, math.MaxFloat64, 2, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		res, err := Float(tt.op, tt.a, tt.b)
+		assert.NoError(t, err, "%g %s %g", tt.a, tt.op, tt.b)
+		assert.Equal(t, tt.want, res, "%g %s %g", tt.a, tt.op, tt.b)
+	}
+}
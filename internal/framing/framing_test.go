@@ -0,0 +1,44 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, map[string]int{"seq": 1}))
+	require.NoError(t, Write(&buf, []int{1, 2}))
+	assert.Equal(t, "Content-Length: 9\r\n\r\n{\"seq\":1}Content-Length: 5\r\n\r\n[1,2]", buf.String())
+
+	r := bufio.NewReader(&buf)
+	body, err := Read(r)
+	require.NoError(t, err)
+	assert.Equal(t, `{"seq":1}`, string(body))
+	body, err = Read(r)
+	require.NoError(t, err)
+	assert.Equal(t, `[1,2]`, string(body))
+}
+
+func TestRead(t *testing.T) {
+	t.Run("ignores_other_headers", func(t *testing.T) {
+		body, err := Read(bufio.NewReader(strings.NewReader("content-length: 2\r\nContent-Type: application/json\r\n\r\n{}")))
+		require.NoError(t, err)
+		assert.Equal(t, "{}", string(body))
+	})
+
+	t.Run("fails_without_content_length", func(t *testing.T) {
+		_, err := Read(bufio.NewReader(strings.NewReader("Content-Type: application/json\r\n\r\n{}")))
+		assert.EqualError(t, err, "missing content length")
+	})
+
+	t.Run("fails_on_invalid_header", func(t *testing.T) {
+		_, err := Read(bufio.NewReader(strings.NewReader("Content-Length 2\r\n\r\n{}")))
+		assert.EqualError(t, err, `invalid header "Content-Length 2"`)
+	})
+}
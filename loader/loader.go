@@ -0,0 +1,177 @@
+// Package loader loads programs that consist of several modules. A module
+// is a source file, the import path "a/b" refers to the file a/b.em below
+// the root directory and its functions are called as b.F().
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/evaluator"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/muggel/emlang/token"
+	"github.com/muggel/emlang/types"
+)
+
+// Ext is the file name extension of modules.
+const Ext = ".em"
+
+// Error is an error in an import declaration.
+type Error struct {
+	File string
+	Pos  token.Pos
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.File + ":" + e.Pos.String() + ": " + e.Msg
+}
+
+// Module is a parsed and checked source file.
+type Module struct {
+	// Path is the import path of the module, empty for the main module.
+	Path string
+	// File is the name of the source file.
+	File    string
+	Program *ast.Program
+	Info    *types.Info
+	// Imports are the imported modules in the order of the import
+	// declarations of the program.
+	Imports []*Module
+}
+
+// Loader loads a module and the modules it imports, every module is loaded
+// once.
+type Loader struct {
+	// Root is the directory import paths are relative to.
+	Root string
+	// Funcs are the host functions every module may call.
+	Funcs map[string]*types.Signature
+
+	modules map[string]*Module // loaded modules by import path
+	loading []string           // import paths of the modules being loaded
+}
+
+// Load loads the main module from file.
+func (l *Loader) Load(file string) (*Module, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadSource(file, src)
+}
+
+// LoadSource loads the main module with the source src, file is used in
+// error messages.
+func (l *Loader) LoadSource(file string, src []byte) (*Module, error) {
+	if l.modules == nil {
+		l.modules = map[string]*Module{}
+	}
+	return l.load("", file, src)
+}
+
+func (l *Loader) load(importPath, file string, src []byte) (*Module, error) {
+	program, err := parser.NewParser(scanner.NewScanner(string(src))).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", file, err)
+	}
+
+	l.loading = append(l.loading, importPath)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	m := &Module{Path: importPath, File: file, Program: program}
+	imports := map[string]*types.Info{}
+	for _, decl := range program.TopLevelDeclarations {
+		id, ok := decl.(*ast.ImportDeclaration)
+		if !ok {
+			continue
+		}
+		imported, err := l.importModule(file, id)
+		if err != nil {
+			return nil, err
+		}
+		imports[imported.Path] = imported.Info
+		m.Imports = append(m.Imports, imported)
+	}
+
+	if m.Info, err = (&types.Config{Funcs: l.Funcs, Imports: imports}).Check(program); err != nil {
+		return nil, fmt.Errorf("%s:%w", file, err)
+	}
+	if importPath != "" {
+		l.modules[importPath] = m
+	}
+	return m, nil
+}
+
+// importModule loads the module imported by id in file.
+func (l *Loader) importModule(file string, id *ast.ImportDeclaration) (*Module, error) {
+	importPath := id.Path.Value
+	if !validPath(importPath) {
+		return nil, &Error{File: file, Pos: id.Path.Pos, Msg: fmt.Sprintf("invalid import path %q", importPath)}
+	}
+	if m, ok := l.modules[importPath]; ok {
+		return m, nil
+	}
+	for i, loading := range l.loading {
+		if loading == importPath {
+			cycle := append(append([]string(nil), l.loading[i:]...), importPath)
+			return nil, &Error{File: file, Pos: id.Path.Pos, Msg: "import cycle: " + strings.Join(cycle, " imports ")}
+		}
+	}
+
+	moduleFile := filepath.Join(l.Root, filepath.FromSlash(importPath)+Ext)
+	src, err := os.ReadFile(moduleFile)
+	if err != nil {
+		return nil, &Error{File: file, Pos: id.Path.Pos, Msg: fmt.Sprintf("could not import %s: %v", importPath, err)}
+	}
+	return l.load(importPath, moduleFile, src)
+}
+
+// validPath reports whether every element of the import path p is an
+// identifier, so that the last one can name the module.
+func validPath(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if elem == "" || token.Lookup(elem) != token.IDENT {
+			return false
+		}
+		for i := 0; i < len(elem); i++ {
+			c := elem[i]
+			// identifiers consist of letters and underscores only
+			if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewEvaluator returns an evaluator for the program of the main module m
+// that can call the modules it imports.
+func NewEvaluator(m *Module) *evaluator.Evaluator {
+	e := evaluator.New(m.Program)
+	e.File = m.File
+	modules := map[*Module]*evaluator.Module{}
+	for _, imported := range m.Imports {
+		e.Import(path.Base(imported.Path), evaluatorModule(imported, modules))
+	}
+	return e
+}
+
+// evaluatorModule returns the evaluator module of m, modules holds the
+// modules created so far so that every module exists once.
+func evaluatorModule(m *Module, modules map[*Module]*evaluator.Module) *evaluator.Module {
+	if em, ok := modules[m]; ok {
+		return em
+	}
+	em := evaluator.NewModule(m.File, m.Program)
+	modules[m] = em
+	for _, imported := range m.Imports {
+		em.Import(path.Base(imported.Path), evaluatorModule(imported, modules))
+	}
+	return em
+}
@@ -0,0 +1,120 @@
+package loader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/muggel/emlang/evaluator"
+	"github.com/muggel/emlang/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes the files, keyed by slash separated names, below a new
+// temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, src := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		require.NoError(t, os.WriteFile(file, []byte(src), 0o644))
+	}
+	return root
+}
+
+func TestLoader_Load(t *testing.T) {
+	t.Run("loads_imported_modules", func(t *testing.T) {
+		root := writeFiles(t, map[string]string{
+			"main.em":     "import \"lib/strs\";\nimport \"count\";\n\nfn main() string {\n\tcount.Inc();\n\treturn strs.Twice(\"a\");\n}\n",
+			"lib/strs.em": "import \"count\";\n\nfn Twice(s string) string {\n\tcount.Inc();\n\treturn s + s;\n}\n",
+			"count.em":    "var n int;\n\nfn Inc() int {\n\tn = n + 1;\n\treturn n;\n}\n",
+		})
+		m, err := (&Loader{Root: root}).Load(filepath.Join(root, "main.em"))
+		require.NoError(t, err)
+		require.Len(t, m.Imports, 2)
+		assert.Equal(t, "lib/strs", m.Imports[0].Path)
+		assert.Same(t, m.Imports[1], m.Imports[0].Imports[0])
+
+		e := NewEvaluator(m)
+		result, err := e.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &object.String{Value: "aa"}, result)
+	})
+
+	t.Run("reports_errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			files map[string]string
+			err   string
+		}{
+			{
+				name:  "missing_modules",
+				files: map[string]string{"main.em": "import \"nope\";\n"},
+				err:   "main.em:1:8: could not import nope",
+			},
+			{
+				name:  "invalid_paths",
+				files: map[string]string{"main.em": "import \"../up\";\n"},
+				err:   "main.em:1:8: invalid import path \"../up\"",
+			},
+			{
+				name:  "keywords_in_paths",
+				files: map[string]string{"main.em": "import \"fn/x\";\n"},
+				err:   "main.em:1:8: invalid import path \"fn/x\"",
+			},
+			{
+				name:  "digits_in_paths",
+				files: map[string]string{"main.em": "import \"lib2\";\n", "lib2.em": "fn F() {\n}\n"},
+				err:   "main.em:1:8: invalid import path \"lib2\"",
+			},
+			{
+				name: "import_cycles",
+				files: map[string]string{
+					"main.em": "import \"a\";\n",
+					"a.em":    "import \"b\";\n",
+					"b.em":    "import \"a\";\n",
+				},
+				err: "b.em:1:8: import cycle: a imports b imports a",
+			},
+			{
+				name: "type_errors_in_imported_modules",
+				files: map[string]string{
+					"main.em": "import \"a\";\n",
+					"a.em":    "fn F() int {\n\treturn \"s\";\n}\n",
+				},
+				err: "a.em:2:9: cannot use string value as int in return statement",
+			},
+			{
+				name: "unexported_functions",
+				files: map[string]string{
+					"main.em": "import \"a\";\n\nfn main() {\n\ta.f();\n}\n",
+					"a.em":    "fn f() {\n}\n",
+				},
+				err: "main.em:4:4: cannot refer to unexported function a.f",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				root := writeFiles(t, tt.files)
+				_, err := (&Loader{Root: root}).Load(filepath.Join(root, "main.em"))
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			})
+		}
+	})
+}
+
+func TestLoader_LoadSource(t *testing.T) {
+	root := writeFiles(t, map[string]string{"lib.em": "fn Fail() {\n\tpanic(\"boom\");\n}\n"})
+	m, err := (&Loader{Root: root}).LoadSource("<standard input>", []byte("import \"lib\";\n\nfn main() {\n\tlib.Fail();\n}\n"))
+	require.NoError(t, err)
+
+	_, err = NewEvaluator(m).Run(context.Background())
+	var runtimeErr *evaluator.RuntimeError
+	require.ErrorAs(t, err, &runtimeErr)
+	assert.Equal(t, filepath.Join(root, "lib.em"), runtimeErr.Stack[0].File)
+	assert.Equal(t, "<standard input>", runtimeErr.Stack[1].File)
+}
@@ -0,0 +1,229 @@
+package lsp
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/highlight"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/token"
+)
+
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	ident, parent := doc.identifierAt(params.Position)
+	if ident == nil || !isFunctionName(ident, parent) {
+		return nil
+	}
+	fd := doc.function(ident.Value)
+	if fd == nil {
+		return nil
+	}
+
+	rng := identifierRange(ident)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```emlang\n" + fd.Signature() + "\n```"},
+		Range:    &rng,
+	}
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) *Location {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	ident, parent := doc.identifierAt(params.Position)
+	if ident == nil || !isFunctionName(ident, parent) {
+		return nil
+	}
+	fd := doc.function(ident.Value)
+	if fd == nil {
+		return nil
+	}
+
+	return &Location{URI: doc.uri, Range: identifierRange(fd.Identifier)}
+}
+
+func (s *Server) documentSymbols(params DocumentSymbolParams) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return symbols
+	}
+
+	for _, decl := range doc.program.TopLevelDeclarations {
+		switch d := decl.(type) {
+		case *ast.FunctionDeclaration:
+			end := toPosition(d.Body.Rbrace)
+			end.Character++
+			kind := SymbolKindFunction
+			if d.Receiver != nil {
+				kind = SymbolKindMethod
+			}
+			symbols = append(symbols, DocumentSymbol{
+				Name:           d.Name(),
+				Detail:         d.Signature(),
+				Kind:           kind,
+				Range:          Range{Start: toPosition(d.Pos), End: end},
+				SelectionRange: identifierRange(d.Identifier),
+			})
+
+		case *ast.StructDeclaration:
+			end := toPosition(d.Rbrace)
+			end.Character++
+			symbols = append(symbols, DocumentSymbol{
+				Name:           d.Identifier.Value,
+				Kind:           SymbolKindStruct,
+				Range:          Range{Start: toPosition(d.Pos), End: end},
+				SelectionRange: identifierRange(d.Identifier),
+			})
+
+		case *ast.EnumDeclaration:
+			end := toPosition(d.Rbrace)
+			end.Character++
+			symbols = append(symbols, DocumentSymbol{
+				Name:           d.Identifier.Value,
+				Kind:           SymbolKindEnum,
+				Range:          Range{Start: toPosition(d.Pos), End: end},
+				SelectionRange: identifierRange(d.Identifier),
+			})
+
+		case *ast.ValueDeclaration:
+			kind := SymbolKindVariable
+			if d.IsConst() {
+				kind = SymbolKindConstant
+			}
+			// the end of the value is not known, the range ends with the
+			// identifier
+			symbols = append(symbols, DocumentSymbol{
+				Name:           d.Identifier.Value,
+				Detail:         strings.TrimSuffix(d.String(), ";\n"),
+				Kind:           kind,
+				Range:          Range{Start: toPosition(d.Pos), End: identifierRange(d.Identifier).End},
+				SelectionRange: identifierRange(d.Identifier),
+			})
+		}
+	}
+	return symbols
+}
+
+func (s *Server) semanticTokens(params SemanticTokensParams) *SemanticTokens {
+	tokens := &SemanticTokens{Data: []uint32{}}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return tokens
+	}
+
+	var prev Position
+	for _, r := range highlight.Source(doc.text) {
+		pos := toPosition(r.Pos)
+		deltaStart := pos.Character
+		if pos.Line == prev.Line {
+			deltaStart -= prev.Character
+		}
+		tokens.Data = append(tokens.Data, uint32(pos.Line-prev.Line), uint32(deltaStart), uint32(r.Length), uint32(r.Class), 0)
+		prev = pos
+	}
+	return tokens
+}
+
+// semanticTokensLegend lists the highlight classes, the token type of a
+// semantic token is the value of its class.
+func semanticTokensLegend() SemanticTokensLegend {
+	legend := SemanticTokensLegend{TokenModifiers: []string{}}
+	for _, class := range highlight.Classes() {
+		legend.TokenTypes = append(legend.TokenTypes, class.String())
+	}
+	return legend
+}
+
+// identifierAt returns the identifier at pos together with its parent node.
+func (d *document) identifierAt(pos Position) (ident *ast.Identifier, parent ast.Node) {
+	var stack []ast.Node
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		if ident != nil {
+			return false
+		}
+		if id, ok := node.(*ast.Identifier); ok && id.Pos.IsValid() && contains(identifierRange(id), pos) {
+			ident, parent = id, stack[len(stack)-1]
+			return false
+		}
+		stack = append(stack, node)
+		return true
+	})
+	return ident, parent
+}
+
+// function returns the declaration of the function called name.
+func (d *document) function(name string) *ast.FunctionDeclaration {
+	for _, decl := range d.program.TopLevelDeclarations {
+		if fd, ok := decl.(*ast.FunctionDeclaration); ok && fd.Receiver == nil && fd.Identifier.Value == name {
+			return fd
+		}
+	}
+	return nil
+}
+
+// isFunctionName reports whether ident names a function of the document,
+// either in its declaration or in a call. Functions of imported modules and
+// methods are not known to the server.
+func isFunctionName(ident *ast.Identifier, parent ast.Node) bool {
+	switch p := parent.(type) {
+	case *ast.FunctionDeclaration:
+		return p.Identifier == ident && p.Receiver == nil
+	case *ast.CallExpression:
+		return p.Function == ident && p.Module == nil && p.Receiver == nil
+	}
+	return false
+}
+
+func toDiagnostic(err error) Diagnostic {
+	var pos token.Pos
+	var parseErr *parser.Error
+	if errors.As(err, &parseErr) {
+		pos = parseErr.Pos
+		err = errors.New(parseErr.Msg)
+	}
+
+	start := toPosition(pos)
+	end := start
+	end.Character++
+	return Diagnostic{
+		Range:    Range{Start: start, End: end},
+		Severity: SeverityError,
+		Source:   "emlang",
+		Message:  err.Error(),
+	}
+}
+
+// toPosition converts a one based source position to a zero based protocol
+// position. Unknown positions map to the start of the document.
+func toPosition(pos token.Pos) Position {
+	if !pos.IsValid() {
+		return Position{}
+	}
+	return Position{Line: pos.Line - 1, Character: pos.Column - 1}
+}
+
+func identifierRange(ident *ast.Identifier) Range {
+	start := toPosition(ident.Pos)
+	end := start
+	end.Character += len(ident.Value)
+	return Range{Start: start, End: end}
+}
+
+// contains reports whether pos lies within r, the end is included so that
+// a cursor right after an identifier still refers to it.
+func contains(r Range, pos Position) bool {
+	return r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character
+}
@@ -0,0 +1,200 @@
+// Package lsp implements a Language Server Protocol server for emlang.
+//
+// The server speaks JSON-RPC 2.0 over a reader and writer pair, usually
+// stdin and stdout. It keeps the open documents in memory, reparses them on
+// every change and publishes the syntax errors as diagnostics. Hover,
+// go-to-definition, document symbols and semantic tokens are answered from
+// the syntax tree of the last parse.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/internal/framing"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+)
+
+// ErrExitWithoutShutdown is returned by Run when the client sent the exit
+// notification without requesting a shutdown first.
+var ErrExitWithoutShutdown = errors.New("lsp: exit without shutdown")
+
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	documents map[string]*document
+	shutdown  bool
+}
+
+type document struct {
+	uri     string
+	version int
+	text    string
+	program *ast.Program
+	errors  []error
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*document{},
+	}
+}
+
+// Run handles messages until the client sends the exit notification or the
+// input is closed.
+func (s *Server) Run() error {
+	for {
+		body, err := framing.Read(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if err := s.handle(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	isRequest := len(msg.ID) > 0
+
+	var (
+		result interface{}
+		err    error
+	)
+	switch msg.Method {
+	case "initialize":
+		result = InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       SyncFull,
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				DocumentSymbolProvider: true,
+				SemanticTokensProvider: &SemanticTokensOptions{Legend: semanticTokensLegend(), Full: true},
+			},
+			ServerInfo: ServerInfo{Name: "emlang"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			item := params.TextDocument
+			err = s.update(item.URI, item.Version, item.Text)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err = decodeParams(msg.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			// with full synchronization the last change holds the whole document
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			err = s.update(params.TextDocument.URI, params.TextDocument.Version, text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			err = s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+				URI:         params.TextDocument.URI,
+				Diagnostics: []Diagnostic{},
+			})
+		}
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			result = s.definition(params)
+		}
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			result = s.documentSymbols(params)
+		}
+	case "textDocument/semanticTokens/full":
+		var params SemanticTokensParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			result = s.semanticTokens(params)
+		}
+	default:
+		if !isRequest {
+			// unknown notifications, like initialized, are ignored
+			return nil
+		}
+		err = &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		if !isRequest {
+			return nil
+		}
+		return s.replyError(msg.ID, respErr.Code, respErr.Message)
+	}
+	if err != nil || !isRequest {
+		return err
+	}
+	return framing.Write(s.out, response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) replyError(id json.RawMessage, code int, msg string) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return framing.Write(s.out, errorResponse{JSONRPC: "2.0", ID: id, Error: &ResponseError{Code: code, Message: msg}})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return framing.Write(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// update reparses the document and publishes its diagnostics.
+func (s *Server) update(uri string, version int, text string) error {
+	p := parser.NewParser(scanner.NewScanner(text))
+	program, _ := p.Parse()
+	doc := &document{uri: uri, version: version, text: text, program: program, errors: p.Errors}
+	s.documents[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, err := range doc.errors {
+		diagnostics = append(diagnostics, toDiagnostic(err))
+	}
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     version,
+		Diagnostics: diagnostics,
+	})
+}
@@ -0,0 +1,284 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/muggel/emlang/highlight"
+	"github.com/muggel/emlang/internal/framing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	uri    = "file:///main.em"
+	source = "fn helper() int {\n\treturn 42;\n}\n\nfn main() {\n\tfoo := helper();\n}\n"
+)
+
+// client drives a server over in-process pipes. The server output is read
+// in the background so that the server never blocks on a notification.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan map[string]json.RawMessage
+	done     chan error
+	nextID   int
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	c := &client{t: t, in: inW, messages: make(chan map[string]json.RawMessage, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			body, err := framing.Read(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(body, &msg); err == nil {
+				c.messages <- msg
+			}
+		}
+	}()
+	t.Cleanup(func() { inW.Close() })
+
+	return c
+}
+
+func (c *client) send(msg interface{}) {
+	require.NoError(c.t, framing.Write(c.in, msg))
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// request sends a request and decodes the result of its response into
+// result. Notifications received in the meantime are dropped.
+func (c *client) request(method string, params interface{}, result interface{}) *ResponseError {
+	c.nextID++
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+
+	for {
+		msg := c.receive()
+		if _, ok := msg["id"]; !ok {
+			continue
+		}
+		assert.JSONEq(c.t, string(mustMarshal(c.t, c.nextID)), string(msg["id"]))
+		if raw, ok := msg["error"]; ok {
+			var respErr ResponseError
+			require.NoError(c.t, json.Unmarshal(raw, &respErr))
+			return &respErr
+		}
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg["result"], result))
+		}
+		return nil
+	}
+}
+
+// diagnostics waits for the next publishDiagnostics notification.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	for {
+		msg := c.receive()
+		if string(msg["method"]) != `"textDocument/publishDiagnostics"` {
+			continue
+		}
+		var params PublishDiagnosticsParams
+		require.NoError(c.t, json.Unmarshal(msg["params"], &params))
+		return params
+	}
+}
+
+func (c *client) receive() map[string]json.RawMessage {
+	select {
+	case msg, ok := <-c.messages:
+		require.True(c.t, ok, "server closed the connection")
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timeout waiting for server")
+		return nil
+	}
+}
+
+func (c *client) open(text string) PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "emlang", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func TestServer_lifecycle(t *testing.T) {
+	c := newClient(t)
+
+	var result InitializeResult
+	assert.Nil(t, c.request("initialize", map[string]interface{}{}, &result))
+	assert.Equal(t, SyncFull, result.Capabilities.TextDocumentSync)
+	assert.True(t, result.Capabilities.HoverProvider)
+	require.NotNil(t, result.Capabilities.SemanticTokensProvider)
+	assert.Equal(t, "keyword", result.Capabilities.SemanticTokensProvider.Legend.TokenTypes[0])
+	c.notify("initialized", map[string]interface{}{})
+
+	err := c.request("workspace/symbol", map[string]interface{}{}, nil)
+	require.NotNil(t, err)
+	assert.Equal(t, codeMethodNotFound, err.Code)
+
+	assert.Nil(t, c.request("shutdown", nil, nil))
+	c.notify("exit", nil)
+	assert.NoError(t, <-c.done)
+}
+
+func TestServer_exitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.notify("exit", nil)
+	assert.ErrorIs(t, <-c.done, ErrExitWithoutShutdown)
+}
+
+func TestServer_diagnostics(t *testing.T) {
+	c := newClient(t)
+
+	params := c.open(source)
+	assert.Equal(t, uri, params.URI)
+	assert.Empty(t, params.Diagnostics)
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "fn main() {\n\treturn 1\n}\n"}},
+	})
+	params = c.diagnostics()
+	assert.Equal(t, 2, params.Version)
+	assert.Equal(t, []Diagnostic{{
+		Range:    Range{Start: Position{Line: 2, Character: 0}, End: Position{Line: 2, Character: 1}},
+		Severity: SeverityError,
+		Source:   "emlang",
+		Message:  "expected semicolon",
+	}}, params.Diagnostics)
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	assert.Empty(t, c.diagnostics().Diagnostics)
+}
+
+func TestServer_hover(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	var hover *Hover
+	assert.Nil(t, c.request("textDocument/hover", at(5, 9), &hover))
+	require.NotNil(t, hover)
+	assert.Equal(t, "```emlang\nfn helper() int\n```", hover.Contents.Value)
+	assert.Equal(t, &Range{Start: Position{Line: 5, Character: 8}, End: Position{Line: 5, Character: 14}}, hover.Range)
+
+	hover = nil
+	assert.Nil(t, c.request("textDocument/hover", at(4, 4), &hover))
+	require.NotNil(t, hover)
+	assert.Equal(t, "```emlang\nfn main()\n```", hover.Contents.Value)
+
+	// variables have no signature
+	hover = nil
+	assert.Nil(t, c.request("textDocument/hover", at(5, 1), &hover))
+	assert.Nil(t, hover)
+}
+
+func TestServer_definition(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	var location *Location
+	assert.Nil(t, c.request("textDocument/definition", at(5, 8), &location))
+	assert.Equal(t, &Location{
+		URI:   uri,
+		Range: Range{Start: Position{Line: 0, Character: 3}, End: Position{Line: 0, Character: 9}},
+	}, location)
+
+	location = nil
+	assert.Nil(t, c.request("textDocument/definition", at(1, 9), &location))
+	assert.Nil(t, location)
+}
+
+func TestServer_documentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	var symbols []DocumentSymbol
+	assert.Nil(t, c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols))
+	assert.Equal(t, []DocumentSymbol{
+		{
+			Name:           "helper",
+			Detail:         "fn helper() int",
+			Kind:           SymbolKindFunction,
+			Range:          Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 2, Character: 1}},
+			SelectionRange: Range{Start: Position{Line: 0, Character: 3}, End: Position{Line: 0, Character: 9}},
+		},
+		{
+			Name:           "main",
+			Detail:         "fn main()",
+			Kind:           SymbolKindFunction,
+			Range:          Range{Start: Position{Line: 4, Character: 0}, End: Position{Line: 6, Character: 1}},
+			SelectionRange: Range{Start: Position{Line: 4, Character: 3}, End: Position{Line: 4, Character: 7}},
+		},
+	}, symbols)
+}
+
+func TestServer_documentSymbols_globals(t *testing.T) {
+	c := newClient(t)
+	c.open("const answer = 42;\nvar name string;\n")
+
+	var symbols []DocumentSymbol
+	assert.Nil(t, c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols))
+	assert.Equal(t, []DocumentSymbol{
+		{
+			Name:           "answer",
+			Detail:         "const answer = 42",
+			Kind:           SymbolKindConstant,
+			Range:          Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 0, Character: 12}},
+			SelectionRange: Range{Start: Position{Line: 0, Character: 6}, End: Position{Line: 0, Character: 12}},
+		},
+		{
+			Name:           "name",
+			Detail:         "var name string",
+			Kind:           SymbolKindVariable,
+			Range:          Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 1, Character: 8}},
+			SelectionRange: Range{Start: Position{Line: 1, Character: 4}, End: Position{Line: 1, Character: 8}},
+		},
+	}, symbols)
+}
+
+func TestServer_semanticTokens(t *testing.T) {
+	c := newClient(t)
+	c.open("fn main() int {\n\treturn 1; // one\n}\n")
+
+	var tokens SemanticTokens
+	assert.Nil(t, c.request("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &tokens))
+	assert.Equal(t, []uint32{
+		0, 0, 2, uint32(highlight.Keyword), 0,
+		0, 3, 4, uint32(highlight.Function), 0,
+		0, 7, 3, uint32(highlight.Type), 0,
+		1, 1, 6, uint32(highlight.Keyword), 0,
+		0, 7, 1, uint32(highlight.Number), 0,
+		0, 3, 6, uint32(highlight.Comment), 0,
+	}, tokens.Data)
+}
@@ -0,0 +1,318 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ  = "INTEGER"
+	FLOAT_OBJ    = "FLOAT"
+	STRING_OBJ   = "STRING"
+	SLICE_OBJ    = "SLICE"
+	MAP_OBJ      = "MAP"
+	STRUCT_OBJ   = "STRUCT"
+	ENUM_OBJ     = "ENUM"
+	FUNCTION_OBJ = "FUNCTION"
+	TUPLE_OBJ    = "TUPLE"
+	ERROR_OBJ    = "ERROR"
+	NIL_OBJ      = "NIL"
+	VOID_OBJ     = "VOID"
+)
+
+// Object is a value of the running program.
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string  { return strconv.FormatFloat(f.Value, 'g', -1, 64) }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+// Slice is a slice of elements of the same type. Like in Go, slices of a
+// slice and the result of appending to it may share its elements.
+type Slice struct {
+	Elements []Object
+}
+
+func (s *Slice) Type() ObjectType { return SLICE_OBJ }
+func (s *Slice) Inspect() string {
+	elems := make([]string, len(s.Elements))
+	for i, elem := range s.Elements {
+		elems[i] = elem.Inspect()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// Map maps int or string keys to values of the same type. Its entries are
+// kept in insertion order, so iterating over a map is deterministic. Zero is
+// the zero value of its values, which lookups of missing keys return.
+type Map struct {
+	Zero    Object
+	entries []MapEntry
+	index   map[interface{}]int // positions of the entries by key value
+}
+
+// MapEntry is a key and its value.
+type MapEntry struct {
+	Key   Object
+	Value Object
+}
+
+// NewMap returns an empty map whose values have the zero value zero.
+func NewMap(zero Object) *Map {
+	return &Map{Zero: zero, index: map[interface{}]int{}}
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Inspect() string {
+	entries := make([]string, len(m.entries))
+	for i, entry := range m.entries {
+		entries[i] = entry.Key.Inspect() + ": " + entry.Value.Inspect()
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// Len returns the number of entries of m.
+func (m *Map) Len() int { return len(m.entries) }
+
+// Get returns the value of key and whether m contains it, a new zero value
+// if it does not.
+func (m *Map) Get(key Object) (Object, bool) {
+	if i, ok := m.index[hashKey(key)]; ok {
+		return m.entries[i].Value, true
+	}
+	return ZeroOf(m.Zero), false
+}
+
+// Set sets the value of key, a new key is added after all others.
+func (m *Map) Set(key, value Object) {
+	k := hashKey(key)
+	if i, ok := m.index[k]; ok {
+		m.entries[i].Value = value
+		return
+	}
+	m.index[k] = len(m.entries)
+	m.entries = append(m.entries, MapEntry{Key: key, Value: value})
+}
+
+// Delete removes key from m, the order of the other keys is kept.
+func (m *Map) Delete(key Object) {
+	k := hashKey(key)
+	i, ok := m.index[k]
+	if !ok {
+		return
+	}
+	delete(m.index, k)
+	m.entries = append(m.entries[:i], m.entries[i+1:]...)
+	for j := i; j < len(m.entries); j++ {
+		m.index[hashKey(m.entries[j].Key)] = j
+	}
+}
+
+// Entries returns a copy of the entries of m in insertion order.
+func (m *Map) Entries() []MapEntry {
+	return append([]MapEntry(nil), m.entries...)
+}
+
+// hashKey returns the Go value that identifies key in a map.
+func hashKey(key Object) interface{} {
+	switch k := key.(type) {
+	case *Integer:
+		return k.Value
+	case *String:
+		return k.Value
+	}
+	return key
+}
+
+// ZeroOf returns a new zero value of the type of o. Zero values of maps are
+// empty maps with the same zero value.
+func ZeroOf(o Object) Object {
+	switch o := o.(type) {
+	case *Integer:
+		return &Integer{}
+	case *Float:
+		return &Float{}
+	case *String:
+		return &String{}
+	case *Slice:
+		return &Slice{}
+	case *Map:
+		return NewMap(o.Zero)
+	case *Struct:
+		zero := &Struct{TypeName: o.TypeName, Fields: make([]StructField, len(o.Fields))}
+		for i, f := range o.Fields {
+			zero.Fields[i] = StructField{Name: f.Name, Value: ZeroOf(f.Value)}
+		}
+		return zero
+	case *Enum:
+		return o.Zero
+	case *Function:
+		return &Function{}
+	case *Error:
+		return NIL
+	}
+	return o
+}
+
+// Struct is a value of a struct type. Like in Go, structs are values: they
+// are copied when they are assigned or passed, while the slices and maps
+// they contain are shared.
+type Struct struct {
+	TypeName string
+	Fields   []StructField // in order of declaration
+}
+
+// StructField is the name and value of a field.
+type StructField struct {
+	Name  string
+	Value Object
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string {
+	fields := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		fields[i] = f.Name + ": " + f.Value.Inspect()
+	}
+	return s.TypeName + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Get returns the value of the field called name, nil if there is none.
+func (s *Struct) Get(name string) Object {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// Set sets the value of the field called name and reports whether there is
+// such a field.
+func (s *Struct) Set(name string, value Object) bool {
+	for i, f := range s.Fields {
+		if f.Name == name {
+			s.Fields[i].Value = value
+			return true
+		}
+	}
+	return false
+}
+
+// Copy returns a copy of s, structs in its fields are copied too.
+func (s *Struct) Copy() *Struct {
+	c := &Struct{TypeName: s.TypeName, Fields: make([]StructField, len(s.Fields))}
+	for i, f := range s.Fields {
+		if v, ok := f.Value.(*Struct); ok {
+			f.Value = v.Copy()
+		}
+		c.Fields[i] = f
+	}
+	return c
+}
+
+// Enum is a value of an enum type, the variant called Variant with its
+// payload. Enum values cannot be changed, so they are shared instead of
+// copied.
+type Enum struct {
+	TypeName string
+	Variant  string
+	Payload  []Object
+	Zero     *Enum // the zero value of the enum type, which is its first variant
+}
+
+func (e *Enum) Type() ObjectType { return ENUM_OBJ }
+func (e *Enum) Inspect() string {
+	if len(e.Payload) == 0 {
+		return e.TypeName + "." + e.Variant
+	}
+	payload := make([]string, len(e.Payload))
+	for i, p := range e.Payload {
+		payload[i] = p.Inspect()
+	}
+	return e.TypeName + "." + e.Variant + "(" + strings.Join(payload, ", ") + ")"
+}
+
+// Function is a function value, a declared function or a function literal
+// together with the environment it captures. Declaration is nil for the
+// zero value, which cannot be called. Module is the module the function
+// belongs to, it is only known to the evaluator.
+type Function struct {
+	Declaration *ast.FunctionDeclaration
+	Env         *Environment
+	Module      interface{}
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	if f.Declaration == nil {
+		return "fn nil"
+	}
+	return "fn " + f.Declaration.Name()
+}
+
+// Error is an error value created by error("msg"). Like enums, errors are
+// immutable and can be shared.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return e.Message }
+
+// Nil is the zero value of the error type, the absence of an error.
+type Nil struct{}
+
+func (n *Nil) Type() ObjectType { return NIL_OBJ }
+func (n *Nil) Inspect() string  { return "nil" }
+
+// NIL is the single instance of Nil.
+var NIL = &Nil{}
+
+// Tuple is the result of functions with several results, it is taken apart
+// by the assignment or return statement that uses it.
+type Tuple struct {
+	Elements []Object
+}
+
+func (t *Tuple) Type() ObjectType { return TUPLE_OBJ }
+func (t *Tuple) Inspect() string {
+	elements := make([]string, len(t.Elements))
+	for i, e := range t.Elements {
+		elements[i] = e.Inspect()
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
+// Void is the result of functions without a return type.
+type Void struct{}
+
+func (v *Void) Type() ObjectType { return VOID_OBJ }
+func (v *Void) Inspect() string  { return "void" }
+
+// VOID is the single instance of Void.
+var VOID = &Void{}
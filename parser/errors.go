@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/muggel/emlang/token"
+)
+
+// Error is a syntax error at a position in the source.
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList is returned by Parse when one or more errors were encountered.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
@@ -1,8 +1,8 @@
 package parser
 
 import (
-	"errors"
 	"strconv"
+	"strings"
 
 	"github.com/muggel/emlang/ast"
 	"github.com/muggel/emlang/scanner"
@@ -14,14 +14,23 @@ type Parser struct {
 
 	currentToken   token.Token
 	currentLiteral string
+	currentPos     token.Pos
 	peekToken      token.Token
 	peekLiteral    string
+	peekPos        token.Pos
+
+	comments []*ast.Comment
+
+	// names of the imported modules, whose functions are called like name.F()
+	imports map[string]bool
+	// set while parsing the iterable of a loop, where a brace starts the body
+	noStructLiteral bool
 
 	Errors []error
 }
 
 func NewParser(s *scanner.Scanner) *Parser {
-	parser := &Parser{s: s}
+	parser := &Parser{s: s, imports: map[string]bool{}}
 
 	// fill both current and peek
 	parser.readNext()
@@ -30,49 +39,140 @@ func NewParser(s *scanner.Scanner) *Parser {
 	return parser
 }
 
-func (p *Parser) Parse() (ast.Node, error) {
-	return nil, nil
+// Parse parses the whole source. The program is returned even if errors were
+// encountered, the error is then an ErrorList holding all of them.
+func (p *Parser) Parse() (*ast.Program, error) {
+	program := p.parseProgram()
+	if len(p.Errors) > 0 {
+		return program, ErrorList(p.Errors)
+	}
+	return program, nil
 }
 
 func (p *Parser) parseProgram() *ast.Program {
 	program := &ast.Program{}
 
+	imports := true
 	for p.currentToken != token.EOF {
+		if p.currentToken != token.IMPORT {
+			imports = false
+		} else if !imports {
+			p.error("imports must appear before other declarations")
+		}
 		decl := p.parseTopLevelDeclaration()
 		if decl != nil {
 			program.TopLevelDeclarations = append(program.TopLevelDeclarations, decl)
 		}
-		// p.readNext()
 	}
+	program.Comments = p.comments
 
 	return program
 }
 
 func (p *Parser) parseTopLevelDeclaration() ast.TopLevelDeclaration {
-	if p.currentToken == token.FN {
+	switch p.currentToken {
+	case token.IMPORT:
+		return p.parseImportDeclaration()
+	case token.FN:
 		return p.parseFunctionDeclaration()
+	case token.STRUCT:
+		return p.parseStructDeclaration()
+	case token.ENUM:
+		return p.parseEnumDeclaration()
+	case token.LET, token.VAR, token.CONST:
+		return p.parseValueDeclaration()
+	}
+
+	p.error("expected top level declaration, found " + p.currentToken.String())
+	for !isTopLevelKeyword(p.currentToken) && p.currentToken != token.EOF {
+		p.readNext()
 	}
 	return nil
 }
 
+func isTopLevelKeyword(tok token.Token) bool {
+	switch tok {
+	case token.IMPORT, token.FN, token.STRUCT, token.ENUM, token.LET, token.VAR, token.CONST:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseImportDeclaration() *ast.ImportDeclaration {
+	decl := &ast.ImportDeclaration{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	if p.currentToken == token.STRING {
+		decl.Path = p.parseStringLiteral()
+		p.imports[decl.Name()] = true
+	} else {
+		p.error("expected import path")
+		decl.Path = &ast.StringLiteral{Token: token.STRING, Literal: `""`, Pos: p.currentPos}
+	}
+	p.readNext()
+
+	decl.Semicolon = p.consumeSemicolon()
+
+	return decl
+}
+
+// parseValueDeclaration parses a declaration like let x int = 1; where
+// either the type or the value may be left out, constants need a value.
+func (p *Parser) parseValueDeclaration() *ast.ValueDeclaration {
+	decl := &ast.ValueDeclaration{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	if p.currentToken != token.IDENT {
+		p.error("expected identifier")
+	}
+	decl.Identifier = p.parseIdentifier()
+	p.readNext()
+
+	if startsType(p.currentToken) {
+		decl.Type = p.parseType()
+		p.readNext()
+	}
+
+	if p.currentToken == token.ASSIGN {
+		p.readNext()
+		decl.Value = p.parseExpression(token.LowestPrec)
+		p.readNext()
+	} else if decl.IsConst() {
+		p.error("missing value in constant declaration")
+	} else if decl.Type == nil {
+		p.error("expected type or value")
+	}
+
+	decl.Semicolon = p.consumeSemicolon()
+
+	return decl
+}
+
 func (p *Parser) parseFunctionDeclaration() *ast.FunctionDeclaration {
 	stmt := &ast.FunctionDeclaration{
 		Token:   p.currentToken,
 		Literal: p.currentLiteral,
+		Pos:     p.currentPos,
 	}
 	p.readNext()
 
+	if p.currentToken == token.LPAREN {
+		pos := p.currentPos
+		receivers := p.parseParameters()
+		if len(receivers) != 1 {
+			p.errorAt(pos, "method must have exactly one receiver")
+		} else {
+			stmt.Receiver = receivers[0]
+		}
+	}
+
 	stmt.Identifier = p.parseIdentifier()
 	p.readNext()
 
-	if p.currentToken != token.LPAREN || p.peekToken != token.RPAREN {
-		p.Errors = append(p.Errors, errors.New("expected empty parameter list"))
-	}
-	p.readNext()
-	p.readNext()
+	stmt.Parameters = p.parseParameters()
 
 	if p.currentToken != token.LBRACE {
-		stmt.ReturnType = p.parseIdentifier()
+		stmt.ReturnType = p.parseResult()
 		p.readNext()
 	} else {
 		stmt.ReturnType = &ast.Identifier{Token: token.IDENT, Literal: "void", Value: "void"}
@@ -83,19 +183,280 @@ func (p *Parser) parseFunctionDeclaration() *ast.FunctionDeclaration {
 	return stmt
 }
 
+// parseStructDeclaration parses a declaration like struct Point { x int,
+// y int } where the last field may be followed by a comma.
+func (p *Parser) parseStructDeclaration() *ast.StructDeclaration {
+	decl := &ast.StructDeclaration{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	if p.currentToken != token.IDENT {
+		p.error("expected identifier")
+	}
+	decl.Identifier = p.parseIdentifier()
+	p.readNext()
+
+	if p.currentToken != token.LBRACE {
+		p.error("expected opening brace")
+		return decl
+	}
+	p.readNext()
+
+	for p.currentToken != token.RBRACE {
+		if p.currentToken != token.IDENT {
+			p.error("expected field name")
+			break
+		}
+		field := &ast.Field{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Identifier: p.parseIdentifier()}
+		p.readNext()
+		field.Type = p.parseType()
+		decl.Fields = append(decl.Fields, field)
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RBRACE {
+		p.error("expected closing brace")
+		for !isTopLevelKeyword(p.currentToken) && p.currentToken != token.EOF {
+			p.readNext()
+		}
+		return decl
+	}
+	decl.Rbrace = p.currentPos
+	p.readNext()
+
+	return decl
+}
+
+// parseEnumDeclaration parses a declaration like enum Shape { Circle(int),
+// Rect(int, int), Empty } where the last variant may be followed by a comma.
+func (p *Parser) parseEnumDeclaration() *ast.EnumDeclaration {
+	decl := &ast.EnumDeclaration{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	if p.currentToken != token.IDENT {
+		p.error("expected identifier")
+	}
+	decl.Identifier = p.parseIdentifier()
+	p.readNext()
+
+	if p.currentToken != token.LBRACE {
+		p.error("expected opening brace")
+		return decl
+	}
+	p.readNext()
+
+	for p.currentToken != token.RBRACE {
+		if p.currentToken != token.IDENT {
+			p.error("expected variant name")
+			break
+		}
+		variant := &ast.Variant{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Identifier: p.parseIdentifier()}
+		p.readNext()
+		if p.currentToken == token.LPAREN {
+			p.readNext()
+			for p.currentToken != token.RPAREN {
+				variant.Payload = append(variant.Payload, p.parseType())
+				p.readNext()
+				if p.currentToken != token.COMMA {
+					break
+				}
+				p.readNext()
+			}
+			if p.currentToken != token.RPAREN {
+				p.error("expected closing parenthesis")
+				break
+			}
+			p.readNext()
+		}
+		decl.Variants = append(decl.Variants, variant)
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RBRACE {
+		p.error("expected closing brace")
+		for !isTopLevelKeyword(p.currentToken) && p.currentToken != token.EOF {
+			p.readNext()
+		}
+		return decl
+	}
+	decl.Rbrace = p.currentPos
+	p.readNext()
+
+	return decl
+}
+
+// parseParameters parses a parenthesized parameter list like (a int, b int)
+// and reads past the closing parenthesis.
+func (p *Parser) parseParameters() []*ast.Parameter {
+	if p.currentToken != token.LPAREN {
+		p.error("expected parameter list")
+		return nil
+	}
+	p.readNext()
+
+	var params []*ast.Parameter
+	for p.currentToken != token.RPAREN {
+		if p.currentToken != token.IDENT || !startsType(p.peekToken) {
+			p.error("expected parameter name and type")
+			// skip to the body, the parameters are lost
+			for p.currentToken != token.LBRACE && p.currentToken != token.EOF {
+				p.readNext()
+			}
+			return params
+		}
+		param := &ast.Parameter{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+		param.Identifier = p.parseIdentifier()
+		p.readNext()
+		param.Type = p.parseType()
+		p.readNext()
+		params = append(params, param)
+
+		if p.currentToken == token.COMMA {
+			p.readNext()
+		} else if p.currentToken != token.RPAREN {
+			p.error("expected comma or closing parenthesis")
+		}
+	}
+	p.readNext()
+
+	return params
+}
+
+// startsType reports whether tok is the first token of a type.
+func startsType(tok token.Token) bool {
+	return tok == token.IDENT || tok == token.LBRACK || tok == token.MAP || tok == token.FN
+}
+
+// parseType parses a type like int, []string, map[string]int or fn(int) int
+// and stops at its last token.
+func (p *Parser) parseType() ast.Expression {
+	if p.currentToken == token.MAP {
+		return p.parseMapType()
+	}
+	if p.currentToken == token.FN {
+		return p.parseFuncType()
+	}
+	if p.currentToken != token.LBRACK {
+		if p.currentToken != token.IDENT {
+			p.error("expected type")
+		}
+		return p.parseIdentifier()
+	}
+
+	typ := &ast.SliceType{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+	if p.currentToken != token.RBRACK {
+		p.error("expected closing bracket")
+	} else {
+		p.readNext()
+	}
+	typ.Elem = p.parseType()
+	return typ
+}
+
+func (p *Parser) parseMapType() *ast.MapType {
+	typ := &ast.MapType{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+	if p.currentToken != token.LBRACK {
+		p.error("expected opening bracket")
+	} else {
+		p.readNext()
+	}
+	typ.Key = p.parseType()
+	p.readNext()
+	if p.currentToken != token.RBRACK {
+		p.error("expected closing bracket")
+	} else {
+		p.readNext()
+	}
+	typ.Value = p.parseType()
+	return typ
+}
+
+// parseFuncType parses a function type like fn(int, string) int whose result
+// type may be left out.
+func (p *Parser) parseFuncType() *ast.FuncType {
+	typ := &ast.FuncType{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+	if p.currentToken != token.LPAREN {
+		p.error("expected opening parenthesis")
+		return typ
+	}
+	p.readNext()
+
+	for p.currentToken != token.RPAREN {
+		typ.Params = append(typ.Params, p.parseType())
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RPAREN {
+		p.error("expected closing parenthesis")
+		return typ
+	}
+
+	if startsType(p.peekToken) || p.peekToken == token.LPAREN {
+		p.readNext()
+		typ.Result = p.parseResult()
+	}
+	return typ
+}
+
+// parseResult parses the result of a function, a type or a result list like
+// (int, string), and stops at its last token.
+func (p *Parser) parseResult() ast.Expression {
+	if p.currentToken != token.LPAREN {
+		return p.parseType()
+	}
+	list := &ast.ResultList{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	for p.currentToken != token.RPAREN {
+		list.Types = append(list.Types, p.parseType())
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RPAREN {
+		p.error("expected closing parenthesis")
+	} else if len(list.Types) == 0 {
+		p.error("expected result type")
+	}
+	return list
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: p.currentToken, Literal: p.currentLiteral}
+	block := p.parseBlock()
+	p.readNext()
+	return block
+}
+
+// parseBlock parses a block and stops at its closing brace.
+func (p *Parser) parseBlock() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
 	p.readNext()
 
 	for p.currentToken != token.RBRACE && p.currentToken != token.EOF {
-		stmt := p.parseStatement()
-		block.Statements = append(block.Statements, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
 	}
 	if p.currentToken == token.EOF {
-		p.Errors = append(p.Errors, errors.New("unexpected end of file"))
+		p.error("unexpected end of file")
 	}
-
-	p.readNext()
+	block.Rbrace = p.currentPos
 
 	return block
 }
@@ -104,96 +465,599 @@ func (p *Parser) parseStatement() ast.Statement {
 	if p.currentToken == token.RETURN {
 		return p.parseReturnStatement()
 	}
-	if p.currentToken == token.IDENT && p.peekToken == token.ASSIGN {
-		return p.parseAssignmentStatement()
+	if p.currentToken == token.FOR {
+		return p.parseForStatement()
+	}
+	if p.currentToken == token.LET || p.currentToken == token.VAR || p.currentToken == token.CONST {
+		return p.parseValueDeclaration()
 	}
+	if p.currentToken == token.IDENT || p.currentToken == token.MATCH || p.currentToken == token.FN {
+		return p.parseSimpleStatement()
+	}
+
+	// skip the broken statement so that parsing can continue after it
+	p.error("unexpected " + p.currentToken.String())
+	p.skipStatement()
 	return nil
 }
 
-func (p *Parser) parseAssignmentStatement() *ast.AssignmentStatement {
-	if p.peekToken != token.ASSIGN || p.currentToken != token.IDENT {
-		p.Errors = append(p.Errors, errors.New("expected single identifier before assignment operator"))
+// skipStatement skips to the end of a broken statement.
+func (p *Parser) skipStatement() {
+	for p.currentToken != token.SEMICOLON && p.currentToken != token.RBRACE && p.currentToken != token.EOF {
+		p.readNext()
 	}
-	stmt := &ast.AssignmentStatement{Token: p.peekToken, Literal: p.peekLiteral}
-	stmt.Identifier = p.parseIdentifier()
-	// read the assignment token
-	p.readNext()
-	// read the value
+	if p.currentToken == token.SEMICOLON {
+		p.readNext()
+	}
+}
+
+// parseSimpleStatement parses a statement that starts with an expression: a
+// call or match whose result is discarded or an assignment to a comma
+// separated list of targets like x, m[k] or p.x.
+func (p *Parser) parseSimpleStatement() ast.Statement {
+	tok, literal, pos := p.currentToken, p.currentLiteral, p.currentPos
+	call := p.peekToken == token.LPAREN || p.peekToken == token.DOT || p.currentToken == token.MATCH
+	var targets []ast.Expression
+	for {
+		target := p.parseExpression(token.LowestPrec)
+		if target == nil {
+			p.skipStatement()
+			return nil
+		}
+		targets = append(targets, target)
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+
+	if p.currentToken != token.ASSIGN && p.currentToken != token.DEFINE {
+		switch targets[0].(type) {
+		case *ast.CallExpression, *ast.TryExpression:
+			call = true
+		}
+		if call && len(targets) == 1 {
+			stmt := &ast.ExpressionStatement{Token: tok, Literal: literal, Pos: pos, Expression: targets[0]}
+			stmt.Semicolon = p.consumeSemicolon()
+			return stmt
+		}
+		p.error("expected assignment operator")
+		p.skipStatement()
+		return nil
+	}
+	stmt := &ast.AssignmentStatement{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Targets: targets}
 	p.readNext()
 
-	value := p.parseExpression()
-	stmt.Value = value
+	stmt.Value = p.parseExpression(token.LowestPrec)
 	p.readNext()
 
-	p.consumeSemicolon()
+	stmt.Semicolon = p.consumeSemicolon()
 
 	return stmt
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	stmt := &ast.ReturnStatement{Token: p.currentToken, Literal: p.currentLiteral}
+	stmt := &ast.ReturnStatement{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
 	p.readNext()
 
-	stmt.ReturnValue = p.parseExpression()
+	// a bare return; ends a void function
+	if p.currentToken != token.SEMICOLON {
+		for {
+			stmt.ReturnValues = append(stmt.ReturnValues, p.parseExpression(token.LowestPrec))
+			p.readNext()
+
+			if p.currentToken != token.COMMA {
+				break
+			}
+			p.readNext()
+		}
+	}
+
+	stmt.Semicolon = p.consumeSemicolon()
+
+	return stmt
+}
+
+// parseForStatement parses a loop like for i, v in xs { ... } where the
+// index may be left out.
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
 	p.readNext()
 
-	p.consumeSemicolon()
+	if p.currentToken != token.IDENT {
+		p.error("expected identifier")
+	}
+	stmt.Value = p.parseIdentifier()
+	p.readNext()
+	if p.currentToken == token.COMMA {
+		p.readNext()
+		if p.currentToken != token.IDENT {
+			p.error("expected identifier")
+		}
+		stmt.Index, stmt.Value = stmt.Value, p.parseIdentifier()
+		p.readNext()
+	}
+
+	if p.currentToken != token.IN {
+		p.error("expected in")
+	} else {
+		p.readNext()
+	}
+	p.noStructLiteral = true
+	stmt.Iterable = p.parseExpression(token.LowestPrec)
+	p.noStructLiteral = false
+	p.readNext()
+
+	stmt.Body = p.parseBlockStatement()
 
 	return stmt
 }
 
-func (p *Parser) parseExpression() ast.Expression {
+// parseExpression parses an expression whose binary operators bind tighter
+// than precedence. It stops at the last token of the expression.
+func (p *Parser) parseExpression(precedence int) ast.Expression {
+	left := p.parseOperand()
+	if left == nil {
+		return nil
+	}
+	// indexing, selectors, calls and ? bind tighter than every binary
+	// operator
+	for p.peekToken == token.LBRACK || p.peekToken == token.DOT || p.peekToken == token.LPAREN || p.peekToken == token.QUESTION {
+		p.readNext()
+		switch p.currentToken {
+		case token.DOT:
+			left = p.parseSelector(left)
+		case token.QUESTION:
+			left = &ast.TryExpression{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, X: left}
+		case token.LPAREN:
+			call := &ast.CallExpression{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Callee: left}
+			left = p.parseArguments(call)
+		default:
+			left = p.parseIndexExpression(left)
+		}
+		if left == nil {
+			return nil
+		}
+	}
+
+	for precedence < p.peekToken.Precedence() {
+		p.readNext()
+		left = p.parseInfixExpression(left)
+	}
+	return left
+}
+
+func (p *Parser) parseOperand() ast.Expression {
 	switch p.currentToken {
 	case token.IDENT:
-		if p.peekToken == token.LPAREN {
+		switch {
+		case p.peekToken == token.LPAREN, p.peekToken == token.DOT && p.imports[p.currentLiteral]:
 			return p.parseCallExpression()
-		} else {
-			return p.parseIdentifier()
+		case p.peekToken == token.LBRACE && !p.noStructLiteral:
+			return p.parseStructLiteral()
 		}
+		return p.parseIdentifier()
 	case token.INT:
 		return p.parseIntLiteral()
+	case token.FLOAT:
+		return p.parseFloatLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.LBRACK:
+		return p.parseSliceLiteral()
+	case token.LBRACE:
+		return p.parseMapLiteral(nil)
+	case token.MAP:
+		typ := p.parseMapType()
+		p.readNext()
+		if p.currentToken != token.LBRACE {
+			p.error("expected opening brace")
+			return nil
+		}
+		return p.parseMapLiteral(typ)
+	case token.MATCH:
+		return p.parseMatchExpression()
+	case token.FN:
+		if lit := p.parseFunctionLiteral(); lit != nil {
+			return lit
+		}
+		return nil
+	case token.LPAREN:
+		defer p.allowStructLiterals()()
+		p.readNext()
+		expr := p.parseExpression(token.LowestPrec)
+		if expr == nil {
+			return nil
+		}
+		p.readNext()
+		if p.currentToken != token.RPAREN {
+			p.error("expected closing parenthesis")
+		}
+		return expr
 	default:
-		p.Errors = append(p.Errors, errors.New("unknown expression"))
+		p.error("unknown expression")
+		return nil
+	}
+}
+
+// parseFunctionLiteral parses an anonymous function like fn(x int) int {
+// return x; } and stops at its closing brace.
+func (p *Parser) parseFunctionLiteral() *ast.FunctionLiteral {
+	defer p.allowStructLiterals()()
+	lit := &ast.FunctionLiteral{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	lit.Parameters = p.parseParameters()
+
+	if p.currentToken != token.LBRACE {
+		lit.ReturnType = p.parseResult()
+		p.readNext()
+	} else {
+		lit.ReturnType = &ast.Identifier{Token: token.IDENT, Literal: "void", Value: "void"}
+	}
+	if p.currentToken != token.LBRACE {
+		p.error("expected opening brace")
+		return nil
+	}
+
+	lit.Body = p.parseBlock()
+
+	return lit
+}
+
+// allowStructLiterals allows struct literals until the returned function is
+// called, they are fine in parentheses and brackets even in loop headers.
+func (p *Parser) allowStructLiterals() (restore func()) {
+	outer := p.noStructLiteral
+	p.noStructLiteral = false
+	return func() { p.noStructLiteral = outer }
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) *ast.InfixExpression {
+	expr := &ast.InfixExpression{
+		Token:    p.currentToken,
+		Literal:  p.currentLiteral,
+		Pos:      p.currentPos,
+		Left:     left,
+		Operator: p.currentLiteral,
+	}
+	precedence := p.currentToken.Precedence()
+	p.readNext()
+
+	expr.Right = p.parseExpression(precedence)
+
+	return expr
+}
+
+// parseIndexExpression parses an index expression like xs[i] or a slice
+// expression like xs[low:high] of left, starting at the opening bracket.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer p.allowStructLiterals()()
+	tok, literal, pos := p.currentToken, p.currentLiteral, p.currentPos
+	p.readNext()
+
+	var low ast.Expression
+	if p.currentToken != token.COLON {
+		low = p.parseExpression(token.LowestPrec)
+		p.readNext()
+	}
+	if p.currentToken != token.COLON {
+		if p.currentToken != token.RBRACK {
+			p.error("expected closing bracket")
+		}
+		return &ast.IndexExpression{Token: tok, Literal: literal, Pos: pos, Left: left, Index: low}
+	}
+	p.readNext()
+
+	var high ast.Expression
+	if p.currentToken != token.RBRACK {
+		high = p.parseExpression(token.LowestPrec)
+		p.readNext()
+	}
+	if p.currentToken != token.RBRACK {
+		p.error("expected closing bracket")
+	}
+	return &ast.SliceExpression{Token: tok, Literal: literal, Pos: pos, Left: left, Low: low, High: high}
+}
+
+func (p *Parser) parseSliceLiteral() *ast.SliceLiteral {
+	lit := &ast.SliceLiteral{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	for p.currentToken != token.RBRACK {
+		elem := p.parseExpression(token.LowestPrec)
+		if elem == nil {
+			return lit
+		}
+		lit.Elements = append(lit.Elements, elem)
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RBRACK {
+		p.error("expected closing bracket")
+	}
+
+	return lit
+}
+
+// parseMatchExpression parses a match expression like match s { P => x,
+// Q if y => z } where the last arm may be followed by a comma. Struct
+// literals are not allowed in the subject, whose end is the opening brace.
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expr := &ast.MatchExpression{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	p.readNext()
+
+	outer := p.noStructLiteral
+	p.noStructLiteral = true
+	expr.Subject = p.parseExpression(token.LowestPrec)
+	p.noStructLiteral = outer
+	if expr.Subject == nil {
+		return nil
+	}
+	p.readNext()
+	if p.currentToken != token.LBRACE {
+		p.error("expected opening brace")
+		return nil
+	}
+	defer p.allowStructLiterals()()
+	p.readNext()
+
+	for p.currentToken != token.RBRACE {
+		arm := &ast.MatchArm{Pattern: p.parseExpression(token.LowestPrec)}
+		if arm.Pattern == nil {
+			return nil
+		}
+		p.readNext()
+		if p.currentToken == token.IF {
+			p.readNext()
+			if arm.Guard = p.parseExpression(token.LowestPrec); arm.Guard == nil {
+				return nil
+			}
+			p.readNext()
+		}
+		if p.currentToken != token.ARROW {
+			p.error("expected =>")
+			return nil
+		}
+		arm.Token, arm.Literal, arm.Pos = p.currentToken, p.currentLiteral, p.currentPos
+		p.readNext()
+		if arm.Body = p.parseExpression(token.LowestPrec); arm.Body == nil {
+			return nil
+		}
+		expr.Arms = append(expr.Arms, arm)
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RBRACE {
+		p.error("expected closing brace")
+		return nil
+	}
+	expr.Rbrace = p.currentPos
+
+	return expr
+}
+
+// parseSelector parses a field selector like p.x or a method call like
+// p.len() of left, starting at the dot.
+func (p *Parser) parseSelector(left ast.Expression) ast.Expression {
+	tok, literal, pos := p.currentToken, p.currentLiteral, p.currentPos
+	p.readNext()
+	if p.currentToken != token.IDENT {
+		p.error("expected field or method name")
 		return nil
 	}
+	if p.peekToken == token.LPAREN {
+		call := p.parseCallExpression()
+		call.Receiver = left
+		return call
+	}
+	return &ast.SelectorExpression{Token: tok, Literal: literal, Pos: pos, X: left, Sel: p.parseIdentifier()}
+}
+
+// parseStructLiteral parses a struct literal like Point{x: 1, y: 2}
+// starting at the name of the type.
+func (p *Parser) parseStructLiteral() *ast.StructLiteral {
+	typ := p.parseIdentifier()
+	p.readNext()
+	lit := &ast.StructLiteral{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Type: typ}
+	p.readNext()
+
+	for p.currentToken != token.RBRACE {
+		if p.currentToken != token.IDENT {
+			p.error("expected field name")
+			return lit
+		}
+		field := &ast.KeyValueExpression{Key: p.parseIdentifier()}
+		p.readNext()
+		if p.currentToken != token.COLON {
+			p.error("expected colon")
+			return lit
+		}
+		field.Token, field.Literal, field.Pos = p.currentToken, p.currentLiteral, p.currentPos
+		p.readNext()
+		field.Value = p.parseExpression(token.LowestPrec)
+		if field.Value == nil {
+			return lit
+		}
+		lit.Fields = append(lit.Fields, field)
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RBRACE {
+		p.error("expected closing brace")
+	}
+
+	return lit
+}
+
+// parseMapLiteral parses a map literal like {"a": 1} starting at the opening
+// brace, typ is the map type written before it or nil.
+func (p *Parser) parseMapLiteral(typ ast.Expression) *ast.MapLiteral {
+	lit := &ast.MapLiteral{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Type: typ}
+	p.readNext()
+
+	for p.currentToken != token.RBRACE {
+		key := p.parseExpression(token.LowestPrec)
+		if key == nil {
+			return lit
+		}
+		p.readNext()
+
+		pair := &ast.KeyValueExpression{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Key: key}
+		if p.currentToken != token.COLON {
+			p.error("expected colon")
+			return lit
+		}
+		p.readNext()
+		pair.Value = p.parseExpression(token.LowestPrec)
+		if pair.Value == nil {
+			return lit
+		}
+		lit.Pairs = append(lit.Pairs, pair)
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
+	if p.currentToken != token.RBRACE {
+		p.error("expected closing brace")
+	}
+
+	return lit
 }
 
+// parseIntLiteral parses decimal, 0x, 0o and 0b literals with optional
+// underscores between digits. Unlike Go, a leading zero does not make a
+// literal octal.
 func (p *Parser) parseIntLiteral() *ast.IntLiteral {
-	intValue, err := strconv.ParseInt(p.currentLiteral, 10, 64)
+	lit := p.currentLiteral
+	var intValue int64
+	var err error
+	if len(lit) > 1 && lit[0] == '0' && strings.IndexByte("xXoObB", lit[1]) >= 0 {
+		intValue, err = strconv.ParseInt(lit, 0, 64)
+	} else if strings.Contains(lit, "__") || strings.HasSuffix(lit, "_") {
+		err = strconv.ErrSyntax
+	} else {
+		intValue, err = strconv.ParseInt(strings.ReplaceAll(lit, "_", ""), 10, 64)
+	}
+	if err != nil {
+		p.error("could not parse int literal")
+	}
+	return &ast.IntLiteral{Token: p.currentToken, Literal: lit, Pos: p.currentPos, Value: intValue}
+}
+
+func (p *Parser) parseFloatLiteral() *ast.FloatLiteral {
+	floatValue, err := strconv.ParseFloat(p.currentLiteral, 64)
 	if err != nil {
-		p.Errors = append(p.Errors, errors.New("could not parse int literal"))
+		p.error("could not parse float literal")
 	}
-	return &ast.IntLiteral{Token: p.currentToken, Literal: p.currentLiteral, Value: intValue}
+	return &ast.FloatLiteral{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Value: floatValue}
+}
+
+func (p *Parser) parseStringLiteral() *ast.StringLiteral {
+	value, err := strconv.Unquote(p.currentLiteral)
+	if err != nil {
+		p.error("could not parse string literal")
+	}
+	return &ast.StringLiteral{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Value: value}
 }
 
 func (p *Parser) parseIdentifier() *ast.Identifier {
-	return &ast.Identifier{Token: p.currentToken, Literal: p.currentLiteral, Value: p.currentLiteral}
+	return &ast.Identifier{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos, Value: p.currentLiteral}
 }
 
 func (p *Parser) parseCallExpression() *ast.CallExpression {
-	call := &ast.CallExpression{Token: p.currentToken, Literal: p.currentLiteral}
+	defer p.allowStructLiterals()()
+	call := &ast.CallExpression{Token: p.currentToken, Literal: p.currentLiteral, Pos: p.currentPos}
+	if p.peekToken == token.DOT {
+		call.Module = p.parseIdentifier()
+		p.readNext()
+		p.readNext()
+		if p.currentToken != token.IDENT {
+			p.error("expected function name")
+		}
+	}
 	call.Function = p.parseIdentifier()
 	p.readNext()
 
 	if p.currentToken != token.LPAREN {
-		p.Errors = append(p.Errors, errors.New("expected left parenthesis"))
+		p.error("expected left parenthesis")
 	}
+	return p.parseArguments(call)
+}
+
+// parseArguments parses the arguments of call starting at the opening
+// parenthesis and stops at the closing one.
+func (p *Parser) parseArguments(call *ast.CallExpression) *ast.CallExpression {
+	defer p.allowStructLiterals()()
 	p.readNext()
+
+	for p.currentToken != token.RPAREN {
+		arg := p.parseExpression(token.LowestPrec)
+		if arg == nil {
+			return call
+		}
+		call.Arguments = append(call.Arguments, arg)
+		p.readNext()
+
+		if p.currentToken != token.COMMA {
+			break
+		}
+		p.readNext()
+	}
 	if p.currentToken != token.RPAREN {
-		p.Errors = append(p.Errors, errors.New("expected closing parenthesis"))
+		p.error("expected closing parenthesis")
 	}
 
 	return call
 }
 
 func (p *Parser) readNext() {
-	p.currentToken, p.currentLiteral = p.peekToken, p.peekLiteral
+	p.currentToken, p.currentLiteral, p.currentPos = p.peekToken, p.peekLiteral, p.peekPos
 	p.peekToken, p.peekLiteral = p.s.Next()
+	p.peekPos = p.s.Pos()
+
+	// comments are not part of the grammar, they are only kept for tools
+	for p.peekToken == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Pos: p.peekPos, Text: p.peekLiteral})
+		p.peekToken, p.peekLiteral = p.s.Next()
+		p.peekPos = p.s.Pos()
+	}
 }
 
-func (p *Parser) consumeSemicolon() {
+// consumeSemicolon skips the semicolon that ends a statement and returns
+// its position, or the zero position if it is missing.
+func (p *Parser) consumeSemicolon() token.Pos {
 	if p.currentToken != token.SEMICOLON {
-		p.Errors = append(p.Errors, errors.New("expected semicolon"))
+		// leave the token alone, it most likely belongs to the next statement
+		p.error("expected semicolon")
+		return token.Pos{}
 	}
+	pos := p.currentPos
 	p.readNext()
+	return pos
+}
+
+func (p *Parser) error(msg string) {
+	p.errorAt(p.currentPos, msg)
+}
+
+func (p *Parser) errorAt(pos token.Pos, msg string) {
+	p.Errors = append(p.Errors, &Error{Pos: pos, Msg: msg})
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/muggel/emlang/scanner"
 	"github.com/muggel/emlang/token"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParser_parseIntLiteral(t *testing.T) {
@@ -15,7 +16,7 @@ func TestParser_parseIntLiteral(t *testing.T) {
 		s := scanner.NewScanner("123")
 		p := NewParser(s)
 		res := p.parseIntLiteral()
-		expected := &ast.IntLiteral{Token: token.INT, Literal: "123", Value: 123}
+		expected := &ast.IntLiteral{Token: token.INT, Literal: "123", Pos: token.Pos{Line: 1, Column: 1}, Value: 123}
 		assert.Equal(t, expected, res)
 	})
 
@@ -25,13 +26,62 @@ func TestParser_parseIntLiteral(t *testing.T) {
 		p.parseIntLiteral()
 		assert.Equal(t, 1, len(p.Errors))
 	})
+
+	t.Run("parses_prefixed_and_separated_literals", func(t *testing.T) {
+		for lit, want := range map[string]int64{"0xFF": 255, "0o17": 15, "0b1010": 10, "1_000_000": 1000000, "017": 17, "0x_1F": 31} {
+			p := NewParser(scanner.NewScanner(lit))
+			res := p.parseIntLiteral()
+			assert.Empty(t, p.Errors, lit)
+			assert.Equal(t, want, res.Value, lit)
+		}
+	})
+
+	t.Run("reports_invalid_digits_and_separators", func(t *testing.T) {
+		for _, lit := range []string{"0b102", "0o8", "0x", "1__0", "1_", "9223372036854775808"} {
+			p := NewParser(scanner.NewScanner(lit))
+			p.parseIntLiteral()
+			assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 1}, Msg: "could not parse int literal"}}, p.Errors, lit)
+		}
+	})
+}
+
+func TestParser_parseFloatLiteral(t *testing.T) {
+	t.Run("parses_float_literals", func(t *testing.T) {
+		for lit, want := range map[string]float64{"1.5": 1.5, "2e10": 2e10, "3E-2": 0.03, "1_0.2_5": 10.25} {
+			p := NewParser(scanner.NewScanner(lit))
+			res := p.parseFloatLiteral()
+			assert.Empty(t, p.Errors, lit)
+			assert.Equal(t, &ast.FloatLiteral{Token: token.FLOAT, Literal: lit, Pos: token.Pos{Line: 1, Column: 1}, Value: want}, res)
+		}
+	})
+
+	t.Run("reports_out_of_range_literals", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("1e400"))
+		p.parseFloatLiteral()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 1}, Msg: "could not parse float literal"}}, p.Errors)
+	})
+}
+
+func TestParser_parseStringLiteral(t *testing.T) {
+	t.Run("parses_string_literal", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner(`"a\tb"`))
+		res := p.parseStringLiteral()
+		expected := &ast.StringLiteral{Token: token.STRING, Literal: `"a\tb"`, Pos: token.Pos{Line: 1, Column: 1}, Value: "a\tb"}
+		assert.Equal(t, expected, res)
+	})
+
+	t.Run("adds_error_to_parser_if_string_literal_is_not_terminated", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner(`"abc`))
+		p.parseStringLiteral()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 1}, Msg: "could not parse string literal"}}, p.Errors)
+	})
 }
 
 func TestParser_parseIdentifier(t *testing.T) {
 	s := scanner.NewScanner("abc")
 	p := NewParser(s)
 	res := p.parseIdentifier()
-	expected := &ast.Identifier{Token: token.IDENT, Literal: "abc", Value: "abc"}
+	expected := &ast.Identifier{Token: token.IDENT, Literal: "abc", Pos: token.Pos{Line: 1, Column: 1}, Value: "abc"}
 	assert.Equal(t, expected, res)
 }
 
@@ -42,7 +92,93 @@ func TestParser_parseCallExpression(t *testing.T) {
 	expected := &ast.CallExpression{
 		Token:    token.IDENT,
 		Literal:  "foo",
-		Function: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
+		Pos:      token.Pos{Line: 1, Column: 1},
+		Function: &ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 1, Column: 1}, Value: "foo"},
+	}
+	assert.Equal(t, expected, res)
+}
+
+func TestParser_parseCallExpression_arguments(t *testing.T) {
+	t.Run("parses_arguments", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("foo(1, bar, baz())"))
+		res := p.parseCallExpression()
+		assert.Empty(t, p.Errors)
+		assert.Equal(t, []ast.Expression{
+			&ast.IntLiteral{Token: token.INT, Literal: "1", Pos: token.Pos{Line: 1, Column: 5}, Value: 1},
+			&ast.Identifier{Token: token.IDENT, Literal: "bar", Pos: token.Pos{Line: 1, Column: 8}, Value: "bar"},
+			&ast.CallExpression{
+				Token:    token.IDENT,
+				Literal:  "baz",
+				Pos:      token.Pos{Line: 1, Column: 13},
+				Function: &ast.Identifier{Token: token.IDENT, Literal: "baz", Pos: token.Pos{Line: 1, Column: 13}, Value: "baz"},
+			},
+		}, res.Arguments)
+	})
+
+	t.Run("adds_error_to_parser_if_closing_parenthesis_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("foo(1 2)"))
+		p.parseCallExpression()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 7}, Msg: "expected closing parenthesis"}}, p.Errors)
+	})
+}
+
+func TestParser_parseExpression(t *testing.T) {
+	t.Run("respects_precedence_and_associativity", func(t *testing.T) {
+		tests := []struct {
+			source   string
+			expected string
+		}{
+			{"1 + 2 * 3", "(1 + (2 * 3))"},
+			{"1 * 2 + 3", "((1 * 2) + 3)"},
+			{"a - b - c", "((a - b) - c)"},
+			{"a / b * c", "((a / b) * c)"},
+			{"(1 + 2) * f(x - 1)", "((1 + 2) * f((x - 1)))"},
+		}
+		for _, tt := range tests {
+			p := NewParser(scanner.NewScanner(tt.source))
+			res := p.parseExpression(token.LowestPrec)
+			assert.Empty(t, p.Errors, tt.source)
+			assert.Equal(t, tt.expected, res.String(), tt.source)
+		}
+	})
+
+	t.Run("parses_infix_expression", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("a / 2"))
+		res := p.parseExpression(token.LowestPrec)
+		expected := &ast.InfixExpression{
+			Token:    token.DIV,
+			Literal:  "/",
+			Pos:      token.Pos{Line: 1, Column: 3},
+			Left:     &ast.Identifier{Token: token.IDENT, Literal: "a", Pos: token.Pos{Line: 1, Column: 1}, Value: "a"},
+			Operator: "/",
+			Right:    &ast.IntLiteral{Token: token.INT, Literal: "2", Pos: token.Pos{Line: 1, Column: 5}, Value: 2},
+		}
+		assert.Equal(t, expected, res)
+	})
+
+	t.Run("adds_error_to_parser_if_closing_parenthesis_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("(1 + 2;"))
+		p.parseExpression(token.LowestPrec)
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 7}, Msg: "expected closing parenthesis"}}, p.Errors)
+	})
+}
+
+func TestParser_parseExpressionStatement(t *testing.T) {
+	p := NewParser(scanner.NewScanner("log(1);"))
+	res := p.parseStatement()
+	assert.Empty(t, p.Errors)
+	expected := &ast.ExpressionStatement{
+		Token:   token.IDENT,
+		Literal: "log",
+		Pos:     token.Pos{Line: 1, Column: 1},
+		Expression: &ast.CallExpression{
+			Token:     token.IDENT,
+			Literal:   "log",
+			Pos:       token.Pos{Line: 1, Column: 1},
+			Function:  &ast.Identifier{Token: token.IDENT, Literal: "log", Pos: token.Pos{Line: 1, Column: 1}, Value: "log"},
+			Arguments: []ast.Expression{&ast.IntLiteral{Token: token.INT, Literal: "1", Pos: token.Pos{Line: 1, Column: 5}, Value: 1}},
+		},
+		Semicolon: token.Pos{Line: 1, Column: 7},
 	}
 	assert.Equal(t, expected, res)
 }
@@ -53,13 +189,24 @@ func TestParser_parseReturnStatement(t *testing.T) {
 		p := NewParser(s)
 		res := p.parseReturnStatement()
 		expected := &ast.ReturnStatement{
-			Token:       token.RETURN,
-			Literal:     "return",
-			ReturnValue: &ast.IntLiteral{Token: token.INT, Literal: "123", Value: 123},
+			Token:        token.RETURN,
+			Literal:      "return",
+			Pos:          token.Pos{Line: 1, Column: 1},
+			ReturnValues: []ast.Expression{&ast.IntLiteral{Token: token.INT, Literal: "123", Pos: token.Pos{Line: 1, Column: 8}, Value: 123}},
+			Semicolon:    token.Pos{Line: 1, Column: 11},
 		}
 		assert.Equal(t, expected, res)
 	})
 
+	t.Run("parses_bare_return", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("return;"))
+		res := p.parseReturnStatement()
+		assert.Empty(t, p.Errors)
+		assert.Empty(t, res.ReturnValues)
+		assert.Equal(t, token.Pos{Line: 1, Column: 7}, res.Semicolon)
+		assert.Equal(t, "return;\n", res.String())
+	})
+
 	t.Run("adds_error_to_parser_if_return_statement_is_missing_semicolon", func(t *testing.T) {
 		s := scanner.NewScanner("return 123")
 		p := NewParser(s)
@@ -72,30 +219,51 @@ func TestParser_parseAssignmentStatement(t *testing.T) {
 	t.Run("parses_assignment_statement", func(t *testing.T) {
 		s := scanner.NewScanner("foo = 123;")
 		p := NewParser(s)
-		res := p.parseAssignmentStatement()
+		res := p.parseSimpleStatement()
 		expected := &ast.AssignmentStatement{
-			Token:      token.ASSIGN,
-			Literal:    "=",
-			Identifier: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
-			Value:      &ast.IntLiteral{Token: token.INT, Literal: "123", Value: 123},
+			Token:     token.ASSIGN,
+			Literal:   "=",
+			Pos:       token.Pos{Line: 1, Column: 5},
+			Targets:   []ast.Expression{&ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 1, Column: 1}, Value: "foo"}},
+			Value:     &ast.IntLiteral{Token: token.INT, Literal: "123", Pos: token.Pos{Line: 1, Column: 7}, Value: 123},
+			Semicolon: token.Pos{Line: 1, Column: 10},
 		}
 
 		assert.Equal(t, expected, res)
 	})
 
+	t.Run("parses_short_variable_declaration", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("foo := 123;"))
+		res := p.parseSimpleStatement().(*ast.AssignmentStatement)
+		assert.Empty(t, p.Errors)
+		assert.True(t, res.IsDefine())
+		assert.Equal(t, token.Pos{Line: 1, Column: 5}, res.Pos)
+		assert.Equal(t, "foo := 123;\n", res.String())
+	})
+
 	t.Run("adds_error_to_parser_if_assignment_statement_is_missing_semicolon", func(t *testing.T) {
 		s := scanner.NewScanner("foo = 123")
 		p := NewParser(s)
-		p.parseAssignmentStatement()
+		p.parseSimpleStatement()
 		assert.Equal(t, 1, len(p.Errors))
 	})
 
 	t.Run("adds_error_to_parser_if_assignment_statement_does_not_contain_assignment_token", func(t *testing.T) {
 		s := scanner.NewScanner("foo - 123;")
 		p := NewParser(s)
-		p.parseAssignmentStatement()
+		p.parseSimpleStatement()
 		assert.Equal(t, 1, len(p.Errors))
 	})
+
+	t.Run("parses_several_targets_and_elements", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("v, ok := m[k];\nm[\"a\"] = xs[0];"))
+		first := p.parseSimpleStatement()
+		second := p.parseSimpleStatement()
+		assert.Empty(t, p.Errors)
+		assert.Equal(t, "v, ok := m[k];\n", first.String())
+		assert.Equal(t, "m[\"a\"] = xs[0];\n", second.String())
+		assert.Equal(t, token.Pos{Line: 2, Column: 1}, ast.Start(second))
+	})
 }
 
 func TestParser_parseBlockStatement(t *testing.T) {
@@ -106,19 +274,25 @@ func TestParser_parseBlockStatement(t *testing.T) {
 		expected := &ast.BlockStatement{
 			Token:   token.LBRACE,
 			Literal: "{",
+			Pos:     token.Pos{Line: 1, Column: 1},
 			Statements: []ast.Statement{
 				&ast.AssignmentStatement{
-					Token:      token.ASSIGN,
-					Literal:    "=",
-					Identifier: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
-					Value:      &ast.IntLiteral{Token: token.INT, Literal: "123", Value: 123},
+					Token:     token.ASSIGN,
+					Literal:   "=",
+					Pos:       token.Pos{Line: 2, Column: 5},
+					Targets:   []ast.Expression{&ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 2, Column: 1}, Value: "foo"}},
+					Value:     &ast.IntLiteral{Token: token.INT, Literal: "123", Pos: token.Pos{Line: 2, Column: 7}, Value: 123},
+					Semicolon: token.Pos{Line: 2, Column: 10},
 				},
 				&ast.ReturnStatement{
-					Token:       token.RETURN,
-					Literal:     "return",
-					ReturnValue: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
+					Token:        token.RETURN,
+					Literal:      "return",
+					Pos:          token.Pos{Line: 3, Column: 1},
+					ReturnValues: []ast.Expression{&ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 3, Column: 8}, Value: "foo"}},
+					Semicolon:    token.Pos{Line: 3, Column: 11},
 				},
 			},
+			Rbrace: token.Pos{Line: 4, Column: 1},
 		}
 
 		assert.Equal(t, expected, res)
@@ -132,6 +306,200 @@ func TestParser_parseBlockStatement(t *testing.T) {
 	})
 }
 
+func TestParser_parseValueDeclaration(t *testing.T) {
+	t.Run("parses_value_declaration", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("let x int = 1;"))
+		res := p.parseValueDeclaration()
+		assert.Empty(t, p.Errors)
+		expected := &ast.ValueDeclaration{
+			Token:      token.LET,
+			Literal:    "let",
+			Pos:        token.Pos{Line: 1, Column: 1},
+			Identifier: &ast.Identifier{Token: token.IDENT, Literal: "x", Pos: token.Pos{Line: 1, Column: 5}, Value: "x"},
+			Type:       &ast.Identifier{Token: token.IDENT, Literal: "int", Pos: token.Pos{Line: 1, Column: 7}, Value: "int"},
+			Value:      &ast.IntLiteral{Token: token.INT, Literal: "1", Pos: token.Pos{Line: 1, Column: 13}, Value: 1},
+			Semicolon:  token.Pos{Line: 1, Column: 14},
+		}
+		assert.Equal(t, expected, res)
+	})
+
+	t.Run("parses_declarations_without_type_or_value", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("var s string;\nconst n = 1 + 2;"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		require.Len(t, program.TopLevelDeclarations, 2)
+		assert.Equal(t, "var s string;\n", program.TopLevelDeclarations[0].String())
+		assert.Equal(t, "const n = (1 + 2);\n", program.TopLevelDeclarations[1].String())
+		assert.True(t, program.TopLevelDeclarations[1].(*ast.ValueDeclaration).IsConst())
+	})
+
+	t.Run("adds_error_to_parser_if_constant_has_no_value", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("const x int;"))
+		p.parseValueDeclaration()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 12}, Msg: "missing value in constant declaration"}}, p.Errors)
+	})
+
+	t.Run("adds_error_to_parser_if_type_and_value_are_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("let x;"))
+		p.parseValueDeclaration()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 6}, Msg: "expected type or value"}}, p.Errors)
+	})
+}
+
+func TestParser_parseStatement(t *testing.T) {
+	t.Run("parses_local_value_declarations", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tlet x int = 1;\n\tconst s = \"s\";\n}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		stmts := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration).Body.Statements
+		require.Len(t, stmts, 2)
+		assert.Equal(t, "let x int = 1;\n", stmts[0].String())
+		assert.True(t, stmts[1].(*ast.ValueDeclaration).IsConst())
+	})
+}
+
+func TestParser_parseTopLevelDeclaration(t *testing.T) {
+	t.Run("skips_to_next_declaration_on_error", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("x = 1;\nlet y = 2;"))
+		program := p.parseProgram()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 1}, Msg: "expected top level declaration, found IDENT"}}, p.Errors)
+		require.Len(t, program.TopLevelDeclarations, 1)
+		assert.Equal(t, "let y = 2;\n", program.TopLevelDeclarations[0].String())
+	})
+}
+
+func TestParser_parseForStatement(t *testing.T) {
+	t.Run("parses_loops_with_and_without_index", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tfor i, x in xs {\n\t\tprintln(i, x);\n\t}\n\tfor x in [1, 2] {\n\t}\n}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		stmts := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration).Body.Statements
+		require.Len(t, stmts, 2)
+
+		loop := stmts[0].(*ast.ForStatement)
+		assert.Equal(t, "i", loop.Index.Value)
+		assert.Equal(t, "x", loop.Value.Value)
+		assert.Equal(t, "xs", loop.Iterable.String())
+		require.Len(t, loop.Body.Statements, 1)
+		assert.Nil(t, stmts[1].(*ast.ForStatement).Index)
+		assert.Equal(t, "for x in [1, 2] {\n}\n", stmts[1].String())
+	})
+
+	t.Run("adds_error_to_parser_if_in_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tfor x xs {\n\t}\n}"))
+		p.parseProgram()
+		require.NotEmpty(t, p.Errors)
+		assert.Equal(t, &Error{Pos: token.Pos{Line: 2, Column: 8}, Msg: "expected in"}, p.Errors[0])
+	})
+}
+
+func TestParser_parseIndexExpression(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"xs[1]", "xs[1]"},
+		{"xs[1:2]", "xs[1:2]"},
+		{"xs[:n + 1]", "xs[:(n + 1)]"},
+		{"xs[1:]", "xs[1:]"},
+		{"xs[:]", "xs[:]"},
+		{"grid[i][j] * 2", "(grid[i][j] * 2)"},
+		{"f(x)[0]", "f(x)[0]"},
+		{"(a + b)[0]", "(a + b)[0]"},
+		{"[[1], []]", "[[1], []]"},
+		{"{\"a\": 1, \"b\": n + 1}[k]", "{\"a\": 1, \"b\": (n + 1)}[k]"},
+		{"map[string][]int{}", "map[string][]int{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			p := NewParser(scanner.NewScanner(tt.source))
+			expr := p.parseExpression(token.LowestPrec)
+			assert.Empty(t, p.Errors)
+			assert.Equal(t, tt.expected, expr.String())
+		})
+	}
+
+	t.Run("positions_index_expressions_at_bracket", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("xs[1]"))
+		expr := p.parseExpression(token.LowestPrec).(*ast.IndexExpression)
+		assert.Equal(t, token.Pos{Line: 1, Column: 3}, expr.Pos)
+		assert.Equal(t, token.Pos{Line: 1, Column: 1}, ast.Start(expr))
+	})
+
+	t.Run("adds_error_to_parser_if_closing_bracket_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("xs[1;"))
+		p.parseExpression(token.LowestPrec)
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 5}, Msg: "expected closing bracket"}}, p.Errors)
+	})
+}
+
+func TestParser_parseType(t *testing.T) {
+	p := NewParser(scanner.NewScanner("fn f(xs []int, grid [][]string) []int {\n\tlet ys []int;\n\treturn ys;\n}"))
+	program := p.parseProgram()
+	assert.Empty(t, p.Errors)
+	fd := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration)
+	assert.Equal(t, "fn f(xs []int, grid [][]string) []int", fd.Signature())
+	assert.Equal(t, &ast.SliceType{
+		Token:   token.LBRACK,
+		Literal: "[",
+		Pos:     token.Pos{Line: 1, Column: 9},
+		Elem:    &ast.Identifier{Token: token.IDENT, Literal: "int", Pos: token.Pos{Line: 1, Column: 11}, Value: "int"},
+	}, fd.Parameters[0].Type)
+
+	t.Run("parses_map_types", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn f(m map[string]map[int][]int) {\n}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		typ := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration).Parameters[0].Type
+		require.IsType(t, &ast.MapType{}, typ)
+		assert.Equal(t, "map[string]map[int][]int", typ.String())
+	})
+
+	t.Run("adds_error_to_parser_if_map_literal_is_missing_colon", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("{\"a\" 1}"))
+		p.parseExpression(token.LowestPrec)
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 6}, Msg: "expected colon"}}, p.Errors)
+	})
+}
+
+func TestParser_parseImportDeclaration(t *testing.T) {
+	t.Run("parses_imports_and_qualified_calls", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("import \"lib/strs\";\n\nfn main() {\n\tx := strs.Upper(\"a\");\n\tstrs.Print(x);\n}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		require.Len(t, program.TopLevelDeclarations, 2)
+		id := program.TopLevelDeclarations[0].(*ast.ImportDeclaration)
+		assert.Equal(t, "lib/strs", id.Path.Value)
+		assert.Equal(t, "strs", id.Name())
+
+		stmts := program.TopLevelDeclarations[1].(*ast.FunctionDeclaration).Body.Statements
+		require.Len(t, stmts, 2)
+		call := stmts[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		assert.Equal(t, "strs", call.Module.Value)
+		assert.Equal(t, "Print", call.Function.Value)
+		assert.Equal(t, "x := strs.Upper(\"a\");\n", stmts[0].String())
+	})
+
+	t.Run("adds_error_to_parser_if_path_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("import strs;"))
+		p.parseProgram()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 8}, Msg: "expected import path"}}, p.Errors)
+	})
+
+	t.Run("adds_error_to_parser_if_import_follows_declarations", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("let x = 1;\nimport \"strs\";"))
+		p.parseProgram()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 2, Column: 1}, Msg: "imports must appear before other declarations"}}, p.Errors)
+	})
+
+	t.Run("adds_error_to_parser_if_function_name_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("import \"strs\";\nfn main() {\n\tstrs.();\n}"))
+		p.parseProgram()
+		require.NotEmpty(t, p.Errors)
+		assert.Equal(t, &Error{Pos: token.Pos{Line: 3, Column: 7}, Msg: "expected function name"}, p.Errors[0])
+	})
+}
+
 func TestParser_parseFunctionDeclaration(t *testing.T) {
 	t.Run("parses_function_declarations", func(t *testing.T) {
 		s := scanner.NewScanner("fn foo() int {\nreturn foo;\n}")
@@ -140,23 +508,59 @@ func TestParser_parseFunctionDeclaration(t *testing.T) {
 		expected := &ast.FunctionDeclaration{
 			Token:      token.FN,
 			Literal:    "fn",
-			Identifier: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
-			ReturnType: &ast.Identifier{Token: token.IDENT, Literal: "int", Value: "int"},
+			Pos:        token.Pos{Line: 1, Column: 1},
+			Identifier: &ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 1, Column: 4}, Value: "foo"},
+			ReturnType: &ast.Identifier{Token: token.IDENT, Literal: "int", Pos: token.Pos{Line: 1, Column: 10}, Value: "int"},
 			Body: &ast.BlockStatement{
 				Token:   token.LBRACE,
 				Literal: "{",
+				Pos:     token.Pos{Line: 1, Column: 14},
 				Statements: []ast.Statement{
 					&ast.ReturnStatement{
-						Token:       token.RETURN,
-						Literal:     "return",
-						ReturnValue: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
+						Token:        token.RETURN,
+						Literal:      "return",
+						Pos:          token.Pos{Line: 2, Column: 1},
+						ReturnValues: []ast.Expression{&ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 2, Column: 8}, Value: "foo"}},
+						Semicolon:    token.Pos{Line: 2, Column: 11},
 					},
 				},
+				Rbrace: token.Pos{Line: 3, Column: 1},
 			},
 		}
 		assert.Equal(t, expected, res)
 	})
 
+	t.Run("parses_parameters", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn add(a int, b int) int {\n}"))
+		res := p.parseFunctionDeclaration()
+		assert.Empty(t, p.Errors)
+		assert.Equal(t, []*ast.Parameter{
+			{
+				Token:      token.IDENT,
+				Literal:    "a",
+				Pos:        token.Pos{Line: 1, Column: 8},
+				Identifier: &ast.Identifier{Token: token.IDENT, Literal: "a", Pos: token.Pos{Line: 1, Column: 8}, Value: "a"},
+				Type:       &ast.Identifier{Token: token.IDENT, Literal: "int", Pos: token.Pos{Line: 1, Column: 10}, Value: "int"},
+			},
+			{
+				Token:      token.IDENT,
+				Literal:    "b",
+				Pos:        token.Pos{Line: 1, Column: 15},
+				Identifier: &ast.Identifier{Token: token.IDENT, Literal: "b", Pos: token.Pos{Line: 1, Column: 15}, Value: "b"},
+				Type:       &ast.Identifier{Token: token.IDENT, Literal: "int", Pos: token.Pos{Line: 1, Column: 17}, Value: "int"},
+			},
+		}, res.Parameters)
+		assert.Equal(t, "int", res.ReturnType.String())
+		assert.Equal(t, "fn add(a int, b int) int", res.Signature())
+	})
+
+	t.Run("adds_error_to_parser_if_parameter_type_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn add(a, b int) int {\n}"))
+		res := p.parseFunctionDeclaration()
+		assert.Equal(t, []error{&Error{Pos: token.Pos{Line: 1, Column: 8}, Msg: "expected parameter name and type"}}, p.Errors)
+		assert.NotNil(t, res.Body)
+	})
+
 	t.Run("handles_void_as_return_type", func(t *testing.T) {
 		s := scanner.NewScanner("fn foo() {\nabc = 123;\n}")
 		p := NewParser(s)
@@ -164,25 +568,185 @@ func TestParser_parseFunctionDeclaration(t *testing.T) {
 		expected := &ast.FunctionDeclaration{
 			Token:      token.FN,
 			Literal:    "fn",
-			Identifier: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
+			Pos:        token.Pos{Line: 1, Column: 1},
+			Identifier: &ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 1, Column: 4}, Value: "foo"},
 			ReturnType: &ast.Identifier{Token: token.IDENT, Literal: "void", Value: "void"},
 			Body: &ast.BlockStatement{
 				Token:   token.LBRACE,
 				Literal: "{",
+				Pos:     token.Pos{Line: 1, Column: 10},
 				Statements: []ast.Statement{
 					&ast.AssignmentStatement{
-						Token:      token.ASSIGN,
-						Literal:    "=",
-						Identifier: &ast.Identifier{Token: token.IDENT, Literal: "abc", Value: "abc"},
-						Value:      &ast.IntLiteral{Token: token.INT, Literal: "123", Value: 123},
+						Token:     token.ASSIGN,
+						Literal:   "=",
+						Pos:       token.Pos{Line: 2, Column: 5},
+						Targets:   []ast.Expression{&ast.Identifier{Token: token.IDENT, Literal: "abc", Pos: token.Pos{Line: 2, Column: 1}, Value: "abc"}},
+						Value:     &ast.IntLiteral{Token: token.INT, Literal: "123", Pos: token.Pos{Line: 2, Column: 7}, Value: 123},
+						Semicolon: token.Pos{Line: 2, Column: 10},
 					},
 				},
+				Rbrace: token.Pos{Line: 3, Column: 1},
 			},
 		}
 		assert.Equal(t, expected, res)
 	})
 }
 
+func TestParser_parseStructDeclaration(t *testing.T) {
+	t.Run("parses_struct_declarations", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("struct Point {\n\tx int,\n\ty []string,\n}\n\nstruct Empty {}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		require.Len(t, program.TopLevelDeclarations, 2)
+		decl := program.TopLevelDeclarations[0].(*ast.StructDeclaration)
+		assert.Equal(t, "struct Point { x int, y []string }\n", decl.String())
+		assert.Equal(t, token.Pos{Line: 4, Column: 1}, decl.Rbrace)
+		assert.Empty(t, program.TopLevelDeclarations[1].(*ast.StructDeclaration).Fields)
+	})
+
+	t.Run("parses_methods", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn (p Point) len() int {\n\treturn p.x;\n}"))
+		fd := p.parseFunctionDeclaration()
+		assert.Empty(t, p.Errors)
+		require.NotNil(t, fd.Receiver)
+		assert.Equal(t, "Point.len", fd.Name())
+		assert.Equal(t, "fn (p Point) len() int", fd.Signature())
+	})
+
+	t.Run("parses_struct_literals_selectors_and_method_calls", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tl.a.x = Point{x: 1, y: p.y}.len() + ps[0].y;\n\tl.a.reset();\n}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		body := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration).Body
+		assign := body.Statements[0].(*ast.AssignmentStatement)
+		require.IsType(t, &ast.SelectorExpression{}, assign.Targets[0])
+		assert.Equal(t, "l.a.x = (Point{x: 1, y: p.y}.len() + ps[0].y);\n", assign.String())
+		call := body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		assert.Equal(t, "l.a", call.Receiver.String())
+		assert.Equal(t, token.Pos{Line: 3, Column: 2}, ast.Start(call))
+	})
+
+	t.Run("does_not_parse_struct_literals_in_loop_headers", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("for x in xs {\n\tys = [P{x: x}];\n}"))
+		stmt := p.parseForStatement()
+		assert.Empty(t, p.Errors)
+		assert.Equal(t, "xs", stmt.Iterable.String())
+	})
+
+	t.Run("adds_error_to_parser_if_field_name_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("struct P { x int, 1 }"))
+		p.parseProgram()
+		assert.Equal(t, &Error{Pos: token.Pos{Line: 1, Column: 19}, Msg: "expected field name"}, p.Errors[0])
+	})
+}
+
+func TestParser_parseEnumDeclaration(t *testing.T) {
+	t.Run("parses_enum_declarations", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("enum Shape {\n\tCircle(int),\n\tRect(int, int),\n\tEmpty,\n}\n\nenum Color { Red, Green }"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		require.Len(t, program.TopLevelDeclarations, 2)
+		decl := program.TopLevelDeclarations[0].(*ast.EnumDeclaration)
+		assert.Equal(t, "enum Shape { Circle(int), Rect(int, int), Empty }\n", decl.String())
+		assert.Equal(t, token.Pos{Line: 5, Column: 1}, decl.Rbrace)
+		assert.Len(t, program.TopLevelDeclarations[1].(*ast.EnumDeclaration).Variants, 2)
+	})
+
+	t.Run("parses_match_expressions", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tx := match s {\n\t\tShape.Circle(r) if r => r,\n\t\tP{x: 1}.c => 2,\n\t\t_ => 0,\n\t};\n\tmatch c { Color.Red => f() };\n}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		body := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration).Body
+		m := body.Statements[0].(*ast.AssignmentStatement).Value.(*ast.MatchExpression)
+		assert.Equal(t, "match s { Shape.Circle(r) if r => r, P{x: 1}.c => 2, _ => 0 }", m.String())
+		require.Len(t, m.Arms, 3)
+		assert.Equal(t, token.Pos{Line: 3, Column: 3}, ast.Start(m.Arms[0]))
+		assert.Equal(t, token.Pos{Line: 6, Column: 2}, m.Rbrace)
+		require.IsType(t, &ast.ExpressionStatement{}, body.Statements[1])
+	})
+
+	t.Run("adds_error_to_parser_if_arrow_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tx := match s { _ 1 };\n}"))
+		p.parseProgram()
+		assert.Equal(t, &Error{Pos: token.Pos{Line: 2, Column: 19}, Msg: "expected =>"}, p.Errors[0])
+	})
+
+	t.Run("adds_error_to_parser_if_variant_name_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("enum E { A, 1 }"))
+		p.parseProgram()
+		assert.Equal(t, &Error{Pos: token.Pos{Line: 1, Column: 13}, Msg: "expected variant name"}, p.Errors[0])
+	})
+}
+
+func TestParser_parseFunctionLiteral(t *testing.T) {
+	t.Run("parses_function_types", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn apply(f fn(int, []string) int, g fn()) fn(int) fn() {\n}"))
+		fd := p.parseFunctionDeclaration()
+		assert.Empty(t, p.Errors)
+		assert.Equal(t, "fn apply(f fn(int, []string) int, g fn()) fn(int) fn()", fd.Signature())
+		require.IsType(t, &ast.FuncType{}, fd.Parameters[0].Type)
+		assert.Nil(t, fd.Parameters[1].Type.(*ast.FuncType).Result)
+	})
+
+	t.Run("parses_function_literals_and_calls_of_values", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tadd := fn(x int) int {\n\t\treturn x + n;\n\t}(1);\n\tmakeAdder(1)(2);\n\tfs[0](p.f(1));\n}"))
+		program := p.parseProgram()
+		assert.Empty(t, p.Errors)
+		body := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration).Body
+		call := body.Statements[0].(*ast.AssignmentStatement).Value.(*ast.CallExpression)
+		lit := call.Callee.(*ast.FunctionLiteral)
+		assert.Equal(t, token.Pos{Line: 4, Column: 2}, lit.Body.Rbrace)
+		assert.Equal(t, token.Pos{Line: 4, Column: 3}, call.Pos)
+		assert.Equal(t, token.Pos{Line: 2, Column: 9}, ast.Start(call))
+
+		call = body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		assert.Equal(t, "makeAdder(1)(2)", call.String())
+		assert.Nil(t, call.Function)
+		call = body.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		assert.Equal(t, "fs[0](p.f(1))", call.String())
+	})
+
+	t.Run("adds_error_to_parser_if_body_is_missing", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn main() {\n\tf := fn(x int) int;\n}"))
+		p.parseProgram()
+		assert.Equal(t, &Error{Pos: token.Pos{Line: 2, Column: 20}, Msg: "expected opening brace"}, p.Errors[0])
+	})
+}
+
+func TestParser_parseResultList(t *testing.T) {
+	t.Run("parses_result_lists_and_return_values", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn divmod(a int, f fn() (int, []string)) (int, int) {\n\treturn a / 2, a - 1;\n}"))
+		fd := p.parseFunctionDeclaration()
+		assert.Empty(t, p.Errors)
+		assert.Equal(t, "fn divmod(a int, f fn() (int, []string)) (int, int)", fd.Signature())
+		results := fd.ReturnType.(*ast.ResultList)
+		assert.Equal(t, token.Pos{Line: 1, Column: 42}, results.Pos)
+		assert.Len(t, results.Types, 2)
+		assert.IsType(t, &ast.ResultList{}, fd.Parameters[1].Type.(*ast.FuncType).Result)
+		ret := fd.Body.Statements[0].(*ast.ReturnStatement)
+		assert.Equal(t, "return (a / 2), (a - 1);\n", ret.String())
+	})
+
+	t.Run("adds_error_to_parser_if_result_list_is_empty", func(t *testing.T) {
+		p := NewParser(scanner.NewScanner("fn f() () {\n}"))
+		p.parseProgram()
+		assert.Equal(t, &Error{Pos: token.Pos{Line: 1, Column: 9}, Msg: "expected result type"}, p.Errors[0])
+	})
+}
+
+func TestParser_parseTryExpression(t *testing.T) {
+	p := NewParser(scanner.NewScanner("fn main() error {\n\tn := parse(s)?;\n\tp.load()?;\n\terr?;\n\treturn check(n)? + 1;\n}"))
+	program := p.parseProgram()
+	assert.Empty(t, p.Errors)
+	body := program.TopLevelDeclarations[0].(*ast.FunctionDeclaration).Body
+	try := body.Statements[0].(*ast.AssignmentStatement).Value.(*ast.TryExpression)
+	assert.Equal(t, token.Pos{Line: 2, Column: 15}, try.Pos)
+	assert.Equal(t, token.Pos{Line: 2, Column: 7}, ast.Start(try))
+	assert.Equal(t, "p.load()?", body.Statements[1].(*ast.ExpressionStatement).Expression.String())
+	assert.IsType(t, &ast.TryExpression{}, body.Statements[2].(*ast.ExpressionStatement).Expression)
+	assert.Equal(t, "return (check(n)? + 1);\n", body.Statements[3].String())
+}
+
 func TestExamples(t *testing.T) {
 	t.Run("main_an_helper.em", func(t *testing.T) {
 		s := scanner.NewScanner(examples.MainAndHelper)
@@ -193,40 +757,51 @@ func TestExamples(t *testing.T) {
 				&ast.FunctionDeclaration{
 					Token:      token.FN,
 					Literal:    "fn",
-					Identifier: &ast.Identifier{Token: token.IDENT, Literal: "helper", Value: "helper"},
-					ReturnType: &ast.Identifier{Token: token.IDENT, Literal: "int", Value: "int"},
+					Pos:        token.Pos{Line: 1, Column: 1},
+					Identifier: &ast.Identifier{Token: token.IDENT, Literal: "helper", Pos: token.Pos{Line: 1, Column: 4}, Value: "helper"},
+					ReturnType: &ast.Identifier{Token: token.IDENT, Literal: "int", Pos: token.Pos{Line: 1, Column: 13}, Value: "int"},
 					Body: &ast.BlockStatement{
 						Token:   token.LBRACE,
 						Literal: "{",
+						Pos:     token.Pos{Line: 1, Column: 17},
 						Statements: []ast.Statement{
 							&ast.ReturnStatement{
-								Token:       token.RETURN,
-								Literal:     "return",
-								ReturnValue: &ast.IntLiteral{Token: token.INT, Literal: "123", Value: 123},
+								Token:        token.RETURN,
+								Literal:      "return",
+								Pos:          token.Pos{Line: 2, Column: 2},
+								ReturnValues: []ast.Expression{&ast.IntLiteral{Token: token.INT, Literal: "123", Pos: token.Pos{Line: 2, Column: 9}, Value: 123}},
+								Semicolon:    token.Pos{Line: 2, Column: 12},
 							},
 						},
+						Rbrace: token.Pos{Line: 3, Column: 1},
 					},
 				},
 				&ast.FunctionDeclaration{
 					Token:      token.FN,
 					Literal:    "fn",
-					Identifier: &ast.Identifier{Token: token.IDENT, Literal: "main", Value: "main"},
+					Pos:        token.Pos{Line: 5, Column: 1},
+					Identifier: &ast.Identifier{Token: token.IDENT, Literal: "main", Pos: token.Pos{Line: 5, Column: 4}, Value: "main"},
 					ReturnType: &ast.Identifier{Token: token.IDENT, Literal: "void", Value: "void"},
 					Body: &ast.BlockStatement{
 						Token:   token.LBRACE,
 						Literal: "{",
+						Pos:     token.Pos{Line: 5, Column: 11},
 						Statements: []ast.Statement{
 							&ast.AssignmentStatement{
-								Token:      token.ASSIGN,
-								Literal:    "=",
-								Identifier: &ast.Identifier{Token: token.IDENT, Literal: "foo", Value: "foo"},
+								Token:   token.DEFINE,
+								Literal: ":=",
+								Pos:     token.Pos{Line: 6, Column: 6},
+								Targets: []ast.Expression{&ast.Identifier{Token: token.IDENT, Literal: "foo", Pos: token.Pos{Line: 6, Column: 2}, Value: "foo"}},
 								Value: &ast.CallExpression{
 									Token:    token.IDENT,
 									Literal:  "helper",
-									Function: &ast.Identifier{Token: token.IDENT, Literal: "helper", Value: "helper"},
+									Pos:      token.Pos{Line: 6, Column: 9},
+									Function: &ast.Identifier{Token: token.IDENT, Literal: "helper", Pos: token.Pos{Line: 6, Column: 9}, Value: "helper"},
 								},
+								Semicolon: token.Pos{Line: 6, Column: 17},
 							},
 						},
+						Rbrace: token.Pos{Line: 7, Column: 1},
 					},
 				},
 			},
@@ -235,3 +810,32 @@ func TestExamples(t *testing.T) {
 		assert.Equal(t, expected, program)
 	})
 }
+
+func TestParser_Parse(t *testing.T) {
+	t.Run("collects_comments", func(t *testing.T) {
+		s := scanner.NewScanner("// doc\nfn main() {\n\tfoo = 1; // trailing\n}")
+		program, err := NewParser(s).Parse()
+		assert.NoError(t, err)
+		expected := []*ast.Comment{
+			{Pos: token.Pos{Line: 1, Column: 1}, Text: "// doc"},
+			{Pos: token.Pos{Line: 3, Column: 11}, Text: "// trailing"},
+		}
+		assert.Equal(t, expected, program.Comments)
+		assert.Equal(t, 1, len(program.TopLevelDeclarations))
+	})
+
+	t.Run("returns_positioned_errors", func(t *testing.T) {
+		s := scanner.NewScanner("fn main() {\n\treturn 1\n}")
+		_, err := NewParser(s).Parse()
+		assert.EqualError(t, err, "3:1: expected semicolon")
+	})
+
+	t.Run("recovers_from_unknown_declarations_and_statements", func(t *testing.T) {
+		s := scanner.NewScanner("123 foo;\nfn main() {\n\t+ 1;\n\treturn 2;\n}")
+		p := NewParser(s)
+		program, err := p.Parse()
+		assert.Error(t, err)
+		assert.Equal(t, 2, len(p.Errors))
+		assert.Equal(t, "fn main() void {\nreturn 2;\n}\n", program.String())
+	})
+}
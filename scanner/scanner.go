@@ -1,6 +1,8 @@
 package scanner
 
 import (
+	"strings"
+
 	"github.com/muggel/emlang/token"
 )
 
@@ -12,10 +14,15 @@ type Scanner struct {
 	position     int
 	readPosition int
 	ch           byte
+
+	// line and column of ch, pos is the position of the last scanned token
+	line   int
+	column int
+	pos    token.Pos
 }
 
 func NewScanner(source string) *Scanner {
-	s := &Scanner{source: source}
+	s := &Scanner{source: source, line: 1}
 	s.readChar()
 	return s
 }
@@ -24,6 +31,7 @@ func (s *Scanner) Next() (token.Token, string /* literal */) {
 	var tok token.Token
 
 	s.skipWhitespace()
+	s.pos = token.Pos{Line: s.line, Column: s.column}
 
 	literal := string(s.ch)
 	switch s.ch {
@@ -34,14 +42,41 @@ func (s *Scanner) Next() (token.Token, string /* literal */) {
 	case '*':
 		tok = token.MUL
 	case '/':
+		if s.peekChar() == '/' {
+			literal = s.readComment()
+			tok = token.COMMENT
+			break
+		}
 		tok = token.DIV
 
 	case '=':
+		if s.peekChar() == '>' {
+			s.readChar()
+			literal = "=>"
+			tok = token.ARROW
+			break
+		}
 		tok = token.ASSIGN
+	case ':':
+		if s.peekChar() == '=' {
+			s.readChar()
+			literal = ":="
+			tok = token.DEFINE
+			break
+		}
+		tok = token.COLON
 	case ',':
 		tok = token.COMMA
 	case ';':
 		tok = token.SEMICOLON
+	case '.':
+		tok = token.DOT
+	case '?':
+		tok = token.QUESTION
+
+	case '"':
+		literal = s.readString()
+		tok = token.STRING
 
 	case '(':
 		tok = token.LPAREN
@@ -51,14 +86,17 @@ func (s *Scanner) Next() (token.Token, string /* literal */) {
 		tok = token.LBRACE
 	case '}':
 		tok = token.RBRACE
+	case '[':
+		tok = token.LBRACK
+	case ']':
+		tok = token.RBRACK
 
 	case 0:
 		tok = token.EOF
 		literal = ""
 	default:
 		if isNumber(s.ch) {
-			literal = s.readNumber()
-			tok = token.INT
+			tok, literal = s.readNumber()
 			break
 		} else if isLetter(s.ch) {
 			literal = s.readIdentifier()
@@ -73,6 +111,41 @@ func (s *Scanner) Next() (token.Token, string /* literal */) {
 	return tok, literal
 }
 
+// Pos returns the position of the token last returned by Next.
+func (s *Scanner) Pos() token.Pos {
+	return s.pos
+}
+
+func (s *Scanner) readComment() string {
+	start := s.position
+	for s.peekChar() != '\n' && s.peekChar() != _eof {
+		s.readChar()
+	}
+
+	return strings.TrimRight(s.source[start:s.readPosition], "\r")
+}
+
+// readString reads a double quoted string literal including its quotes. An
+// unterminated literal ends before the end of the line.
+func (s *Scanner) readString() string {
+	start := s.position
+	for {
+		switch s.peekChar() {
+		case '\\':
+			s.readChar()
+			if s.peekChar() == '\n' || s.peekChar() == _eof {
+				return s.source[start:s.readPosition]
+			}
+		case '"':
+			s.readChar()
+			return s.source[start:s.readPosition]
+		case '\n', _eof:
+			return s.source[start:s.readPosition]
+		}
+		s.readChar()
+	}
+}
+
 func (s *Scanner) readIdentifier() string {
 	start := s.position
 	for isLetter(s.peekChar()) {
@@ -82,19 +155,56 @@ func (s *Scanner) readIdentifier() string {
 	return s.source[start:s.readPosition]
 }
 
-func (s *Scanner) readNumber() string {
+// readNumber reads an int or float literal. Ints may use a 0x, 0o or 0b
+// prefix, and any digits may be separated by underscores; the parser
+// validates the digits and separators.
+func (s *Scanner) readNumber() (token.Token, string) {
 	start := s.position
-	for isNumber(s.peekChar()) {
+	if s.ch == '0' && strings.IndexByte("xXoObB", s.peekChar()) >= 0 {
 		s.readChar()
+		for isNumber(s.peekChar()) || isLetter(s.peekChar()) {
+			s.readChar()
+		}
+		return token.INT, s.source[start:s.readPosition]
 	}
 
-	return s.source[start:s.readPosition]
+	tok := token.INT
+	s.readDigits()
+	if s.peekChar() == '.' && isNumber(s.peekCharAt(1)) {
+		s.readChar()
+		s.readDigits()
+		tok = token.FLOAT
+	}
+	if ch := s.peekChar(); ch == 'e' || ch == 'E' {
+		next := s.peekCharAt(1)
+		if isNumber(next) || (next == '+' || next == '-') && isNumber(s.peekCharAt(2)) {
+			s.readChar()
+			if !isNumber(next) {
+				s.readChar()
+			}
+			s.readDigits()
+			tok = token.FLOAT
+		}
+	}
+
+	return tok, s.source[start:s.readPosition]
+}
+
+func (s *Scanner) readDigits() {
+	for isNumber(s.peekChar()) || s.peekChar() == '_' {
+		s.readChar()
+	}
 }
 
 func (s *Scanner) readChar() {
+	if s.ch == '\n' {
+		s.line++
+		s.column = 0
+	}
 	s.ch = s.peekChar()
 	s.position = s.readPosition
 	s.readPosition++
+	s.column++
 }
 
 func (s *Scanner) peekChar() byte {
@@ -104,6 +214,14 @@ func (s *Scanner) peekChar() byte {
 	return s.source[s.readPosition]
 }
 
+// peekCharAt returns the byte n positions after peekChar.
+func (s *Scanner) peekCharAt(n int) byte {
+	if s.readPosition+n >= len(s.source) {
+		return _eof
+	}
+	return s.source[s.readPosition+n]
+}
+
 func (s *Scanner) skipWhitespace() {
 	for s.ch == ' ' || s.ch == '\t' || s.ch == '\n' || s.ch == '\r' {
 		s.readChar()
@@ -49,9 +49,9 @@ func TestScanner__Next(t *testing.T) {
 		},
 		{
 			name:   "scans_brackets",
-			source: "(){}",
+			source: "(){}[]",
 			expected: []tokenLitPair{
-				{token.LPAREN, "("}, {token.RPAREN, ")"}, {token.LBRACE, "{"}, {token.RBRACE, "}"}, {token.EOF, ""},
+				{token.LPAREN, "("}, {token.RPAREN, ")"}, {token.LBRACE, "{"}, {token.RBRACE, "}"}, {token.LBRACK, "["}, {token.RBRACK, "]"}, {token.EOF, ""},
 			},
 		},
 		{
@@ -61,6 +61,34 @@ func TestScanner__Next(t *testing.T) {
 				{token.COMMA, ","}, {token.ASSIGN, "="}, {token.SEMICOLON, ";"}, {token.EOF, ""},
 			},
 		},
+		{
+			name:   "scans_define",
+			source: "x:=1:",
+			expected: []tokenLitPair{
+				{token.IDENT, "x"}, {token.DEFINE, ":="}, {token.INT, "1"}, {token.COLON, ":"}, {token.EOF, ""},
+			},
+		},
+		{
+			name:   "scans_arrow",
+			source: "_ => x=1",
+			expected: []tokenLitPair{
+				{token.IDENT, "_"}, {token.ARROW, "=>"}, {token.IDENT, "x"}, {token.ASSIGN, "="}, {token.INT, "1"}, {token.EOF, ""},
+			},
+		},
+		{
+			name:   "scans_question_mark",
+			source: "f()?",
+			expected: []tokenLitPair{
+				{token.IDENT, "f"}, {token.LPAREN, "("}, {token.RPAREN, ")"}, {token.QUESTION, "?"}, {token.EOF, ""},
+			},
+		},
+		{
+			name:   "scans_qualified_calls",
+			source: "import m.f",
+			expected: []tokenLitPair{
+				{token.IMPORT, "import"}, {token.IDENT, "m"}, {token.DOT, "."}, {token.IDENT, "f"}, {token.EOF, ""},
+			},
+		},
 		{
 			name:   "scans_integers",
 			source: "123",
@@ -68,6 +96,29 @@ func TestScanner__Next(t *testing.T) {
 				{token.INT, "123"}, {token.EOF, ""},
 			},
 		},
+		{
+			name:   "scans_prefixed_integers",
+			source: "0xFF 0o17 0b1010 1_000 0b102",
+			expected: []tokenLitPair{
+				{token.INT, "0xFF"}, {token.INT, "0o17"}, {token.INT, "0b1010"}, {token.INT, "1_000"}, {token.INT, "0b102"}, {token.EOF, ""},
+			},
+		},
+		{
+			name:   "scans_floats",
+			source: "1.5 2e10 3E-2 1_0.2_5 4.x 5e",
+			expected: []tokenLitPair{
+				{token.FLOAT, "1.5"}, {token.FLOAT, "2e10"}, {token.FLOAT, "3E-2"}, {token.FLOAT, "1_0.2_5"},
+				{token.INT, "4"}, {token.DOT, "."}, {token.IDENT, "x"},
+				{token.INT, "5"}, {token.IDENT, "e"}, {token.EOF, ""},
+			},
+		},
+		{
+			name:   "scans_strings",
+			source: `"" "a \"b\" \\" "open` + "\n" + `x`,
+			expected: []tokenLitPair{
+				{token.STRING, `""`}, {token.STRING, `"a \"b\" \\"`}, {token.STRING, `"open`}, {token.IDENT, "x"}, {token.EOF, ""},
+			},
+		},
 		{
 			name:   "scans_identifiers",
 			source: "_abc",
@@ -77,9 +128,17 @@ func TestScanner__Next(t *testing.T) {
 		},
 		{
 			name:   "scans_keywords",
-			source: "fn return",
+			source: "fn return for in map struct enum match if",
+			expected: []tokenLitPair{
+				{token.FN, "fn"}, {token.RETURN, "return"}, {token.FOR, "for"}, {token.IN, "in"}, {token.MAP, "map"}, {token.STRUCT, "struct"},
+				{token.ENUM, "enum"}, {token.MATCH, "match"}, {token.IF, "if"}, {token.EOF, ""},
+			},
+		},
+		{
+			name:   "scans_comments",
+			source: "a // comment\r\n/ b //",
 			expected: []tokenLitPair{
-				{token.FN, "fn"}, {token.RETURN, "return"}, {token.EOF, ""},
+				{token.IDENT, "a"}, {token.COMMENT, "// comment"}, {token.DIV, "/"}, {token.IDENT, "b"}, {token.COMMENT, "//"}, {token.EOF, ""},
 			},
 		},
 		{
@@ -120,3 +179,22 @@ func scanAll(t *testing.T, s *Scanner) (res []tokenLitPair) {
 	}
 	return res
 }
+
+func TestScanner_Pos(t *testing.T) {
+	s := NewScanner("fn main() {\n\treturn 1;\n}")
+	var res []token.Pos
+	for {
+		tok, _ := s.Next()
+		res = append(res, s.Pos())
+		if tok == token.EOF {
+			break
+		}
+	}
+
+	expected := []token.Pos{
+		{Line: 1, Column: 1}, {Line: 1, Column: 4}, {Line: 1, Column: 8}, {Line: 1, Column: 9}, {Line: 1, Column: 11},
+		{Line: 2, Column: 2}, {Line: 2, Column: 9}, {Line: 2, Column: 10},
+		{Line: 3, Column: 1}, {Line: 3, Column: 2},
+	}
+	assert.Equal(t, expected, res)
+}
@@ -0,0 +1,24 @@
+package token
+
+import "fmt"
+
+// Pos is a position in the source. Lines and columns start at 1, the zero
+// value means that the position is unknown.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+func (p Pos) IsValid() bool { return p.Line > 0 }
+
+// Before reports whether p comes strictly before q in the source.
+func (p Pos) Before(q Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
@@ -5,8 +5,11 @@ type Token int
 const (
 	ILLEGAL Token = iota
 	EOF
+	COMMENT
 	IDENT
 	INT
+	FLOAT
+	STRING
 
 	ADD
 	SUB
@@ -15,24 +18,45 @@ const (
 
 	LPAREN
 	LBRACE
+	LBRACK
 
 	RPAREN
 	RBRACE
+	RBRACK
 
 	ASSIGN
+	DEFINE
 	COMMA
 	SEMICOLON
+	COLON
+	DOT
+	ARROW
+	QUESTION
 
 	FN
 	RETURN
+	LET
+	VAR
+	CONST
+	IMPORT
+	FOR
+	IN
+	MAP
+	STRUCT
+	ENUM
+	MATCH
+	IF
 )
 
 var tokens = [...]string{
 	ILLEGAL: "ILLEGAL",
 	EOF:     "EOF",
+	COMMENT: "COMMENT",
 
-	IDENT: "IDENT",
-	INT:   "INT",
+	IDENT:  "IDENT",
+	INT:    "INT",
+	FLOAT:  "FLOAT",
+	STRING: "STRING",
 
 	ADD: "+",
 	SUB: "-",
@@ -41,16 +65,34 @@ var tokens = [...]string{
 
 	LPAREN: "(",
 	LBRACE: "{",
+	LBRACK: "[",
 
 	RPAREN: ")",
 	RBRACE: "}",
+	RBRACK: "]",
 
 	ASSIGN:    "=",
+	DEFINE:    ":=",
 	COMMA:     ",",
 	SEMICOLON: ";",
+	COLON:     ":",
+	DOT:       ".",
+	ARROW:     "=>",
+	QUESTION:  "?",
 
 	FN:     "fn",
 	RETURN: "return",
+	LET:    "let",
+	VAR:    "var",
+	CONST:  "const",
+	IMPORT: "import",
+	FOR:    "for",
+	IN:     "in",
+	MAP:    "map",
+	STRUCT: "struct",
+	ENUM:   "enum",
+	MATCH:  "match",
+	IF:     "if",
 }
 
 func (t Token) String() string {
@@ -60,6 +102,17 @@ func (t Token) String() string {
 var keywords = map[string]Token{
 	"fn":     FN,
 	"return": RETURN,
+	"let":    LET,
+	"var":    VAR,
+	"const":  CONST,
+	"import": IMPORT,
+	"for":    FOR,
+	"in":     IN,
+	"map":    MAP,
+	"struct": STRUCT,
+	"enum":   ENUM,
+	"match":  MATCH,
+	"if":     IF,
 }
 
 func Lookup(ident string) Token {
@@ -68,3 +121,44 @@ func Lookup(ident string) Token {
 	}
 	return IDENT
 }
+
+// FromString returns the token whose String() is s, it is the inverse of
+// Token.String.
+func FromString(s string) (Token, bool) {
+	for tok, str := range tokens {
+		if str == s {
+			return Token(tok), true
+		}
+	}
+	return ILLEGAL, false
+}
+
+// IsKeyword reports whether t is a keyword like fn or return.
+func (t Token) IsKeyword() bool {
+	_, ok := keywords[t.String()]
+	return ok
+}
+
+// IsOperator reports whether t is an arithmetic or assignment operator.
+func (t Token) IsOperator() bool {
+	switch t {
+	case ADD, SUB, MUL, DIV, ASSIGN, DEFINE:
+		return true
+	}
+	return false
+}
+
+// LowestPrec is the precedence below that of every binary operator.
+const LowestPrec = 0
+
+// Precedence returns the precedence of the binary operator t, or
+// LowestPrec if t is not a binary operator.
+func (t Token) Precedence() int {
+	switch t {
+	case ADD, SUB:
+		return 1
+	case MUL, DIV:
+		return 2
+	}
+	return LowestPrec
+}
@@ -0,0 +1,165 @@
+package types
+
+import "github.com/muggel/emlang/ast"
+
+// anyType is the parameter type of builtins that accept values of every type.
+const anyType Type = "any"
+
+// builtin describes a predeclared function.
+type builtin struct {
+	params   []Type
+	required int  // number of parameters that have to be passed
+	variadic bool // the last parameter may be repeated
+	result   Type
+}
+
+// builtins are the functions predeclared in every program. They are
+// resolved before the functions of the program and of the host, which must
+// not redeclare them.
+var builtins = map[string]*builtin{
+	"print":   {params: []Type{anyType}, variadic: true, result: Void},
+	"println": {params: []Type{anyType}, variadic: true, result: Void},
+	"len":     {params: []Type{anyType}, required: 1, result: Int},
+	"append":  {params: []Type{anyType}, required: 1, variadic: true, result: anyType},
+	"delete":  {params: []Type{anyType, anyType}, required: 2, result: Void},
+	"abs":     {params: []Type{Int}, required: 1, result: Int},
+	"min":     {params: []Type{Int}, required: 1, variadic: true, result: Int},
+	"max":     {params: []Type{Int}, required: 1, variadic: true, result: Int},
+	"assert":  {params: []Type{Int, String}, required: 1, result: Void},
+	"panic":   {params: []Type{anyType}, required: 1, result: Void},
+	"error":   {params: []Type{String}, required: 1, result: ErrorType},
+	"int":     {params: []Type{anyType}, required: 1, result: Int},
+	"float":   {params: []Type{anyType}, required: 1, result: Float},
+}
+
+// IsBuiltin reports whether name is a predeclared function.
+func IsBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
+}
+
+// builtinCall checks a call of the builtin b and returns its result type.
+func (c *checker) builtinCall(call *ast.CallExpression, b *builtin) Type {
+	name := call.Function.Value
+	switch name {
+	case "append":
+		return c.appendCall(call)
+	case "delete":
+		return c.deleteCall(call, b)
+	case "int", "float":
+		return c.conversion(call, b)
+	}
+	for i, arg := range call.Arguments {
+		t := c.value(arg)
+		if name == "len" && t != "" && t != String && t.Elem() == "" {
+			c.errorf(ast.Start(arg), "invalid argument %s of type %s for len", arg, t)
+		}
+		param := anyType
+		switch {
+		case i < len(b.params):
+			param = b.params[i]
+		case b.variadic:
+			param = b.params[len(b.params)-1]
+		}
+		if t != "" && param != anyType && t != param {
+			c.errorf(ast.Start(arg), "cannot use %s value as %s in argument to %s", t, param, name)
+		}
+	}
+	switch {
+	case len(call.Arguments) < b.required:
+		c.errorf(call.Pos, "not enough arguments in call to %s", name)
+	case len(call.Arguments) > len(b.params) && !b.variadic:
+		c.errorf(call.Pos, "too many arguments in call to %s", name)
+	}
+	return b.result
+}
+
+// conversion checks a call of int or float, which convert between the
+// numeric types. float to int conversions truncate towards zero.
+func (c *checker) conversion(call *ast.CallExpression, b *builtin) Type {
+	name := call.Function.Value
+	if len(call.Arguments) != 1 {
+		if len(call.Arguments) == 0 {
+			c.errorf(call.Pos, "not enough arguments in call to %s", name)
+		} else {
+			c.errorf(call.Pos, "too many arguments in call to %s", name)
+		}
+		for _, arg := range call.Arguments {
+			c.value(arg)
+		}
+		return b.result
+	}
+	arg := call.Arguments[0]
+	t := c.value(arg)
+	if t != "" && t != Int && t != Float {
+		c.errorf(ast.Start(arg), "cannot convert %s (value of type %s) to type %s", arg, t, b.result)
+		return b.result
+	}
+	x, ok := toFloat(c.consts[arg])
+	switch {
+	case !ok:
+	case b.result == Float:
+		c.consts[call] = x
+	case x < -(1<<63) || x >= 1<<63:
+		c.errorf(ast.Start(arg), "cannot convert %s (constant of type %s) to type int: overflows int", arg, t)
+	default:
+		if t == Int {
+			c.consts[call] = c.consts[arg]
+		} else {
+			c.consts[call] = int64(x)
+		}
+	}
+	return b.result
+}
+
+// appendCall checks a call of append, which appends its other arguments to
+// the slice passed first and returns the result.
+func (c *checker) appendCall(call *ast.CallExpression) Type {
+	if len(call.Arguments) == 0 {
+		c.errorf(call.Pos, "not enough arguments in call to append")
+		return ""
+	}
+	t := c.value(call.Arguments[0])
+	elem := t.Elem()
+	if !t.isSlice() {
+		elem = ""
+	}
+	if t != "" && elem == "" {
+		c.errorf(ast.Start(call.Arguments[0]), "invalid argument %s of type %s for append", call.Arguments[0], t)
+	}
+	for _, arg := range call.Arguments[1:] {
+		if at := c.valueAs(arg, elem); elem != "" && at != "" && at != elem {
+			c.errorf(ast.Start(arg), "cannot use %s value as %s in argument to append", at, elem)
+		}
+	}
+	if elem == "" {
+		return ""
+	}
+	return t
+}
+
+// deleteCall checks a call of delete, which removes the element with the
+// key passed second from the map passed first.
+func (c *checker) deleteCall(call *ast.CallExpression, b *builtin) Type {
+	if len(call.Arguments) != len(b.params) {
+		if len(call.Arguments) < len(b.params) {
+			c.errorf(call.Pos, "not enough arguments in call to delete")
+		} else {
+			c.errorf(call.Pos, "too many arguments in call to delete")
+		}
+		for _, arg := range call.Arguments {
+			c.value(arg)
+		}
+		return Void
+	}
+	m, k := call.Arguments[0], call.Arguments[1]
+	t := c.value(m)
+	key := t.Key()
+	if t != "" && key == "" {
+		c.errorf(ast.Start(m), "invalid argument %s of type %s for delete", m, t)
+	}
+	if kt := c.value(k); key != "" && kt != "" && kt != key {
+		c.errorf(ast.Start(k), "cannot use %s value as %s in argument to delete", kt, key)
+	}
+	return Void
+}
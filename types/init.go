@@ -0,0 +1,161 @@
+package types
+
+import (
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+)
+
+// InitOrder returns the global declarations of program in the order they
+// are initialized: in declaration order, except that a global is
+// initialized before the first global whose value refers to it, directly or
+// through the functions it calls. A global that refers to itself is
+// reported as an *Error.
+func InitOrder(program *ast.Program) ([]*ast.ValueDeclaration, error) {
+	o := &initOrder{
+		globals:   map[string]*ast.ValueDeclaration{},
+		functions: map[string]*ast.FunctionDeclaration{},
+		methods:   map[string][]*ast.FunctionDeclaration{},
+		state:     map[*ast.ValueDeclaration]int{},
+	}
+	var decls []*ast.ValueDeclaration
+	for _, decl := range program.TopLevelDeclarations {
+		switch d := decl.(type) {
+		case *ast.ValueDeclaration:
+			if _, ok := o.globals[d.Identifier.Value]; !ok {
+				o.globals[d.Identifier.Value] = d
+				decls = append(decls, d)
+			}
+		case *ast.FunctionDeclaration:
+			if d.Receiver != nil {
+				o.methods[d.Identifier.Value] = append(o.methods[d.Identifier.Value], d)
+			} else if _, ok := o.functions[d.Identifier.Value]; !ok {
+				o.functions[d.Identifier.Value] = d
+			}
+		}
+	}
+
+	for _, decl := range decls {
+		if err := o.visit(decl); err != nil {
+			return nil, err
+		}
+	}
+	return o.order, nil
+}
+
+// states of a declaration during the depth first search
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+type initOrder struct {
+	globals   map[string]*ast.ValueDeclaration
+	functions map[string]*ast.FunctionDeclaration
+	methods   map[string][]*ast.FunctionDeclaration // by method name
+	state     map[*ast.ValueDeclaration]int
+	path      []*ast.ValueDeclaration
+	order     []*ast.ValueDeclaration
+}
+
+func (o *initOrder) visit(decl *ast.ValueDeclaration) error {
+	switch o.state[decl] {
+	case visited:
+		return nil
+	case visiting:
+		return o.cycle(decl)
+	}
+
+	o.state[decl] = visiting
+	o.path = append(o.path, decl)
+	for _, dep := range o.dependencies(decl) {
+		if err := o.visit(dep); err != nil {
+			return err
+		}
+	}
+	o.path = o.path[:len(o.path)-1]
+	o.state[decl] = visited
+	o.order = append(o.order, decl)
+	return nil
+}
+
+func (o *initOrder) cycle(decl *ast.ValueDeclaration) error {
+	var names []string
+	for i := len(o.path) - 1; i >= 0; i-- {
+		names = append([]string{o.path[i].Identifier.Value}, names...)
+		if o.path[i] == decl {
+			break
+		}
+	}
+	if len(names) == 1 {
+		return &Error{Pos: decl.Identifier.Pos, Msg: "initialization cycle: " + names[0] + " refers to itself"}
+	}
+	names = append(names, decl.Identifier.Value)
+	return &Error{Pos: decl.Identifier.Pos, Msg: "initialization cycle: " + strings.Join(names, " refers to ")}
+}
+
+// dependencies returns the globals the value of decl refers to in order of
+// appearance. Identifiers in the bodies of called functions count unless they
+// name a parameter. The type of a receiver is not known here, so a method
+// call counts as a call of every method with its name. Functions used as
+// values count as called, since they may be called at any time.
+func (o *initOrder) dependencies(decl *ast.ValueDeclaration) []*ast.ValueDeclaration {
+	var (
+		deps   []*ast.ValueDeclaration
+		seen   = map[*ast.ValueDeclaration]bool{}
+		called = map[*ast.FunctionDeclaration]bool{}
+		visit  func(node ast.Node, params map[string]bool)
+	)
+	call := func(fd *ast.FunctionDeclaration) {
+		if called[fd] {
+			return
+		}
+		called[fd] = true
+		fnParams := map[string]bool{}
+		if fd.Receiver != nil {
+			fnParams[fd.Receiver.Identifier.Value] = true
+		}
+		for _, param := range fd.Parameters {
+			fnParams[param.Identifier.Value] = true
+		}
+		visit(fd.Body, fnParams)
+	}
+	visit = func(node ast.Node, params map[string]bool) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Identifier:
+				if dep, ok := o.globals[n.Value]; ok && !params[n.Value] && !seen[dep] {
+					seen[dep] = true
+					deps = append(deps, dep)
+				}
+				if fd, ok := o.functions[n.Value]; ok && !params[n.Value] {
+					call(fd)
+				}
+			case *ast.CallExpression:
+				switch {
+				case n.Receiver != nil:
+					visit(n.Receiver, params)
+					for _, fd := range o.methods[n.Function.Value] {
+						call(fd)
+					}
+				case n.Callee != nil:
+					visit(n.Callee, params)
+				case !params[n.Function.Value]:
+					if fd, ok := o.functions[n.Function.Value]; ok {
+						call(fd)
+					}
+				}
+				for _, arg := range n.Arguments {
+					visit(arg, params)
+				}
+				return false
+			}
+			return true
+		})
+	}
+	if decl.Value != nil {
+		visit(decl.Value, nil)
+	}
+	return deps
+}
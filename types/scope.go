@@ -0,0 +1,30 @@
+package types
+
+// variable is a declared variable or constant.
+type variable struct {
+	typ      Type // empty if the declaration is invalid
+	constant bool
+	value    interface{} // value of a constant, an int64, a float64 or a string
+}
+
+// scope holds the variables declared in a block, the outermost scope holds
+// the globals.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, vars: map[string]*variable{}}
+}
+
+// lookup returns the variable called name in s or the scopes enclosing it,
+// nil if there is none.
+func (s *scope) lookup(name string) *variable {
+	for ; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
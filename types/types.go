@@ -0,0 +1,1690 @@
+// Package types checks that programs are well typed: every identifier and
+// function is defined, calls pass the right number and types of arguments
+// and functions return values of their result type.
+package types
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/internal/arith"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/token"
+)
+
+// Type is the name of a type as written in source.
+type Type string
+
+const (
+	Int       Type = "int"
+	Float     Type = "float"
+	String    Type = "string"
+	ErrorType Type = "error"
+	Void      Type = "void"
+)
+
+// SliceOf returns the type of slices with elements of type elem.
+func SliceOf(elem Type) Type {
+	return "[]" + elem
+}
+
+// MapOf returns the type of maps from keys of type key to values of type
+// value.
+func MapOf(key, value Type) Type {
+	return "map[" + key + "]" + value
+}
+
+// TupleOf returns the type of the results of a function with several
+// results.
+func TupleOf(elems ...Type) Type {
+	res := make([]string, len(elems))
+	for i, elem := range elems {
+		res[i] = string(elem)
+	}
+	return Type("(" + strings.Join(res, ", ") + ")")
+}
+
+// Tuple returns the types of the results if t is the type of several
+// results, or nil otherwise.
+func (t Type) Tuple() []Type {
+	s := string(t)
+	if !strings.HasPrefix(s, "(") {
+		return nil
+	}
+	var elems []Type
+	depth, start := 0, len("(")
+	for i := start; i < len(s)-1; i++ {
+		switch s[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				elems = append(elems, Type(s[start:i]))
+				start = i + len(", ")
+			}
+		}
+	}
+	return append(elems, Type(s[start:len(s)-1]))
+}
+
+// Elem returns the element type of the slice type t or the value type of the
+// map type t, or the empty type if t is neither.
+func (t Type) Elem() Type {
+	if strings.HasPrefix(string(t), "[]") {
+		return t[2:]
+	}
+	if key := t.Key(); key != "" {
+		return t[len("map[")+len(key)+1:]
+	}
+	return ""
+}
+
+// Key returns the key type of the map type t, or the empty type if t is not
+// a map type. Keys are ints or strings, so the key type ends at the first
+// closing bracket.
+func (t Type) Key() Type {
+	if !strings.HasPrefix(string(t), "map[") {
+		return ""
+	}
+	return t[len("map["):strings.IndexByte(string(t), ']')]
+}
+
+// Signature returns the signature of the function type t, or nil if t is not
+// a function type.
+func (t Type) Signature() *Signature {
+	s := string(t)
+	if !strings.HasPrefix(s, "fn(") {
+		return nil
+	}
+	sig := &Signature{Result: Void}
+	depth, start := 0, len("fn(")
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				sig.Params = append(sig.Params, Type(s[start:i]))
+				start = i + len(", ")
+			}
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if i > start {
+				sig.Params = append(sig.Params, Type(s[start:i]))
+			}
+			if i+1 < len(s) {
+				sig.Result = Type(s[i+len(") "):])
+			}
+			return sig
+		}
+	}
+	return nil
+}
+
+// isSlice reports whether t is a slice type.
+func (t Type) isSlice() bool {
+	return strings.HasPrefix(string(t), "[]")
+}
+
+// Signature is the type of a function.
+type Signature struct {
+	Params []Type
+	Result Type
+}
+
+func (s *Signature) String() string {
+	params := make([]string, len(s.Params))
+	for i, param := range s.Params {
+		params[i] = string(param)
+	}
+	sig := "fn(" + strings.Join(params, ", ") + ")"
+	if s.Result != Void {
+		sig += " " + string(s.Result)
+	}
+	return sig
+}
+
+// Struct is a struct type declared by a program.
+type Struct struct {
+	Fields  []*Field
+	Methods map[string]*Signature
+}
+
+// Field is a field of a struct type.
+type Field struct {
+	Name string
+	Type Type
+}
+
+// Field returns the field called name, nil if there is none.
+func (s *Struct) Field(name string) *Field {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Enum is an enum type declared by a program.
+type Enum struct {
+	Variants []*Variant
+}
+
+// Variant is a variant of an enum type, Payload holds the types of the
+// values it carries.
+type Variant struct {
+	Name    string
+	Payload []Type
+}
+
+// Variant returns the variant called name, nil if there is none.
+func (e *Enum) Variant(name string) *Variant {
+	for _, v := range e.Variants {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Error is a type error.
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Config configures the checker.
+type Config struct {
+	// Funcs are functions declared outside of the program, like functions
+	// provided by the host. Programs must not redeclare them, builtins
+	// take precedence over them.
+	Funcs map[string]*Signature
+	// Imports maps the path of every module the program may import to the
+	// result of checking it.
+	Imports map[string]*Info
+}
+
+// Info holds the results of a successful check.
+type Info struct {
+	// Types maps every expression to its type.
+	Types map[ast.Expression]Type
+	// Funcs maps every function that can be called, except for the
+	// builtins, to its signature.
+	Funcs map[string]*Signature
+	// Exports maps the exported functions declared by the program to their
+	// signatures.
+	Exports map[string]*Signature
+	// Globals maps every global variable and constant to its type.
+	Globals map[string]Type
+	// Consts maps every global constant to its value, an int64, a float64 or
+	// a string.
+	Consts map[string]interface{}
+	// Structs maps every struct type declared by the program to its fields
+	// and methods. Struct types are local to the program.
+	Structs map[string]*Struct
+	// Enums maps every enum type declared by the program to its variants.
+	// Enum types are local to the program.
+	Enums map[string]*Enum
+	// InitOrder lists the global declarations in the order they are
+	// initialized.
+	InitOrder []*ast.ValueDeclaration
+}
+
+// Check checks program. All errors are returned as a parser.ErrorList of
+// *Error, sorted by position.
+func (c *Config) Check(program *ast.Program) (*Info, error) {
+	ch := &checker{
+		info: &Info{
+			Types:   map[ast.Expression]Type{},
+			Funcs:   map[string]*Signature{},
+			Exports: map[string]*Signature{},
+			Globals: map[string]Type{},
+			Consts:  map[string]interface{}{},
+			Structs: map[string]*Struct{},
+			Enums:   map[string]*Enum{},
+		},
+		imports: map[string]*Info{},
+		globals: map[string]*ast.ValueDeclaration{},
+		pkg:     newScope(nil),
+		consts:  map[ast.Expression]interface{}{},
+	}
+	for name, sig := range c.Funcs {
+		ch.info.Funcs[name] = sig
+	}
+
+	// struct and enum types are declared first, so that they can be used
+	// in any signature and in each other
+	var (
+		structs []*ast.StructDeclaration
+		enums   []*ast.EnumDeclaration
+	)
+	for _, decl := range program.TopLevelDeclarations {
+		switch d := decl.(type) {
+		case *ast.StructDeclaration:
+			if ch.declareType(d.Identifier) {
+				ch.info.Structs[d.Identifier.Value] = &Struct{Methods: map[string]*Signature{}}
+				structs = append(structs, d)
+			}
+		case *ast.EnumDeclaration:
+			if ch.declareType(d.Identifier) {
+				ch.info.Enums[d.Identifier.Value] = &Enum{}
+				enums = append(enums, d)
+			}
+		}
+	}
+	for _, d := range structs {
+		ch.fields(d)
+	}
+	for _, d := range enums {
+		ch.variants(d)
+	}
+	for _, d := range structs {
+		ch.recursive(d.Identifier)
+	}
+	for _, d := range enums {
+		ch.recursive(d.Identifier)
+	}
+
+	var (
+		funcs   []*ast.FunctionDeclaration
+		methods []*ast.FunctionDeclaration
+		globals []*ast.ValueDeclaration
+	)
+	for _, decl := range program.TopLevelDeclarations {
+		switch d := decl.(type) {
+		case *ast.ImportDeclaration:
+			ch.importModule(d, c.Imports)
+		case *ast.FunctionDeclaration:
+			if d.Receiver != nil {
+				methods = append(methods, d)
+			} else if ch.declare(d) {
+				funcs = append(funcs, d)
+			}
+		case *ast.ValueDeclaration:
+			if ch.declareGlobal(d) {
+				globals = append(globals, d)
+			}
+		}
+	}
+
+	// globals are checked in initialization order, so the type of every
+	// global is known before it is used
+	order, err := InitOrder(program)
+	if err != nil {
+		ch.errors = append(ch.errors, err)
+		order = globals
+	}
+	for _, vd := range order {
+		if ch.globals[vd.Identifier.Value] == vd {
+			ch.global(vd)
+		}
+	}
+	ch.info.InitOrder = order
+	for i := 0; i < len(methods); i++ {
+		if !ch.declareMethod(methods[i]) {
+			methods = append(methods[:i], methods[i+1:]...)
+			i--
+		}
+	}
+	for _, fd := range funcs {
+		ch.function(fd)
+	}
+	for _, fd := range methods {
+		ch.function(fd)
+	}
+
+	if len(ch.errors) > 0 {
+		sort.SliceStable(ch.errors, func(i, j int) bool {
+			return ch.errors[i].(*Error).Pos.Before(ch.errors[j].(*Error).Pos)
+		})
+		return nil, ch.errors
+	}
+	return ch.info, nil
+}
+
+type checker struct {
+	info    *Info
+	errors  parser.ErrorList
+	imports map[string]*Info // imported modules by name
+	globals map[string]*ast.ValueDeclaration
+	pkg     *scope                         // globals that have been checked
+	consts  map[ast.Expression]interface{} // values of constant expressions
+
+	// state of the function being checked
+	result Type
+	scope  *scope
+}
+
+func (c *checker) errorf(pos token.Pos, format string, args ...interface{}) {
+	c.errors = append(c.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// declare adds the signature of fd and reports whether it is valid.
+func (c *checker) declare(fd *ast.FunctionDeclaration) bool {
+	name := fd.Identifier.Value
+	if IsBuiltin(name) {
+		c.errorf(fd.Identifier.Pos, "cannot redeclare builtin %s", name)
+		return false
+	}
+	if _, ok := c.info.Funcs[name]; ok {
+		c.errorf(fd.Identifier.Pos, "function %s redeclared", name)
+		return false
+	}
+	if _, ok := c.globals[name]; ok || c.isType(name) {
+		c.errorf(fd.Identifier.Pos, "%s redeclared", name)
+		return false
+	}
+
+	sig := c.signature(fd)
+	c.info.Funcs[name] = sig
+	if ast.IsExported(name) {
+		c.info.Exports[name] = sig
+		for i, t := range append(sig.Params, sig.Result) {
+			local := c.localType(t)
+			if local == "" {
+				continue
+			}
+			pos := ast.Start(fd.ReturnType)
+			if i < len(fd.Parameters) {
+				pos = ast.Start(fd.Parameters[i].Type)
+			}
+			kind := "struct"
+			if _, ok := c.info.Enums[string(local)]; ok {
+				kind = "enum"
+			}
+			c.errorf(pos, "exported function %s cannot use %s type %s", name, kind, local)
+		}
+	}
+	return true
+}
+
+// signature resolves the parameter and result types of fd.
+func (c *checker) signature(fd *ast.FunctionDeclaration) *Signature {
+	sig := &Signature{Result: c.typ(fd.ReturnType, true)}
+	for _, param := range fd.Parameters {
+		sig.Params = append(sig.Params, c.typ(param.Type, false))
+	}
+	return sig
+}
+
+// localType returns the struct or enum type t is made of, looking into the
+// elements of slices and maps and the parameters and results of functions,
+// or the empty type if there is none.
+func (c *checker) localType(t Type) Type {
+	for t.Elem() != "" {
+		t = t.Elem()
+	}
+	inner := t.Tuple()
+	if sig := t.Signature(); sig != nil {
+		inner = append(sig.Params, sig.Result)
+	}
+	for _, inner := range inner {
+		if local := c.localType(inner); local != "" {
+			return local
+		}
+	}
+	if c.isType(string(t)) {
+		return t
+	}
+	return ""
+}
+
+// declareType reports whether a struct or enum type called ident may be
+// declared.
+func (c *checker) declareType(ident *ast.Identifier) bool {
+	name := ident.Value
+	if c.isType(name) || name == string(Int) || name == string(Float) || name == string(String) || name == string(ErrorType) || name == string(Void) {
+		c.errorf(ident.Pos, "%s redeclared", name)
+		return false
+	}
+	return true
+}
+
+// isType reports whether name is a struct or enum type of the program.
+func (c *checker) isType(name string) bool {
+	_, isStruct := c.info.Structs[name]
+	_, isEnum := c.info.Enums[name]
+	return isStruct || isEnum
+}
+
+// fields resolves the fields of the struct type sd.
+func (c *checker) fields(sd *ast.StructDeclaration) {
+	s := c.info.Structs[sd.Identifier.Value]
+	for _, f := range sd.Fields {
+		t := c.typ(f.Type, false)
+		if s.Field(f.Identifier.Value) != nil {
+			c.errorf(f.Pos, "duplicate field %s", f.Identifier.Value)
+			continue
+		}
+		s.Fields = append(s.Fields, &Field{Name: f.Identifier.Value, Type: t})
+	}
+}
+
+// variants resolves the variants of the enum type ed.
+func (c *checker) variants(ed *ast.EnumDeclaration) {
+	e := c.info.Enums[ed.Identifier.Value]
+	if len(ed.Variants) == 0 {
+		c.errorf(ed.Identifier.Pos, "enum %s has no variants", ed.Identifier.Value)
+	}
+	for _, v := range ed.Variants {
+		variant := &Variant{Name: v.Identifier.Value}
+		for _, typ := range v.Payload {
+			variant.Payload = append(variant.Payload, c.typ(typ, false))
+		}
+		if e.Variant(variant.Name) != nil {
+			c.errorf(v.Pos, "duplicate variant %s", variant.Name)
+			continue
+		}
+		e.Variants = append(e.Variants, variant)
+	}
+}
+
+// recursive reports the struct or enum type called ident if it contains
+// itself, since its values would be infinitely large. Slices and maps of it
+// are fine.
+func (c *checker) recursive(ident *ast.Identifier) {
+	name := Type(ident.Value)
+	seen := map[Type]bool{}
+	var contains func(t Type) bool
+	contains = func(t Type) bool {
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		for _, inner := range c.components(t) {
+			if inner == name || contains(inner) {
+				return true
+			}
+		}
+		return false
+	}
+	if contains(name) {
+		c.errorf(ident.Pos, "invalid recursive type %s", name)
+	}
+}
+
+// components returns the types of the fields of the struct type t or of the
+// payloads of the enum type t.
+func (c *checker) components(t Type) []Type {
+	var res []Type
+	if s, ok := c.info.Structs[string(t)]; ok {
+		for _, f := range s.Fields {
+			res = append(res, f.Type)
+		}
+	}
+	if e, ok := c.info.Enums[string(t)]; ok {
+		for _, v := range e.Variants {
+			res = append(res, v.Payload...)
+		}
+	}
+	return res
+}
+
+// declareMethod adds the signature of the method fd to the struct type of
+// its receiver and reports whether it is valid.
+func (c *checker) declareMethod(fd *ast.FunctionDeclaration) bool {
+	recv, ok := fd.Receiver.Type.(*ast.Identifier)
+	var s *Struct
+	if ok {
+		s, ok = c.info.Structs[recv.Value]
+	}
+	if !ok {
+		c.errorf(ast.Start(fd.Receiver.Type), "invalid receiver type %s", fd.Receiver.Type)
+		return false
+	}
+	name := fd.Identifier.Value
+	if _, ok := s.Methods[name]; ok {
+		c.errorf(fd.Identifier.Pos, "method %s redeclared", fd.Name())
+		return false
+	}
+	if s.Field(name) != nil {
+		c.errorf(fd.Identifier.Pos, "field and method with the same name %s", name)
+		return false
+	}
+	s.Methods[name] = c.signature(fd)
+	return true
+}
+
+// importModule makes the functions of the module imported by id callable
+// with its name.
+func (c *checker) importModule(id *ast.ImportDeclaration, imports map[string]*Info) {
+	info, ok := imports[id.Path.Value]
+	if !ok {
+		c.errorf(id.Path.Pos, "could not import %s", id.Path.Value)
+		return
+	}
+	name := id.Name()
+	if _, ok := c.imports[name]; ok {
+		c.errorf(id.Path.Pos, "%s redeclared in this block", name)
+		return
+	}
+	c.imports[name] = info
+}
+
+// declareGlobal adds the global vd and reports whether it is valid. Its
+// type is only known once its value is checked.
+func (c *checker) declareGlobal(vd *ast.ValueDeclaration) bool {
+	name := vd.Identifier.Value
+	if IsBuiltin(name) {
+		c.errorf(vd.Identifier.Pos, "cannot redeclare builtin %s", name)
+		return false
+	}
+	_, isFunc := c.info.Funcs[name]
+	if _, ok := c.globals[name]; ok || isFunc || c.isType(name) {
+		c.errorf(vd.Identifier.Pos, "%s redeclared", name)
+		return false
+	}
+	c.globals[name] = vd
+	return true
+}
+
+// global checks the global vd and adds it to the package scope.
+func (c *checker) global(vd *ast.ValueDeclaration) {
+	c.scope = c.pkg
+	v := c.declaration(vd)
+	c.pkg.vars[vd.Identifier.Value] = v
+	if v.typ != "" {
+		c.info.Globals[vd.Identifier.Value] = v.typ
+	}
+	if v.value != nil {
+		c.info.Consts[vd.Identifier.Value] = v.value
+	}
+}
+
+// declaration checks the value of vd against its type and returns the
+// declared variable. Constants must have a value that is known at compile
+// time.
+func (c *checker) declaration(vd *ast.ValueDeclaration) *variable {
+	name := vd.Identifier.Value
+	v := &variable{constant: vd.IsConst()}
+	if vd.Type != nil {
+		v.typ = c.typ(vd.Type, false)
+	}
+	if vd.Value == nil {
+		return v
+	}
+
+	errs := len(c.errors)
+	switch t := c.valueAs(vd.Value, v.typ); {
+	case v.typ != "" && t != "" && t != v.typ:
+		c.errorf(ast.Start(vd.Value), "cannot use %s value as %s in declaration of %s", t, v.typ, name)
+	case v.typ == "":
+		v.typ = t
+	}
+	if v.constant && len(c.errors) == errs {
+		if val, ok := c.consts[vd.Value]; ok {
+			v.value = val
+		} else {
+			c.errorf(ast.Start(vd.Value), "%s is not constant", vd.Value)
+		}
+	}
+	return v
+}
+
+// declareLocal adds v to the current scope.
+func (c *checker) declareLocal(ident *ast.Identifier, v *variable) {
+	if _, ok := c.scope.vars[ident.Value]; ok {
+		c.errorf(ident.Pos, "%s redeclared in this block", ident.Value)
+		return
+	}
+	c.scope.vars[ident.Value] = v
+}
+
+// typ resolves a type, void is only allowed if allowVoid is set.
+func (c *checker) typ(expr ast.Expression, allowVoid bool) Type {
+	switch e := expr.(type) {
+	case *ast.SliceType:
+		return SliceOf(c.typ(e.Elem, false))
+	case *ast.MapType:
+		key := c.typ(e.Key, false)
+		if key != Int && key != String {
+			c.errorf(ast.Start(e.Key), "invalid map key type %s", key)
+			key = Int
+		}
+		return MapOf(key, c.typ(e.Value, false))
+	case *ast.FuncType:
+		sig := &Signature{Result: Void}
+		for _, param := range e.Params {
+			sig.Params = append(sig.Params, c.typ(param, false))
+		}
+		if e.Result != nil {
+			sig.Result = c.typ(e.Result, false)
+		}
+		return Type(sig.String())
+	case *ast.ResultList:
+		elems := make([]Type, len(e.Types))
+		for i, typ := range e.Types {
+			elems[i] = c.typ(typ, false)
+		}
+		if len(elems) == 1 {
+			return elems[0]
+		}
+		return TupleOf(elems...)
+	case *ast.Identifier:
+		switch t := Type(e.Value); t {
+		case Int, Float, String, ErrorType:
+			return t
+		case Void:
+			if allowVoid {
+				return t
+			}
+		}
+		if c.isType(e.Value) {
+			return Type(e.Value)
+		}
+		c.errorf(e.Pos, "unknown type %s", e.Value)
+		return Int
+	}
+	c.errorf(ast.Start(expr), "%s is not a type", expr)
+	return Int
+}
+
+func (c *checker) function(fd *ast.FunctionDeclaration) {
+	sig := c.info.Funcs[fd.Identifier.Value]
+	c.scope = newScope(c.pkg)
+	if fd.Receiver != nil {
+		recv := fd.Receiver.Type.(*ast.Identifier).Value
+		sig = c.info.Structs[recv].Methods[fd.Identifier.Value]
+		c.scope.vars[fd.Receiver.Identifier.Value] = &variable{typ: Type(recv)}
+	}
+	c.body(fd.Parameters, fd.Body, sig)
+}
+
+// functionLiteral checks the function literal lit, whose body can use the
+// variables of the enclosing scopes.
+func (c *checker) functionLiteral(lit *ast.FunctionLiteral) Type {
+	sig := &Signature{Result: c.typ(lit.ReturnType, true)}
+	for _, param := range lit.Parameters {
+		sig.Params = append(sig.Params, c.typ(param.Type, false))
+	}
+
+	outer, result := c.scope, c.result
+	c.scope = newScope(outer)
+	c.body(lit.Parameters, lit.Body, sig)
+	c.scope, c.result = outer, result
+	return Type(sig.String())
+}
+
+// body declares the parameters of a function with the signature sig in the
+// current scope and checks its body.
+func (c *checker) body(params []*ast.Parameter, body *ast.BlockStatement, sig *Signature) {
+	c.result = sig.Result
+	for i, param := range params {
+		if _, ok := c.scope.vars[param.Identifier.Value]; ok {
+			c.errorf(param.Pos, "duplicate parameter %s", param.Identifier.Value)
+		}
+		c.scope.vars[param.Identifier.Value] = &variable{typ: sig.Params[i]}
+	}
+
+	returns := false
+	for _, stmt := range body.Statements {
+		c.statement(stmt)
+		_, isReturn := stmt.(*ast.ReturnStatement)
+		returns = returns || isReturn
+	}
+	if sig.Result != Void && !returns {
+		c.errorf(body.Rbrace, "missing return")
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.AssignmentStatement:
+		c.assignment(s)
+
+	case *ast.ValueDeclaration:
+		c.declareLocal(s.Identifier, c.declaration(s))
+
+	case *ast.ExpressionStatement:
+		switch s.Expression.(type) {
+		case *ast.CallExpression, *ast.MatchExpression, *ast.TryExpression:
+		default:
+			c.errorf(s.Pos, "%s is not used", s.Expression)
+		}
+		c.expression(s.Expression)
+
+	case *ast.ReturnStatement:
+		c.returnStatement(s)
+
+	case *ast.ForStatement:
+		c.forStatement(s)
+
+	case *ast.BlockStatement:
+		outer := c.scope
+		c.scope = newScope(outer)
+		for _, stmt := range s.Statements {
+			c.statement(stmt)
+		}
+		c.scope = outer
+	}
+}
+
+// returnStatement checks that the values of s match the results of the
+// function.
+func (c *checker) returnStatement(s *ast.ReturnStatement) {
+	want := c.result.Tuple()
+	switch {
+	case c.result == Void:
+		want = nil
+	case want == nil:
+		want = []Type{c.result}
+	}
+	got := c.results(s.ReturnValues, want)
+	switch {
+	case len(got) < len(want):
+		c.errorf(s.Pos, "not enough return values")
+		return
+	case len(got) > len(want):
+		c.errorf(s.Pos, "too many return values")
+		return
+	}
+	for i, t := range got {
+		pos := ast.Start(s.ReturnValues[0])
+		if len(s.ReturnValues) == len(got) {
+			pos = ast.Start(s.ReturnValues[i])
+		}
+		if t != "" && t != want[i] {
+			c.errorf(pos, "cannot use %s value as %s in return statement", t, want[i])
+		}
+	}
+}
+
+// forStatement checks a loop over a slice or map. The index or key and the
+// value are declared in a scope of their own that encloses the body.
+func (c *checker) forStatement(s *ast.ForStatement) {
+	t := c.value(s.Iterable)
+	elem := t.Elem()
+	if t != "" && elem == "" {
+		c.errorf(ast.Start(s.Iterable), "cannot iterate over %s of type %s", s.Iterable, t)
+	}
+
+	outer := c.scope
+	c.scope = newScope(outer)
+	if s.Index != nil {
+		index := Int
+		if key := t.Key(); key != "" {
+			index = key
+		}
+		c.declareLocal(s.Index, &variable{typ: index})
+	}
+	c.declareLocal(s.Value, &variable{typ: elem})
+	c.statement(s.Body)
+	c.scope = outer
+}
+
+// assignment checks the assignment s. Several targets are only allowed for
+// the results of a call and for the value and presence of a map element,
+// which is an int.
+func (c *checker) assignment(s *ast.AssignmentStatement) {
+	multi := multiValued(s.Value)
+	if len(s.Targets) == 1 && !multi {
+		target := s.Targets[0]
+		if s.IsDefine() {
+			c.define(target, c.value(s.Value))
+			return
+		}
+		want := c.target(target)
+		if t := c.valueAs(s.Value, want); t != "" && want != "" && t != want {
+			c.errorf(ast.Start(s.Value), "cannot use %s value as %s in assignment to %s", t, want, target)
+		}
+		return
+	}
+
+	var values []Type
+	if multi {
+		values = c.results([]ast.Expression{s.Value}, nil)
+		if len(values) != len(s.Targets) {
+			if values[0] != "" {
+				c.errorf(ast.Start(s.Targets[0]), "assignment mismatch: %s but %s returns %s",
+					count(len(s.Targets), "variable"), s.Value, count(len(values), "value"))
+			}
+			values = make([]Type, len(s.Targets))
+		}
+	} else {
+		t := c.value(s.Value)
+		values = []Type{t, Int}
+		if ie, ok := s.Value.(*ast.IndexExpression); !ok || len(s.Targets) != 2 || c.info.Types[ie.Left].Key() == "" {
+			if t != "" {
+				c.errorf(ast.Start(s.Targets[0]), "assignment mismatch: %d variables but 1 value", len(s.Targets))
+			}
+			values = make([]Type, len(s.Targets))
+		}
+	}
+	for i, target := range s.Targets {
+		if s.IsDefine() {
+			c.define(target, values[i])
+			continue
+		}
+		if want := c.target(target); values[i] != "" && want != "" && values[i] != want {
+			c.errorf(ast.Start(s.Value), "cannot use %s value as %s in assignment to %s", values[i], want, target)
+		}
+	}
+}
+
+// count returns n with the noun, which is plural unless n is 1.
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// define declares the target of a short variable declaration.
+func (c *checker) define(target ast.Expression, t Type) {
+	ident, ok := target.(*ast.Identifier)
+	if !ok {
+		c.errorf(ast.Start(target), "non-name %s on left side of :=", target)
+		return
+	}
+	c.declareLocal(ident, &variable{typ: t})
+}
+
+// target checks the target of an assignment, a declared variable or an
+// element of a slice or map, and returns its type.
+func (c *checker) target(target ast.Expression) Type {
+	switch e := target.(type) {
+	case *ast.Identifier:
+		v := c.scope.lookup(e.Value)
+		switch {
+		case v == nil:
+			if _, ok := c.globals[e.Value]; !ok {
+				c.errorf(e.Pos, "undefined: %s", e.Value)
+			}
+			return ""
+		case v.constant:
+			c.errorf(e.Pos, "cannot assign to constant %s", e.Value)
+			return ""
+		}
+		c.info.Types[e] = v.typ
+		return v.typ
+	case *ast.IndexExpression:
+		return c.value(e)
+	case *ast.SelectorExpression:
+		t := c.value(e)
+		if t != "" && !c.addressable(e.X, e) {
+			return ""
+		}
+		return t
+	}
+	if c.value(target) != "" {
+		c.errorf(ast.Start(target), "cannot assign to %s", target)
+	}
+	return ""
+}
+
+// addressable reports whether the struct x can be changed by assigning to
+// its field target, which is only possible if x is stored in a variable or
+// slice element, possibly as a field of another struct.
+func (c *checker) addressable(x ast.Expression, target ast.Expression) bool {
+	switch x := x.(type) {
+	case *ast.Identifier:
+		return true
+	case *ast.SelectorExpression:
+		return c.addressable(x.X, target)
+	case *ast.IndexExpression:
+		if c.info.Types[x.Left].Key() == "" {
+			return true
+		}
+		c.errorf(ast.Start(target), "cannot assign to struct field %s in map", target)
+		return false
+	}
+	c.errorf(ast.Start(target), "cannot assign to %s", target)
+	return false
+}
+
+// value checks an expression that is used as a value and returns its type.
+// The type is empty if the expression is invalid.
+func (c *checker) value(expr ast.Expression) Type {
+	t := c.expression(expr)
+	if t == Void {
+		c.errorf(ast.Start(expr), "%s (no value) used as value", expr)
+		return ""
+	}
+	if t.Tuple() != nil {
+		c.errorf(ast.Start(expr), "multiple-value %s (value of type %s) in single-value context", expr, t)
+		return ""
+	}
+	return t
+}
+
+// multiValued reports whether expr may have several values, which is the
+// case for calls and the propagation of their errors.
+func multiValued(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.CallExpression, *ast.TryExpression:
+		return true
+	}
+	return false
+}
+
+// results checks the values of a return statement or assignment and returns
+// their types. A single call may have several results, each of them is a
+// value. Empty slice literals and nil take their type from want.
+func (c *checker) results(exprs []ast.Expression, want []Type) []Type {
+	if len(exprs) == 1 && multiValued(exprs[0]) {
+		t := c.expression(exprs[0])
+		if elems := t.Tuple(); elems != nil {
+			return elems
+		}
+		if t == Void {
+			c.errorf(ast.Start(exprs[0]), "%s (no value) used as value", exprs[0])
+			return []Type{""}
+		}
+		return []Type{t}
+	}
+	res := make([]Type, len(exprs))
+	for i, expr := range exprs {
+		if i < len(want) {
+			res[i] = c.valueAs(expr, want[i])
+		} else {
+			res[i] = c.value(expr)
+		}
+	}
+	return res
+}
+
+// valueAs is like value for an expression whose value is assigned to a
+// variable of type want, which gives slice literals and nil their type.
+// Constant ints are converted where floats are wanted.
+func (c *checker) valueAs(expr ast.Expression, want Type) Type {
+	if lit, ok := expr.(*ast.SliceLiteral); ok && want.isSlice() {
+		for _, e := range lit.Elements {
+			if t := c.valueAs(e, want.Elem()); t != "" && t != want.Elem() {
+				c.errorf(ast.Start(e), "cannot use %s value as %s in slice literal", t, want.Elem())
+			}
+		}
+		c.info.Types[expr] = want
+		return want
+	}
+	if c.isNil(expr) && want == ErrorType {
+		c.info.Types[expr] = want
+		return want
+	}
+	t := c.value(expr)
+	if v, ok := c.consts[expr].(int64); ok && t == Int && want == Float {
+		c.consts[expr] = float64(v)
+		c.info.Types[expr] = Float
+		return Float
+	}
+	return t
+}
+
+// isNil reports whether expr is the predeclared nil, the zero value of the
+// error type.
+func (c *checker) isNil(expr ast.Expression) bool {
+	ident, ok := expr.(*ast.Identifier)
+	return ok && ident.Value == "nil" && c.scope.lookup("nil") == nil
+}
+
+func (c *checker) expression(expr ast.Expression) Type {
+	var t Type
+	switch e := expr.(type) {
+	case *ast.IntLiteral:
+		t = Int
+		c.consts[e] = e.Value
+
+	case *ast.FloatLiteral:
+		t = Float
+		c.consts[e] = e.Value
+
+	case *ast.StringLiteral:
+		t = String
+		c.consts[e] = e.Value
+
+	case *ast.Identifier:
+		if v := c.scope.lookup(e.Value); v != nil {
+			t = v.typ
+			if v.value != nil {
+				c.consts[e] = v.value
+			}
+			break
+		}
+		if sig, ok := c.info.Funcs[e.Value]; ok {
+			t = Type(sig.String())
+			break
+		}
+		if IsBuiltin(e.Value) {
+			c.errorf(e.Pos, "builtin %s must be called", e.Value)
+			break
+		}
+		if e.Value == "nil" {
+			c.errorf(e.Pos, "use of untyped nil")
+			break
+		}
+		// globals that are not checked yet are part of an initialization
+		// cycle, which is reported already
+		if _, ok := c.globals[e.Value]; !ok {
+			c.errorf(e.Pos, "undefined: %s", e.Value)
+		}
+
+	case *ast.SliceLiteral:
+		t = c.sliceLiteral(e)
+
+	case *ast.MapLiteral:
+		t = c.mapLiteral(e)
+
+	case *ast.StructLiteral:
+		t = c.structLiteral(e)
+
+	case *ast.SelectorExpression:
+		t = c.selector(e)
+
+	case *ast.CallExpression:
+		t = c.call(e)
+
+	case *ast.TryExpression:
+		t = c.try(e)
+
+	case *ast.FunctionLiteral:
+		t = c.functionLiteral(e)
+
+	case *ast.FuncType:
+		c.errorf(e.Pos, "%s is not an expression", e)
+
+	case *ast.MatchExpression:
+		t = c.match(e)
+
+	case *ast.IndexExpression:
+		left := c.indexed(e.Left)
+		t = left.Elem()
+		if key := left.Key(); key != "" {
+			if kt := c.value(e.Index); kt != "" && kt != key {
+				c.errorf(ast.Start(e.Index), "cannot use %s value as %s in map index", kt, key)
+			}
+			break
+		}
+		c.index(e.Index)
+
+	case *ast.SliceExpression:
+		t = c.indexed(e.Left)
+		if t.Key() != "" {
+			c.errorf(ast.Start(e.Left), "invalid operation: cannot slice %s of type %s", e.Left, t)
+			t = ""
+		}
+		for _, index := range []ast.Expression{e.Low, e.High} {
+			if index != nil {
+				c.index(index)
+			}
+		}
+
+	case *ast.InfixExpression:
+		t = c.infix(e)
+
+	case *ast.SliceType:
+		c.errorf(e.Pos, "%s is not an expression", e)
+
+	case *ast.MapType:
+		c.errorf(e.Pos, "%s is not an expression", e)
+	}
+
+	if t != "" {
+		c.info.Types[expr] = t
+	}
+	return t
+}
+
+// sliceLiteral checks a non-empty slice literal, the type of its first
+// element is the type of all elements.
+func (c *checker) sliceLiteral(lit *ast.SliceLiteral) Type {
+	if len(lit.Elements) == 0 {
+		c.errorf(lit.Pos, "cannot infer type of empty slice literal")
+		return ""
+	}
+	elem := c.value(lit.Elements[0])
+	for _, e := range lit.Elements[1:] {
+		if t := c.valueAs(e, elem); elem != "" && t != "" && t != elem {
+			c.errorf(ast.Start(e), "cannot use %s value as %s in slice literal", t, elem)
+		}
+	}
+	if elem == "" {
+		return ""
+	}
+	return SliceOf(elem)
+}
+
+// mapLiteral checks a map literal. Without a type the types of the first key
+// and value are the types of all keys and values, so the literal must not
+// be empty.
+func (c *checker) mapLiteral(lit *ast.MapLiteral) Type {
+	var key, elem Type
+	switch {
+	case lit.Type != nil:
+		t := c.typ(lit.Type, false)
+		key, elem = t.Key(), t.Elem()
+	case len(lit.Pairs) == 0:
+		c.errorf(lit.Pos, "cannot infer type of empty map literal")
+		return ""
+	default:
+		key, elem = c.value(lit.Pairs[0].Key), c.value(lit.Pairs[0].Value)
+		if key != "" && key != Int && key != String {
+			c.errorf(ast.Start(lit.Pairs[0].Key), "invalid map key type %s", key)
+			key = ""
+		}
+	}
+
+	seen := map[interface{}]bool{}
+	for i, pair := range lit.Pairs {
+		if i > 0 || lit.Type != nil {
+			if t := c.valueAs(pair.Key, key); key != "" && t != "" && t != key {
+				c.errorf(ast.Start(pair.Key), "cannot use %s value as %s key in map literal", t, key)
+			}
+			if t := c.valueAs(pair.Value, elem); elem != "" && t != "" && t != elem {
+				c.errorf(ast.Start(pair.Value), "cannot use %s value as %s value in map literal", t, elem)
+			}
+		}
+		if k, ok := c.consts[pair.Key]; ok {
+			if seen[k] {
+				c.errorf(ast.Start(pair.Key), "duplicate key %s in map literal", pair.Key)
+			}
+			seen[k] = true
+		}
+	}
+	if key == "" || elem == "" {
+		return ""
+	}
+	return MapOf(key, elem)
+}
+
+// structLiteral checks a struct literal, fields that are left out have their
+// zero value.
+func (c *checker) structLiteral(lit *ast.StructLiteral) Type {
+	s, ok := c.info.Structs[lit.Type.Value]
+	if !ok {
+		c.errorf(lit.Type.Pos, "invalid composite literal type %s", lit.Type.Value)
+	}
+	seen := map[string]bool{}
+	for _, kv := range lit.Fields {
+		name := kv.Key.(*ast.Identifier).Value
+		var want Type
+		switch {
+		case !ok:
+		case s.Field(name) == nil:
+			c.errorf(ast.Start(kv.Key), "unknown field %s in struct literal of type %s", name, lit.Type.Value)
+		case seen[name]:
+			c.errorf(ast.Start(kv.Key), "duplicate field %s in struct literal", name)
+		default:
+			want = s.Field(name).Type
+		}
+		seen[name] = true
+		if t := c.valueAs(kv.Value, want); want != "" && t != "" && t != want {
+			c.errorf(ast.Start(kv.Value), "cannot use %s value as %s value in struct literal", t, want)
+		}
+	}
+	if !ok {
+		return ""
+	}
+	return Type(lit.Type.Value)
+}
+
+// selector checks the selection of a field of a struct or of a variant of
+// an enum without payload.
+func (c *checker) selector(sel *ast.SelectorExpression) Type {
+	if name, e := c.enumOf(sel.X); e != nil {
+		v := c.variant(name, e, sel.Sel)
+		switch {
+		case v == nil:
+			return ""
+		case len(v.Payload) > 0:
+			c.errorf(sel.Sel.Pos, "cannot use variant %s without its payload", sel)
+			return ""
+		}
+		return Type(name)
+	}
+	t := c.value(sel.X)
+	if t == "" {
+		return ""
+	}
+	s, ok := c.info.Structs[string(t)]
+	if ok {
+		if f := s.Field(sel.Sel.Value); f != nil {
+			return f.Type
+		}
+		if _, ok := s.Methods[sel.Sel.Value]; ok {
+			c.errorf(sel.Sel.Pos, "cannot use method %s.%s as value", t, sel.Sel.Value)
+			return ""
+		}
+	}
+	c.errorf(sel.Sel.Pos, "%s undefined (type %s has no field or method %s)", sel, t, sel.Sel.Value)
+	return ""
+}
+
+// indexed checks the operand of an index or slice expression and returns
+// its type, which is empty if it is neither a slice nor a map.
+func (c *checker) indexed(expr ast.Expression) Type {
+	t := c.value(expr)
+	if t != "" && t.Elem() == "" {
+		c.errorf(ast.Start(expr), "invalid operation: cannot index %s of type %s", expr, t)
+		return ""
+	}
+	return t
+}
+
+// index checks an index or slice bound, which has to be a non-negative int.
+func (c *checker) index(expr ast.Expression) {
+	t := c.value(expr)
+	if t != "" && t != Int {
+		c.errorf(ast.Start(expr), "cannot use %s value as int in index", t)
+		return
+	}
+	if x, ok := c.consts[expr].(int64); ok && x < 0 {
+		c.errorf(ast.Start(expr), "invalid index %s: must not be negative", expr)
+	}
+}
+
+func (c *checker) infix(infix *ast.InfixExpression) Type {
+	left, right := c.value(infix.Left), c.value(infix.Right)
+	if left == "" || right == "" {
+		return ""
+	}
+	// a constant int operand is converted to float if the other one is a
+	// float, all other operands have to be of the same type
+	switch {
+	case left == Float && right == Int && c.isConst(infix.Right):
+		right = Float
+	case left == Int && right == Float && c.isConst(infix.Left):
+		left = Float
+	}
+	if left != right {
+		c.errorf(infix.Pos, "invalid operation: mismatched types %s and %s", left, right)
+		return ""
+	}
+	if left != Int && left != Float && (left != String || infix.Token != token.ADD) {
+		c.errorf(infix.Pos, "invalid operation: operator %s not defined on %s", infix.Operator, left)
+		return ""
+	}
+	if y, ok := toFloat(c.consts[infix.Right]); ok && infix.Token == token.DIV && y == 0 {
+		c.errorf(infix.Pos, "invalid operation: division by zero")
+		return left
+	}
+	c.fold(infix)
+	return left
+}
+
+// isConst reports whether the value of expr is known at compile time.
+func (c *checker) isConst(expr ast.Expression) bool {
+	_, ok := c.consts[expr]
+	return ok
+}
+
+// toFloat converts a constant int or float value to float64.
+func toFloat(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
+
+// fold records the value of infix if both operands are constant.
+func (c *checker) fold(infix *ast.InfixExpression) {
+	x, ok := c.consts[infix.Left]
+	if !ok {
+		return
+	}
+	y, ok := c.consts[infix.Right]
+	if !ok {
+		return
+	}
+	_, xf := x.(float64)
+	_, yf := y.(float64)
+	if xf || yf {
+		x, _ := toFloat(x)
+		y, _ := toFloat(y)
+		val, err := arith.Float(infix.Token, x, y)
+		if err != nil || math.IsInf(val, 0) {
+			c.errorf(infix.Pos, "constant %s overflows float", infix)
+			return
+		}
+		c.consts[infix] = val
+		return
+	}
+	switch x := x.(type) {
+	case string:
+		c.consts[infix] = x + y.(string)
+	case int64:
+		val, err := arith.Int(infix.Token, x, y.(int64))
+		if err != nil {
+			c.errorf(infix.Pos, "constant %s overflows int", infix)
+			return
+		}
+		c.consts[infix] = val
+	}
+}
+
+func (c *checker) call(call *ast.CallExpression) Type {
+	if call.Module != nil {
+		return c.moduleCall(call)
+	}
+	if call.Receiver != nil {
+		return c.methodCall(call)
+	}
+	if call.Callee != nil {
+		return c.valueCall(call, call.Callee.String(), c.value(call.Callee))
+	}
+	name := call.Function.Value
+	if c.scope.lookup(name) != nil {
+		// variables shadow functions and builtins
+		return c.valueCall(call, name, c.value(call.Function))
+	}
+	if b, ok := builtins[name]; ok {
+		return c.builtinCall(call, b)
+	}
+	sig, ok := c.info.Funcs[name]
+	if !ok {
+		c.errorf(call.Pos, "undefined function %s", name)
+		for _, arg := range call.Arguments {
+			c.value(arg)
+		}
+		return ""
+	}
+	return c.arguments(call, name, sig)
+}
+
+// try checks the propagation of the error that is the last value of te.X,
+// which requires the enclosing function to return an error last. The other
+// values of te.X are the values of te.
+func (c *checker) try(te *ast.TryExpression) Type {
+	t := c.expression(te.X)
+	values := t.Tuple()
+	if values == nil {
+		values = []Type{t}
+	}
+	if t != "" && values[len(values)-1] != ErrorType {
+		c.errorf(te.Pos, "invalid operation: %s (value of type %s) has no error to propagate", te.X, t)
+		t = ""
+	}
+
+	results := c.result.Tuple()
+	if results == nil {
+		results = []Type{c.result}
+	}
+	switch {
+	case c.result == "":
+		c.errorf(te.Pos, "cannot use ? outside of a function")
+	case results[len(results)-1] != ErrorType:
+		c.errorf(te.Pos, "cannot use ? in function with result %s", c.result)
+	}
+
+	if t == "" {
+		return ""
+	}
+	switch values = values[:len(values)-1]; len(values) {
+	case 0:
+		return Void
+	case 1:
+		return values[0]
+	}
+	return TupleOf(values...)
+}
+
+// valueCall checks a call of the function value name of type t.
+func (c *checker) valueCall(call *ast.CallExpression, name string, t Type) Type {
+	sig := t.Signature()
+	if sig == nil {
+		if t != "" {
+			c.errorf(call.Pos, "invalid operation: cannot call non-function %s of type %s", name, t)
+		}
+		for _, arg := range call.Arguments {
+			c.value(arg)
+		}
+		return ""
+	}
+	return c.arguments(call, name, sig)
+}
+
+// moduleCall checks a call of a function of an imported module.
+func (c *checker) moduleCall(call *ast.CallExpression) Type {
+	name := call.Module.Value + "." + call.Function.Value
+	var sig *Signature
+	if info, ok := c.imports[call.Module.Value]; !ok {
+		c.errorf(call.Module.Pos, "undefined: %s", call.Module.Value)
+	} else if sig, ok = info.Exports[call.Function.Value]; !ok {
+		if _, declared := info.Funcs[call.Function.Value]; declared && !ast.IsExported(call.Function.Value) {
+			c.errorf(call.Function.Pos, "cannot refer to unexported function %s", name)
+		} else {
+			c.errorf(call.Function.Pos, "undefined function %s", name)
+		}
+	}
+	if sig == nil {
+		for _, arg := range call.Arguments {
+			c.value(arg)
+		}
+		return ""
+	}
+	return c.arguments(call, name, sig)
+}
+
+// enumOf returns the enum type x refers to, if x is the name of an enum
+// that is not shadowed by a variable.
+func (c *checker) enumOf(x ast.Expression) (string, *Enum) {
+	ident, ok := x.(*ast.Identifier)
+	if !ok || c.scope.lookup(ident.Value) != nil {
+		return "", nil
+	}
+	return ident.Value, c.info.Enums[ident.Value]
+}
+
+// variant returns the variant sel of the enum e called name, nil if there is
+// none.
+func (c *checker) variant(name string, e *Enum, sel *ast.Identifier) *Variant {
+	v := e.Variant(sel.Value)
+	if v == nil {
+		c.errorf(sel.Pos, "%s.%s undefined (type %s has no variant %s)", name, sel.Value, name, sel.Value)
+	}
+	return v
+}
+
+// variantCall checks the construction of a variant of the enum e with its
+// payload.
+func (c *checker) variantCall(call *ast.CallExpression, name string, e *Enum) Type {
+	v := c.variant(name, e, call.Function)
+	if v != nil && len(v.Payload) == 0 {
+		c.errorf(call.Pos, "cannot call variant %s.%s without payload", name, v.Name)
+		v = nil
+	}
+	if v == nil {
+		for _, arg := range call.Arguments {
+			c.value(arg)
+		}
+		return ""
+	}
+	return c.arguments(call, name+"."+v.Name, &Signature{Params: v.Payload, Result: Type(name)})
+}
+
+// methodCall checks a call of a method of a struct or the construction of a
+// variant with payload.
+func (c *checker) methodCall(call *ast.CallExpression) Type {
+	if name, e := c.enumOf(call.Receiver); e != nil {
+		return c.variantCall(call, name, e)
+	}
+	t := c.value(call.Receiver)
+	name := call.Function.Value
+	var sig *Signature
+	if s, ok := c.info.Structs[string(t)]; ok {
+		if f := s.Field(name); f != nil {
+			// fields of function types are called like methods
+			return c.valueCall(call, call.Receiver.String()+"."+name, f.Type)
+		}
+		sig = s.Methods[name]
+	}
+	if sig == nil {
+		if t != "" {
+			c.errorf(call.Pos, "%s.%s undefined (type %s has no field or method %s)", call.Receiver, name, t, name)
+		}
+		for _, arg := range call.Arguments {
+			c.value(arg)
+		}
+		return ""
+	}
+	return c.arguments(call, string(t)+"."+name, sig)
+}
+
+// arguments checks the arguments of a call of the function name with the
+// signature sig and returns its result type.
+func (c *checker) arguments(call *ast.CallExpression, name string, sig *Signature) Type {
+	for i, arg := range call.Arguments {
+		var t Type
+		if i < len(sig.Params) {
+			t = c.valueAs(arg, sig.Params[i])
+		} else {
+			t = c.value(arg)
+		}
+		if i < len(sig.Params) && t != "" && t != sig.Params[i] {
+			c.errorf(ast.Start(arg), "cannot use %s value as %s in argument to %s", t, sig.Params[i], name)
+		}
+	}
+	switch {
+	case len(call.Arguments) < len(sig.Params):
+		c.errorf(call.Pos, "not enough arguments in call to %s", name)
+	case len(call.Arguments) > len(sig.Params):
+		c.errorf(call.Pos, "too many arguments in call to %s", name)
+	}
+	return sig.Result
+}
+
+// match checks a match expression. Its arms are checked in order, an arm is
+// unreachable if the arms before it match every value it matches already.
+// Unless there is an arm for every value of the subject the match is
+// reported as non-exhaustive. The arms must all have the same type, which is
+// the type of the match.
+func (c *checker) match(m *ast.MatchExpression) Type {
+	subject := c.value(m.Subject)
+	enum := c.info.Enums[string(subject)]
+
+	var (
+		result   Type
+		covered  = map[string]bool{} // variants and literals matched by unguarded arms
+		complete bool                // whether every value is matched already
+	)
+	for _, arm := range m.Arms {
+		outer := c.scope
+		c.scope = newScope(outer)
+
+		key, all := c.pattern(arm.Pattern, subject)
+		if complete || key != "" && covered[key] {
+			c.errorf(ast.Start(arm.Pattern), "unreachable match arm")
+		}
+		if arm.Guard != nil {
+			if t := c.value(arm.Guard); t != "" && t != Int {
+				c.errorf(ast.Start(arm.Guard), "cannot use %s value as int in match guard", t)
+			}
+		} else if all && key == "" {
+			complete = true
+		} else if all {
+			covered[key] = true
+			complete = complete || enum != nil && len(covered) == len(enum.Variants)
+		}
+
+		var t Type
+		if result != "" && result != Void {
+			t = c.valueAs(arm.Body, result)
+		} else {
+			t = c.expression(arm.Body)
+		}
+		if result == "" {
+			result = t
+		} else if t != "" && t != result {
+			c.errorf(ast.Start(arm.Body), "mismatched types %s and %s in match arms", result, t)
+		}
+		c.scope = outer
+	}
+
+	switch {
+	case complete || subject == "":
+	case enum != nil:
+		var missing []string
+		for _, v := range enum.Variants {
+			if !covered[v.Name] {
+				missing = append(missing, string(subject)+"."+v.Name)
+			}
+		}
+		c.errorf(m.Pos, "non-exhaustive match: missing %s", strings.Join(missing, ", "))
+	default:
+		c.errorf(m.Pos, "non-exhaustive match: missing _ arm")
+	}
+	return result
+}
+
+// pattern checks the pattern pat of a match arm for a value of type t and
+// declares the names it binds. It returns the variant or literal the
+// pattern matches, which is empty if it matches every value, and whether it
+// matches every value of that variant.
+func (c *checker) pattern(pat ast.Expression, t Type) (key string, all bool) {
+	switch p := pat.(type) {
+	case *ast.Identifier:
+		if c.isNil(p) {
+			if t != "" && t != ErrorType {
+				c.errorf(p.Pos, "cannot match %s value with nil pattern", t)
+			}
+			return "nil", false
+		}
+		if p.Value != "_" {
+			c.declareLocal(p, &variable{typ: t})
+			if t != "" {
+				c.info.Types[p] = t
+			}
+		}
+		return "", true
+
+	case *ast.IntLiteral, *ast.FloatLiteral, *ast.StringLiteral:
+		if lt := c.expression(p); t != "" && lt != t {
+			c.errorf(ast.Start(p), "cannot match %s value with %s pattern", t, lt)
+		}
+		return p.String(), false
+
+	case *ast.SelectorExpression:
+		if name, e := c.enumOf(p.X); e != nil {
+			return c.variantPattern(pat, name, e, p.Sel, nil, t)
+		}
+
+	case *ast.CallExpression:
+		if name, e := c.enumOf(p.Receiver); e != nil {
+			return c.variantPattern(pat, name, e, p.Function, p.Arguments, t)
+		}
+	}
+	c.errorf(ast.Start(pat), "invalid pattern %s", pat)
+	return "", false
+}
+
+// variantPattern checks the pattern pat of the variant sel of the enum e
+// called name, payload holds the patterns for its payload.
+func (c *checker) variantPattern(pat ast.Expression, name string, e *Enum, sel *ast.Identifier, payload []ast.Expression, t Type) (string, bool) {
+	if t != "" && t != Type(name) {
+		c.errorf(ast.Start(pat), "cannot match %s value with %s pattern", t, name)
+	}
+	v := c.variant(name, e, sel)
+	if v == nil {
+		return "", false
+	}
+	if len(payload) != len(v.Payload) {
+		c.errorf(ast.Start(pat), "wrong number of patterns for %s.%s: want %d, got %d", name, v.Name, len(v.Payload), len(payload))
+	}
+	all := true
+	for i, p := range payload {
+		var pt Type
+		if i < len(v.Payload) {
+			pt = v.Payload[i]
+		}
+		key, _ := c.pattern(p, pt)
+		all = all && key == ""
+	}
+	c.info.Types[pat] = Type(name)
+	return v.Name, all
+}
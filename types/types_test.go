@@ -0,0 +1,506 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/muggel/emlang/ast"
+	"github.com/muggel/emlang/parser"
+	"github.com/muggel/emlang/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	program, err := parser.NewParser(scanner.NewScanner(src)).Parse()
+	require.NoError(t, err)
+	return program
+}
+
+func check(t *testing.T, src string) error {
+	config := &Config{Funcs: map[string]*Signature{
+		"now": {Result: Int},
+		"log": {Params: []Type{Int}, Result: Void},
+	}}
+	_, err := config.Check(parse(t, src))
+	return err
+}
+
+func TestConfig_Check(t *testing.T) {
+	t.Run("records_types_and_signatures", func(t *testing.T) {
+		program := parse(t, "fn id(x int) int {\n\treturn x;\n}\n\nfn main() {\n\ty := id(1);\n}")
+		info, err := (&Config{}).Check(program)
+		require.NoError(t, err)
+
+		assert.Equal(t, &Signature{Params: []Type{Int}, Result: Int}, info.Funcs["id"])
+		assert.Equal(t, "fn(int) int", info.Funcs["id"].String())
+		assert.Equal(t, "fn()", info.Funcs["main"].String())
+
+		call := program.TopLevelDeclarations[1].(*ast.FunctionDeclaration).Body.Statements[0].(*ast.AssignmentStatement).Value
+		assert.Equal(t, Int, info.Types[call])
+	})
+
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{
+			name:   "accepts_valid_programs",
+			source: "fn id(x int) int {\n\treturn x;\n}\n\nfn main() {\n\tlog(id(now()));\n}",
+		},
+		{
+			name:   "reports_undefined_identifiers",
+			source: "fn main() int {\n\treturn x;\n}",
+			err:    "2:9: undefined: x",
+		},
+		{
+			name:   "reports_undefined_functions",
+			source: "fn main() {\n\tfoo();\n}",
+			err:    "2:2: undefined function foo",
+		},
+		{
+			name:   "reports_redeclared_functions",
+			source: "fn log() {\n}",
+			err:    "1:4: function log redeclared",
+		},
+		{
+			name:   "reports_unknown_types",
+			source: "fn main(x void) string {\n\treturn 1;\n}",
+			err:    "1:11: unknown type void (and 1 more errors)",
+		},
+		{
+			name:   "reports_duplicate_parameters",
+			source: "fn f(a int, a int) {\n}",
+			err:    "1:13: duplicate parameter a",
+		},
+		{
+			name:   "reports_wrong_argument_counts",
+			source: "fn main() {\n\tlog();\n\tlog(1, 2);\n}",
+			err:    "2:2: not enough arguments in call to log (and 1 more errors)",
+		},
+		{
+			name:   "reports_void_values",
+			source: "fn main() {\n\tx := log(1);\n}",
+			err:    "2:7: log(1) (no value) used as value",
+		},
+		{
+			name:   "reports_return_values_of_void_functions",
+			source: "fn main() {\n\treturn 1;\n}",
+			err:    "2:2: too many return values",
+		},
+		{
+			name:   "reports_void_operands",
+			source: "fn main() int {\n\treturn 1 + log(1);\n}",
+			err:    "2:13: log(1) (no value) used as value",
+		},
+		{
+			name:   "reports_constant_division_by_zero",
+			source: "fn main() int {\n\treturn 1 / 0;\n}",
+			err:    "2:11: invalid operation: division by zero",
+		},
+		{
+			name:   "accepts_strings_and_builtins",
+			source: "fn greet(name string) string {\n\treturn \"hello \" + name;\n}\n\nfn main() {\n\tprintln(greet(\"you\"), len(\"abc\"));\n\tprint();\n\tassert(max(1, abs(2), 3) - min(4), \"max\");\n\tpanic(1);\n}",
+		},
+		{
+			name:   "reports_mismatched_operands",
+			source: "fn main() int {\n\treturn 1 + \"a\";\n}",
+			err:    "2:11: invalid operation: mismatched types int and string",
+		},
+		{
+			name:   "reports_undefined_string_operators",
+			source: "fn main() string {\n\treturn \"a\" - \"b\";\n}",
+			err:    "2:13: invalid operation: operator - not defined on string",
+		},
+		{
+			name:   "reports_invalid_builtin_calls",
+			source: "fn main() {\n\tx := len(1);\n\ty := min();\n\tassert(1, \"a\", \"b\");\n\tprint(log(1));\n}",
+			err:    "2:11: invalid argument 1 of type int for len (and 3 more errors)",
+		},
+		{
+			name:   "accepts_slices",
+			source: "fn sum(xs []int) int {\n\tn := 0;\n\tfor i, x in xs[1:] {\n\t\tn = n + i * x;\n\t}\n\treturn n + len(xs);\n}\n\nfn main() []string {\n\tlet xs []int = [];\n\txs = append(xs, 1, sum([]));\n\tvar grid [][]int;\n\tgrid = append(grid, xs, []);\n\treturn [\"a\", \"b\"][:grid[0][1]];\n}",
+		},
+		{
+			name:   "reports_mismatched_slice_elements",
+			source: "fn main() {\n\txs := [1, \"a\"];\n}",
+			err:    "2:12: cannot use string value as int in slice literal",
+		},
+		{
+			name:   "reports_empty_slice_literals_without_type",
+			source: "fn main() {\n\txs := [];\n}",
+			err:    "2:8: cannot infer type of empty slice literal",
+		},
+		{
+			name:   "reports_invalid_index_operations",
+			source: "fn main() {\n\tn := 1;\n\tx := n[0];\n\ty := [1][\"a\"];\n\tz := [1][0 - 1:];\n}",
+			err:    "3:7: invalid operation: cannot index n of type int (and 2 more errors)",
+		},
+		{
+			name:   "reports_loops_over_non_slices",
+			source: "fn main() {\n\tfor x in \"abc\" {\n\t}\n}",
+			err:    "2:11: cannot iterate over \"abc\" of type string",
+		},
+		{
+			name:   "scopes_loop_variables_to_the_loop",
+			source: "fn main() int {\n\tfor i, x in [1] {\n\t\tx := i;\n\t}\n\treturn x;\n}",
+			err:    "5:9: undefined: x",
+		},
+		{
+			name:   "reports_operators_on_slices",
+			source: "fn main() {\n\txs := [1] + [2];\n}",
+			err:    "2:12: invalid operation: operator + not defined on []int",
+		},
+		{
+			name:   "reports_invalid_append_calls",
+			source: "fn main() {\n\txs := append(1, 2);\n\tys := append([1], \"a\");\n}",
+			err:    "2:15: invalid argument 1 of type int for append (and 1 more errors)",
+		},
+		{
+			name:   "accepts_maps",
+			source: "fn count(words []string) map[string]int {\n\tm := map[string]int{};\n\tfor w in words {\n\t\tm[w] = m[w] + 1;\n\t}\n\treturn m;\n}\n\nfn main() int {\n\tm := count([\"a\"]);\n\tdelete(m, \"a\");\n\tn, ok := m[\"a\"];\n\tfor k, v in {1: \"a\"} {\n\t\tn = k + len(v);\n\t}\n\tn, ok = m[\"b\"];\n\treturn n + ok + len(m);\n}",
+		},
+		{
+			name:   "reports_duplicate_map_keys",
+			source: "fn main() {\n\tm := {\"a\": 1, \"a\": 2};\n}",
+			err:    "2:16: duplicate key \"a\" in map literal",
+		},
+		{
+			name:   "reports_empty_map_literals_without_type",
+			source: "fn main() {\n\tvar m map[string]int = {};\n}",
+			err:    "2:25: cannot infer type of empty map literal",
+		},
+		{
+			name:   "reports_invalid_map_key_types",
+			source: "fn main() {\n\tvar m map[[]int]int;\n}",
+			err:    "2:12: invalid map key type []int",
+		},
+		{
+			name:   "reports_mismatched_map_keys_and_values",
+			source: "fn main() {\n\tm := {\"a\": 1, 2: \"b\"};\n\tx := m[1];\n\tm[\"c\"] = \"d\";\n\tdelete(m, 1);\n}",
+			err:    "2:16: cannot use int value as string key in map literal (and 4 more errors)",
+		},
+		{
+			name:   "reports_invalid_assignment_targets",
+			source: "fn main() {\n\tm := {\"a\": 1};\n\tm[\"a\"] := 2;\n\tx, y := len(m);\n\ts := m[:];\n}",
+			err:    "3:2: non-name m[\"a\"] on left side of := (and 2 more errors)",
+		},
+		{
+			name:   "reports_unknown_element_types",
+			source: "fn main() {\n\tlet xs []bool;\n}",
+			err:    "2:11: unknown type bool",
+		},
+		{
+			name:   "reports_redeclared_builtins",
+			source: "fn print() {\n}",
+			err:    "1:4: cannot redeclare builtin print",
+		},
+		{
+			name:   "checks_globals",
+			source: "let a = 1;\nvar s string;\nconst c = a;\n\nfn main() int {\n\ts = \"x\";\n\ta = a + 1;\n\treturn a;\n}",
+			err:    "3:11: a is not constant",
+		},
+		{
+			name:   "reports_mismatched_global_types",
+			source: "let x string = 1;\n\nfn main() {\n\tx = 2;\n}",
+			err:    "1:16: cannot use int value as string in declaration of x (and 1 more errors)",
+		},
+		{
+			name:   "reports_assignments_to_constants",
+			source: "const x = 1;\n\nfn main() {\n\tx = 2;\n}",
+			err:    "4:2: cannot assign to constant x",
+		},
+		{
+			name:   "reports_redeclared_globals",
+			source: "let x = 1;\nconst x = 2;\n\nfn x() {\n}\n\nvar len int;",
+			err:    "2:7: x redeclared (and 2 more errors)",
+		},
+		{
+			name:   "reports_constant_overflow",
+			source: "const big = 9223372036854775807;\nconst x = big + 1;",
+			err:    "2:15: constant (big + 1) overflows int",
+		},
+		{
+			name:   "reports_constant_division_by_zero",
+			source: "const zero = 0;\n\nfn main() int {\n\treturn 1 / zero;\n}",
+			err:    "4:11: invalid operation: division by zero",
+		},
+		{
+			name:   "reports_initialization_cycles",
+			source: "let a = f();\nlet b = a;\n\nfn f() int {\n\treturn b;\n}",
+			err:    "1:5: initialization cycle: a refers to b refers to a",
+		},
+		{
+			name:   "checks_local_declarations",
+			source: "let g = \"global\";\n\nfn main() int {\n\tlet a int = 1;\n\tvar s string;\n\tconst c = 2 * a;\n\tb := a + c;\n\tg := 3;\n\tb = g;\n\ts = \"x\";\n\treturn b;\n}",
+			err:    "6:12: (2 * a) is not constant",
+		},
+		{
+			name:   "reports_assignments_to_undeclared_names",
+			source: "fn main() {\n\tx = 1;\n}",
+			err:    "2:2: undefined: x",
+		},
+		{
+			name:   "reports_redeclarations_in_the_same_scope",
+			source: "fn f(a int) {\n\ta := 1;\n\tlet b = 2;\n\tb := \"b\";\n}",
+			err:    "2:2: a redeclared in this block (and 1 more errors)",
+		},
+		{
+			name:   "reports_mismatched_local_types",
+			source: "fn main() {\n\tlet x string = 1;\n\ty := 2;\n\ty = \"y\";\n}",
+			err:    "2:17: cannot use int value as string in declaration of x (and 1 more errors)",
+		},
+		{
+			name:   "reports_assignments_to_local_constants",
+			source: "fn main() {\n\tconst x = 1;\n\tx = 2;\n}",
+			err:    "3:2: cannot assign to constant x",
+		},
+		{
+			name:   "accepts_structs_and_methods",
+			source: "struct Point { x int, y int }\n\nstruct Path { points []Point, name string }\n\nfn (p Point) add(q Point) Point {\n\treturn Point{x: p.x + q.x, y: p.y + q.y};\n}\n\nfn main() int {\n\tvar path Path;\n\tpath.points = append(path.points, Point{x: 1});\n\tpath.points[0].y = 2;\n\tp := path.points[0].add(Point{y: 1});\n\treturn p.x + p.y;\n}",
+		},
+		{
+			name:   "reports_invalid_struct_declarations",
+			source: "struct A { b B }\nstruct B { a A, n int, n int }\nstruct C { c []C }\n\nfn C() {\n}",
+			err:    "1:8: invalid recursive type A (and 3 more errors)",
+		},
+		{
+			name:   "reports_invalid_struct_literals",
+			source: "struct P { x int }\n\nfn main() {\n\tp := P{x: \"a\", y: 1, x: 2};\n\tq := Q{};\n}",
+			err:    "4:12: cannot use string value as int value in struct literal (and 3 more errors)",
+		},
+		{
+			name:   "reports_undefined_fields_and_methods",
+			source: "struct P { x int }\n\nfn (p P) f() int {\n\treturn p.y;\n}\n\nfn main() {\n\tp := P{};\n\tp.g();\n\tn := p.f;\n}",
+			err:    "4:11: p.y undefined (type P has no field or method y) (and 2 more errors)",
+		},
+		{
+			name:   "reports_invalid_receivers",
+			source: "struct P { x int }\n\nfn (p int) f() {\n}\n\nfn (p P) x() {\n}",
+			err:    "3:7: invalid receiver type int (and 1 more errors)",
+		},
+		{
+			name:   "reports_assignments_to_fields_of_map_elements",
+			source: "struct P { x int }\n\nfn main() {\n\tm := {1: P{}};\n\tm[1].x = 2;\n}",
+			err:    "5:2: cannot assign to struct field m[1].x in map",
+		},
+		{
+			name:   "reports_struct_types_in_exported_functions",
+			source: "struct P { x int }\n\nfn Origin() P {\n\treturn P{};\n}",
+			err:    "3:13: exported function Origin cannot use struct type P",
+		},
+		{
+			name:   "accepts_enums_and_matches",
+			source: "enum Shape { Circle(int), Rect(int, int), Empty }\n\nfn area(s Shape) int {\n\treturn match s {\n\t\tShape.Circle(r) => 3 * r * r,\n\t\tShape.Rect(w, h) if w - h => w * h,\n\t\tShape.Rect(w, _) => w * w,\n\t\tShape.Empty => 0,\n\t};\n}\n\nfn main() {\n\tmatch area(Shape.Rect(1, 2)) {\n\t\t0 => println(\"none\"),\n\t\tn => println(n),\n\t};\n}",
+		},
+		{
+			name:   "reports_invalid_enum_declarations",
+			source: "enum A { X(B) }\nenum B { Y(A), Y }\nenum C {}\n\nstruct A {}",
+			err:    "1:6: invalid recursive type A (and 4 more errors)",
+		},
+		{
+			name:   "reports_invalid_variants",
+			source: "enum E { A, B(int) }\n\nfn main() {\n\ta := E.B;\n\tb := E.A(1);\n\tc := E.C;\n\td := E.B(\"x\");\n}",
+			err:    "4:9: cannot use variant E.B without its payload (and 3 more errors)",
+		},
+		{
+			name:   "reports_non_exhaustive_matches",
+			source: "enum E { A, B(int), C }\n\nfn main() {\n\tx := match E.A {\n\t\tE.B(1) => 1,\n\t\tE.A => 2,\n\t};\n\ty := match 1 { 1 => 1 };\n}",
+			err:    "4:7: non-exhaustive match: missing E.B, E.C (and 1 more errors)",
+		},
+		{
+			name:   "reports_unreachable_match_arms",
+			source: "enum E { A, B(int) }\n\nfn main() {\n\tx := match E.A {\n\t\tE.B(n) if n => 1,\n\t\tE.B(_) => 2,\n\t\tE.A => 3,\n\t\tE.B(1) => 4,\n\t\t_ => 5,\n\t};\n}",
+			err:    "8:3: unreachable match arm (and 1 more errors)",
+		},
+		{
+			name:   "reports_invalid_patterns",
+			source: "enum E { A, B(int) }\n\nfn main() {\n\tx := match E.A {\n\t\tE.B(\"s\", y) => 1,\n\t\tf() => 2,\n\t\t_ if \"s\" => \"s\",\n\t\t_ => 0,\n\t};\n}",
+			err:    "5:3: wrong number of patterns for E.B: want 1, got 2 (and 4 more errors)",
+		},
+		{
+			name:   "accepts_function_values_and_closures",
+			source: "struct Button { onClick fn(int) int }\n\nfn makeAdder(n int) fn(int) int {\n\treturn fn(x int) int {\n\t\treturn x + n;\n\t};\n}\n\nfn double(x int) int {\n\treturn x * 2;\n}\n\nlet inc = makeAdder(1);\n\nfn main() int {\n\tvar f fn(int) int = double;\n\tfs := [f, inc, makeAdder(2)];\n\tb := Button{onClick: fs[2]};\n\treturn makeAdder(1)(2) + fs[0](1) + b.onClick(3);\n}",
+		},
+		{
+			name:   "reports_calls_of_non_functions",
+			source: "struct P { x int }\n\nfn main() {\n\tx := 1;\n\tx(2);\n\tp := P{};\n\tp.x();\n\ty := len;\n}",
+			err:    "5:2: invalid operation: cannot call non-function x of type int (and 2 more errors)",
+		},
+		{
+			name:   "reports_invalid_function_literals",
+			source: "fn main() {\n\tf := fn(a int, a int) int {\n\t};\n\tvar g fn(string) = fn(s int) {\n\t};\n\tf(\"s\", 1);\n}",
+			err:    "2:17: duplicate parameter a (and 3 more errors)",
+		},
+		{
+			name:   "reports_struct_types_in_exported_function_types",
+			source: "struct P { x int }\n\nfn Each(f fn([]P)) {\n}",
+			err:    "3:11: exported function Each cannot use struct type P",
+		},
+		{
+			name:   "accepts_multiple_results",
+			source: "struct P { x int }\n\nfn divmod(a int, b int) (int, int) {\n\treturn a / b, a - a / b * b;\n}\n\nfn again(a int) (int, int) {\n\treturn divmod(a, 2);\n}\n\nfn pair() (P, []int) {\n\treturn P{x: 1}, [];\n}\n\nfn main() int {\n\tq, r := divmod(7, 2);\n\tq, r = again(q);\n\tp, xs := pair();\n\tvar f fn() (int, string) = fn() (int, string) {\n\t\treturn 1, \"one\";\n\t};\n\tn, s := f();\n\tprintln(s, len(xs));\n\treturn q + r + p.x + n;\n}",
+		},
+		{
+			name:   "reports_wrong_numbers_of_results",
+			source: "fn divmod(a int, b int) (int, int) {\n\treturn a / b;\n}\n\nfn one() int {\n\treturn 1, 2;\n}\n\nfn main() {\n\tx := divmod(7, 2);\n\ta, b, c := divmod(7, 2);\n\td, e := one();\n\tprintln(divmod(1, 2));\n}",
+			err:    "2:2: not enough return values (and 5 more errors)",
+		},
+		{
+			name:   "reports_mismatched_result_types",
+			source: "fn f() (int, string) {\n\treturn 1, 2;\n}\n\nfn main() {\n\tvar s string;\n\tvar n int;\n\ts, n = f();\n}",
+			err:    "2:12: cannot use int value as string in return statement (and 2 more errors)",
+		},
+		{
+			name:   "reports_struct_types_in_exported_results",
+			source: "struct P { x int }\n\nfn Pair() (int, P) {\n\treturn 1, P{};\n}",
+			err:    "3:11: exported function Pair cannot use struct type P",
+		},
+		{
+			name:   "accepts_error_values_and_propagation",
+			source: "fn parse(s string) (int, error) {\n\tports := {\"80\": 80};\n\tport, ok := ports[s];\n\treturn port, match ok {\n\t\t0 => error(\"invalid port \" + s),\n\t\t_ => nil,\n\t};\n}\n\nfn check(s string) error {\n\tparse(s)?;\n\treturn nil;\n}\n\nfn main() error {\n\tvar err error;\n\tn := parse(\"80\")?;\n\tf := fn() (int, int, error) {\n\t\treturn n, n, check(\"x\");\n\t};\n\ta, b := f()?;\n\tprintln(a + b, match err {\n\t\tnil => \"ok\",\n\t\te => \"failed\",\n\t});\n\treturn err;\n}",
+		},
+		{
+			name:   "reports_invalid_propagation",
+			source: "let g = f()?;\n\nfn f() (int, error) {\n\treturn 1, nil;\n}\n\nfn two() int {\n\treturn 2;\n}\n\nfn main() {\n\tx := f()?;\n\ty := two()?;\n}",
+			err:    "1:12: cannot use ? outside of a function (and 3 more errors)",
+		},
+		{
+			name:   "reports_invalid_uses_of_nil_and_error",
+			source: "fn main() {\n\tz := nil;\n\tvar e error = error(1);\n\tmatch 1 {\n\t\tnil => 0,\n\t\t_ => 1,\n\t};\n}\n\nstruct error { x int }",
+			err:    "2:7: use of untyped nil (and 3 more errors)",
+		},
+		{
+			name:   "accepts_floats_and_conversions",
+			source: "const Half = 1 / 2.0;\nconst Mask = int(0xFF_FF * Half);\n\nfn mean(xs []int) float {\n\tvar sum float;\n\tfor _, x in xs {\n\t\tsum = sum + float(x);\n\t}\n\treturn sum / float(len(xs)) * 2 + Half;\n}\n\nfn main() int {\n\tm := mean([0b1, 0o2, 3]);\n\treturn int(m) + Mask + match m {\n\t\t2.5 => 1,\n\t\t_ => 0,\n\t};\n}",
+		},
+		{
+			name:   "reports_invalid_mixed_arithmetic_and_conversions",
+			source: "fn main() {\n\tx := 1;\n\ty := 2.5;\n\tz := x + y;\n\ts := \"a\" * 1.0;\n\tk := int(\"a\");\n\tc := 1.0 / 0;\n\tn := int(1e30);\n\tf := float(1, 2);\n}\n\nstruct float { x int }",
+			err:    "4:9: invalid operation: mismatched types int and float (and 6 more errors)",
+		},
+		{
+			name:   "converts_int_constants_to_floats",
+			source: "const g float = 3;\n\nstruct P { x float, ys []float }\n\nenum Shape { Circle(float) }\n\nfn half(x float) float {\n\treturn x / 2;\n}\n\nfn three() float {\n\treturn 3;\n}\n\nfn main() {\n\tlet x float = 1;\n\tx = 2 * 3;\n\tlet xs []float = [1, 2.5];\n\tys := [0.5, 1];\n\tm := map[string]float{\"a\": 1};\n\tp := P{x: 1, ys: [2]};\n\ts := Shape.Circle(1);\n\tprint(half(3) + three() + g);\n}",
+		},
+		{
+			name:   "reports_int_variables_as_floats",
+			source: "fn half(x float) float {\n\treturn x / 2;\n}\n\nfn main() {\n\tn := 1;\n\tlet x float = n;\n\tlet xs []float = [n];\n\tprint(half(n));\n}",
+			err:    "7:16: cannot use int value as float in declaration of x (and 2 more errors)",
+		},
+		{
+			name:   "accepts_bare_returns_in_void_functions",
+			source: "fn f(xs []int) {\n\tfor x in xs {\n\t\treturn;\n\t}\n}\n\nfn main() {\n\tf([1]);\n\treturn;\n}",
+		},
+		{
+			name:   "reports_bare_returns_in_functions_with_results",
+			source: "fn main() int {\n\treturn;\n}",
+			err:    "2:2: not enough return values",
+		},
+		{
+			name:   "reports_missing_returns",
+			source: "fn main() int {\n\tx := 1;\n}",
+			err:    "3:1: missing return",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := check(t, tt.source)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestConfig_Check_globals(t *testing.T) {
+	program := parse(t, "let a = b * 2;\nconst b = 2 + 3;\nconst s = \"a\" + \"b\";\nvar n int;")
+	info, err := (&Config{}).Check(program)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Type{"a": Int, "b": Int, "s": String, "n": Int}, info.Globals)
+	assert.Equal(t, map[string]interface{}{"b": int64(5), "s": "ab"}, info.Consts)
+	var order []string
+	for _, vd := range info.InitOrder {
+		order = append(order, vd.Identifier.Value)
+	}
+	assert.Equal(t, []string{"b", "a", "s", "n"}, order)
+}
+
+func TestConfig_Check_floatConstants(t *testing.T) {
+	info, err := (&Config{}).Check(parse(t, "const g float = 3;\nconst h = g / 2;"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Type{"g": Float, "h": Float}, info.Globals)
+	assert.Equal(t, map[string]interface{}{"g": 3.0, "h": 1.5}, info.Consts)
+}
+
+func TestConfig_Check_imports(t *testing.T) {
+	lib, err := (&Config{}).Check(parse(t, "fn Upper(s string) string {\n\treturn s;\n}\n\nfn helper() {\n}"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*Signature{"Upper": {Params: []Type{String}, Result: String}}, lib.Exports)
+	config := &Config{Imports: map[string]*Info{"lib/strs": lib}}
+
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{
+			name:   "accepts_calls_of_exported_functions",
+			source: "import \"lib/strs\";\n\nfn main() string {\n\treturn strs.Upper(\"a\");\n}",
+		},
+		{
+			name:   "reports_unexported_functions",
+			source: "import \"lib/strs\";\n\nfn main() {\n\tstrs.helper();\n}",
+			err:    "4:7: cannot refer to unexported function strs.helper",
+		},
+		{
+			name:   "reports_undefined_functions",
+			source: "import \"lib/strs\";\n\nfn main() {\n\tstrs.Lower(\"a\");\n}",
+			err:    "4:7: undefined function strs.Lower",
+		},
+		{
+			name:   "reports_undefined_modules",
+			source: "fn main() {\n\tstrs.Upper(\"a\");\n}",
+			err:    "2:2: undefined: strs",
+		},
+		{
+			name:   "reports_mismatched_arguments",
+			source: "import \"lib/strs\";\n\nfn main() {\n\tstrs.Upper(1);\n}",
+			err:    "4:13: cannot use int value as string in argument to strs.Upper",
+		},
+		{
+			name:   "reports_missing_modules",
+			source: "import \"lib/ints\";\n\nfn main() {\n}",
+			err:    "1:8: could not import lib/ints",
+		},
+		{
+			name:   "reports_duplicate_imports",
+			source: "import \"lib/strs\";\nimport \"lib/strs\";\n\nfn main() {\n}",
+			err:    "2:8: strs redeclared in this block",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Check(parse(t, tt.source))
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestConfig_Check_sortsErrors(t *testing.T) {
+	err := check(t, "fn main() {\n\tx := y;\n}\n\nfn main() {\n}\n")
+	require.Error(t, err)
+	errs := err.(parser.ErrorList)
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "2:7: undefined: y")
+	assert.EqualError(t, errs[1], "5:4: function main redeclared")
+}
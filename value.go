@@ -0,0 +1,301 @@
+package emlang
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/muggel/emlang/object"
+	"github.com/muggel/emlang/types"
+)
+
+// Type is the name of an emlang type as written in source.
+type Type = types.Type
+
+const (
+	Int       = types.Int
+	Float     = types.Float
+	String    = types.String
+	ErrorType = types.ErrorType
+	Void      = types.Void
+)
+
+// TypeOf returns the type of obj. Slices and maps only know the types of
+// their elements from the elements, so the element type of an empty slice
+// and the key type of an empty map are left empty, like in "[]".
+func TypeOf(obj object.Object) Type {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return Int
+	case *object.Float:
+		return Float
+	case *object.String:
+		return String
+	case *object.Error, *object.Nil:
+		return ErrorType
+	case *object.Void:
+		return Void
+	case *object.Slice:
+		var elem Type
+		if len(o.Elements) > 0 {
+			elem = TypeOf(o.Elements[0])
+		}
+		return types.SliceOf(elem)
+	case *object.Map:
+		var key, value Type
+		if entries := o.Entries(); len(entries) > 0 {
+			key, value = TypeOf(entries[0].Key), TypeOf(entries[0].Value)
+		} else if o.Zero != nil {
+			value = TypeOf(o.Zero)
+		}
+		return types.MapOf(key, value)
+	case *object.Struct:
+		return Type(o.TypeName)
+	case *object.Enum:
+		return Type(o.TypeName)
+	case *object.Tuple:
+		elems := make([]Type, len(o.Elements))
+		for i, e := range o.Elements {
+			elems[i] = TypeOf(e)
+		}
+		return types.TupleOf(elems...)
+	}
+	return Type(obj.Type())
+}
+
+// ToObject converts a Go value to an emlang object. Integers of any size
+// become ints, floating-point numbers floats, strings strings and errors
+// error values, nil becomes the nil error. Slices, arrays and maps with
+// integer or string keys convert element by element, maps in the order of
+// their keys. Named structs become structs of the same name with their
+// exported fields, which are named like the Go fields unless an emlang tag
+// gives another name, e.g. `emlang:"x"`. Objects are returned as they are.
+func ToObject(v interface{}) (object.Object, error) {
+	switch v := v.(type) {
+	case object.Object:
+		return v, nil
+	case error:
+		return &object.Error{Message: v.Error()}, nil
+	case nil:
+		return object.NIL, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int", rv.Uint())
+		}
+		return &object.Integer{Value: int64(rv.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: rv.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: rv.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elems := make([]object.Object, rv.Len())
+		for i := range elems {
+			elem, err := ToObject(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return &object.Slice{Elements: elems}, nil
+	case reflect.Map:
+		if key, ok := typeOfGo(rv.Type().Key()); ok && (key == Int || key == String) {
+			return mapToObject(rv)
+		}
+	case reflect.Struct:
+		if rv.Type().Name() != "" {
+			return structToObject(rv)
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %T to an emlang value", v)
+}
+
+// mapToObject converts the map rv, whose keys are integers or strings.
+func mapToObject(rv reflect.Value) (object.Object, error) {
+	zero, err := ToObject(reflect.Zero(rv.Type().Elem()).Interface())
+	if err != nil {
+		return nil, err
+	}
+	res := object.NewMap(zero)
+
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Kind() == reflect.String {
+			return keys[i].String() < keys[j].String()
+		}
+		if keys[i].CanInt() {
+			return keys[i].Int() < keys[j].Int()
+		}
+		return keys[i].Uint() < keys[j].Uint()
+	})
+	for _, k := range keys {
+		key, err := ToObject(k.Interface())
+		if err != nil {
+			return nil, err
+		}
+		value, err := ToObject(rv.MapIndex(k).Interface())
+		if err != nil {
+			return nil, err
+		}
+		res.Set(key, value)
+	}
+	return res, nil
+}
+
+// structToObject converts the exported fields of the named struct rv.
+func structToObject(rv reflect.Value) (object.Object, error) {
+	t := rv.Type()
+	res := &object.Struct{TypeName: t.Name()}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		value, err := ToObject(rv.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		res.Fields = append(res.Fields, object.StructField{Name: fieldName(f), Value: value})
+	}
+	return res, nil
+}
+
+// fieldName returns the name of the emlang field for the Go field f.
+func fieldName(f reflect.StructField) string {
+	if name := f.Tag.Get("emlang"); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// FromObject converts an emlang object to a Go value: ints become int64,
+// floats float64, strings string, error values errors, void and the nil
+// error become nil and several results become a []interface{} of their
+// values. Slices and maps become Go slices and maps of the converted
+// elements, e.g. []int64 for []int, structs a map[string]interface{} of
+// their fields. Elements of structs or unknown types are interface{}.
+func FromObject(obj object.Object) interface{} {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return o.Value
+	case *object.Float:
+		return o.Value
+	case *object.String:
+		return o.Value
+	case *object.Error:
+		return errors.New(o.Message)
+	case *object.Void, *object.Nil:
+		return nil
+	case *object.Tuple:
+		res := make([]interface{}, len(o.Elements))
+		for i, e := range o.Elements {
+			res[i] = FromObject(e)
+		}
+		return res
+	case *object.Slice:
+		t := goType(TypeOf(o))
+		res := reflect.MakeSlice(t, len(o.Elements), len(o.Elements))
+		for i, e := range o.Elements {
+			v, ok := fromObject(e, t.Elem())
+			if !ok {
+				return fromObjects(o.Elements)
+			}
+			res.Index(i).Set(v)
+		}
+		return res.Interface()
+	case *object.Map:
+		t := goType(TypeOf(o))
+		res := reflect.MakeMapWithSize(t, o.Len())
+		for _, entry := range o.Entries() {
+			k, ok := fromObject(entry.Key, t.Key())
+			v, ok2 := fromObject(entry.Value, t.Elem())
+			if !ok || !ok2 {
+				return fromEntries(o.Entries())
+			}
+			res.SetMapIndex(k, v)
+		}
+		return res.Interface()
+	case *object.Struct:
+		res := make(map[string]interface{}, len(o.Fields))
+		for _, f := range o.Fields {
+			res[f.Name] = FromObject(f.Value)
+		}
+		return res
+	}
+	return obj
+}
+
+// fromObject converts obj with FromObject to a value of the Go type t and
+// reports whether the value has that type. It has not if the element type
+// of an empty slice or map inside obj was unknown.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, bool) {
+	switch o := obj.(type) {
+	case *object.Slice:
+		if len(o.Elements) == 0 && t.Kind() == reflect.Slice {
+			return reflect.MakeSlice(t, 0, 0), true
+		}
+	case *object.Map:
+		if o.Len() == 0 && t.Kind() == reflect.Map {
+			return reflect.MakeMap(t), true
+		}
+	}
+	v := FromObject(obj)
+	if v == nil {
+		return reflect.Zero(t), true
+	}
+	rv := reflect.ValueOf(v)
+	return rv, rv.Type().AssignableTo(t)
+}
+
+// fromObjects converts the elements of a slice whose element type is not
+// known to a []interface{}.
+func fromObjects(elems []object.Object) []interface{} {
+	res := make([]interface{}, len(elems))
+	for i, e := range elems {
+		res[i] = FromObject(e)
+	}
+	return res
+}
+
+// fromEntries converts the entries of a map whose key or value type is not
+// known to a map[interface{}]interface{}.
+func fromEntries(entries []object.MapEntry) map[interface{}]interface{} {
+	res := make(map[interface{}]interface{}, len(entries))
+	for _, entry := range entries {
+		res[FromObject(entry.Key)] = FromObject(entry.Value)
+	}
+	return res
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// goType returns the Go type that FromObject converts values of type t to,
+// interface{} for unknown types and structs.
+func goType(t Type) reflect.Type {
+	s := string(t)
+	switch {
+	case t == Int:
+		return reflect.TypeOf(int64(0))
+	case t == Float:
+		return reflect.TypeOf(float64(0))
+	case t == String:
+		return reflect.TypeOf("")
+	case t == ErrorType:
+		return errorType
+	case strings.HasPrefix(s, "[]"):
+		return reflect.SliceOf(goType(t[len("[]"):]))
+	case strings.HasPrefix(s, "map["):
+		// the key type of an empty map is empty
+		end := strings.IndexByte(s, ']')
+		return reflect.MapOf(goType(t[len("map["):end]), goType(t[end+1:]))
+	}
+	return interfaceType
+}